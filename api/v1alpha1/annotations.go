@@ -8,6 +8,11 @@ const (
 	// in-place upgrade failure, configuration change failure, etc.).
 	// The default value is false, which will directly delete all failed Sandboxes.
 	AnnotationReserveFailedSandbox = InternalPrefix + "reserve-failed-sandbox"
+	// AnnotationColdStartTimeoutSeconds creates a Sandbox from the template of the SandboxSet on demand when it has
+	// no available Sandbox to claim, and waits for it to be ready for the seconds at most. Disabled by default.
+	AnnotationColdStartTimeoutSeconds = InternalPrefix + "cold-start-timeout-seconds"
+	// AnnotationColdStart marks a Sandbox created on demand when claimed, rather than taken from the SandboxSet.
+	AnnotationColdStart = InternalPrefix + "cold-start"
 )
 
 // E2B annotations
@@ -20,6 +25,22 @@ const (
 	AnnotationShouldInitEnvd  = E2BPrefix + "should-init-envd"
 	AnnotationEnvdAccessToken = E2BPrefix + "envd-access-token"
 	AnnotationEnvdURL         = E2BPrefix + "envd-url"
+	// AnnotationInitedPod is the UID of the pod where the claim-time init of the Sandbox, such as the envd init, is done.
+	// A claimed Sandbox whose pod is recreated, e.g. by resuming or restarting, is not running until the init is
+	// replayed in the new pod.
+	AnnotationInitedPod = E2BPrefix + "inited-pod"
+	// AnnotationDefaultTimeoutSeconds is the timeout used when the Sandbox is claimed without specifying one.
+	AnnotationDefaultTimeoutSeconds = E2BPrefix + "default-timeout-seconds"
+	// AnnotationExposedPorts is a json list of the ports that the Sandbox exposes, see SandboxPort. It can also be set
+	// on a SandboxSet to override the ones declared by the SandboxTemplate.
+	AnnotationExposedPorts = E2BPrefix + "exposed-ports"
+	// AnnotationSecure requires the envd access token in the requests to the Sandbox through the proxy, except the
+	// ones to the exposed ports with Auth None, which supersede the former public-ports annotation.
+	AnnotationSecure = E2BPrefix + "secure"
+	// AnnotationIdleTimeoutSeconds pauses the Sandbox when it has not been requested through the proxy for the seconds.
+	AnnotationIdleTimeoutSeconds = E2BPrefix + "idle-timeout-seconds"
+	// AnnotationExtendTimeoutOnActivity extends the timeout of the Sandbox to the idle timeout after its last request.
+	AnnotationExtendTimeoutOnActivity = E2BPrefix + "extend-timeout-on-activity"
 )
 
 const True = "true"
@@ -45,18 +45,55 @@ type SandboxSpec struct {
 	Paused bool `json:"paused,omitempty"`
 
 	// PersistentContents indicates resume pod with persistent content, Enum: ip, memory, filesystem
+	// Each content is saved by its persistence backend when the sandbox is paused, and restored when it is resumed.
 	PersistentContents []string `json:"persistentContents,omitempty"`
 
-	// ShutdownTime - Absolute time when the sandbox is deleted.
-	// If a time in the past is provided, the sandbox will be deleted immediately.
+	// ShutdownTime - Absolute time when the sandbox is deleted, or paused if ShutdownPolicy is Pause.
+	// If a time in the past is provided, the sandbox will be shut down immediately.
 	// +kubebuilder:validation:Format="date-time"
 	ShutdownTime *metav1.Time `json:"shutdownTime,omitempty"`
 
-	SandboxTemplate `json:",inline"`
+	// ShutdownPolicy is what happens to the sandbox when ShutdownTime is reached, Delete or Pause.
+	// Defaults to Delete.
+	// +kubebuilder:validation:Enum=Delete;Pause
+	// +optional
+	ShutdownPolicy SandboxShutdownPolicy `json:"shutdownPolicy,omitempty"`
+
+	// PausedRetentionSeconds is the maximum time a paused sandbox is retained, counted from when it was paused.
+	// The sandbox is deleted once it expires. ShutdownTime is ignored while the sandbox is paused by ShutdownPolicy.
+	// If not set, paused sandboxes are retained until deleted.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PausedRetentionSeconds *int32 `json:"pausedRetentionSeconds,omitempty"`
+
+	// ResumeRevisionPolicy is which template revision the paused sandbox is resumed from, Paused or Latest.
+	// Paused resumes from the revision recorded when the sandbox was paused, the template changes made while paused
+	// are not applied until the template changes again. Latest resumes from the current template.
+	// Defaults to Paused.
+	// +kubebuilder:validation:Enum=Paused;Latest
+	// +optional
+	ResumeRevisionPolicy SandboxResumeRevisionPolicy `json:"resumeRevisionPolicy,omitempty"`
+
+	// RestartPolicy is whether the pod is recreated when it disappears or fails while the sandbox is running,
+	// Never or OnFailure. The recreated pod reattaches the PVCs of VolumeClaimTemplates.
+	// Defaults to Never, the sandbox fails once the pod is lost.
+	// +kubebuilder:validation:Enum=Never;OnFailure
+	// +optional
+	RestartPolicy SandboxRestartPolicy `json:"restartPolicy,omitempty"`
+
+	// MaxRestarts is the maximum times the pod is recreated by the OnFailure restart policy, the sandbox fails
+	// once the pod is lost after that. If not set, the pod is always recreated.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxRestarts *int32 `json:"maxRestarts,omitempty"`
+
+	EmbeddedSandboxTemplate `json:",inline"`
 }
 
-type SandboxTemplate struct {
-	// TemplateRef references a SandboxTemplate, which will be used to create the sandbox.
+// EmbeddedSandboxTemplate describes how the pods of sandboxes are created, it is embedded in Sandbox and SandboxSet.
+type EmbeddedSandboxTemplate struct {
+	// TemplateRef references a template in the same namespace, which will be used to create the sandbox.
+	// Changes of the referenced template are watched and rolled out like changes of Template.
 	// +optional
 	TemplateRef *SandboxTemplateRef `json:"templateRef,omitempty"`
 
@@ -72,7 +109,7 @@ type SandboxTemplate struct {
 	VolumeClaimTemplates []v1.PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
 }
 
-// SandboxTemmplateRef references a SandboxTemplate
+// SandboxTemplateRef references a PodTemplate or a SandboxTemplate
 type SandboxTemplateRef struct {
 	// name of the SandboxTemplate
 	// +kubebuilder:validation:Required
@@ -87,8 +124,43 @@ type SandboxTemplateRef struct {
 	// Default to v1
 	// +optional
 	APIVersion *string `json:"apiVersion,omitempty"`
+
+	// Revision pins the name of a SandboxTemplate revision, only valid when kind is SandboxTemplate.
+	// If empty, the current revision of the SandboxTemplate is followed.
+	// +optional
+	Revision string `json:"revision,omitempty"`
 }
 
+// SandboxShutdownPolicy describes what happens to the sandbox when ShutdownTime is reached.
+type SandboxShutdownPolicy string
+
+const (
+	// SandboxShutdownDelete deletes the sandbox.
+	SandboxShutdownDelete SandboxShutdownPolicy = "Delete"
+	// SandboxShutdownPause pauses the sandbox, which is deleted after PausedRetentionSeconds.
+	SandboxShutdownPause SandboxShutdownPolicy = "Pause"
+)
+
+// SandboxRestartPolicy describes whether the pod is recreated when it is lost while the sandbox is running.
+type SandboxRestartPolicy string
+
+const (
+	// SandboxRestartNever fails the sandbox once the pod is lost.
+	SandboxRestartNever SandboxRestartPolicy = "Never"
+	// SandboxRestartOnFailure recreates the pod once it disappears or fails, up to MaxRestarts times.
+	SandboxRestartOnFailure SandboxRestartPolicy = "OnFailure"
+)
+
+// SandboxResumeRevisionPolicy describes which template revision the paused sandbox is resumed from.
+type SandboxResumeRevisionPolicy string
+
+const (
+	// SandboxResumeRevisionPaused resumes from the template revision recorded when the sandbox was paused.
+	SandboxResumeRevisionPaused SandboxResumeRevisionPolicy = "Paused"
+	// SandboxResumeRevisionLatest resumes from the current template.
+	SandboxResumeRevisionLatest SandboxResumeRevisionPolicy = "Latest"
+)
+
 const (
 	PersistentContentIp         string = "ip"
 	PersistentContentMemory     string = "memory"
@@ -134,9 +206,23 @@ type SandboxStatus struct {
 	// +optional
 	SandboxIp string `json:"sandboxIp,omitempty"`
 
-	// UpdateRevision is the template-hash calculated from `spec.template`.
+	// UpdateRevision is the template-hash calculated from `spec.template`, or from the template
+	// referenced by `spec.templateRef`.
 	// +optional
 	UpdateRevision string `json:"updateRevision,omitempty"`
+
+	// PausedRevision is the template revision recorded when the sandbox was last paused, or its failed pod was
+	// restarted. The template of the revision is kept in a ControllerRevision owned by the sandbox.
+	// +optional
+	PausedRevision string `json:"pausedRevision,omitempty"`
+
+	// RestartCount is the number of times the pod has been recreated by the restart policy.
+	// +optional
+	RestartCount int32 `json:"restartCount,omitempty"`
+
+	// LastRestartTime is the time when the pod was last recreated by the restart policy.
+	// +optional
+	LastRestartTime *metav1.Time `json:"lastRestartTime,omitempty"`
 }
 
 // SandboxPhase is a label for the condition of a pod at the current time.
@@ -197,6 +283,9 @@ const (
 
 	// SandboxConditionInplaceUpdate means inplace update state.
 	SandboxConditionInplaceUpdate SandboxConditionType = "InplaceUpdate"
+
+	// SandboxConditionTemplateResolved means whether spec.templateRef has been resolved into a pod template.
+	SandboxConditionTemplateResolved SandboxConditionType = "TemplateResolved"
 )
 
 const (
@@ -204,6 +293,7 @@ const (
 	SandboxReadyReasonPodReady             = "PodReady"
 	SandboxReadyReasonInplaceUpdating      = "InplaceUpdating"
 	SandboxReadyReasonStartContainerFailed = "StartContainerFailed"
+	SandboxReadyReasonRestarting           = "Restarting"
 
 	// SandboxConditionInplaceUpdate Reason
 	SandboxInplaceUpdateReasonInplaceUpdating = "InplaceUpdating"
@@ -211,12 +301,22 @@ const (
 	SandboxInplaceUpdateReasonFailed          = "Failed"
 
 	// SandboxConditionPaused Reason
-	SandboxPausedReasonSetPause  = "SetPause"
-	SandboxPausedReasonDeletePod = "DeletePod"
+	SandboxPausedReasonSetPause           = "SetPause"
+	SandboxPausedReasonDeletePod          = "DeletePod"
+	SandboxPausedReasonPersistingContents = "PersistingContents"
+	SandboxPausedReasonPersistFailed      = "PersistFailed"
 
 	// SandboxConditionResume Reason
-	SandboxResumeReasonCreatePod = "CreatePod"
-	SandboxResumeReasonResumePod = "ResumePod"
+	SandboxResumeReasonCreatePod         = "CreatePod"
+	SandboxResumeReasonResumePod         = "ResumePod"
+	SandboxResumeReasonRestoringContents = "RestoringContents"
+	SandboxResumeReasonRestoreFailed     = "RestoreFailed"
+
+	// SandboxConditionTemplateResolved Reason
+	SandboxTemplateReasonResolved         = "Resolved"
+	SandboxTemplateReasonNotFound         = "TemplateNotFound"
+	SandboxTemplateReasonUnsupportedKind  = "UnsupportedKind"
+	SandboxTemplateReasonRevisionNotReady = "RevisionNotReady"
 )
 
 // +genclient
@@ -225,6 +325,7 @@ const (
 // +kubebuilder:resource:path=sandboxes,shortName={sbx},singular=sandbox
 // +kubebuilder:storageversion
 // +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Restarts",type="integer",JSONPath=".status.restartCount"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="shutdown_time",type="string",JSONPath=".spec.shutdownTime"
 // +kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.message"
@@ -0,0 +1,181 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// LabelSandboxClaim identifies which SandboxClaim the sandbox is bound to
+	LabelSandboxClaim = InternalPrefix + "sandbox-claim"
+)
+
+var SandboxClaimControllerKind = GroupVersion.WithKind("SandboxClaim")
+
+// SandboxClaimReclaimPolicy describes what happens to the bound sandbox when the claim is deleted.
+type SandboxClaimReclaimPolicy string
+
+const (
+	// SandboxClaimReclaimDelete deletes the bound sandbox with the claim.
+	SandboxClaimReclaimDelete SandboxClaimReclaimPolicy = "Delete"
+	// SandboxClaimReclaimRetain releases the bound sandbox, which keeps running until its shutdownTime.
+	SandboxClaimReclaimRetain SandboxClaimReclaimPolicy = "Retain"
+)
+
+// SandboxClaimPhase is the phase of a SandboxClaim.
+type SandboxClaimPhase string
+
+const (
+	// SandboxClaimPending means no sandbox has been bound to the claim yet.
+	SandboxClaimPending SandboxClaimPhase = "Pending"
+	// SandboxClaimBound means a sandbox has been claimed from the pool and bound to the claim.
+	SandboxClaimBound SandboxClaimPhase = "Bound"
+	// SandboxClaimLost means the bound sandbox has been deleted or is dead.
+	SandboxClaimLost SandboxClaimPhase = "Lost"
+)
+
+const (
+	// SandboxClaimConditionReady means whether the bound sandbox is running and ready to be accessed.
+	SandboxClaimConditionReady = "Ready"
+
+	SandboxClaimReasonNoAvailableSandbox = "NoAvailableSandbox"
+	SandboxClaimReasonInvalidSpec        = "InvalidSpec"
+	SandboxClaimReasonSandboxNotReady    = "SandboxNotReady"
+	SandboxClaimReasonSandboxReady       = "SandboxReady"
+	SandboxClaimReasonSandboxLost        = "SandboxLost"
+	SandboxClaimReasonEnvdInitFailed     = "EnvdInitFailed"
+)
+
+// SandboxClaimSpec defines the desired state of SandboxClaim
+type SandboxClaimSpec struct {
+	// SandboxSetName is the name of the SandboxSet in the same namespace to claim the sandbox from.
+	// +required
+	SandboxSetName string `json:"sandboxSetName"`
+
+	// Owner is the user recorded as the owner of the claimed sandbox. Defaults to the name of the claim.
+	// Only the trusted service accounts can claim on behalf of the other users, the others can only set it to
+	// their own username.
+	// +optional
+	Owner string `json:"owner,omitempty"`
+
+	// TimeoutSeconds is the lifetime of the claimed sandbox, which is shut down once it expires.
+	// Defaults to the default timeout of the SandboxTemplate of the sandbox if any, or the sandbox never expires.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// Image overrides the image of the first container of the claimed sandbox, which is updated in place.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Labels are added to the claimed sandbox.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to the claimed sandbox.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// ReclaimPolicy is what happens to the bound sandbox when the claim is deleted, Delete or Retain.
+	// Defaults to Delete.
+	// +kubebuilder:validation:Enum=Delete;Retain
+	// +optional
+	ReclaimPolicy SandboxClaimReclaimPolicy `json:"reclaimPolicy,omitempty"`
+}
+
+// SandboxClaimStatus defines the observed state of SandboxClaim.
+type SandboxClaimStatus struct {
+	// observedGeneration is the most recent generation observed for this SandboxClaim.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Phase is Pending, Bound or Lost.
+	// +optional
+	Phase SandboxClaimPhase `json:"phase,omitempty"`
+
+	// SandboxName is the name of the bound sandbox.
+	// +optional
+	SandboxName string `json:"sandboxName,omitempty"`
+
+	// SandboxID is the id used to access the bound sandbox through the sandbox-manager.
+	// +optional
+	SandboxID string `json:"sandboxID,omitempty"`
+
+	// SandboxIP is the ip of the bound sandbox.
+	// +optional
+	SandboxIP string `json:"sandboxIP,omitempty"`
+
+	// Endpoint is the URL of envd in the bound sandbox, which is set once envd is initialized if required.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// AccessTokenSecretRef selects the envd access token of the bound sandbox in a Secret owned by the claim.
+	// +optional
+	AccessTokenSecretRef *corev1.SecretKeySelector `json:"accessTokenSecretRef,omitempty"`
+
+	// ClaimTime is the time when the sandbox was bound.
+	// +optional
+	ClaimTime *metav1.Time `json:"claimTime,omitempty"`
+
+	// conditions represent the current state of the SandboxClaim resource.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=sandboxclaims,shortName={sbc},singular=sandboxclaim
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="SandboxSet",type="string",JSONPath=".spec.sandboxSetName"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Sandbox",type="string",JSONPath=".status.sandboxName"
+// +kubebuilder:printcolumn:name="IP",type="string",JSONPath=".status.sandboxIP"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// SandboxClaim is the Schema for the sandboxclaims API, which claims a sandbox from a SandboxSet.
+type SandboxClaim struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of SandboxClaim
+	// +required
+	Spec SandboxClaimSpec `json:"spec"`
+
+	// status defines the observed state of SandboxClaim
+	// +optional
+	Status SandboxClaimStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// SandboxClaimList contains a list of SandboxClaim
+type SandboxClaimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SandboxClaim `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SandboxClaim{}, &SandboxClaimList{})
+}
@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -42,17 +43,97 @@ const (
 	SandboxStateDead      = "dead"
 )
 
+const (
+	// SandboxSetConditionTemplateResolved means whether spec.templateRef has been resolved into a pod template,
+	// the reasons are the same as SandboxConditionTemplateResolved.
+	SandboxSetConditionTemplateResolved = "TemplateResolved"
+)
+
+const (
+	// DefaultSandboxSetHighWaterMark is the default percentage of used sandboxes above which the pool is scaled up.
+	DefaultSandboxSetHighWaterMark int32 = 80
+	// DefaultSandboxSetLowWaterMark is the default percentage of used sandboxes below which the pool is scaled down.
+	DefaultSandboxSetLowWaterMark int32 = 40
+)
+
 var SandboxSetControllerKind = GroupVersion.WithKind("SandboxSet")
 
 // SandboxSetSpec defines the desired state of SandboxSet
 type SandboxSetSpec struct {
 	// Replicas is the number of unused sandboxes, including available and creating ones.
+	// It is ignored when autoScaling is set.
 	Replicas int32 `json:"replicas"`
 
+	// AutoScaling sizes the unused sandboxes from the number of used ones instead of the fixed replicas.
+	// +optional
+	AutoScaling *SandboxSetAutoScaling `json:"autoScaling,omitempty"`
+
+	// UpdateStrategy indicates how the unused sandboxes of old revisions are updated when the template changes.
+	// Claimed sandboxes are never updated by the SandboxSet.
+	// +optional
+	UpdateStrategy SandboxSetUpdateStrategy `json:"updateStrategy,omitempty"`
+
 	// PersistentContents indicates resume pod with persistent content, Enum: ip, memory, filesystem
 	PersistentContents []string `json:"persistentContents,omitempty"`
 
-	SandboxTemplate `json:",inline"`
+	EmbeddedSandboxTemplate `json:",inline"`
+}
+
+// SandboxSetUpdateStrategyType is the way to update unused sandboxes.
+type SandboxSetUpdateStrategyType string
+
+const (
+	// RecreateSandboxSetUpdateStrategyType deletes the sandboxes of old revisions, which are replaced by new ones.
+	RecreateSandboxSetUpdateStrategyType SandboxSetUpdateStrategyType = "ReCreate"
+	// InPlaceSandboxSetUpdateStrategyType updates the images and resources of the sandboxes in place, and falls
+	// back to ReCreate if any other field of the template changes.
+	InPlaceSandboxSetUpdateStrategyType SandboxSetUpdateStrategyType = "InPlace"
+)
+
+// SandboxSetUpdateStrategy defines the strategy to update unused sandboxes.
+type SandboxSetUpdateStrategy struct {
+	// Type of the update strategy, ReCreate or InPlace. Defaults to ReCreate.
+	// +kubebuilder:validation:Enum=ReCreate;InPlace
+	// +optional
+	Type SandboxSetUpdateStrategyType `json:"type,omitempty"`
+
+	// Partition is the number or percentage of unused sandboxes which are kept at old revisions.
+	// Defaults to 0, which means all the unused sandboxes are updated.
+	// +optional
+	Partition *intstr.IntOrString `json:"partition,omitempty"`
+
+	// MaxUnavailable is the maximum number or percentage of unused sandboxes which can be unavailable during the
+	// update, sandboxes being created are also counted. Defaults to 20%, and at least 1 sandbox is updated at a time.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// SandboxSetAutoScaling keeps the percentage of used (claimed) sandboxes in the total pool between lowWaterMark and
+// highWaterMark. When it goes beyond the marks, the unused sandboxes are resized so that the percentage goes back to
+// the middle of the marks, bounded by minReplicas and maxReplicas.
+type SandboxSetAutoScaling struct {
+	// MinReplicas is the lower bound of the unused sandboxes. Zero keeps one unused sandbox, since an empty pool has
+	// no usage to scale up from, unless the sandboxes are created on demand with the cold start annotation.
+	// +kubebuilder:validation:Minimum=0
+	MinReplicas int32 `json:"minReplicas"`
+
+	// MaxReplicas is the upper bound of the unused sandboxes.
+	// +kubebuilder:validation:Minimum=0
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// HighWaterMark is the percentage of used sandboxes in the total pool above which the pool is scaled up.
+	// Defaults to 80.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	HighWaterMark *int32 `json:"highWaterMark,omitempty"`
+
+	// LowWaterMark is the percentage of used sandboxes in the total pool below which the pool is scaled down.
+	// Defaults to 40.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	LowWaterMark *int32 `json:"lowWaterMark,omitempty"`
 }
 
 // SandboxSetStatus defines the observed state of SandboxSet.
@@ -67,7 +148,30 @@ type SandboxSetStatus struct {
 	// AvailableReplicas is the number of available sandboxes, which are ready to be claimed.
 	AvailableReplicas int32 `json:"availableReplicas"`
 
-	// UpdateRevision is the template-hash calculated from `spec.template`.
+	// UpdatedReplicas is the number of unused sandboxes of the update revision.
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+
+	// UpdatedAvailableReplicas is the number of available sandboxes of the update revision.
+	// +optional
+	UpdatedAvailableReplicas int32 `json:"updatedAvailableReplicas,omitempty"`
+
+	// UsedReplicas is the number of running and paused sandboxes, which have been claimed.
+	// +optional
+	UsedReplicas int32 `json:"usedReplicas,omitempty"`
+
+	// DesiredReplicas is the number of unused sandboxes the controller is scaling to, which is spec.replicas, or
+	// the result of the last scale decision when spec.autoScaling is set.
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty"`
+
+	// LastScaleTime is the last time the unused sandboxes were resized by spec.autoScaling.
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+
+	// UpdateRevision is the template-hash calculated from `spec.template`, `spec.volumeClaimTemplates`,
+	// `spec.persistentContents` and the E2B annotations, or from the template referenced by `spec.templateRef`
+	// together with its E2B defaults.
 	UpdateRevision string `json:"updateRevision,omitempty"`
 
 	// conditions represent the current state of the SandboxSet resource.
@@ -93,6 +197,8 @@ type SandboxSetStatus struct {
 // +kubebuilder:storageversion
 // +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.replicas"
 // +kubebuilder:printcolumn:name="Available",type="integer",JSONPath=".status.availableReplicas"
+// +kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".status.desiredReplicas"
+// +kubebuilder:printcolumn:name="Updated",type="integer",JSONPath=".status.updatedReplicas"
 // +kubebuilder:printcolumn:name="UpdateRevision",type="string",JSONPath=".status.updateRevision"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
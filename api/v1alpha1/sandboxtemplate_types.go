@@ -0,0 +1,201 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// LabelSandboxTemplate identifies which SandboxTemplate the revision belongs to
+	LabelSandboxTemplate = InternalPrefix + "sandbox-template"
+
+	// DefaultSandboxTemplateRevisionHistoryLimit is the default number of unused revisions kept for a SandboxTemplate
+	DefaultSandboxTemplateRevisionHistoryLimit int32 = 10
+)
+
+var SandboxTemplateControllerKind = GroupVersion.WithKind("SandboxTemplate")
+
+// SandboxTemplateSpec defines the desired state of SandboxTemplate
+type SandboxTemplateSpec struct {
+	// Template describes the pods that will be created.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +required
+	Template *v1.PodTemplateSpec `json:"template"`
+
+	// VolumeClaimTemplates is a list of PVC templates to create for the sandboxes.
+	// It is used only if the referencing Sandbox or SandboxSet has no volumeClaimTemplates of its own.
+	// +optional
+	VolumeClaimTemplates []v1.PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
+
+	// PersistentContents indicates resume pod with persistent content, Enum: ip, memory, filesystem
+	// It is used only if the referencing Sandbox or SandboxSet has no persistentContents of its own.
+	// +optional
+	PersistentContents []string `json:"persistentContents,omitempty"`
+
+	// E2B holds the defaults used by the E2B API for the sandboxes created from this template.
+	// +optional
+	E2B *SandboxTemplateE2B `json:"e2b,omitempty"`
+
+	// RevisionHistoryLimit is the maximum number of revisions that will be maintained besides the current one and the
+	// pinned ones. Default to 10.
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+}
+
+// SandboxTemplateE2B defines the E2B-facing defaults of a SandboxTemplate
+type SandboxTemplateE2B struct {
+	// TimeoutSeconds is the timeout used when a sandbox is claimed without specifying one.
+	// +kubebuilder:validation:Minimum=30
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// InitEnvd indicates whether envd should be initialized when a sandbox is claimed.
+	// +optional
+	InitEnvd bool `json:"initEnvd,omitempty"`
+
+	// ExposedPorts declares the ports of the sandbox that can be accessed through the sandbox-manager proxy, requests
+	// to the other ports are rejected. All the ports are accessible if not declared. The ports named envd and cdp are
+	// used by the E2B API as the envd and CDP ports, and envd is declared with DefaultEnvdPort if missing.
+	// +listType=map
+	// +listMapKey=port
+	// +optional
+	ExposedPorts []SandboxPort `json:"exposedPorts,omitempty"`
+
+	// IdleTimeoutSeconds pauses a claimed sandbox which has not been requested through the sandbox-manager proxy
+	// for the duration.
+	// +kubebuilder:validation:Minimum=60
+	// +optional
+	IdleTimeoutSeconds *int32 `json:"idleTimeoutSeconds,omitempty"`
+
+	// ExtendTimeoutOnActivity keeps a claimed sandbox from being shut down within IdleTimeoutSeconds after its last
+	// request, by extending its timeout.
+	// +optional
+	ExtendTimeoutOnActivity bool `json:"extendTimeoutOnActivity,omitempty"`
+}
+
+// SandboxPort describes a port exposed by the sandbox
+type SandboxPort struct {
+	// Name of the port
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Port number
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// Protocol is a hint of the application protocol served on the port. Default to HTTP.
+	// +kubebuilder:validation:Enum=HTTP;WebSocket;GRPC
+	// +optional
+	Protocol SandboxPortProtocol `json:"protocol,omitempty"`
+
+	// Auth is the credential required by the requests to the port through the sandbox-manager proxy. AccessToken
+	// only takes effect for the sandboxes claimed in secure mode. Default to AccessToken.
+	// It supersedes the former publicPorts of SandboxTemplateE2B, a port is public by declaring it with Auth None.
+	// +kubebuilder:validation:Enum=AccessToken;None
+	// +optional
+	Auth SandboxPortAuth `json:"auth,omitempty"`
+}
+
+type SandboxPortProtocol string
+
+const (
+	SandboxPortProtocolHTTP      SandboxPortProtocol = "HTTP"
+	SandboxPortProtocolWebSocket SandboxPortProtocol = "WebSocket"
+	SandboxPortProtocolGRPC      SandboxPortProtocol = "GRPC"
+)
+
+type SandboxPortAuth string
+
+const (
+	// SandboxPortAuthAccessToken requires the envd access token of the secure sandbox
+	SandboxPortAuthAccessToken SandboxPortAuth = "AccessToken"
+	// SandboxPortAuthNone makes the port public even if the sandbox is secure, e.g. the CDP port
+	SandboxPortAuthNone SandboxPortAuth = "None"
+)
+
+// Well-known ports used by the E2B API
+const (
+	SandboxPortNameEnvd = "envd"
+	DefaultEnvdPort     = 49983
+	SandboxPortNameCDP  = "cdp"
+	DefaultCDPPort      = 9222
+)
+
+// SandboxTemplateStatus defines the observed state of SandboxTemplate.
+type SandboxTemplateStatus struct {
+	// observedGeneration is the most recent generation observed for this SandboxTemplate. It corresponds to the
+	// SandboxTemplate's generation, which is updated on mutation by the API Server.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// CurrentRevision is the name of the revision (ControllerRevision) recording the current spec.
+	// +optional
+	CurrentRevision string `json:"currentRevision,omitempty"`
+
+	// CollisionCount is the count of hash collisions for the revisions of the SandboxTemplate. It is used as
+	// a collision avoidance mechanism when the name of the newest revision is computed.
+	// +optional
+	CollisionCount *int32 `json:"collisionCount,omitempty"`
+
+	// conditions represent the current state of the SandboxTemplate resource.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=sandboxtemplates,shortName={sbt},singular=sandboxtemplate
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="CurrentRevision",type="string",JSONPath=".status.currentRevision"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// SandboxTemplate is the Schema for the sandboxtemplates API, each change of its spec is recorded as an
+// immutable revision which can be followed or pinned by Sandboxes and SandboxSets.
+type SandboxTemplate struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of SandboxTemplate
+	// +required
+	Spec SandboxTemplateSpec `json:"spec"`
+
+	// status defines the observed state of SandboxTemplate
+	// +optional
+	Status SandboxTemplateStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// SandboxTemplateList contains a list of SandboxTemplate
+type SandboxTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SandboxTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SandboxTemplate{}, &SandboxTemplateList{})
+}
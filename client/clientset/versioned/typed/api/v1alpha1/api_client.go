@@ -28,7 +28,9 @@ import (
 type ApiV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	SandboxesGetter
+	SandboxClaimsGetter
 	SandboxSetsGetter
+	SandboxTemplatesGetter
 }
 
 // ApiV1alpha1Client is used to interact with features provided by the api group.
@@ -40,10 +42,18 @@ func (c *ApiV1alpha1Client) Sandboxes(namespace string) SandboxInterface {
 	return newSandboxes(c, namespace)
 }
 
+func (c *ApiV1alpha1Client) SandboxClaims(namespace string) SandboxClaimInterface {
+	return newSandboxClaims(c, namespace)
+}
+
 func (c *ApiV1alpha1Client) SandboxSets(namespace string) SandboxSetInterface {
 	return newSandboxSets(c, namespace)
 }
 
+func (c *ApiV1alpha1Client) SandboxTemplates(namespace string) SandboxTemplateInterface {
+	return newSandboxTemplates(c, namespace)
+}
+
 // NewForConfig creates a new ApiV1alpha1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
@@ -7,6 +7,8 @@ import (
 	_ "net/http/pprof" // Added to register pprof handlers
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/spf13/pflag"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/openkruise/agents/pkg/sandbox-manager/clients"
 	"github.com/openkruise/agents/pkg/servers/e2b"
+	"github.com/openkruise/agents/pkg/servers/e2b/adapters"
 	"github.com/openkruise/agents/pkg/servers/e2b/models"
 	utilfeature "github.com/openkruise/agents/pkg/utils/feature"
 )
@@ -84,6 +87,57 @@ func main() {
 	if peerSelector == "" {
 		klog.Fatalf("env var PEER_SELECTOR is required")
 	}
+
+	// Requests to paused sandboxes through the proxy resume them if set, e.g. "60s"
+	var wakeOnRequestTimeout time.Duration
+	if value := os.Getenv("WAKE_ON_REQUEST_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			klog.Fatalf("WAKE_ON_REQUEST_TIMEOUT must be a positive duration")
+		}
+		wakeOnRequestTimeout = timeout
+	}
+
+	// Routes of sandboxes are pushed to Envoy through xDS on the ext_proc port if set
+	enableXDS := os.Getenv("ENABLE_XDS") == "true"
+
+	// Requests to sandboxes are served on the port without Envoy if set
+	var reverseProxyPort int
+	if value := os.Getenv("REVERSE_PROXY_PORT"); value != "" {
+		p, err := strconv.Atoi(value)
+		if err != nil || p <= 0 || p > 65535 {
+			klog.Fatalf("REVERSE_PROXY_PORT must be a valid port")
+		}
+		reverseProxyPort = p
+	}
+	// Requests are routed by the rules in the file or the ConfigMap in SYSTEM_NAMESPACE before the E2B schemes if set
+	proxyRulesFile := os.Getenv("PROXY_RULES_FILE")
+	proxyRulesConfigMap := os.Getenv("PROXY_RULES_CONFIGMAP")
+	if proxyRulesFile != "" && proxyRulesConfigMap != "" {
+		klog.Fatalf("only one of PROXY_RULES_FILE and PROXY_RULES_CONFIGMAP can be set")
+	}
+	// Claims wait for available sandboxes for the duration at most if set, e.g. "10s"
+	var claimMaxWait time.Duration
+	if value := os.Getenv("CLAIM_MAX_WAIT"); value != "" {
+		wait, err := time.ParseDuration(value)
+		if err != nil || wait <= 0 {
+			klog.Fatalf("CLAIM_MAX_WAIT must be a positive duration")
+		}
+		claimMaxWait = wait
+	}
+	// Waiting claims are shared between the API keys by weights if set, e.g. "<key-id>=3,<key-id>=1"
+	var claimWeights map[string]int
+	if value := os.Getenv("CLAIM_WEIGHTS"); value != "" {
+		claimWeights = map[string]int{}
+		for _, pair := range strings.Split(value, ",") {
+			id, raw, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			weight, err := strconv.Atoi(raw)
+			if id == "" || err != nil || weight <= 0 {
+				klog.Fatalf("CLAIM_WEIGHTS must be a list of <key-id>=<positive weight>")
+			}
+			claimWeights[id] = weight
+		}
+	}
 	// =========== End Env =============
 
 	// Initialize Kubernetes client and config
@@ -93,6 +147,27 @@ func main() {
 	}
 
 	sandboxController := e2b.NewController(domain, e2bAdminKey, sysNs, e2bMaxTimeout, port, e2bEnableAuth, clientSet)
+	if wakeOnRequestTimeout > 0 {
+		sandboxController.EnableWakeOnRequest(wakeOnRequestTimeout)
+	}
+	if enableXDS {
+		sandboxController.EnableXDS()
+	}
+	if reverseProxyPort > 0 {
+		sandboxController.EnableReverseProxy(reverseProxyPort)
+	}
+	if proxyRulesFile != "" {
+		sandboxController.EnableRequestRules(adapters.FileRuleSource{Path: proxyRulesFile})
+	} else if proxyRulesConfigMap != "" {
+		sandboxController.EnableRequestRules(adapters.ConfigMapRuleSource{
+			Client:    clientSet.K8sClient,
+			Namespace: sysNs,
+			Name:      proxyRulesConfigMap,
+		})
+	}
+	if claimMaxWait > 0 || len(claimWeights) > 0 {
+		sandboxController.EnableClaimQueue(claimMaxWait, claimWeights)
+	}
 	if err := sandboxController.Init(infra); err != nil {
 		klog.Fatalf("Failed to initialize sandbox controller: %v", err)
 	}
@@ -18,10 +18,12 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
@@ -36,10 +38,21 @@ import (
 
 const CommonControlName = "common"
 
+// PodAnnotationRestartCount records the restart count of the sandbox on the recreated pod, so that the count is not lost
+// if the status fails to be updated after the pod is created.
+const PodAnnotationRestartCount = agentsv1alpha1.InternalPrefix + "restart-count"
+
+const (
+	EventPersistFailed    = "PersistFailed"
+	EventResumeFailed     = "ResumeFailed"
+	EventSandboxRestarted = "SandboxRestarted"
+)
+
 type commonControl struct {
 	client.Client
 	recorder             record.EventRecorder
 	inplaceUpdateControl *inplaceupdate.InPlaceUpdateControl
+	persistenceBackends  map[string]PersistenceBackend
 }
 
 func NewCommonControl(c client.Client, recorder record.EventRecorder) SandboxControl {
@@ -47,6 +60,7 @@ func NewCommonControl(c client.Client, recorder record.EventRecorder) SandboxCon
 		Client:               c,
 		recorder:             recorder,
 		inplaceUpdateControl: inplaceupdate.NewInPlaceUpdateControl(c, inplaceupdate.DefaultGeneratePatchBodyFunc),
+		persistenceBackends:  newPersistenceBackends(c),
 	}
 	return control
 }
@@ -86,8 +100,13 @@ func (r *commonControl) EnsureSandboxRunning(ctx context.Context, args EnsureFun
 }
 
 func (r *commonControl) EnsureSandboxUpdated(ctx context.Context, args EnsureFuncArgs) error {
-	pod, _, newStatus := args.Pod, args.Box, args.NewStatus
+	pod, box, newStatus := args.Pod, args.Box, args.NewStatus
 	logger := logf.FromContext(ctx).WithValues("pod", klog.KObj(pod))
+	syncRestartCount(pod, newStatus)
+	// The lost pod is recreated if the restart policy permits.
+	if (pod == nil || pod.Status.Phase == corev1.PodFailed) && CanRestart(box, newStatus) {
+		return r.restartPod(ctx, args)
+	}
 	// If a Pod is no longer present in the Running state, it should be considered an abnormal situation.
 	if pod == nil {
 		newStatus.Phase = agentsv1alpha1.SandboxFailed
@@ -118,6 +137,12 @@ func (r *commonControl) EnsureSandboxUpdated(ctx context.Context, args EnsureFun
 		cond.Status = metav1.ConditionStatus(pCond.Status)
 		cond.LastTransitionTime = pCond.LastTransitionTime
 	}
+	// the recreated or in-place updated pod is ready
+	if cond.Status == metav1.ConditionTrue && (cond.Reason == agentsv1alpha1.SandboxReadyReasonRestarting ||
+		cond.Reason == agentsv1alpha1.SandboxReadyReasonInplaceUpdating) {
+		cond.Reason = agentsv1alpha1.SandboxReadyReasonPodReady
+		cond.Message = ""
+	}
 	for _, cStatus := range pod.Status.ContainerStatuses {
 		// indicating container startup failure
 		if cond.Status == metav1.ConditionFalse && cStatus.State.Waiting != nil {
@@ -134,13 +159,17 @@ func (r *commonControl) EnsureSandboxPaused(ctx context.Context, args EnsureFunc
 	logger := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(box), "pod", klog.KObj(pod))
 	cond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionPaused))
 	if cond == nil {
-		cond = &metav1.Condition{
+		reason := agentsv1alpha1.SandboxPausedReasonDeletePod
+		if len(box.Spec.PersistentContents) > 0 {
+			reason = agentsv1alpha1.SandboxPausedReasonPersistingContents
+		}
+		utils.SetSandboxCondition(newStatus, metav1.Condition{
 			Type:               string(agentsv1alpha1.SandboxConditionPaused),
 			Status:             metav1.ConditionFalse,
-			Reason:             agentsv1alpha1.SandboxPausedReasonDeletePod,
+			Reason:             reason,
 			LastTransitionTime: metav1.Now(),
-		}
-		utils.SetSandboxCondition(newStatus, *cond)
+		})
+		cond = utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionPaused))
 	} else if cond.Status == metav1.ConditionTrue {
 		return nil
 	}
@@ -165,6 +194,29 @@ func (r *commonControl) EnsureSandboxPaused(ctx context.Context, args EnsureFunc
 		logger.Info("Sandbox wait pod paused")
 		return nil
 	}
+	// The template revision is recorded to resume from.
+	if err := r.savePausedRevision(ctx, box, pod, newStatus); err != nil {
+		return err
+	}
+	// The persistent contents must be saved before the pod is deleted.
+	if cond.Reason != agentsv1alpha1.SandboxPausedReasonDeletePod {
+		if err := r.persistContents(ctx, box, pod); err != nil {
+			if !errors.Is(err, ErrPersistenceUnsupported) {
+				logger.Error(err, "Persist contents failed")
+				return err
+			}
+			logger.Info("Persistent contents unsupported, keep the pod", "reason", err.Error())
+			if cond.Reason != agentsv1alpha1.SandboxPausedReasonPersistFailed || cond.Message != err.Error() {
+				cond.Reason = agentsv1alpha1.SandboxPausedReasonPersistFailed
+				cond.Message = err.Error()
+				r.recorder.Eventf(box, corev1.EventTypeWarning, EventPersistFailed, "Failed to pause sandbox: %s", err)
+			}
+			return nil
+		}
+		cond.Reason = agentsv1alpha1.SandboxPausedReasonDeletePod
+		cond.Message = ""
+		logger.Info("Persist contents success", "contents", box.Spec.PersistentContents)
+	}
 	err := client.IgnoreNotFound(r.Delete(ctx, pod, &client.DeleteOptions{GracePeriodSeconds: ptr.To(int64(30))}))
 	if err != nil {
 		logger.Error(err, "Delete pod failed")
@@ -183,15 +235,35 @@ func (r *commonControl) EnsureSandboxResumed(ctx context.Context, args EnsureFun
 		return fmt.Errorf("the pods created in the previous stage are still in the terminating state.")
 	}
 
-	// first create pod
-	var err error
+	// first create pod with the persistent contents restored
+	resumedCond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionResumed))
 	if pod == nil {
-		_, err = r.createPod(ctx, box, newStatus)
-		return err
+		newPod, err := r.newResumedPod(ctx, box, newStatus)
+		if err != nil {
+			return err
+		}
+		if len(box.Spec.PersistentContents) > 0 {
+			if err = r.restoreContents(ctx, box, newPod); err != nil {
+				return r.handleRestoreError(ctx, box, newStatus, err)
+			}
+			if resumedCond != nil {
+				resumedCond.Reason = agentsv1alpha1.SandboxResumeReasonRestoringContents
+			}
+		}
+		return r.doCreatePod(ctx, box, newPod)
 	}
 
-	// create pod success, set resumed condition to true
-	if resumedCond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionResumed)); resumedCond != nil && resumedCond.Status == metav1.ConditionFalse {
+	// create pod success, set resumed condition to true.
+	// The persistent contents are verified once the pod is running.
+	if resumedCond != nil && resumedCond.Status == metav1.ConditionFalse {
+		if len(box.Spec.PersistentContents) > 0 {
+			if pod.Status.Phase != corev1.PodRunning {
+				return nil
+			}
+			if err := r.verifyContents(ctx, box, pod); err != nil {
+				return r.handleRestoreError(ctx, box, newStatus, err)
+			}
+		}
 		resumedCond.Status = metav1.ConditionTrue
 		resumedCond.LastTransitionTime = metav1.Now()
 		utils.SetSandboxCondition(newStatus, *resumedCond)
@@ -236,7 +308,112 @@ func (r *commonControl) EnsureSandboxTerminated(ctx context.Context, args Ensure
 	return nil
 }
 
+// restartPod deletes the failed pod, and recreates the pod once it is gone. The revision of the failed pod is recorded
+// as the paused one, so that the pod is recreated from the template it ran, the same as it is resumed, while the lost
+// pod whose revision is unknown is recreated from spec.template.
+func (r *commonControl) restartPod(ctx context.Context, args EnsureFuncArgs) error {
+	pod, box, newStatus := args.Pod, args.Box, args.NewStatus
+	logger := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(box))
+	cond := metav1.Condition{
+		Type:               string(agentsv1alpha1.SandboxConditionReady),
+		Status:             metav1.ConditionFalse,
+		Reason:             agentsv1alpha1.SandboxReadyReasonRestarting,
+		LastTransitionTime: metav1.Now(),
+	}
+	if pod != nil {
+		logger.Info("Sandbox pod failed, and it will be recreated", "pod", klog.KObj(pod), "reason", pod.Status.Reason)
+		if err := r.savePausedRevision(ctx, box, pod, newStatus); err != nil {
+			logger.Error(err, "Record revision of failed pod failed")
+			return err
+		}
+		if err := client.IgnoreNotFound(r.Delete(ctx, pod)); err != nil {
+			logger.Error(err, "Delete failed pod failed")
+			return err
+		}
+		cond.Message = fmt.Sprintf("pod failed: %s", pod.Status.Reason)
+		utils.SetSandboxCondition(newStatus, cond)
+		return nil
+	}
+
+	var newPod *corev1.Pod
+	var err error
+	if last := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionReady)); last != nil &&
+		last.Reason == agentsv1alpha1.SandboxReadyReasonRestarting {
+		// the failed pod has been deleted, whose revision is recorded
+		newPod, err = r.newResumedPod(ctx, box, newStatus)
+	} else {
+		newPod, err = r.newPod(ctx, box, newStatus.UpdateRevision)
+	}
+	if err != nil {
+		return err
+	}
+	restartCount := newStatus.RestartCount + 1
+	newPod.Annotations[PodAnnotationRestartCount] = strconv.Itoa(int(restartCount))
+	if err = r.Create(ctx, newPod); err != nil {
+		// the recreated pod has not been observed yet
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		logger.Error(err, "Recreate pod failed")
+		return err
+	}
+	newStatus.RestartCount = restartCount
+	newStatus.LastRestartTime = ptr.To(metav1.Now())
+	cond.Message = fmt.Sprintf("pod recreated, restart count %d", newStatus.RestartCount)
+	utils.SetSandboxCondition(newStatus, cond)
+	r.recorder.Eventf(box, corev1.EventTypeNormal, EventSandboxRestarted, "Sandbox pod recreated, restart count %d", newStatus.RestartCount)
+	logger.Info("Sandbox pod recreated", "restartCount", newStatus.RestartCount)
+	return nil
+}
+
+// syncRestartCount restores the restart count from the recreated pod, in case the status failed to be updated after
+// the pod was created.
+func syncRestartCount(pod *corev1.Pod, newStatus *agentsv1alpha1.SandboxStatus) {
+	if pod == nil {
+		return
+	}
+	count, err := strconv.ParseInt(pod.Annotations[PodAnnotationRestartCount], 10, 32)
+	if err != nil || int32(count) <= newStatus.RestartCount {
+		return
+	}
+	newStatus.RestartCount = int32(count)
+	newStatus.LastRestartTime = ptr.To(pod.CreationTimestamp)
+}
+
+// handleRestoreError fails the sandbox if the persistent contents cannot be restored, other errors are returned to retry.
+func (r *commonControl) handleRestoreError(ctx context.Context, box *agentsv1alpha1.Sandbox, newStatus *agentsv1alpha1.SandboxStatus, err error) error {
+	logger := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(box))
+	if !errors.Is(err, ErrContentNotRestorable) {
+		logger.Error(err, "Restore contents failed")
+		return err
+	}
+	logger.Info("Persistent contents cannot be restored, and sandbox failed", "reason", err.Error())
+	newStatus.Phase = agentsv1alpha1.SandboxFailed
+	newStatus.Message = err.Error()
+	utils.SetSandboxCondition(newStatus, metav1.Condition{
+		Type:               string(agentsv1alpha1.SandboxConditionResumed),
+		Status:             metav1.ConditionFalse,
+		Reason:             agentsv1alpha1.SandboxResumeReasonRestoreFailed,
+		Message:            err.Error(),
+		LastTransitionTime: metav1.Now(),
+	})
+	r.recorder.Eventf(box, corev1.EventTypeWarning, EventResumeFailed, "Failed to resume sandbox: %s", err)
+	return nil
+}
+
 func (r *commonControl) createPod(ctx context.Context, box *agentsv1alpha1.Sandbox, newStatus *agentsv1alpha1.SandboxStatus) (*corev1.Pod, error) {
+	pod, err := r.newPod(ctx, box, newStatus.UpdateRevision)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.doCreatePod(ctx, box, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// newPod generates the pod of the sandbox from spec.template, which is labeled with the revision of the template.
+func (r *commonControl) newPod(ctx context.Context, box *agentsv1alpha1.Sandbox, revision string) (*corev1.Pod, error) {
 	logger := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(box))
 
 	pod := &corev1.Pod{
@@ -256,8 +433,7 @@ func (r *commonControl) createPod(ctx context.Context, box *agentsv1alpha1.Sandb
 	if pod.Labels == nil {
 		pod.Labels = map[string]string{}
 	}
-	// todo, when resume, create Pod based on the revision from the paused state.
-	pod.Labels[agentsv1alpha1.PodLabelTemplateHash] = newStatus.UpdateRevision
+	pod.Labels[agentsv1alpha1.PodLabelTemplateHash] = revision
 
 	volumes := make([]corev1.Volume, 0, len(box.Spec.VolumeClaimTemplates))
 	for _, template := range box.Spec.VolumeClaimTemplates {
@@ -277,14 +453,18 @@ func (r *commonControl) createPod(ctx context.Context, box *agentsv1alpha1.Sandb
 		})
 	}
 	pod.Spec.Volumes = append(pod.Spec.Volumes, volumes...)
+	return pod, nil
+}
 
+func (r *commonControl) doCreatePod(ctx context.Context, box *agentsv1alpha1.Sandbox, pod *corev1.Pod) error {
+	logger := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(box))
 	err := r.Create(ctx, pod)
-	if err != nil && !errors.IsAlreadyExists(err) {
+	if err != nil && !apierrors.IsAlreadyExists(err) {
 		logger.Error(err, "create pod failed")
-		return nil, err
+		return err
 	}
 	logger.Info("Create pod success", "Body", utils.DumpJson(pod))
-	return pod, nil
+	return nil
 }
 
 func (r *commonControl) handleInplaceUpdateSandbox(ctx context.Context, args EnsureFuncArgs) (bool, error) {
@@ -302,51 +482,57 @@ func (r *commonControl) handleInplaceUpdateSandbox(ctx context.Context, args Ens
 		r.recorder.Eventf(box, corev1.EventTypeWarning, "InplaceUpdateForbidden", "InplaceUpdate only support image, resources")
 		return true, nil
 	}
-	// revision consistent
-	if pod.Labels[agentsv1alpha1.PodLabelTemplateHash] == newStatus.UpdateRevision {
+	// revision consistent, or the pod is resumed from the paused revision and the template has not changed since then
+	liveRevision := pod.Labels[agentsv1alpha1.PodLabelTemplateHash]
+	if liveRevision == newStatus.UpdateRevision || pod.Annotations[PodAnnotationSkippedRevision] == newStatus.UpdateRevision {
 		// inplace update is incompleted
 		if !inplaceupdate.IsInplaceUpdateCompleted(ctx, pod) {
-			return false, nil
+			return r.checkInplaceUpdateFailed(box, pod, newStatus, liveRevision), nil
+		}
+		message := fmt.Sprintf("revision %s is live", liveRevision)
+		if cond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionInplaceUpdate)); cond != nil &&
+			cond.Status == metav1.ConditionTrue && cond.Message == message {
+			return true, nil
 		}
 		cond := metav1.Condition{
 			Type:               string(agentsv1alpha1.SandboxConditionInplaceUpdate),
 			Status:             metav1.ConditionTrue,
 			Reason:             agentsv1alpha1.SandboxInplaceUpdateReasonSucceeded,
+			Message:            message,
 			LastTransitionTime: metav1.Now(),
 		}
 		utils.SetSandboxCondition(newStatus, cond)
 		return true, nil
 	}
 
-	state, err := inplaceupdate.GetPodInPlaceUpdateState(pod)
-	if err != nil {
-		return false, err
-		// state!=nil indicates that an in-place upgrade has already been performed previously.
-	} else if state != nil {
-		// currently, multiple in-place updates are not supported.
-		logger.Info("currently, multiple in-place updates are not supported")
-		r.recorder.Eventf(box, corev1.EventTypeWarning, "InplaceUpdateForbidden", "currently, multiple in-place updates are not supported")
-		// inplace update is incompleted
-		if !inplaceupdate.IsInplaceUpdateCompleted(ctx, pod) {
-			return false, nil
-		}
+	// the in-place update to the generation has failed, which is never retried until the sandbox changes again
+	if cond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionInplaceUpdate)); cond != nil &&
+		cond.Reason == agentsv1alpha1.SandboxInplaceUpdateReasonFailed && cond.ObservedGeneration == box.Generation {
 		return true, nil
 	}
 
-	// start inplace update sandbox
+	// start inplace update sandbox, the last in-place update may be still in progress, which is superseded
 	opts := inplaceupdate.InPlaceUpdateOptions{Pod: pod, Box: box, Revision: newStatus.UpdateRevision}
 	changed, err := r.inplaceUpdateControl.Update(ctx, opts)
-	if err != nil {
+	if errors.Is(err, inplaceupdate.ErrPodResizeNotSupported) {
+		r.setInplaceUpdateFailed(box, newStatus, fmt.Sprintf("resize to revision %s failed: %s", newStatus.UpdateRevision, err))
+		return true, nil
+	} else if err != nil {
 		return false, err
 	} else if !changed {
-		return true, nil
+		if inplaceupdate.IsInplaceUpdateCompleted(ctx, pod) {
+			return true, nil
+		}
+		return r.checkInplaceUpdateFailed(box, pod, newStatus, newStatus.UpdateRevision), nil
 	}
+	r.recorder.Eventf(box, corev1.EventTypeNormal, "InplaceUpdating", "In-place updating from revision %s to %s", liveRevision, newStatus.UpdateRevision)
 
 	// update sandbox inplace-update
 	cond := metav1.Condition{
 		Type:               string(agentsv1alpha1.SandboxConditionInplaceUpdate),
 		Status:             metav1.ConditionFalse,
 		Reason:             agentsv1alpha1.SandboxInplaceUpdateReasonInplaceUpdating,
+		Message:            fmt.Sprintf("updating from revision %s to %s", liveRevision, newStatus.UpdateRevision),
 		LastTransitionTime: metav1.Now(),
 	}
 	utils.SetSandboxCondition(newStatus, cond)
@@ -360,3 +546,35 @@ func (r *commonControl) handleInplaceUpdateSandbox(ctx context.Context, args Ens
 	utils.SetSandboxCondition(newStatus, cond)
 	return false, nil
 }
+
+// checkInplaceUpdateFailed marks the incompleted in-place update to revision as failed if kubelet refuses to resize
+// the pod, which never completes. The sandbox keeps running with the resources allocated before, so that the failed
+// update is regarded as done, and a new revision starts another in-place update.
+func (r *commonControl) checkInplaceUpdateFailed(box *agentsv1alpha1.Sandbox, pod *corev1.Pod,
+	newStatus *agentsv1alpha1.SandboxStatus, revision string) bool {
+	infeasible, reason := inplaceupdate.IsPodResizeInfeasible(pod)
+	if !infeasible {
+		return false
+	}
+	r.setInplaceUpdateFailed(box, newStatus, fmt.Sprintf("resize to revision %s is infeasible: %s", revision, reason))
+	return true
+}
+
+// setInplaceUpdateFailed sets the InplaceUpdate condition failed for the generation of the sandbox, so that the
+// claim waiting for the in-place update of the generation fails instead of taking the sandbox as updated.
+func (r *commonControl) setInplaceUpdateFailed(box *agentsv1alpha1.Sandbox, newStatus *agentsv1alpha1.SandboxStatus, message string) {
+	if cond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionInplaceUpdate)); cond != nil &&
+		cond.Reason == agentsv1alpha1.SandboxInplaceUpdateReasonFailed && cond.Message == message &&
+		cond.ObservedGeneration == box.Generation {
+		return
+	}
+	r.recorder.Eventf(box, corev1.EventTypeWarning, "InplaceUpdateFailed", "In-place update failed, %s", message)
+	utils.SetSandboxCondition(newStatus, metav1.Condition{
+		Type:               string(agentsv1alpha1.SandboxConditionInplaceUpdate),
+		Status:             metav1.ConditionFalse,
+		Reason:             agentsv1alpha1.SandboxInplaceUpdateReasonFailed,
+		Message:            message,
+		ObservedGeneration: box.Generation,
+		LastTransitionTime: metav1.Now(),
+	})
+}
@@ -15,20 +15,24 @@ package core
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
 	"github.com/openkruise/agents/pkg/utils"
 	"github.com/openkruise/agents/pkg/utils/inplaceupdate"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 )
 
 func TestCommonControl_EnsureSandboxRunning(t *testing.T) {
@@ -52,7 +56,7 @@ func TestCommonControl_EnsureSandboxRunning(t *testing.T) {
 						Namespace: "default",
 					},
 					Spec: agentsv1alpha1.SandboxSpec{
-						SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+						EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 							Template: &corev1.PodTemplateSpec{
 								Spec: corev1.PodSpec{
 									Containers: []corev1.Container{
@@ -212,7 +216,7 @@ func TestCommonControl_EnsureSandboxUpdated(t *testing.T) {
 						Namespace: "default",
 					},
 					Spec: agentsv1alpha1.SandboxSpec{
-						SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+						EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 							Template: &corev1.PodTemplateSpec{
 								Spec: corev1.PodSpec{
 									NodeName: "node-1",
@@ -278,7 +282,7 @@ func TestCommonControl_EnsureSandboxUpdated(t *testing.T) {
 						Namespace: "default",
 					},
 					Spec: agentsv1alpha1.SandboxSpec{
-						SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+						EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 							Template: &corev1.PodTemplateSpec{
 								Spec: corev1.PodSpec{
 									NodeName: "node-1",
@@ -349,6 +353,199 @@ func TestCommonControl_EnsureSandboxUpdated(t *testing.T) {
 	}
 }
 
+func TestCommonControl_EnsureSandboxUpdated_Restart(t *testing.T) {
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sandbox", Namespace: "default"},
+		Status:     corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted"},
+	}
+	readyPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sandbox", Namespace: "default"},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: "10.0.0.2",
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Now()},
+			},
+		},
+	}
+	restartingCond := metav1.Condition{
+		Type:   string(agentsv1alpha1.SandboxConditionReady),
+		Status: metav1.ConditionFalse,
+		Reason: agentsv1alpha1.SandboxReadyReasonRestarting,
+	}
+
+	tests := []struct {
+		name          string
+		pod           *corev1.Pod
+		policy        agentsv1alpha1.SandboxRestartPolicy
+		maxRestarts   *int32
+		restartCount  int32
+		conditions    []metav1.Condition
+		expectPhase   agentsv1alpha1.SandboxPhase
+		expectCount   int32
+		expectReason  string
+		expectPodLive bool
+	}{
+		{
+			name:          "pod not found, recreated",
+			policy:        agentsv1alpha1.SandboxRestartOnFailure,
+			expectPhase:   agentsv1alpha1.SandboxRunning,
+			expectCount:   1,
+			expectReason:  agentsv1alpha1.SandboxReadyReasonRestarting,
+			expectPodLive: true,
+		},
+		{
+			name:          "pod not found, recreated within the budget",
+			policy:        agentsv1alpha1.SandboxRestartOnFailure,
+			maxRestarts:   ptr.To[int32](2),
+			restartCount:  1,
+			expectPhase:   agentsv1alpha1.SandboxRunning,
+			expectCount:   2,
+			expectReason:  agentsv1alpha1.SandboxReadyReasonRestarting,
+			expectPodLive: true,
+		},
+		{
+			name:         "pod not found, restart budget exhausted",
+			policy:       agentsv1alpha1.SandboxRestartOnFailure,
+			maxRestarts:  ptr.To[int32](1),
+			restartCount: 1,
+			expectPhase:  agentsv1alpha1.SandboxFailed,
+			expectCount:  1,
+		},
+		{
+			name:        "pod not found, never restart",
+			expectPhase: agentsv1alpha1.SandboxFailed,
+		},
+		{
+			name:         "pod failed, deleted before recreated",
+			pod:          failedPod,
+			policy:       agentsv1alpha1.SandboxRestartOnFailure,
+			expectPhase:  agentsv1alpha1.SandboxRunning,
+			expectReason: agentsv1alpha1.SandboxReadyReasonRestarting,
+		},
+		{
+			name:          "recreated pod ready",
+			pod:           readyPod,
+			policy:        agentsv1alpha1.SandboxRestartOnFailure,
+			restartCount:  1,
+			conditions:    []metav1.Condition{restartingCond},
+			expectPhase:   agentsv1alpha1.SandboxRunning,
+			expectCount:   1,
+			expectReason:  agentsv1alpha1.SandboxReadyReasonPodReady,
+			expectPodLive: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, revision := newRevisionTestSandbox("nginx:v1")
+			box.Spec.RestartPolicy = tt.policy
+			box.Spec.MaxRestarts = tt.maxRestarts
+			var objects []client.Object
+			var pod *corev1.Pod
+			if tt.pod != nil {
+				pod = tt.pod.DeepCopy()
+				pod.Labels = map[string]string{agentsv1alpha1.PodLabelTemplateHash: revision}
+				pod.Spec.Containers = box.Spec.Template.Spec.Containers
+				objects = append(objects, pod)
+			}
+			control := newRevisionTestControl(objects...)
+			newStatus := &agentsv1alpha1.SandboxStatus{
+				Phase:          agentsv1alpha1.SandboxRunning,
+				UpdateRevision: revision,
+				RestartCount:   tt.restartCount,
+				Conditions:     tt.conditions,
+			}
+			if err := control.EnsureSandboxUpdated(context.TODO(), EnsureFuncArgs{Pod: pod, Box: box, NewStatus: newStatus}); err != nil {
+				t.Fatalf("EnsureSandboxUpdated() error = %v", err)
+			}
+			if newStatus.Phase != tt.expectPhase {
+				t.Errorf("Expected phase %s, got %s", tt.expectPhase, newStatus.Phase)
+			}
+			if newStatus.RestartCount != tt.expectCount {
+				t.Errorf("Expected restart count %d, got %d", tt.expectCount, newStatus.RestartCount)
+			}
+			if tt.expectReason != "" {
+				cond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionReady))
+				if cond == nil || cond.Reason != tt.expectReason {
+					t.Errorf("Expected ready reason %s, got %v", tt.expectReason, cond)
+				}
+			}
+			err := control.Get(context.TODO(), client.ObjectKey{Namespace: box.Namespace, Name: box.Name}, &corev1.Pod{})
+			if tt.expectPodLive != (err == nil) {
+				t.Errorf("Expected pod exists %v, got error %v", tt.expectPodLive, err)
+			}
+		})
+	}
+}
+
+func TestCommonControl_EnsureSandboxUpdated_RestartLiveRevision(t *testing.T) {
+	boxV1, revV1 := newRevisionTestSandbox("nginx:v1")
+	box, revV2 := newRevisionTestSandbox("nginx:v2")
+	box.Spec.RestartPolicy = agentsv1alpha1.SandboxRestartOnFailure
+	control := newRevisionTestControl()
+	ctx := context.TODO()
+	// resumed from the paused revision v1 while the template is v2
+	newStatus := &agentsv1alpha1.SandboxStatus{Phase: agentsv1alpha1.SandboxRunning, UpdateRevision: revV1}
+	failedPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        box.Name,
+			Namespace:   box.Namespace,
+			Labels:      map[string]string{agentsv1alpha1.PodLabelTemplateHash: revV1},
+			Annotations: map[string]string{PodAnnotationSkippedRevision: revV2},
+		},
+		Spec:   boxV1.Spec.Template.Spec,
+		Status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted"},
+	}
+	if err := control.savePausedRevision(ctx, boxV1, failedPod, newStatus); err != nil {
+		t.Fatalf("savePausedRevision() error = %v", err)
+	}
+	newStatus.UpdateRevision = revV2
+	if err := control.Create(ctx, failedPod); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// the failed pod is deleted, and recreated from its live revision
+	if err := control.EnsureSandboxUpdated(ctx, EnsureFuncArgs{Pod: failedPod, Box: box, NewStatus: newStatus}); err != nil {
+		t.Fatalf("EnsureSandboxUpdated() error = %v", err)
+	}
+	if newStatus.PausedRevision != revV1 {
+		t.Errorf("Expected revision of the failed pod %s recorded, got %s", revV1, newStatus.PausedRevision)
+	}
+	if err := control.EnsureSandboxUpdated(ctx, EnsureFuncArgs{Box: box, NewStatus: newStatus}); err != nil {
+		t.Fatalf("EnsureSandboxUpdated() error = %v", err)
+	}
+	pod := &corev1.Pod{}
+	if err := control.Get(ctx, client.ObjectKey{Namespace: box.Namespace, Name: box.Name}, pod); err != nil {
+		t.Fatalf("Get recreated pod error = %v", err)
+	}
+	if pod.Labels[agentsv1alpha1.PodLabelTemplateHash] != revV1 || pod.Spec.Containers[0].Image != "nginx:v1" {
+		t.Errorf("Expected pod recreated from revision %s, got %s with image %s",
+			revV1, pod.Labels[agentsv1alpha1.PodLabelTemplateHash], pod.Spec.Containers[0].Image)
+	}
+	if pod.Annotations[PodAnnotationSkippedRevision] != revV2 {
+		t.Errorf("Expected skipped revision %s, got %s", revV2, pod.Annotations[PodAnnotationSkippedRevision])
+	}
+	if newStatus.RestartCount != 1 || pod.Annotations[PodAnnotationRestartCount] != "1" {
+		t.Errorf("Expected restart count 1, got %d in status and %q on pod",
+			newStatus.RestartCount, pod.Annotations[PodAnnotationRestartCount])
+	}
+
+	// the status failed to be updated after the pod was created
+	lostStatus := &agentsv1alpha1.SandboxStatus{
+		Phase:          agentsv1alpha1.SandboxRunning,
+		UpdateRevision: revV2,
+		PausedRevision: revV1,
+		Conditions:     newStatus.Conditions,
+	}
+	if err := control.EnsureSandboxUpdated(ctx, EnsureFuncArgs{Pod: pod, Box: box, NewStatus: lostStatus}); err != nil {
+		t.Fatalf("EnsureSandboxUpdated() error = %v", err)
+	}
+	if lostStatus.RestartCount != 1 || lostStatus.LastRestartTime == nil {
+		t.Errorf("Expected restart count restored from the pod, got %d at %v", lostStatus.RestartCount, lostStatus.LastRestartTime)
+	}
+}
+
 func TestCommonControl_EnsureSandboxPaused(t *testing.T) {
 	scheme := runtime.NewScheme()
 	_ = clientgoscheme.AddToScheme(scheme)
@@ -499,7 +696,7 @@ func TestCommonControl_EnsureSandboxResumed(t *testing.T) {
 						Namespace: "default",
 					},
 					Spec: agentsv1alpha1.SandboxSpec{
-						SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+						EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 							Template: &corev1.PodTemplateSpec{
 								Spec: corev1.PodSpec{
 									Containers: []corev1.Container{
@@ -738,7 +935,7 @@ func TestCommonControl_createPod(t *testing.T) {
 			Namespace: "default",
 		},
 		Spec: agentsv1alpha1.SandboxSpec{
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					ObjectMeta: metav1.ObjectMeta{
 						Labels:      map[string]string{"app": "test"},
@@ -785,7 +982,7 @@ func TestCommonControl_createPod(t *testing.T) {
 			Namespace: "default",
 		},
 		Spec: agentsv1alpha1.SandboxSpec{
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					ObjectMeta: metav1.ObjectMeta{
 						Labels:      map[string]string{"app": "test"},
@@ -885,7 +1082,7 @@ func TestCommonControl_handleInplaceUpdateSandbox(t *testing.T) {
 			Namespace: "default",
 		},
 		Spec: agentsv1alpha1.SandboxSpec{
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					Spec: corev1.PodSpec{
 						Containers: []corev1.Container{
@@ -932,7 +1129,7 @@ func TestCommonControl_handleInplaceUpdateSandbox(t *testing.T) {
 			},
 		},
 		Spec: agentsv1alpha1.SandboxSpec{
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					Spec: corev1.PodSpec{
 						Containers: []corev1.Container{
@@ -979,7 +1176,7 @@ func TestCommonControl_handleInplaceUpdateSandbox(t *testing.T) {
 			},
 		},
 		Spec: agentsv1alpha1.SandboxSpec{
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					Spec: corev1.PodSpec{
 						Containers: []corev1.Container{
@@ -1015,4 +1212,179 @@ func TestCommonControl_handleInplaceUpdateSandbox(t *testing.T) {
 	if !done {
 		t.Errorf("Expected done to be true when revision is consistent and inplace update is completed")
 	}
+
+	// Test case 4: Another in-place update starts while the last one is still in flight
+	sandbox4 := sandbox3.DeepCopy()
+	sandbox4.Spec.Template.Spec.Containers[0].Image = "nginx:1.27"
+	_, hash := HashSandbox(sandbox4)
+	sandbox4.Annotations[agentsv1alpha1.SandboxHashWithoutImageAndResources] = hash
+	pod4 := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-sandbox-4",
+			Namespace: "default",
+			Labels:    map[string]string{agentsv1alpha1.PodLabelTemplateHash: "revision-2"},
+			Annotations: map[string]string{
+				inplaceupdate.PodAnnotationInPlaceUpdateStateKey: `{"revision":"revision-2","lastContainerStatuses":{"test-container":{"imageID":"nginx-id-1"}},"updateCount":1}`,
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "test-container", Image: "nginx:1.26"}},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "test-container", Image: "nginx:1.25", ImageID: "nginx-id-1"}},
+		},
+	}
+	if err = control.Create(context.TODO(), pod4); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	args4 := EnsureFuncArgs{
+		Pod:       pod4,
+		Box:       sandbox4,
+		NewStatus: &agentsv1alpha1.SandboxStatus{UpdateRevision: "revision-3"},
+	}
+	done, err = control.handleInplaceUpdateSandbox(context.TODO(), args4)
+	if err != nil {
+		t.Fatalf("handleInplaceUpdateSandbox() error = %v", err)
+	}
+	if done {
+		t.Errorf("Expected done to be false when the in-place update is started")
+	}
+	cond := utils.GetSandboxCondition(args4.NewStatus, string(agentsv1alpha1.SandboxConditionInplaceUpdate))
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Message != "updating from revision revision-2 to revision-3" {
+		t.Errorf("Expected InplaceUpdating condition from revision-2 to revision-3, got %v", cond)
+	}
+	updated := &corev1.Pod{}
+	if err = control.Get(context.TODO(), client.ObjectKeyFromObject(pod4), updated); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if updated.Labels[agentsv1alpha1.PodLabelTemplateHash] != "revision-3" || updated.Spec.Containers[0].Image != "nginx:1.27" {
+		t.Errorf("Expected pod to be updated to revision-3, got labels %v, image %s", updated.Labels, updated.Spec.Containers[0].Image)
+	}
+	state, err := inplaceupdate.GetPodInPlaceUpdateState(updated)
+	if err != nil || state == nil {
+		t.Fatalf("GetPodInPlaceUpdateState() state = %v, error = %v", state, err)
+	}
+	if state.PreviousRevision != "revision-2" || state.UpdateCount != 2 || state.LastContainerStatuses["test-container"].ImageID != "nginx-id-1" {
+		t.Errorf("Unexpected in-place update state %+v", state)
+	}
+
+	// Test case 5: The in-place update is completed and the condition shows the live revision
+	updated.Status.ContainerStatuses[0] = corev1.ContainerStatus{Name: "test-container", Image: "nginx:1.27", ImageID: "nginx-id-3"}
+	args4.Pod = updated
+	done, err = control.handleInplaceUpdateSandbox(context.TODO(), args4)
+	if err != nil {
+		t.Fatalf("handleInplaceUpdateSandbox() error = %v", err)
+	}
+	if !done {
+		t.Errorf("Expected done to be true when the in-place update is completed")
+	}
+	cond = utils.GetSandboxCondition(args4.NewStatus, string(agentsv1alpha1.SandboxConditionInplaceUpdate))
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Message != "revision revision-3 is live" {
+		t.Errorf("Expected succeeded InplaceUpdate condition for revision-3, got %v", cond)
+	}
+
+	// Test case 6: The resize is refused by kubelet, which fails the in-place update
+	resizing := updated.DeepCopy()
+	resizing.Spec.Containers[0].Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("64")}
+	resizing.Status.ContainerStatuses[0].AllocatedResources = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+	resizing.Status.Conditions = []corev1.PodCondition{{
+		Type:    corev1.PodResizePending,
+		Status:  corev1.ConditionTrue,
+		Reason:  corev1.PodReasonInfeasible,
+		Message: "Node didn't have enough capacity: cpu",
+	}}
+	args4.Pod = resizing
+	done, err = control.handleInplaceUpdateSandbox(context.TODO(), args4)
+	if err != nil {
+		t.Fatalf("handleInplaceUpdateSandbox() error = %v", err)
+	}
+	if !done {
+		t.Errorf("Expected done to be true when the resize is infeasible")
+	}
+	cond = utils.GetSandboxCondition(args4.NewStatus, string(agentsv1alpha1.SandboxConditionInplaceUpdate))
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != agentsv1alpha1.SandboxInplaceUpdateReasonFailed ||
+		!strings.Contains(cond.Message, "Node didn't have enough capacity") {
+		t.Errorf("Expected failed InplaceUpdate condition, got %v", cond)
+	}
+}
+
+func TestCommonControl_handleInplaceUpdateSandbox_ResizeNotSupported(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = agentsv1alpha1.AddToScheme(scheme)
+
+	box := &agentsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sandbox", Namespace: "default", Generation: 2},
+		Spec: agentsv1alpha1.SandboxSpec{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
+				Template: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{
+							Name:      "test-container",
+							Image:     "nginx:1.26",
+							Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}},
+						}},
+					},
+				},
+			},
+		},
+	}
+	_, hash := HashSandbox(box)
+	box.Annotations = map[string]string{agentsv1alpha1.SandboxHashWithoutImageAndResources: hash}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-sandbox",
+			Namespace: "default",
+			Labels:    map[string]string{agentsv1alpha1.PodLabelTemplateHash: "revision-1"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:      "test-container",
+				Image:     "nginx:1.25",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+			}},
+		},
+	}
+	resizes := 0
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).WithInterceptorFuncs(interceptor.Funcs{
+		SubResourcePatch: func(context.Context, client.Client, string, client.Object, client.Patch, ...client.SubResourcePatchOption) error {
+			resizes++
+			return apierrors.NewMethodNotSupported(corev1.Resource("pods/resize"), "patch")
+		},
+	}).Build()
+	recorder := record.NewFakeRecorder(10)
+	control := &commonControl{Client: c, recorder: recorder}
+	control.inplaceUpdateControl = inplaceupdate.NewInPlaceUpdateControl(c, inplaceupdate.DefaultGeneratePatchBodyFunc)
+
+	args := EnsureFuncArgs{Pod: pod, Box: box, NewStatus: &agentsv1alpha1.SandboxStatus{UpdateRevision: "revision-2"}}
+	done, err := control.handleInplaceUpdateSandbox(context.TODO(), args)
+	if err != nil {
+		t.Fatalf("handleInplaceUpdateSandbox() error = %v", err)
+	}
+	if !done {
+		t.Errorf("Expected done to be true when the resize is not supported")
+	}
+	cond := utils.GetSandboxCondition(args.NewStatus, string(agentsv1alpha1.SandboxConditionInplaceUpdate))
+	if cond == nil || cond.Reason != agentsv1alpha1.SandboxInplaceUpdateReasonFailed || cond.ObservedGeneration != 2 ||
+		!strings.Contains(cond.Message, "Kubernetes 1.33+") {
+		t.Errorf("Expected failed InplaceUpdate condition of generation 2, got %v", cond)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("Expected 1 event, got %d", len(recorder.Events))
+	}
+
+	// never retried until the sandbox changes again
+	if done, err = control.handleInplaceUpdateSandbox(context.TODO(), args); err != nil || !done {
+		t.Errorf("handleInplaceUpdateSandbox() done = %v, error = %v", done, err)
+	}
+	if resizes != 1 {
+		t.Errorf("Expected resize requested once, got %d", resizes)
+	}
+	box.Generation = 3
+	if _, err = control.handleInplaceUpdateSandbox(context.TODO(), args); err != nil {
+		t.Errorf("handleInplaceUpdateSandbox() error = %v", err)
+	}
+	if resizes != 2 {
+		t.Errorf("Expected resize requested again for the new generation, got %d", resizes)
+	}
 }
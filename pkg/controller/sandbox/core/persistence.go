@@ -0,0 +1,212 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+)
+
+func init() {
+	flag.StringVar(&persistentIPAnnotation, "sandbox-persistent-ip-annotation", persistentIPAnnotation,
+		"The pod annotation used to request the ip of a paused sandbox from the CNI when it is resumed, empty means the CNI keeps the ip by pod name, and a changed ip does not fail the resume.")
+}
+
+var (
+	persistentIPAnnotation string
+
+	// ErrPersistenceUnsupported is wrapped by PersistenceBackend.Save when the content of the sandbox can never be
+	// persisted, e.g. there is no backend for the content.
+	ErrPersistenceUnsupported = errors.New("persistent content unsupported")
+	// ErrContentNotRestorable is wrapped by PersistenceBackend.Restore and PersistenceBackend.Verify when the saved
+	// content is lost or cannot be restored into the resumed pod.
+	ErrContentNotRestorable = errors.New("persistent content cannot be restored")
+
+	persistenceBackendFuncs = map[string]NewPersistenceBackendFunc{
+		agentsv1alpha1.PersistentContentIp:         newIPPersistenceBackend,
+		agentsv1alpha1.PersistentContentFilesystem: newFilesystemPersistenceBackend,
+	}
+)
+
+// PersistenceBackend keeps one kind of persistent content (see SandboxSpec.PersistentContents) of a sandbox across
+// pause and resume. Errors other than ErrPersistenceUnsupported and ErrContentNotRestorable are retried.
+type PersistenceBackend interface {
+	// Save persists the content of the running pod, it is called before the pod is deleted by pause.
+	Save(ctx context.Context, box *agentsv1alpha1.Sandbox, pod *corev1.Pod) error
+
+	// Restore prepares the pod to be created by resume, so that the saved content is restored into it.
+	Restore(ctx context.Context, box *agentsv1alpha1.Sandbox, pod *corev1.Pod) error
+
+	// Verify checks whether the content has been restored, it is called once the pod created by resume is running.
+	Verify(ctx context.Context, box *agentsv1alpha1.Sandbox, pod *corev1.Pod) error
+}
+
+type NewPersistenceBackendFunc func(c client.Client) PersistenceBackend
+
+// RegisterPersistenceBackend registers the backend of content, it overrides the built-in one and must be called
+// before the controllers are set up. There is no built-in backend for memory, which relies on the container runtime,
+// so memory is rejected by the webhook unless a backend is registered.
+func RegisterPersistenceBackend(content string, newFunc NewPersistenceBackendFunc) {
+	persistenceBackendFuncs[content] = newFunc
+}
+
+// SupportedPersistentContents returns the contents having a persistence backend.
+func SupportedPersistentContents() sets.Set[string] {
+	return sets.KeySet(persistenceBackendFuncs)
+}
+
+func newPersistenceBackends(c client.Client) map[string]PersistenceBackend {
+	backends := make(map[string]PersistenceBackend, len(persistenceBackendFuncs))
+	for content, newFunc := range persistenceBackendFuncs {
+		backends[content] = newFunc(c)
+	}
+	return backends
+}
+
+// ipPersistenceBackend keeps the ip of a sandbox, the ip recorded in status.sandboxIp is requested for the resumed pod.
+type ipPersistenceBackend struct{}
+
+func newIPPersistenceBackend(_ client.Client) PersistenceBackend {
+	return ipPersistenceBackend{}
+}
+
+func (ipPersistenceBackend) Save(_ context.Context, _ *agentsv1alpha1.Sandbox, pod *corev1.Pod) error {
+	if pod.Status.PodIP == "" {
+		return fmt.Errorf("%w: pod %s has no ip", ErrPersistenceUnsupported, pod.Name)
+	}
+	return nil
+}
+
+func (ipPersistenceBackend) Restore(_ context.Context, box *agentsv1alpha1.Sandbox, pod *corev1.Pod) error {
+	if box.Status.SandboxIp == "" {
+		return fmt.Errorf("%w: ip of sandbox %s is not recorded", ErrContentNotRestorable, box.Name)
+	}
+	if persistentIPAnnotation != "" {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[persistentIPAnnotation] = box.Status.SandboxIp
+	}
+	return nil
+}
+
+func (ipPersistenceBackend) Verify(ctx context.Context, box *agentsv1alpha1.Sandbox, pod *corev1.Pod) error {
+	if pod.Status.PodIP == box.Status.SandboxIp {
+		return nil
+	}
+	// the ip is kept by the CNI on its own without the annotation, which is not guaranteed, so a changed ip is tolerated
+	if persistentIPAnnotation == "" {
+		logf.FromContext(ctx).Info("ip of the resumed sandbox changed, set --sandbox-persistent-ip-annotation to keep it",
+			"sandbox", klog.KObj(box), "podIP", pod.Status.PodIP, "sandboxIP", box.Status.SandboxIp)
+		return nil
+	}
+	return fmt.Errorf("%w: pod ip %s differs from the sandbox ip %s", ErrContentNotRestorable, pod.Status.PodIP, box.Status.SandboxIp)
+}
+
+// filesystemPersistenceBackend keeps the filesystem of a sandbox in the PVCs of spec.volumeClaimTemplates, which are
+// retained while the pod is deleted.
+type filesystemPersistenceBackend struct {
+	client.Client
+}
+
+func newFilesystemPersistenceBackend(c client.Client) PersistenceBackend {
+	return filesystemPersistenceBackend{Client: c}
+}
+
+func (b filesystemPersistenceBackend) Save(_ context.Context, box *agentsv1alpha1.Sandbox, _ *corev1.Pod) error {
+	if len(box.Spec.VolumeClaimTemplates) == 0 {
+		return fmt.Errorf("%w: filesystem is persisted by volumeClaimTemplates, but sandbox %s has none", ErrPersistenceUnsupported, box.Name)
+	}
+	return nil
+}
+
+func (b filesystemPersistenceBackend) Restore(ctx context.Context, box *agentsv1alpha1.Sandbox, _ *corev1.Pod) error {
+	if len(box.Spec.VolumeClaimTemplates) == 0 {
+		return fmt.Errorf("%w: sandbox %s has no volumeClaimTemplates", ErrContentNotRestorable, box.Name)
+	}
+	for _, template := range box.Spec.VolumeClaimTemplates {
+		pvcName, err := GeneratePVCName(template.Name, box.Name)
+		if err != nil {
+			return err
+		}
+		pvc := &corev1.PersistentVolumeClaim{}
+		err = b.Get(ctx, client.ObjectKey{Namespace: box.Namespace, Name: pvcName}, pvc)
+		if apierrors.IsNotFound(err) || (err == nil && !pvc.DeletionTimestamp.IsZero()) {
+			return fmt.Errorf("%w: pvc %s is lost", ErrContentNotRestorable, pvcName)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b filesystemPersistenceBackend) Verify(_ context.Context, _ *agentsv1alpha1.Sandbox, _ *corev1.Pod) error {
+	return nil
+}
+
+// persistContents saves all the persistent contents of box.
+func (r *commonControl) persistContents(ctx context.Context, box *agentsv1alpha1.Sandbox, pod *corev1.Pod) error {
+	for _, content := range box.Spec.PersistentContents {
+		backend, ok := r.persistenceBackends[content]
+		if !ok {
+			return fmt.Errorf("%w: no persistence backend for %s", ErrPersistenceUnsupported, content)
+		}
+		if err := backend.Save(ctx, box, pod); err != nil {
+			return fmt.Errorf("failed to persist %s: %w", content, err)
+		}
+	}
+	return nil
+}
+
+// restoreContents prepares the pod to be created with all the persistent contents of box.
+func (r *commonControl) restoreContents(ctx context.Context, box *agentsv1alpha1.Sandbox, pod *corev1.Pod) error {
+	for _, content := range box.Spec.PersistentContents {
+		backend, ok := r.persistenceBackends[content]
+		if !ok {
+			return fmt.Errorf("%w: no persistence backend for %s", ErrContentNotRestorable, content)
+		}
+		if err := backend.Restore(ctx, box, pod); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", content, err)
+		}
+	}
+	return nil
+}
+
+// verifyContents checks all the persistent contents of box have been restored into the running pod.
+func (r *commonControl) verifyContents(ctx context.Context, box *agentsv1alpha1.Sandbox, pod *corev1.Pod) error {
+	for _, content := range box.Spec.PersistentContents {
+		backend, ok := r.persistenceBackends[content]
+		if !ok {
+			return fmt.Errorf("%w: no persistence backend for %s", ErrContentNotRestorable, content)
+		}
+		if err := backend.Verify(ctx, box, pod); err != nil {
+			return fmt.Errorf("failed to verify %s: %w", content, err)
+		}
+	}
+	return nil
+}
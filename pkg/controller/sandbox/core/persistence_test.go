@@ -0,0 +1,220 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"testing"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/utils"
+	"github.com/openkruise/agents/pkg/utils/inplaceupdate"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newPersistenceTestControl(objs ...client.Object) *commonControl {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = agentsv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	return &commonControl{
+		Client:               c,
+		recorder:             record.NewFakeRecorder(10),
+		inplaceUpdateControl: inplaceupdate.NewInPlaceUpdateControl(c, inplaceupdate.DefaultGeneratePatchBodyFunc),
+		persistenceBackends:  newPersistenceBackends(c),
+	}
+}
+
+func newPersistentSandbox(contents ...string) *agentsv1alpha1.Sandbox {
+	return &agentsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sandbox", Namespace: "default"},
+		Spec: agentsv1alpha1.SandboxSpec{
+			PersistentContents: contents,
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
+				Template: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "nginx"}}},
+				},
+			},
+		},
+		Status: agentsv1alpha1.SandboxStatus{
+			SandboxIp: "10.0.0.1",
+			Conditions: []metav1.Condition{{
+				Type:   string(agentsv1alpha1.SandboxConditionReady),
+				Status: metav1.ConditionFalse,
+				Reason: agentsv1alpha1.SandboxReadyReasonPodReady,
+			}},
+		},
+	}
+}
+
+func newRunningPod(ip string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sandbox", Namespace: "default"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+	}
+}
+
+func TestEnsureSandboxPaused_PersistentContents(t *testing.T) {
+	tests := []struct {
+		name         string
+		contents     []string
+		podIP        string
+		expectReason string
+		expectDelete bool
+	}{
+		{
+			name:         "no persistent contents",
+			podIP:        "10.0.0.1",
+			expectReason: agentsv1alpha1.SandboxPausedReasonDeletePod,
+			expectDelete: true,
+		},
+		{
+			name:         "ip persisted",
+			contents:     []string{agentsv1alpha1.PersistentContentIp},
+			podIP:        "10.0.0.1",
+			expectReason: agentsv1alpha1.SandboxPausedReasonDeletePod,
+			expectDelete: true,
+		},
+		{
+			name:         "pod without ip",
+			contents:     []string{agentsv1alpha1.PersistentContentIp},
+			expectReason: agentsv1alpha1.SandboxPausedReasonPersistFailed,
+		},
+		{
+			name:         "filesystem without volumeClaimTemplates",
+			contents:     []string{agentsv1alpha1.PersistentContentFilesystem},
+			podIP:        "10.0.0.1",
+			expectReason: agentsv1alpha1.SandboxPausedReasonPersistFailed,
+		},
+		{
+			name:         "no backend for memory",
+			contents:     []string{agentsv1alpha1.PersistentContentMemory},
+			podIP:        "10.0.0.1",
+			expectReason: agentsv1alpha1.SandboxPausedReasonPersistFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newRunningPod(tt.podIP)
+			control := newPersistenceTestControl(pod)
+			box := newPersistentSandbox(tt.contents...)
+			newStatus := box.Status.DeepCopy()
+			err := control.EnsureSandboxPaused(context.TODO(), EnsureFuncArgs{Pod: pod, Box: box, NewStatus: newStatus})
+			assert.NoError(t, err)
+
+			cond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionPaused))
+			assert.NotNil(t, cond)
+			assert.Equal(t, metav1.ConditionFalse, cond.Status)
+			assert.Equal(t, tt.expectReason, cond.Reason)
+			err = control.Get(context.TODO(), client.ObjectKeyFromObject(pod), &corev1.Pod{})
+			assert.Equal(t, tt.expectDelete, apierrors.IsNotFound(err))
+		})
+	}
+}
+
+func TestEnsureSandboxResumed_PersistentContents(t *testing.T) {
+	t.Run("restore and verify ip", func(t *testing.T) {
+		persistentIPAnnotation = "cni.test/ip"
+		defer func() { persistentIPAnnotation = "" }()
+		control := newPersistenceTestControl()
+		box := newPersistentSandbox(agentsv1alpha1.PersistentContentIp)
+		newStatus := box.Status.DeepCopy()
+		utils.SetSandboxCondition(newStatus, metav1.Condition{
+			Type:   string(agentsv1alpha1.SandboxConditionResumed),
+			Status: metav1.ConditionFalse,
+			Reason: agentsv1alpha1.SandboxResumeReasonCreatePod,
+		})
+		assert.NoError(t, control.EnsureSandboxResumed(context.TODO(), EnsureFuncArgs{Box: box, NewStatus: newStatus}))
+		pod := &corev1.Pod{}
+		assert.NoError(t, control.Get(context.TODO(), client.ObjectKeyFromObject(box), pod))
+		assert.Equal(t, "10.0.0.1", pod.Annotations["cni.test/ip"])
+		cond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionResumed))
+		assert.Equal(t, agentsv1alpha1.SandboxResumeReasonRestoringContents, cond.Reason)
+
+		// the resumed condition is not set until the pod is running
+		pod.Status.Phase = corev1.PodPending
+		assert.NoError(t, control.EnsureSandboxResumed(context.TODO(), EnsureFuncArgs{Pod: pod, Box: box, NewStatus: newStatus}))
+		assert.Equal(t, metav1.ConditionFalse, utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionResumed)).Status)
+
+		pod.Status = corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"}
+		assert.NoError(t, control.EnsureSandboxResumed(context.TODO(), EnsureFuncArgs{Pod: pod, Box: box, NewStatus: newStatus}))
+		assert.Equal(t, metav1.ConditionTrue, utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionResumed)).Status)
+		assert.Equal(t, agentsv1alpha1.SandboxRunning, newStatus.Phase)
+	})
+
+	t.Run("ip changed", func(t *testing.T) {
+		persistentIPAnnotation = "cni.test/ip"
+		defer func() { persistentIPAnnotation = "" }()
+		control := newPersistenceTestControl()
+		box := newPersistentSandbox(agentsv1alpha1.PersistentContentIp)
+		newStatus := box.Status.DeepCopy()
+		utils.SetSandboxCondition(newStatus, metav1.Condition{
+			Type:   string(agentsv1alpha1.SandboxConditionResumed),
+			Status: metav1.ConditionFalse,
+			Reason: agentsv1alpha1.SandboxResumeReasonRestoringContents,
+		})
+		pod := newRunningPod("10.0.0.2")
+		assert.NoError(t, control.EnsureSandboxResumed(context.TODO(), EnsureFuncArgs{Pod: pod, Box: box, NewStatus: newStatus}))
+		assert.Equal(t, agentsv1alpha1.SandboxFailed, newStatus.Phase)
+		cond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionResumed))
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		assert.Equal(t, agentsv1alpha1.SandboxResumeReasonRestoreFailed, cond.Reason)
+	})
+
+	t.Run("ip changed without the ip annotation", func(t *testing.T) {
+		control := newPersistenceTestControl()
+		box := newPersistentSandbox(agentsv1alpha1.PersistentContentIp)
+		newStatus := box.Status.DeepCopy()
+		utils.SetSandboxCondition(newStatus, metav1.Condition{
+			Type:   string(agentsv1alpha1.SandboxConditionResumed),
+			Status: metav1.ConditionFalse,
+			Reason: agentsv1alpha1.SandboxResumeReasonRestoringContents,
+		})
+		pod := newRunningPod("10.0.0.2")
+		assert.NoError(t, control.EnsureSandboxResumed(context.TODO(), EnsureFuncArgs{Pod: pod, Box: box, NewStatus: newStatus}))
+		assert.Equal(t, agentsv1alpha1.SandboxRunning, newStatus.Phase)
+		assert.Equal(t, metav1.ConditionTrue, utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionResumed)).Status)
+	})
+
+	t.Run("pvc lost", func(t *testing.T) {
+		control := newPersistenceTestControl()
+		box := newPersistentSandbox(agentsv1alpha1.PersistentContentFilesystem)
+		box.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}
+		newStatus := box.Status.DeepCopy()
+		assert.NoError(t, control.EnsureSandboxResumed(context.TODO(), EnsureFuncArgs{Box: box, NewStatus: newStatus}))
+		assert.Equal(t, agentsv1alpha1.SandboxFailed, newStatus.Phase)
+		assert.Contains(t, newStatus.Message, "pvc data-test-sandbox is lost")
+		err := control.Get(context.TODO(), client.ObjectKeyFromObject(box), &corev1.Pod{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("pvc retained", func(t *testing.T) {
+		pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-test-sandbox", Namespace: "default"}}
+		control := newPersistenceTestControl(pvc)
+		box := newPersistentSandbox(agentsv1alpha1.PersistentContentFilesystem)
+		box.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}
+		newStatus := box.Status.DeepCopy()
+		assert.NoError(t, control.EnsureSandboxResumed(context.TODO(), EnsureFuncArgs{Box: box, NewStatus: newStatus}))
+		assert.NotEqual(t, agentsv1alpha1.SandboxFailed, newStatus.Phase)
+		assert.NoError(t, control.Get(context.TODO(), client.ObjectKeyFromObject(box), &corev1.Pod{}))
+	})
+}
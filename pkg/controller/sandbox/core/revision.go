@@ -0,0 +1,174 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+)
+
+// PodAnnotationSkippedRevision records the template revision skipped when the pod is resumed from the paused revision.
+// The pod is not in-place updated to the skipped revision, until the template changes again.
+const PodAnnotationSkippedRevision = agentsv1alpha1.InternalPrefix + "skipped-revision"
+
+// PausedRevisionName returns the name of the ControllerRevision which keeps the template of the paused revision.
+// The name is no larger than 253 bytes, a long one is truncated with the hash of the whole name to keep it unique.
+func PausedRevisionName(sandboxName, revision string) string {
+	name := fmt.Sprintf("%s-%s", sandboxName, revision)
+	if len(name) <= validation.DNS1123SubdomainMaxLength {
+		return name
+	}
+	hf := fnv.New32a()
+	_, _ = hf.Write([]byte(name))
+	hash := rand.SafeEncodeString(fmt.Sprint(hf.Sum32()))
+	prefix := strings.TrimRight(name[:validation.DNS1123SubdomainMaxLength-len(hash)-1], "-.")
+	return fmt.Sprintf("%s-%s", prefix, hash)
+}
+
+// savePausedRevision records the template revision of the pod before it is deleted for pausing or restarting. The
+// template of the revision is kept in a ControllerRevision owned by the sandbox, and the one of the last pause is deleted.
+func (r *commonControl) savePausedRevision(ctx context.Context, box *agentsv1alpha1.Sandbox, pod *corev1.Pod, newStatus *agentsv1alpha1.SandboxStatus) error {
+	logger := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(box))
+	revision := pod.Labels[agentsv1alpha1.PodLabelTemplateHash]
+	if revision == "" {
+		revision = newStatus.UpdateRevision
+	}
+	if revision == newStatus.PausedRevision {
+		return nil
+	}
+	template := box.Spec.Template
+	if revision != newStatus.UpdateRevision {
+		// the pod is not updated to the current template, e.g. it has been resumed from the last paused revision
+		if _, err := r.getRevisionTemplate(ctx, box, revision); err == nil {
+			r.deletePausedRevision(ctx, box, newStatus.PausedRevision)
+			newStatus.PausedRevision = revision
+			return nil
+		} else if !apierrors.IsNotFound(err) {
+			return err
+		}
+		logger.Info("template of the pod revision not found, record the current template", "podRevision", revision)
+		revision = newStatus.UpdateRevision
+	}
+
+	data, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	cr := &apps.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       box.Namespace,
+			Name:            PausedRevisionName(box.Name, revision),
+			Labels:          map[string]string{agentsv1alpha1.PodLabelTemplateHash: revision},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(box, sandboxControllerKind)},
+		},
+		Data: runtime.RawExtension{Raw: data},
+	}
+	if err = r.Create(ctx, cr); apierrors.IsAlreadyExists(err) {
+		// the name may be taken by a revision of the other controllers
+		if _, err = r.getPausedRevision(ctx, box, revision); err != nil {
+			logger.Error(err, "paused revision already exists", "revision", revision)
+			return err
+		}
+	} else if err != nil {
+		logger.Error(err, "create paused revision failed", "revision", revision)
+		return err
+	}
+	r.deletePausedRevision(ctx, box, newStatus.PausedRevision)
+	newStatus.PausedRevision = revision
+	logger.Info("paused revision recorded", "revision", revision)
+	return nil
+}
+
+// deletePausedRevision deletes the ControllerRevision of the last pause, which is also garbage collected with the sandbox.
+func (r *commonControl) deletePausedRevision(ctx context.Context, box *agentsv1alpha1.Sandbox, revision string) {
+	if revision == "" {
+		return
+	}
+	cr, err := r.getPausedRevision(ctx, box, revision)
+	if err == nil {
+		err = r.Delete(ctx, cr, client.Preconditions{UID: &cr.UID})
+	}
+	if err = client.IgnoreNotFound(err); err != nil {
+		logf.FromContext(ctx).Error(err, "delete last paused revision failed", "sandbox", klog.KObj(box), "revision", revision)
+	}
+}
+
+// getPausedRevision gets the ControllerRevision of the paused revision. Its name may collide with the revisions of
+// the other controllers, so it must be controlled by the sandbox and labeled with the revision.
+func (r *commonControl) getPausedRevision(ctx context.Context, box *agentsv1alpha1.Sandbox, revision string) (*apps.ControllerRevision, error) {
+	cr := &apps.ControllerRevision{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: box.Namespace, Name: PausedRevisionName(box.Name, revision)}, cr); err != nil {
+		return nil, err
+	}
+	if !metav1.IsControlledBy(cr, box) || cr.Labels[agentsv1alpha1.PodLabelTemplateHash] != revision {
+		return nil, fmt.Errorf("ControllerRevision %s is not the paused revision %s of the sandbox", klog.KObj(cr), revision)
+	}
+	return cr, nil
+}
+
+// getRevisionTemplate gets the template of the revision recorded when the sandbox was paused.
+func (r *commonControl) getRevisionTemplate(ctx context.Context, box *agentsv1alpha1.Sandbox, revision string) (*corev1.PodTemplateSpec, error) {
+	cr, err := r.getPausedRevision(ctx, box, revision)
+	if err != nil {
+		return nil, err
+	}
+	template := &corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(cr.Data.Raw, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// newResumedPod generates the pod of the resumed or restarted sandbox, from the paused revision unless the latest one
+// is required.
+func (r *commonControl) newResumedPod(ctx context.Context, box *agentsv1alpha1.Sandbox, newStatus *agentsv1alpha1.SandboxStatus) (*corev1.Pod, error) {
+	logger := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(box))
+	paused := newStatus.PausedRevision
+	if box.Spec.ResumeRevisionPolicy == agentsv1alpha1.SandboxResumeRevisionLatest || paused == "" || paused == newStatus.UpdateRevision {
+		return r.newPod(ctx, box, newStatus.UpdateRevision)
+	}
+	template, err := r.getRevisionTemplate(ctx, box, paused)
+	if err != nil {
+		logger.Error(err, "get paused revision failed", "revision", paused)
+		return nil, err
+	}
+	clone := box.DeepCopy()
+	clone.Spec.Template = template
+	pod, err := r.newPod(ctx, clone, paused)
+	if err != nil {
+		return nil, err
+	}
+	pod.Annotations[PodAnnotationSkippedRevision] = newStatus.UpdateRevision
+	logger.Info("recreate pod from the paused revision", "revision", paused, "skippedRevision", newStatus.UpdateRevision)
+	return pod, nil
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/utils"
+	"github.com/openkruise/agents/pkg/utils/inplaceupdate"
+)
+
+func newRevisionTestControl(objects ...client.Object) *commonControl {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = agentsv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	return &commonControl{
+		Client:               c,
+		recorder:             record.NewFakeRecorder(10),
+		inplaceUpdateControl: inplaceupdate.NewInPlaceUpdateControl(c, inplaceupdate.DefaultGeneratePatchBodyFunc),
+	}
+}
+
+func newRevisionTestSandbox(image string) (*agentsv1alpha1.Sandbox, string) {
+	box := &agentsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-sandbox",
+			Namespace:   "default",
+			UID:         "sandbox-uid",
+			Annotations: map[string]string{},
+		},
+		Spec: agentsv1alpha1.SandboxSpec{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
+				Template: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "main", Image: image}},
+					},
+				},
+			},
+		},
+	}
+	revision, hashWithoutImageAndResources := HashSandbox(box)
+	box.Annotations[agentsv1alpha1.SandboxHashWithoutImageAndResources] = hashWithoutImageAndResources
+	return box, revision
+}
+
+func TestPausedRevisionName(t *testing.T) {
+	if got := PausedRevisionName("sbx", "rev"); got != "sbx-rev" {
+		t.Errorf("expected sbx-rev, got %s", got)
+	}
+	longName := strings.Repeat("a", 250) + ".b"
+	name1 := PausedRevisionName(longName, "5f7d8c9b4d")
+	name2 := PausedRevisionName(longName, "6c8d7b5f9a")
+	for _, name := range []string{name1, name2} {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			t.Errorf("invalid name %s: %v", name, errs)
+		}
+	}
+	if name1 == name2 {
+		t.Errorf("expected different names of different revisions, got %s", name1)
+	}
+	if name1 != PausedRevisionName(longName, "5f7d8c9b4d") {
+		t.Errorf("expected stable name, got %s", PausedRevisionName(longName, "5f7d8c9b4d"))
+	}
+}
+
+func TestCommonControl_savePausedRevision(t *testing.T) {
+	boxV1, revV1 := newRevisionTestSandbox("nginx:v1")
+	boxV2, revV2 := newRevisionTestSandbox("nginx:v2")
+	control := newRevisionTestControl()
+	ctx := context.TODO()
+
+	// pause on v1
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{agentsv1alpha1.PodLabelTemplateHash: revV1}}}
+	newStatus := &agentsv1alpha1.SandboxStatus{UpdateRevision: revV1}
+	if err := control.savePausedRevision(ctx, boxV1, pod, newStatus); err != nil {
+		t.Fatalf("savePausedRevision() error = %v", err)
+	}
+	if newStatus.PausedRevision != revV1 {
+		t.Errorf("Expected paused revision %s, got %s", revV1, newStatus.PausedRevision)
+	}
+	cr := &apps.ControllerRevision{}
+	if err := control.Get(ctx, client.ObjectKey{Namespace: "default", Name: PausedRevisionName(boxV1.Name, revV1)}, cr); err != nil {
+		t.Fatalf("Get paused revision error = %v", err)
+	}
+	if len(cr.OwnerReferences) != 1 || cr.OwnerReferences[0].UID != boxV1.UID {
+		t.Errorf("Expected paused revision owned by the sandbox, got %v", cr.OwnerReferences)
+	}
+	template, err := control.getRevisionTemplate(ctx, boxV1, revV1)
+	if err != nil {
+		t.Fatalf("getRevisionTemplate() error = %v", err)
+	}
+	if template.Spec.Containers[0].Image != "nginx:v1" {
+		t.Errorf("Expected paused template image nginx:v1, got %s", template.Spec.Containers[0].Image)
+	}
+
+	// resumed from v1 while the template is v2, and paused again
+	pod.Annotations = map[string]string{PodAnnotationSkippedRevision: revV2}
+	newStatus.UpdateRevision = revV2
+	if err = control.savePausedRevision(ctx, boxV2, pod, newStatus); err != nil {
+		t.Fatalf("savePausedRevision() error = %v", err)
+	}
+	if newStatus.PausedRevision != revV1 {
+		t.Errorf("Expected paused revision kept %s, got %s", revV1, newStatus.PausedRevision)
+	}
+
+	// updated to v2 and paused again
+	pod.Labels[agentsv1alpha1.PodLabelTemplateHash] = revV2
+	if err = control.savePausedRevision(ctx, boxV2, pod, newStatus); err != nil {
+		t.Fatalf("savePausedRevision() error = %v", err)
+	}
+	if newStatus.PausedRevision != revV2 {
+		t.Errorf("Expected paused revision %s, got %s", revV2, newStatus.PausedRevision)
+	}
+	err = control.Get(ctx, client.ObjectKey{Namespace: "default", Name: PausedRevisionName(boxV1.Name, revV1)}, cr)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the last paused revision deleted, got error %v", err)
+	}
+	if _, err = control.getRevisionTemplate(ctx, boxV2, revV2); err != nil {
+		t.Errorf("getRevisionTemplate() error = %v", err)
+	}
+}
+
+func TestCommonControl_savePausedRevision_NameCollision(t *testing.T) {
+	box, revision := newRevisionTestSandbox("nginx:v1")
+	owner := &agentsv1alpha1.SandboxSet{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default", UID: "other-uid"}}
+	tests := []struct {
+		name     string
+		modifyCR func(cr *apps.ControllerRevision)
+	}{
+		{
+			name: "controlled by another object",
+			modifyCR: func(cr *apps.ControllerRevision) {
+				cr.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(owner, agentsv1alpha1.SandboxSetControllerKind)}
+			},
+		},
+		{
+			name: "labeled with another revision",
+			modifyCR: func(cr *apps.ControllerRevision) {
+				cr.Labels[agentsv1alpha1.PodLabelTemplateHash] = "other"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			cr := &apps.ControllerRevision{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       box.Namespace,
+					Name:            PausedRevisionName(box.Name, revision),
+					Labels:          map[string]string{agentsv1alpha1.PodLabelTemplateHash: revision},
+					OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(box, sandboxControllerKind)},
+				},
+				Data: runtime.RawExtension{Raw: []byte(`{}`)},
+			}
+			tt.modifyCR(cr)
+			control := newRevisionTestControl(cr)
+
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{agentsv1alpha1.PodLabelTemplateHash: revision}}}
+			newStatus := &agentsv1alpha1.SandboxStatus{UpdateRevision: revision}
+			if err := control.savePausedRevision(ctx, box, pod, newStatus); err == nil {
+				t.Errorf("Expected savePausedRevision() to fail on a colliding revision")
+			}
+			if newStatus.PausedRevision != "" {
+				t.Errorf("Expected no paused revision recorded, got %s", newStatus.PausedRevision)
+			}
+			if _, err := control.getRevisionTemplate(ctx, box, revision); err == nil || apierrors.IsNotFound(err) {
+				t.Errorf("Expected getRevisionTemplate() to fail on a colliding revision, got %v", err)
+			}
+			control.deletePausedRevision(ctx, box, revision)
+			if err := control.Get(ctx, client.ObjectKeyFromObject(cr), &apps.ControllerRevision{}); err != nil {
+				t.Errorf("Expected the colliding revision kept, got error %v", err)
+			}
+		})
+	}
+}
+
+func TestCommonControl_newResumedPod(t *testing.T) {
+	boxV1, revV1 := newRevisionTestSandbox("nginx:v1")
+	boxV2, revV2 := newRevisionTestSandbox("nginx:v2")
+	control := newRevisionTestControl()
+	ctx := context.TODO()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{agentsv1alpha1.PodLabelTemplateHash: revV1}}}
+	if err := control.savePausedRevision(ctx, boxV1, pod, &agentsv1alpha1.SandboxStatus{UpdateRevision: revV1}); err != nil {
+		t.Fatalf("savePausedRevision() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		policy        agentsv1alpha1.SandboxResumeRevisionPolicy
+		status        agentsv1alpha1.SandboxStatus
+		expectImage   string
+		expectLabel   string
+		expectSkipped string
+		expectErr     bool
+	}{
+		{
+			name:          "resume from the paused revision",
+			status:        agentsv1alpha1.SandboxStatus{UpdateRevision: revV2, PausedRevision: revV1},
+			expectImage:   "nginx:v1",
+			expectLabel:   revV1,
+			expectSkipped: revV2,
+		},
+		{
+			name:        "resume from the latest revision",
+			policy:      agentsv1alpha1.SandboxResumeRevisionLatest,
+			status:      agentsv1alpha1.SandboxStatus{UpdateRevision: revV2, PausedRevision: revV1},
+			expectImage: "nginx:v2",
+			expectLabel: revV2,
+		},
+		{
+			name:        "template not changed while paused",
+			status:      agentsv1alpha1.SandboxStatus{UpdateRevision: revV2, PausedRevision: revV2},
+			expectImage: "nginx:v2",
+			expectLabel: revV2,
+		},
+		{
+			name:        "no paused revision recorded",
+			status:      agentsv1alpha1.SandboxStatus{UpdateRevision: revV2},
+			expectImage: "nginx:v2",
+			expectLabel: revV2,
+		},
+		{
+			name:      "paused revision not found",
+			status:    agentsv1alpha1.SandboxStatus{UpdateRevision: revV2, PausedRevision: "not-found"},
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := boxV2.DeepCopy()
+			box.Spec.ResumeRevisionPolicy = tt.policy
+			newPod, err := control.newResumedPod(ctx, box, &tt.status)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("newResumedPod() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if tt.expectErr {
+				return
+			}
+			if newPod.Spec.Containers[0].Image != tt.expectImage {
+				t.Errorf("Expected image %s, got %s", tt.expectImage, newPod.Spec.Containers[0].Image)
+			}
+			if newPod.Labels[agentsv1alpha1.PodLabelTemplateHash] != tt.expectLabel {
+				t.Errorf("Expected revision label %s, got %s", tt.expectLabel, newPod.Labels[agentsv1alpha1.PodLabelTemplateHash])
+			}
+			if newPod.Annotations[PodAnnotationSkippedRevision] != tt.expectSkipped {
+				t.Errorf("Expected skipped revision %s, got %s", tt.expectSkipped, newPod.Annotations[PodAnnotationSkippedRevision])
+			}
+			if box.Spec.Template.Spec.Containers[0].Image != "nginx:v2" {
+				t.Errorf("Expected the sandbox template not changed")
+			}
+		})
+	}
+}
+
+func TestCommonControl_handleInplaceUpdateSandbox_SkippedRevision(t *testing.T) {
+	_, revV1 := newRevisionTestSandbox("nginx:v1")
+	boxV2, revV2 := newRevisionTestSandbox("nginx:v2")
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-sandbox",
+			Namespace:   "default",
+			Labels:      map[string]string{agentsv1alpha1.PodLabelTemplateHash: revV1},
+			Annotations: map[string]string{PodAnnotationSkippedRevision: revV2},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "nginx:v1"}}},
+	}
+	control := newRevisionTestControl(pod)
+	ctx := context.TODO()
+
+	// the template revision skipped at resume is not updated in place
+	newStatus := &agentsv1alpha1.SandboxStatus{UpdateRevision: revV2}
+	done, err := control.handleInplaceUpdateSandbox(ctx, EnsureFuncArgs{Pod: pod, Box: boxV2, NewStatus: newStatus})
+	if err != nil || !done {
+		t.Fatalf("handleInplaceUpdateSandbox() done = %v, error = %v", done, err)
+	}
+	cond := utils.GetSandboxCondition(newStatus, string(agentsv1alpha1.SandboxConditionInplaceUpdate))
+	if cond == nil || cond.Message != "revision "+revV1+" is live" {
+		t.Errorf("Expected the paused revision live, got %v", cond)
+	}
+
+	// the template changes after resumed
+	boxV3 := boxV2.DeepCopy()
+	boxV3.Spec.Template.Spec.Containers[0].Image = "nginx:v3"
+	revV3, _ := HashSandbox(boxV3)
+	newStatus = &agentsv1alpha1.SandboxStatus{UpdateRevision: revV3}
+	done, err = control.handleInplaceUpdateSandbox(ctx, EnsureFuncArgs{Pod: pod, Box: boxV3, NewStatus: newStatus})
+	if err != nil || done {
+		t.Fatalf("handleInplaceUpdateSandbox() done = %v, error = %v", done, err)
+	}
+	updated := &corev1.Pod{}
+	if err = control.Get(ctx, client.ObjectKeyFromObject(pod), updated); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if updated.Spec.Containers[0].Image != "nginx:v3" || updated.Labels[agentsv1alpha1.PodLabelTemplateHash] != revV3 {
+		t.Errorf("Expected pod updated to nginx:v3, got %s", updated.Spec.Containers[0].Image)
+	}
+}
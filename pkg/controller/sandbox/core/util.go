@@ -48,6 +48,14 @@ func HashSandbox(box *agentsv1alpha1.Sandbox) (string, string) {
 	return hash, hashWithoutImageResources
 }
 
+// CanRestart returns whether the lost pod of the running sandbox can be recreated by the restart policy.
+func CanRestart(box *agentsv1alpha1.Sandbox, status *agentsv1alpha1.SandboxStatus) bool {
+	if box.Spec.RestartPolicy != agentsv1alpha1.SandboxRestartOnFailure || status.Phase != agentsv1alpha1.SandboxRunning {
+		return false
+	}
+	return box.Spec.MaxRestarts == nil || status.RestartCount < *box.Spec.MaxRestarts
+}
+
 // GeneratePVCName generates a persistent volume claim name from template name and sandbox name
 func GeneratePVCName(templateName, sandboxName string) (string, error) {
 	if templateName == "" || sandboxName == "" {
@@ -20,6 +20,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
 )
 
 func TestHashSandbox(t *testing.T) {
@@ -34,7 +35,7 @@ func TestHashSandbox(t *testing.T) {
 			name: "basic sandbox with containers",
 			sandbox: &agentsv1alpha1.Sandbox{
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -60,7 +61,7 @@ func TestHashSandbox(t *testing.T) {
 			name: "sandbox with init containers",
 			sandbox: &agentsv1alpha1.Sandbox{
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								InitContainers: []corev1.Container{
@@ -92,7 +93,7 @@ func TestHashSandbox(t *testing.T) {
 			name: "sandbox with multiple containers",
 			sandbox: &agentsv1alpha1.Sandbox{
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								InitContainers: []corev1.Container{
@@ -138,7 +139,7 @@ func TestHashSandbox(t *testing.T) {
 			name: "sandbox with empty containers",
 			sandbox: &agentsv1alpha1.Sandbox{
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{},
@@ -153,7 +154,7 @@ func TestHashSandbox(t *testing.T) {
 			name: "sandbox with volumes and other fields",
 			sandbox: &agentsv1alpha1.Sandbox{
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							ObjectMeta: metav1.ObjectMeta{
 								Labels: map[string]string{
@@ -231,7 +232,7 @@ func TestHashSandboxWithDifferentImages(t *testing.T) {
 	// Test that changing only image results in different full hash but same hash without image/resources
 	sandbox1 := &agentsv1alpha1.Sandbox{
 		Spec: agentsv1alpha1.SandboxSpec{
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					Spec: corev1.PodSpec{
 						Containers: []corev1.Container{
@@ -248,7 +249,7 @@ func TestHashSandboxWithDifferentImages(t *testing.T) {
 
 	sandbox2 := &agentsv1alpha1.Sandbox{
 		Spec: agentsv1alpha1.SandboxSpec{
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					Spec: corev1.PodSpec{
 						Containers: []corev1.Container{
@@ -282,7 +283,7 @@ func TestHashSandboxWithDifferentResources(t *testing.T) {
 	// Test that changing only resources results in different full hash but same hash without image/resources
 	sandbox1 := &agentsv1alpha1.Sandbox{
 		Spec: agentsv1alpha1.SandboxSpec{
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					Spec: corev1.PodSpec{
 						Containers: []corev1.Container{
@@ -305,7 +306,7 @@ func TestHashSandboxWithDifferentResources(t *testing.T) {
 
 	sandbox2 := &agentsv1alpha1.Sandbox{
 		Spec: agentsv1alpha1.SandboxSpec{
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					Spec: corev1.PodSpec{
 						Containers: []corev1.Container{
@@ -413,3 +414,30 @@ func TestGeneratePVCName(t *testing.T) {
 		})
 	}
 }
+
+func TestCanRestart(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       agentsv1alpha1.SandboxRestartPolicy
+		maxRestarts  *int32
+		phase        agentsv1alpha1.SandboxPhase
+		restartCount int32
+		expect       bool
+	}{
+		{name: "never restart", phase: agentsv1alpha1.SandboxRunning, expect: false},
+		{name: "restart without budget", policy: agentsv1alpha1.SandboxRestartOnFailure, phase: agentsv1alpha1.SandboxRunning, restartCount: 100, expect: true},
+		{name: "restart within budget", policy: agentsv1alpha1.SandboxRestartOnFailure, maxRestarts: ptr.To[int32](3), phase: agentsv1alpha1.SandboxRunning, restartCount: 2, expect: true},
+		{name: "restart budget exhausted", policy: agentsv1alpha1.SandboxRestartOnFailure, maxRestarts: ptr.To[int32](3), phase: agentsv1alpha1.SandboxRunning, restartCount: 3, expect: false},
+		{name: "zero budget", policy: agentsv1alpha1.SandboxRestartOnFailure, maxRestarts: ptr.To[int32](0), phase: agentsv1alpha1.SandboxRunning, expect: false},
+		{name: "not running", policy: agentsv1alpha1.SandboxRestartOnFailure, phase: agentsv1alpha1.SandboxPending, expect: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := &agentsv1alpha1.Sandbox{Spec: agentsv1alpha1.SandboxSpec{RestartPolicy: tt.policy, MaxRestarts: tt.maxRestarts}}
+			status := &agentsv1alpha1.SandboxStatus{Phase: tt.phase, RestartCount: tt.restartCount}
+			if got := CanRestart(box, status); got != tt.expect {
+				t.Errorf("CanRestart() = %v, expect %v", got, tt.expect)
+			}
+		})
+	}
+}
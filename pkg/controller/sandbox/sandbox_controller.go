@@ -50,6 +50,8 @@ import (
 	"github.com/openkruise/agents/pkg/utils"
 	"github.com/openkruise/agents/pkg/utils/expectations"
 	utilfeature "github.com/openkruise/agents/pkg/utils/feature"
+	"github.com/openkruise/agents/pkg/utils/fieldindex"
+	"github.com/openkruise/agents/pkg/utils/templateref"
 )
 
 func init() {
@@ -89,8 +91,12 @@ type SandboxReconciler struct {
 // +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxes/finalizers,verbs=update
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=pods/resize,verbs=patch
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;update;patch
 // +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=podtemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxtemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;delete
 
 func (r *SandboxReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	// Fetch the sandbox instance
@@ -101,7 +107,7 @@ func (r *SandboxReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 	logger := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(box))
 
-	if box.Spec.Template == nil {
+	if box.Spec.Template == nil && box.Spec.TemplateRef == nil {
 		logger.Info("sandbox template is nil, and ignore")
 		return reconcile.Result{}, nil
 	}
@@ -123,6 +129,27 @@ func (r *SandboxReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		box.Annotations = map[string]string{}
 	}
 
+	// Resolve spec.templateRef into spec of the in-memory object, so that the following logic
+	// (hash, pod creation, inplace update) only has to deal with spec.template.
+	resolved, err := templateref.ResolveTemplate(ctx, r.Client, box.Namespace, &box.Spec.EmbeddedSandboxTemplate)
+	if err != nil && !templateref.IsUnresolvable(err) {
+		logger.Error(err, "resolve sandbox template failed")
+		return reconcile.Result{}, err
+	}
+	if box.Spec.Template == nil {
+		setTemplateResolvedCondition(newStatus, err)
+	}
+	if resolved == nil && box.DeletionTimestamp.IsZero() {
+		logger.Info("sandbox templateRef cannot be resolved, wait for the template", "templateRef", utils.DumpJson(box.Spec.TemplateRef), "reason", err.Error())
+		return reconcile.Result{}, r.updateSandboxStatus(ctx, *newStatus, box)
+	} else if resolved != nil {
+		box.Spec.Template = resolved.Template
+		box.Spec.VolumeClaimTemplates = resolved.VolumeClaimTemplates
+		if len(box.Spec.PersistentContents) == 0 {
+			box.Spec.PersistentContents = resolved.PersistentContents
+		}
+	}
+
 	// Process VolumeClaimTemplates for persistent data recovery during sleep/wake operations
 	if err := r.ensureVolumeClaimTemplates(ctx, box); err != nil {
 		logger.Error(err, "failed to ensure volume claim templates")
@@ -156,14 +183,9 @@ func (r *SandboxReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	// Check Shutdown
-	var requeueAfter time.Duration
-	if box.Spec.ShutdownTime != nil && box.DeletionTimestamp == nil {
-		now := metav1.Now()
-		if box.Spec.ShutdownTime.Before(&now) {
-			logger.Info("Sandbox shutdown time reached，and it will be deleted.")
-			return reconcile.Result{}, r.Delete(ctx, box)
-		}
-		requeueAfter = box.Spec.ShutdownTime.Sub(now.Time)
+	shutdown, requeueAfter, err := r.ensureShutdown(ctx, box)
+	if err != nil || shutdown {
+		return reconcile.Result{}, err
 	}
 
 	// calculate sandbox status
@@ -211,6 +233,46 @@ func (r *SandboxReconciler) handleTerminating(ctx context.Context, args core.Ens
 	return ctrl.Result{}, r.updateSandboxStatus(ctx, *newStatus, box)
 }
 
+// ensureShutdown deletes or pauses the sandbox according to the shutdown policy once the shutdown time is reached,
+// and deletes the paused sandbox once the paused retention expires. It returns whether the sandbox is shut down,
+// or how long to wait for the next check.
+func (r *SandboxReconciler) ensureShutdown(ctx context.Context, box *agentsv1alpha1.Sandbox) (bool, time.Duration, error) {
+	logger := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(box))
+	now := time.Now()
+	var requeueAfter time.Duration
+	if box.Spec.Paused && box.Spec.PausedRetentionSeconds != nil {
+		cond := utils.GetSandboxCondition(&box.Status, string(agentsv1alpha1.SandboxConditionPaused))
+		if cond != nil && cond.Status == metav1.ConditionTrue {
+			expireTime := cond.LastTransitionTime.Add(time.Duration(*box.Spec.PausedRetentionSeconds) * time.Second)
+			if !expireTime.After(now) {
+				logger.Info("Sandbox paused retention expired, and it will be deleted.")
+				return true, 0, r.Delete(ctx, box)
+			}
+			requeueAfter = expireTime.Sub(now)
+		}
+	}
+
+	pausePolicy := box.Spec.ShutdownPolicy == agentsv1alpha1.SandboxShutdownPause
+	if box.Spec.ShutdownTime == nil || (pausePolicy && box.Spec.Paused) {
+		return false, requeueAfter, nil
+	}
+	if after := box.Spec.ShutdownTime.Sub(now); after > 0 {
+		if requeueAfter == 0 || after < requeueAfter {
+			requeueAfter = after
+		}
+		return false, requeueAfter, nil
+	}
+	// the sandbox which has never run has nothing to retain
+	if !pausePolicy || box.Status.Phase == agentsv1alpha1.SandboxPending {
+		logger.Info("Sandbox shutdown time reached, and it will be deleted.")
+		return true, 0, r.Delete(ctx, box)
+	}
+	logger.Info("Sandbox shutdown time reached, and it will be paused.")
+	// patch a copy, the spec of box may be resolved from templateRef
+	patch := client.RawPatch(types.MergePatchType, []byte(`{"spec":{"paused":true}}`))
+	return true, 0, r.Patch(ctx, box.DeepCopy(), patch)
+}
+
 func (r *SandboxReconciler) isCompletedPhase(phase agentsv1alpha1.SandboxPhase) bool {
 	return phase == agentsv1alpha1.SandboxFailed || phase == agentsv1alpha1.SandboxSucceeded
 }
@@ -223,6 +285,8 @@ func (r *SandboxReconciler) ensureFinalizer(ctx context.Context, box *agentsv1al
 			logger.Error(err, "patch finalizer failed")
 			return nil, err
 		}
+		// the patched object is decoded from the apiserver response, keep the resolved spec
+		newObj.(*agentsv1alpha1.Sandbox).Spec = box.Spec
 		box = newObj.(*agentsv1alpha1.Sandbox)
 		logger.Info("add sandbox finalizer success")
 	}
@@ -242,6 +306,7 @@ func (r *SandboxReconciler) patchSandboxAnnotationHash(ctx context.Context, box
 		logger.Error(err, "patch sandbox annotation failed")
 		return nil, err
 	}
+	clone.Spec = box.Spec
 	logger.Info("patch sandbox annotation success", "annotation", agentsv1alpha1.SandboxHashWithoutImageAndResources)
 	return clone, nil
 }
@@ -281,8 +346,9 @@ func calculateStatus(args core.EnsureFuncArgs) (*agentsv1alpha1.SandboxStatus, b
 		} else if pod.Status.Phase == corev1.PodSucceeded && !box.Spec.Paused {
 			newStatus.Phase = agentsv1alpha1.SandboxSucceeded
 			return newStatus, true
-		} else if pod.Status.Phase == corev1.PodFailed && !box.Spec.Paused {
+		} else if pod.Status.Phase == corev1.PodFailed && !box.Spec.Paused && !core.CanRestart(box, newStatus) {
 			// During the paused phase, the pod transitions to the Failed state and should be ignored.
+			// The failed pod of the running sandbox is recreated if the restart policy permits.
 			newStatus.Phase = agentsv1alpha1.SandboxFailed
 			return newStatus, true
 		}
@@ -312,7 +378,7 @@ func calculateStatus(args core.EnsureFuncArgs) (*agentsv1alpha1.SandboxStatus, b
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SandboxReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		WithOptions(controller.Options{MaxConcurrentReconciles: concurrentReconciles}).
 		For(&agentsv1alpha1.Sandbox{}).
 		Named("sandbox").
@@ -326,7 +392,50 @@ func (r *SandboxReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				return false
 			},
 		})).Watches(&corev1.Pod{}, &SandboxPodEventHandler{}).
-		Complete(r)
+		Watches(&corev1.PodTemplate{}, handler.EnqueueRequestsFromMapFunc(r.mapTemplateToSandboxes(templateref.DefaultKind)))
+	if discovery.DiscoverGVK(agentsv1alpha1.SandboxTemplateControllerKind) {
+		b = b.Watches(&agentsv1alpha1.SandboxTemplate{},
+			handler.EnqueueRequestsFromMapFunc(r.mapTemplateToSandboxes(agentsv1alpha1.SandboxTemplateControllerKind.Kind)))
+	}
+	return b.Complete(r)
+}
+
+// mapTemplateToSandboxes returns a map func which enqueues all sandboxes referencing the changed template of kind.
+func (r *SandboxReconciler) mapTemplateToSandboxes(kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		sandboxList := &agentsv1alpha1.SandboxList{}
+		if err := r.List(ctx, sandboxList, client.InNamespace(obj.GetNamespace()),
+			client.MatchingFields{fieldindex.IndexNameForTemplateRef: templateref.IndexKey(kind, obj.GetName())},
+		); err != nil {
+			logf.FromContext(ctx).Error(err, "list sandboxes referencing template failed", "kind", kind, "template", klog.KObj(obj))
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(sandboxList.Items))
+		for i := range sandboxList.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&sandboxList.Items[i])})
+		}
+		return requests
+	}
+}
+
+// setTemplateResolvedCondition records the result of resolving spec.templateRef, resolveErr must be nil or unresolvable.
+func setTemplateResolvedCondition(newStatus *agentsv1alpha1.SandboxStatus, resolveErr error) {
+	cond := metav1.Condition{
+		Type:   string(agentsv1alpha1.SandboxConditionTemplateResolved),
+		Status: metav1.ConditionTrue,
+		Reason: agentsv1alpha1.SandboxTemplateReasonResolved,
+	}
+	if resolveErr != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = templateref.Reason(resolveErr)
+		cond.Message = resolveErr.Error()
+	}
+	if old := utils.GetSandboxCondition(newStatus, cond.Type); old != nil && old.Status == cond.Status &&
+		old.Reason == cond.Reason && old.Message == cond.Message {
+		return
+	}
+	cond.LastTransitionTime = metav1.Now()
+	utils.SetSandboxCondition(newStatus, cond)
 }
 
 // ensureVolumeClaimTemplates creates and ensures PVCs exist for persistent data recovery during sleep/wake operations
@@ -23,16 +23,23 @@ import (
 
 	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
 	"github.com/openkruise/agents/pkg/controller/sandbox/core"
+	"github.com/openkruise/agents/pkg/utils"
+	"github.com/openkruise/agents/pkg/utils/fieldindex"
+	"github.com/openkruise/agents/pkg/utils/templateref"
+	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 func TestSandboxReconciler_Reconcile(t *testing.T) {
@@ -69,7 +76,7 @@ func TestSandboxReconciler_Reconcile(t *testing.T) {
 					Namespace: "default",
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: nil,
 					},
 				},
@@ -86,7 +93,7 @@ func TestSandboxReconciler_Reconcile(t *testing.T) {
 					Namespace: "default",
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -115,7 +122,7 @@ func TestSandboxReconciler_Reconcile(t *testing.T) {
 					Namespace: "default",
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -145,7 +152,7 @@ func TestSandboxReconciler_Reconcile(t *testing.T) {
 					Generation: 1,
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -172,7 +179,7 @@ func TestSandboxReconciler_Reconcile(t *testing.T) {
 					DeletionTimestamp: &metav1.Time{Time: time.Now()},
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -209,7 +216,7 @@ func TestSandboxReconciler_Reconcile(t *testing.T) {
 					Namespace: "default",
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -246,7 +253,7 @@ func TestSandboxReconciler_Reconcile(t *testing.T) {
 					Namespace: "default",
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -284,7 +291,7 @@ func TestSandboxReconciler_Reconcile(t *testing.T) {
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
 					Paused: true,
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -322,7 +329,7 @@ func TestSandboxReconciler_Reconcile(t *testing.T) {
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
 					Paused: false,
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -532,7 +539,7 @@ func TestSandboxReconciler_ShutdownTime(t *testing.T) {
 		},
 		Spec: agentsv1alpha1.SandboxSpec{
 			ShutdownTime: &pastTime,
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					Spec: corev1.PodSpec{
 						Containers: []corev1.Container{
@@ -572,6 +579,130 @@ func TestSandboxReconciler_ShutdownTime(t *testing.T) {
 	}
 }
 
+func TestSandboxReconciler_ShutdownPolicy(t *testing.T) {
+	pastTime := metav1.NewTime(time.Now().Add(-1 * time.Hour))
+	futureTime := metav1.NewTime(time.Now().Add(1 * time.Hour))
+	pausedCondition := func(pausedAt time.Time) []metav1.Condition {
+		return []metav1.Condition{{
+			Type:               string(agentsv1alpha1.SandboxConditionPaused),
+			Status:             metav1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(pausedAt),
+		}}
+	}
+	tests := []struct {
+		name           string
+		spec           agentsv1alpha1.SandboxSpec
+		status         agentsv1alpha1.SandboxStatus
+		expectShutdown bool
+		expectDeleted  bool
+		expectPaused   bool
+		expectRequeue  bool
+	}{
+		{
+			name: "delete by default",
+			spec: agentsv1alpha1.SandboxSpec{ShutdownTime: &pastTime},
+			status: agentsv1alpha1.SandboxStatus{
+				Phase: agentsv1alpha1.SandboxRunning,
+			},
+			expectShutdown: true,
+			expectDeleted:  true,
+		},
+		{
+			name: "pause running sandbox",
+			spec: agentsv1alpha1.SandboxSpec{
+				ShutdownTime:   &pastTime,
+				ShutdownPolicy: agentsv1alpha1.SandboxShutdownPause,
+			},
+			status: agentsv1alpha1.SandboxStatus{
+				Phase: agentsv1alpha1.SandboxRunning,
+			},
+			expectShutdown: true,
+			expectPaused:   true,
+		},
+		{
+			name: "delete pending sandbox with pause policy",
+			spec: agentsv1alpha1.SandboxSpec{
+				ShutdownTime:   &pastTime,
+				ShutdownPolicy: agentsv1alpha1.SandboxShutdownPause,
+			},
+			status: agentsv1alpha1.SandboxStatus{
+				Phase: agentsv1alpha1.SandboxPending,
+			},
+			expectShutdown: true,
+			expectDeleted:  true,
+		},
+		{
+			name: "shutdown time ignored while paused",
+			spec: agentsv1alpha1.SandboxSpec{
+				Paused:         true,
+				ShutdownTime:   &pastTime,
+				ShutdownPolicy: agentsv1alpha1.SandboxShutdownPause,
+			},
+			status: agentsv1alpha1.SandboxStatus{
+				Phase:      agentsv1alpha1.SandboxPaused,
+				Conditions: pausedCondition(time.Now()),
+			},
+			expectPaused: true,
+		},
+		{
+			name: "paused retention not expired",
+			spec: agentsv1alpha1.SandboxSpec{
+				Paused:                 true,
+				ShutdownTime:           &pastTime,
+				ShutdownPolicy:         agentsv1alpha1.SandboxShutdownPause,
+				PausedRetentionSeconds: ptr.To[int32](600),
+			},
+			status: agentsv1alpha1.SandboxStatus{
+				Phase:      agentsv1alpha1.SandboxPaused,
+				Conditions: pausedCondition(time.Now()),
+			},
+			expectPaused:  true,
+			expectRequeue: true,
+		},
+		{
+			name: "paused retention expired",
+			spec: agentsv1alpha1.SandboxSpec{
+				Paused:                 true,
+				ShutdownTime:           &futureTime,
+				PausedRetentionSeconds: ptr.To[int32](600),
+			},
+			status: agentsv1alpha1.SandboxStatus{
+				Phase:      agentsv1alpha1.SandboxPaused,
+				Conditions: pausedCondition(time.Now().Add(-time.Hour)),
+			},
+			expectShutdown: true,
+			expectDeleted:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = clientgoscheme.AddToScheme(scheme)
+			_ = agentsv1alpha1.AddToScheme(scheme)
+			sandbox := &agentsv1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{Name: "shutdown-sandbox", Namespace: "default"},
+				Spec:       tt.spec,
+				Status:     tt.status,
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sandbox).Build()
+			reconciler := &SandboxReconciler{Client: c, Scheme: scheme}
+
+			shutdown, requeueAfter, err := reconciler.ensureShutdown(context.Background(), sandbox)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectShutdown, shutdown)
+			assert.Equal(t, tt.expectRequeue, requeueAfter > 0)
+			got := &agentsv1alpha1.Sandbox{}
+			err = c.Get(context.Background(), client.ObjectKeyFromObject(sandbox), got)
+			if tt.expectDeleted {
+				assert.True(t, errors.IsNotFound(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectPaused, got.Spec.Paused)
+		})
+	}
+}
+
 func TestSandboxReconcile_WithVolumeClaimTemplates(t *testing.T) {
 	scheme := runtime.NewScheme()
 	_ = clientgoscheme.AddToScheme(scheme)
@@ -593,7 +724,7 @@ func TestSandboxReconcile_WithVolumeClaimTemplates(t *testing.T) {
 					Namespace: "default",
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -619,7 +750,7 @@ func TestSandboxReconcile_WithVolumeClaimTemplates(t *testing.T) {
 					Namespace: "default",
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -660,7 +791,7 @@ func TestSandboxReconcile_WithVolumeClaimTemplates(t *testing.T) {
 					Namespace: "default",
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -714,7 +845,7 @@ func TestSandboxReconcile_WithVolumeClaimTemplates(t *testing.T) {
 					Namespace: "default",
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -850,3 +981,121 @@ func TestSandboxReconcile_WithVolumeClaimTemplates(t *testing.T) {
 		})
 	}
 }
+
+func TestSandboxReconcile_WithTemplateRef(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = agentsv1alpha1.AddToScheme(scheme)
+
+	podTemplate := &corev1.PodTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "shared-template",
+			Namespace: "default",
+		},
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"app": "shared"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "main", Image: "nginx:latest"}},
+			},
+		},
+	}
+	getSandbox := func(name string) *agentsv1alpha1.Sandbox {
+		return &agentsv1alpha1.Sandbox{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: agentsv1alpha1.SandboxSpec{
+				EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
+					TemplateRef: &agentsv1alpha1.SandboxTemplateRef{Name: "shared-template"},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		sandbox         *agentsv1alpha1.Sandbox
+		objects         []client.Object
+		expectPod       bool
+		expectCondition metav1.ConditionStatus
+		expectReason    string
+	}{
+		{
+			name:            "referenced template found",
+			sandbox:         getSandbox("ref-found"),
+			objects:         []client.Object{podTemplate.DeepCopy()},
+			expectPod:       true,
+			expectCondition: metav1.ConditionTrue,
+			expectReason:    agentsv1alpha1.SandboxTemplateReasonResolved,
+		},
+		{
+			name:            "referenced template not found",
+			sandbox:         getSandbox("ref-not-found"),
+			expectPod:       false,
+			expectCondition: metav1.ConditionFalse,
+			expectReason:    agentsv1alpha1.SandboxTemplateReasonNotFound,
+		},
+		{
+			name: "referenced kind not supported",
+			sandbox: func() *agentsv1alpha1.Sandbox {
+				box := getSandbox("ref-unsupported")
+				box.Spec.TemplateRef.Kind = ptr.To("Deployment")
+				box.Spec.TemplateRef.APIVersion = ptr.To("apps/v1")
+				return box
+			}(),
+			expectPod:       false,
+			expectCondition: metav1.ConditionFalse,
+			expectReason:    agentsv1alpha1.SandboxTemplateReasonUnsupportedKind,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			objects := append([]client.Object{tt.sandbox}, tt.objects...)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+				WithStatusSubresource(&agentsv1alpha1.Sandbox{}).
+				WithIndex(&agentsv1alpha1.Sandbox{}, fieldindex.IndexNameForTemplateRef, fieldindex.TemplateRefIndexFunc).
+				WithObjects(objects...).Build()
+			reconciler := &SandboxReconciler{
+				Client:   fakeClient,
+				Scheme:   scheme,
+				controls: core.NewSandboxControl(fakeClient, record.NewFakeRecorder(10)),
+			}
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tt.sandbox)})
+			assert.NoError(t, err)
+
+			box := &agentsv1alpha1.Sandbox{}
+			assert.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(tt.sandbox), box))
+			assert.Nil(t, box.Spec.Template, "resolved template should not be persisted into sandbox")
+			cond := utils.GetSandboxCondition(&box.Status, string(agentsv1alpha1.SandboxConditionTemplateResolved))
+			assert.NotNil(t, cond)
+			assert.Equal(t, tt.expectCondition, cond.Status)
+			assert.Equal(t, tt.expectReason, cond.Reason)
+
+			pod := &corev1.Pod{}
+			err = fakeClient.Get(ctx, client.ObjectKeyFromObject(tt.sandbox), pod)
+			if !tt.expectPod {
+				assert.True(t, errors.IsNotFound(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "nginx:latest", pod.Spec.Containers[0].Image)
+			assert.Equal(t, "shared", pod.Labels["app"])
+			// the revision of the referenced template equals to the revision of the same inline template
+			inline := box.DeepCopy()
+			inline.Spec.Template = podTemplate.Template.DeepCopy()
+			hash, hashWithoutImageResources := core.HashSandbox(inline)
+			assert.Equal(t, hash, box.Status.UpdateRevision)
+			assert.Equal(t, hash, pod.Labels[agentsv1alpha1.PodLabelTemplateHash])
+			assert.Equal(t, hashWithoutImageResources, box.Annotations[agentsv1alpha1.SandboxHashWithoutImageAndResources])
+
+			// changes of the referenced template enqueue the sandbox
+			requests := reconciler.mapTemplateToSandboxes(templateref.DefaultKind)(ctx, podTemplate)
+			assert.Equal(t, []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(tt.sandbox)}}, requests)
+		})
+	}
+}
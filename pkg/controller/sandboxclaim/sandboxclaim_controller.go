@@ -0,0 +1,532 @@
+/*
+Copyright 2025 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sandboxclaim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/discovery"
+	"github.com/openkruise/agents/pkg/features"
+	"github.com/openkruise/agents/pkg/sandbox-manager/consts"
+	"github.com/openkruise/agents/pkg/utils"
+	utilfeature "github.com/openkruise/agents/pkg/utils/feature"
+	managerutils "github.com/openkruise/agents/pkg/utils/sandbox-manager"
+	"github.com/openkruise/agents/pkg/utils/sandbox-manager/proxyutils"
+	stateutils "github.com/openkruise/agents/pkg/utils/sandboxutils"
+)
+
+func init() {
+	flag.IntVar(&concurrentReconciles, "sandboxclaim-workers", concurrentReconciles, "Max concurrent workers for SandboxClaim controller.")
+}
+
+var (
+	concurrentReconciles = 3
+	controllerKind       = agentsv1alpha1.SandboxClaimControllerKind
+	// retryAfterNoAvailable is the interval to retry pending claims, besides the sandboxes becoming available.
+	retryAfterNoAvailable = 10 * time.Second
+	// initEnvd initializes envd of the bound sandbox with the access token, the same as the E2B API at claim time.
+	initEnvd = func(ctx context.Context, ip string, port int, accessToken string) error {
+		body, err := json.Marshal(map[string]any{"accessToken": accessToken})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/init", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp, err := proxyutils.ProxyRequest(req, "/init", port, ip)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+)
+
+const (
+	// SandboxClaimFinalizer makes sure the bound sandbox is reclaimed before the claim is deleted
+	SandboxClaimFinalizer = "agents.kruise.io/sandbox-claim"
+	// AccessTokenSecretSuffix is appended to the claim name for the Secret keeping the access token of the sandbox
+	AccessTokenSecretSuffix = "-access-token"
+	// AccessTokenSecretKey is the key of the access token in the Secret
+	AccessTokenSecretKey = "token"
+
+	EventSandboxBound     = "SandboxBound"
+	EventSandboxReclaimed = "SandboxReclaimed"
+	EventSandboxLost      = "SandboxLost"
+)
+
+func Add(mgr manager.Manager) error {
+	if !utilfeature.DefaultFeatureGate.Enabled(features.SandboxClaimGate) || !discovery.DiscoverGVK(controllerKind) {
+		return nil
+	}
+	err := (&Reconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr)
+	if err != nil {
+		return err
+	}
+	klog.Infof("start SandboxClaimReconciler success")
+	return nil
+}
+
+// Reconciler binds a SandboxClaim to an available sandbox of the SandboxSet it names
+type Reconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxclaims/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxclaims/finalizers,verbs=update
+// +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxes,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=create;patch
+
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx).WithValues("sandboxclaim", req.NamespacedName)
+	ctx = logf.IntoContext(ctx, log)
+	claim := &agentsv1alpha1.SandboxClaim{}
+	if err := r.Get(ctx, req.NamespacedName, claim); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !claim.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.reclaim(ctx, claim)
+	}
+	if !controllerutil.ContainsFinalizer(claim, SandboxClaimFinalizer) {
+		obj, err := utils.PatchFinalizer(ctx, r.Client, claim, utils.AddFinalizerOpType, SandboxClaimFinalizer)
+		if err != nil {
+			log.Error(err, "failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+		claim = obj.(*agentsv1alpha1.SandboxClaim)
+	}
+
+	newStatus := claim.Status.DeepCopy()
+	newStatus.ObservedGeneration = claim.Generation
+	if newStatus.Phase == "" {
+		newStatus.Phase = agentsv1alpha1.SandboxClaimPending
+	}
+	sbx, err := r.getBoundSandbox(ctx, claim)
+	if err != nil {
+		log.Error(err, "failed to get bound sandbox")
+		return ctrl.Result{}, err
+	}
+
+	var requeueAfter time.Duration
+	var syncErr error
+	switch {
+	case sbx != nil:
+		syncStatusFromSandbox(newStatus, sbx)
+		syncErr = r.syncAccess(ctx, claim, sbx, newStatus)
+	case newStatus.Phase == agentsv1alpha1.SandboxClaimPending:
+		if sbx, err = r.bindSandbox(ctx, claim, newStatus); err != nil {
+			return ctrl.Result{}, err
+		}
+		if sbx == nil {
+			requeueAfter = retryAfterNoAvailable
+		} else {
+			syncErr = r.syncAccess(ctx, claim, sbx, newStatus)
+		}
+	case newStatus.Phase == agentsv1alpha1.SandboxClaimBound:
+		// a bound claim is never bound again, even if the sandbox is lost
+		newStatus.Phase = agentsv1alpha1.SandboxClaimLost
+		newStatus.SandboxIP = ""
+		newStatus.Endpoint = ""
+		setReadyCondition(newStatus, metav1.ConditionFalse, agentsv1alpha1.SandboxClaimReasonSandboxLost,
+			fmt.Sprintf("sandbox %s is not found", newStatus.SandboxName))
+		r.Recorder.Eventf(claim, corev1.EventTypeWarning, EventSandboxLost, "Sandbox %s is lost", newStatus.SandboxName)
+	}
+	if err = r.updateSandboxClaimStatus(ctx, *newStatus, claim); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, syncErr
+}
+
+// getBoundSandbox returns the sandbox bound to claim, it is found by label in case the status failed to be updated
+// after the sandbox was bound.
+func (r *Reconciler) getBoundSandbox(ctx context.Context, claim *agentsv1alpha1.SandboxClaim) (*agentsv1alpha1.Sandbox, error) {
+	if name := claim.Status.SandboxName; name != "" {
+		sbx := &agentsv1alpha1.Sandbox{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: claim.Namespace, Name: name}, sbx)
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return sbx, err
+	}
+	sbxList := &agentsv1alpha1.SandboxList{}
+	if err := r.List(ctx, sbxList, client.InNamespace(claim.Namespace),
+		client.MatchingLabels{agentsv1alpha1.LabelSandboxClaim: claim.Name}); err != nil {
+		return nil, err
+	}
+	for i := range sbxList.Items {
+		sbx := &sbxList.Items[i]
+		if sbx.Annotations[agentsv1alpha1.AnnotationLock] == string(claim.UID) {
+			return sbx, nil
+		}
+	}
+	return nil, nil
+}
+
+// bindSandbox claims an available sandbox of the SandboxSet with the same lock and claimed-label protocol of the
+// sandbox-manager, nil is returned if there is no available sandbox.
+func (r *Reconciler) bindSandbox(ctx context.Context, claim *agentsv1alpha1.SandboxClaim,
+	newStatus *agentsv1alpha1.SandboxClaimStatus) (*agentsv1alpha1.Sandbox, error) {
+	log := logf.FromContext(ctx)
+	if err := validateMetadata(claim); err != nil {
+		setReadyCondition(newStatus, metav1.ConditionFalse, agentsv1alpha1.SandboxClaimReasonInvalidSpec, err.Error())
+		return nil, nil
+	}
+	candidates, err := r.listAvailableSandboxes(ctx, claim)
+	if err != nil {
+		log.Error(err, "failed to list available sandboxes")
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		log.Info("no available sandbox to claim", "sandboxset", claim.Spec.SandboxSetName)
+		setReadyCondition(newStatus, metav1.ConditionFalse, agentsv1alpha1.SandboxClaimReasonNoAvailableSandbox,
+			fmt.Sprintf("no available sandboxes in sandboxset %s", claim.Spec.SandboxSetName))
+		return nil, nil
+	}
+	// pick randomly to reduce conflicts with the sandbox-manager and other workers
+	sbx := candidates[rand.Intn(len(candidates))].DeepCopy()
+	claimTime := metav1.Now()
+	modifyPickedSandbox(claim, sbx, claimTime.Time, uuid.NewString())
+	if err = r.Update(ctx, sbx); err != nil {
+		// the sandbox may be claimed by others in the meantime
+		log.Error(err, "failed to claim sandbox", "sandbox", klog.KObj(sbx))
+		return nil, err
+	}
+	log.Info("sandbox claimed", "sandbox", klog.KObj(sbx))
+	r.Recorder.Eventf(claim, corev1.EventTypeNormal, EventSandboxBound, "Sandbox %s is bound", klog.KObj(sbx))
+	newStatus.ClaimTime = &claimTime
+	syncStatusFromSandbox(newStatus, sbx)
+	return sbx, nil
+}
+
+func (r *Reconciler) listAvailableSandboxes(ctx context.Context, claim *agentsv1alpha1.SandboxClaim) ([]*agentsv1alpha1.Sandbox, error) {
+	sbxList := &agentsv1alpha1.SandboxList{}
+	if err := r.List(ctx, sbxList, client.InNamespace(claim.Namespace), client.MatchingLabels{
+		agentsv1alpha1.LabelSandboxPool:      claim.Spec.SandboxSetName,
+		agentsv1alpha1.LabelSandboxIsClaimed: "false",
+	}, client.UnsafeDisableDeepCopy); err != nil {
+		return nil, err
+	}
+	var candidates []*agentsv1alpha1.Sandbox
+	for i := range sbxList.Items {
+		sbx := &sbxList.Items[i]
+		if state, _ := stateutils.GetSandboxState(sbx); state != agentsv1alpha1.SandboxStateAvailable {
+			continue
+		}
+		if sbx.Annotations[agentsv1alpha1.AnnotationLock] != "" {
+			continue
+		}
+		candidates = append(candidates, sbx)
+	}
+	return candidates, nil
+}
+
+// modifyPickedSandbox applies the claim to the picked sandbox, the same as Pool.ClaimSandbox of the sandbox-manager,
+// and sets the envd access token and URL the same as the E2B API.
+func modifyPickedSandbox(claim *agentsv1alpha1.SandboxClaim, sbx *agentsv1alpha1.Sandbox, now time.Time, accessToken string) {
+	if claim.Spec.Image != "" && sbx.Spec.Template != nil {
+		sbx.Spec.Template.Spec.Containers[0].Image = claim.Spec.Image
+	}
+	if sbx.Labels == nil {
+		sbx.Labels = map[string]string{}
+	}
+	if sbx.Annotations == nil {
+		sbx.Annotations = map[string]string{}
+	}
+	for k, v := range claim.Spec.Labels {
+		sbx.Labels[k] = v
+	}
+	for k, v := range claim.Spec.Annotations {
+		sbx.Annotations[k] = v
+	}
+	timeout := claim.Spec.TimeoutSeconds
+	if timeout == nil {
+		if seconds, err := strconv.Atoi(sbx.Annotations[agentsv1alpha1.AnnotationDefaultTimeoutSeconds]); err == nil && seconds > 0 {
+			timeout = ptr.To(int32(seconds))
+		}
+	}
+	if timeout != nil {
+		sbx.Spec.ShutdownTime = ptr.To(metav1.NewTime(now.Add(time.Duration(*timeout) * time.Second)))
+	}
+	// claim sandbox
+	sbx.SetOwnerReferences([]metav1.OwnerReference{}) // make SandboxSet scale up
+	sbx.Labels[agentsv1alpha1.LabelSandboxIsClaimed] = "true"
+	sbx.Labels[agentsv1alpha1.LabelSandboxClaim] = claim.Name
+	sbx.Annotations[agentsv1alpha1.AnnotationClaimTime] = now.Format(time.RFC3339)
+	sbx.Annotations[agentsv1alpha1.AnnotationEnvdAccessToken] = accessToken
+	sbx.Annotations[agentsv1alpha1.AnnotationEnvdURL] = envdEndpoint(sbx)
+	if sbx.Annotations[agentsv1alpha1.AnnotationShouldInitEnvd] == agentsv1alpha1.True {
+		// envd is inited in this pod once the claim is ready, and re-inited in the recreated ones
+		sbx.Annotations[agentsv1alpha1.AnnotationInitedPod] = string(sbx.Status.PodInfo.PodUID)
+	}
+	owner := claim.Spec.Owner
+	if owner == "" {
+		owner = claim.Name
+	}
+	managerutils.LockSandbox(sbx, string(claim.UID), owner)
+}
+
+// reclaim deletes or releases the bound sandbox according to the reclaim policy, and then removes the finalizer.
+func (r *Reconciler) reclaim(ctx context.Context, claim *agentsv1alpha1.SandboxClaim) error {
+	log := logf.FromContext(ctx)
+	if !controllerutil.ContainsFinalizer(claim, SandboxClaimFinalizer) {
+		return nil
+	}
+	sbx, err := r.getBoundSandbox(ctx, claim)
+	if err != nil {
+		return err
+	}
+	if sbx != nil {
+		if claim.Spec.ReclaimPolicy == agentsv1alpha1.SandboxClaimReclaimRetain {
+			clone := sbx.DeepCopy()
+			delete(clone.Labels, agentsv1alpha1.LabelSandboxClaim)
+			err = r.Update(ctx, clone)
+		} else {
+			err = client.IgnoreNotFound(r.Delete(ctx, sbx))
+		}
+		if err != nil {
+			log.Error(err, "failed to reclaim sandbox", "sandbox", klog.KObj(sbx), "policy", claim.Spec.ReclaimPolicy)
+			return err
+		}
+		log.Info("sandbox reclaimed", "sandbox", klog.KObj(sbx), "policy", claim.Spec.ReclaimPolicy)
+		r.Recorder.Eventf(claim, corev1.EventTypeNormal, EventSandboxReclaimed, "Sandbox %s reclaimed", klog.KObj(sbx))
+	}
+	_, err = utils.PatchFinalizer(ctx, r.Client, claim, utils.RemoveFinalizerOpType, SandboxClaimFinalizer)
+	return err
+}
+
+// syncAccess keeps the access token of the bound sandbox in a Secret owned by the claim, and publishes the envd
+// endpoint once the sandbox is ready, initializing envd first if the sandbox requires it.
+func (r *Reconciler) syncAccess(ctx context.Context, claim *agentsv1alpha1.SandboxClaim, sbx *agentsv1alpha1.Sandbox,
+	newStatus *agentsv1alpha1.SandboxClaimStatus) error {
+	log := logf.FromContext(ctx)
+	accessToken := sbx.Annotations[agentsv1alpha1.AnnotationEnvdAccessToken]
+	if accessToken == "" {
+		return nil
+	}
+	if newStatus.AccessTokenSecretRef == nil {
+		if err := r.saveAccessToken(ctx, claim, accessToken); err != nil {
+			log.Error(err, "failed to save access token")
+			return err
+		}
+		newStatus.AccessTokenSecretRef = &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: claim.Name + AccessTokenSecretSuffix},
+			Key:                  AccessTokenSecretKey,
+		}
+	}
+	if stateutils.IsSandboxReiniting(sbx) && stateutils.IsSandboxReady(sbx) && !sbx.Spec.Paused {
+		return r.reinitSandbox(ctx, sbx, newStatus, accessToken)
+	}
+	if !meta.IsStatusConditionTrue(newStatus.Conditions, agentsv1alpha1.SandboxClaimConditionReady) {
+		return nil
+	}
+	endpoint := envdEndpoint(sbx)
+	if newStatus.Endpoint == endpoint {
+		return nil
+	}
+	if newStatus.Endpoint == "" && sbx.Annotations[agentsv1alpha1.AnnotationShouldInitEnvd] == agentsv1alpha1.True {
+		port := int(stateutils.GetPortByName(sbx.Annotations, agentsv1alpha1.SandboxPortNameEnvd, agentsv1alpha1.DefaultEnvdPort))
+		if err := initEnvd(ctx, newStatus.SandboxIP, port, accessToken); err != nil {
+			log.Error(err, "failed to init envd", "sandbox", klog.KObj(sbx))
+			setReadyCondition(newStatus, metav1.ConditionFalse, agentsv1alpha1.SandboxClaimReasonEnvdInitFailed, err.Error())
+			return err
+		}
+		log.Info("envd inited", "sandbox", klog.KObj(sbx))
+	}
+	newStatus.Endpoint = endpoint
+	return nil
+}
+
+// reinitSandbox replays the envd init in the recreated pod of the bound sandbox, e.g. resumed or restarted, and then
+// marks the pod inited, which makes the sandbox running again.
+func (r *Reconciler) reinitSandbox(ctx context.Context, sbx *agentsv1alpha1.Sandbox, newStatus *agentsv1alpha1.SandboxClaimStatus,
+	accessToken string) error {
+	log := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(sbx))
+	port := int(stateutils.GetPortByName(sbx.Annotations, agentsv1alpha1.SandboxPortNameEnvd, agentsv1alpha1.DefaultEnvdPort))
+	if err := initEnvd(ctx, sbx.Status.PodInfo.PodIP, port, accessToken); err != nil {
+		log.Error(err, "failed to re-init envd")
+		setReadyCondition(newStatus, metav1.ConditionFalse, agentsv1alpha1.SandboxClaimReasonEnvdInitFailed, err.Error())
+		return err
+	}
+	clone := sbx.DeepCopy()
+	clone.Annotations[agentsv1alpha1.AnnotationInitedPod] = string(sbx.Status.PodInfo.PodUID)
+	clone.Annotations[agentsv1alpha1.AnnotationEnvdURL] = envdEndpoint(sbx)
+	if err := r.Patch(ctx, clone, client.MergeFrom(sbx)); err != nil {
+		log.Error(err, "failed to mark the pod inited")
+		return err
+	}
+	log.Info("envd re-inited")
+	return nil
+}
+
+// saveAccessToken creates the Secret of the access token, which is garbage collected with the claim. The Secret is
+// patched if it exists, e.g., the status failed to be updated after it was created, so that secrets are not cached.
+func (r *Reconciler) saveAccessToken(ctx context.Context, claim *agentsv1alpha1.SandboxClaim, accessToken string) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: claim.Namespace, Name: claim.Name + AccessTokenSecretSuffix},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{AccessTokenSecretKey: []byte(accessToken)},
+	}
+	if err := controllerutil.SetControllerReference(claim, secret, r.Scheme); err != nil {
+		return err
+	}
+	err := r.Create(ctx, secret.DeepCopy())
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return r.Patch(ctx, secret, client.Merge)
+}
+
+// envdEndpoint returns the URL of envd in the sandbox.
+func envdEndpoint(sbx *agentsv1alpha1.Sandbox) string {
+	port := stateutils.GetPortByName(sbx.Annotations, agentsv1alpha1.SandboxPortNameEnvd, agentsv1alpha1.DefaultEnvdPort)
+	return fmt.Sprintf("http://%s:%d", sbx.Status.PodInfo.PodIP, port)
+}
+
+func syncStatusFromSandbox(newStatus *agentsv1alpha1.SandboxClaimStatus, sbx *agentsv1alpha1.Sandbox) {
+	newStatus.Phase = agentsv1alpha1.SandboxClaimBound
+	newStatus.SandboxName = sbx.Name
+	newStatus.SandboxID = stateutils.GetSandboxID(sbx)
+	newStatus.SandboxIP = sbx.Status.PodInfo.PodIP
+	state, reason := stateutils.GetSandboxState(sbx)
+	switch state {
+	case agentsv1alpha1.SandboxStateRunning:
+		setReadyCondition(newStatus, metav1.ConditionTrue, agentsv1alpha1.SandboxClaimReasonSandboxReady, "")
+	case agentsv1alpha1.SandboxStateDead:
+		newStatus.Phase = agentsv1alpha1.SandboxClaimLost
+		newStatus.Endpoint = ""
+		setReadyCondition(newStatus, metav1.ConditionFalse, agentsv1alpha1.SandboxClaimReasonSandboxLost, reason)
+	default:
+		setReadyCondition(newStatus, metav1.ConditionFalse, agentsv1alpha1.SandboxClaimReasonSandboxNotReady, reason)
+	}
+}
+
+func setReadyCondition(newStatus *agentsv1alpha1.SandboxClaimStatus, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
+		Type:    agentsv1alpha1.SandboxClaimConditionReady,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// validateMetadata forbids the claim from overriding the keys managed by the controllers, the same as the metadata
+// of the E2B API.
+func validateMetadata(claim *agentsv1alpha1.SandboxClaim) error {
+	for _, m := range []map[string]string{claim.Spec.Labels, claim.Spec.Annotations} {
+		for k := range m {
+			if strings.HasPrefix(k, agentsv1alpha1.InternalPrefix) || strings.HasPrefix(k, agentsv1alpha1.E2BPrefix) {
+				return fmt.Errorf("forbidden metadata key %s", k)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) updateSandboxClaimStatus(ctx context.Context, newStatus agentsv1alpha1.SandboxClaimStatus, claim *agentsv1alpha1.SandboxClaim) error {
+	log := logf.FromContext(ctx).V(consts.DebugLogLevel)
+	if reflect.DeepEqual(claim.Status, newStatus) {
+		return nil
+	}
+	clone := claim.DeepCopy()
+	clone.Status = newStatus
+	if err := r.Status().Update(ctx, clone); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		log.Error(err, "update sandboxclaim status failed")
+		return err
+	}
+	log.Info("update sandboxclaim status success", "status", utils.DumpJson(newStatus))
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	controllerName := "sandboxclaim-controller"
+	r.Recorder = mgr.GetEventRecorderFor(controllerName)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		WithOptions(controller.Options{MaxConcurrentReconciles: concurrentReconciles}).
+		Watches(&agentsv1alpha1.SandboxClaim{}, &handler.EnqueueRequestForObject{}).
+		Watches(&agentsv1alpha1.Sandbox{}, handler.EnqueueRequestsFromMapFunc(r.mapSandboxToClaims)).
+		Complete(r)
+}
+
+// mapSandboxToClaims enqueues the claim bound to the sandbox, or the pending claims of its pool if it is available.
+func (r *Reconciler) mapSandboxToClaims(ctx context.Context, obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	if name := labels[agentsv1alpha1.LabelSandboxClaim]; name != "" {
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: obj.GetNamespace(), Name: name}}}
+	}
+	sbx, ok := obj.(*agentsv1alpha1.Sandbox)
+	if !ok || labels[agentsv1alpha1.LabelSandboxPool] == "" {
+		return nil
+	}
+	if state, _ := stateutils.GetSandboxState(sbx); state != agentsv1alpha1.SandboxStateAvailable {
+		return nil
+	}
+	claimList := &agentsv1alpha1.SandboxClaimList{}
+	if err := r.List(ctx, claimList, client.InNamespace(obj.GetNamespace())); err != nil {
+		logf.FromContext(ctx).Error(err, "failed to list sandboxclaims")
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range claimList.Items {
+		claim := &claimList.Items[i]
+		if claim.Spec.SandboxSetName == labels[agentsv1alpha1.LabelSandboxPool] &&
+			(claim.Status.Phase == "" || claim.Status.Phase == agentsv1alpha1.SandboxClaimPending) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(claim)})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,335 @@
+/*
+Copyright 2025 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sandboxclaim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+)
+
+func newTestReconciler(objs ...client.Object) *Reconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = agentsv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&agentsv1alpha1.SandboxClaim{}, &agentsv1alpha1.Sandbox{}).
+		Build()
+	return &Reconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(100)}
+}
+
+func newSandboxClaim() *agentsv1alpha1.SandboxClaim {
+	return &agentsv1alpha1.SandboxClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "claim", Namespace: "default", UID: types.UID("claim-uid"), Generation: 1},
+		Spec: agentsv1alpha1.SandboxClaimSpec{
+			SandboxSetName: "pool",
+			TimeoutSeconds: ptr.To[int32](300),
+			Image:          "new-image",
+			Labels:         map[string]string{"foo": "bar"},
+		},
+	}
+}
+
+// newAvailableSandbox returns a ready sandbox of the SandboxSet pool.
+func newAvailableSandbox(name string) *agentsv1alpha1.Sandbox {
+	sbs := &agentsv1alpha1.SandboxSet{ObjectMeta: metav1.ObjectMeta{Name: "pool", Namespace: "default", UID: "pool-uid"}}
+	return &agentsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels: map[string]string{
+				agentsv1alpha1.LabelSandboxPool:      "pool",
+				agentsv1alpha1.LabelSandboxIsClaimed: "false",
+			},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(sbs, agentsv1alpha1.SandboxSetControllerKind)},
+		},
+		Spec: agentsv1alpha1.SandboxSpec{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
+				Template: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "old-image"}}},
+				},
+			},
+		},
+		Status: agentsv1alpha1.SandboxStatus{
+			Phase:   agentsv1alpha1.SandboxRunning,
+			PodInfo: agentsv1alpha1.PodInfo{PodIP: "1.2.3.4"},
+			Conditions: []metav1.Condition{{
+				Type:   string(agentsv1alpha1.SandboxConditionReady),
+				Status: metav1.ConditionTrue,
+			}},
+		},
+	}
+}
+
+func reconcileAndGet(t *testing.T, r *Reconciler) (ctrl.Result, *agentsv1alpha1.SandboxClaim) {
+	key := types.NamespacedName{Namespace: "default", Name: "claim"}
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	assert.NoError(t, err)
+	claim := &agentsv1alpha1.SandboxClaim{}
+	if err = r.Get(context.Background(), key, claim); apierrors.IsNotFound(err) {
+		return result, nil
+	}
+	assert.NoError(t, err)
+	return result, claim
+}
+
+func TestReconcile_Bind(t *testing.T) {
+	r := newTestReconciler(newSandboxClaim(), newAvailableSandbox("sbx"))
+	_, claim := reconcileAndGet(t, r)
+	assert.Contains(t, claim.Finalizers, SandboxClaimFinalizer)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimBound, claim.Status.Phase)
+	assert.Equal(t, "sbx", claim.Status.SandboxName)
+	assert.Equal(t, "default--sbx", claim.Status.SandboxID)
+	assert.Equal(t, "1.2.3.4", claim.Status.SandboxIP)
+	assert.NotNil(t, claim.Status.ClaimTime)
+
+	sbx := &agentsv1alpha1.Sandbox{}
+	assert.NoError(t, r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "sbx"}, sbx))
+	assert.Empty(t, sbx.OwnerReferences)
+	assert.Equal(t, "true", sbx.Labels[agentsv1alpha1.LabelSandboxIsClaimed])
+	assert.Equal(t, "claim", sbx.Labels[agentsv1alpha1.LabelSandboxClaim])
+	assert.Equal(t, "bar", sbx.Labels["foo"])
+	assert.Equal(t, "claim-uid", sbx.Annotations[agentsv1alpha1.AnnotationLock])
+	assert.Equal(t, "claim", sbx.Annotations[agentsv1alpha1.AnnotationOwner])
+	assert.Equal(t, "new-image", sbx.Spec.Template.Spec.Containers[0].Image)
+	assert.NotNil(t, sbx.Spec.ShutdownTime)
+	assert.WithinDuration(t, time.Now().Add(300*time.Second), sbx.Spec.ShutdownTime.Time, 10*time.Second)
+	accessToken := sbx.Annotations[agentsv1alpha1.AnnotationEnvdAccessToken]
+	assert.NotEmpty(t, accessToken)
+	assert.Equal(t, "http://1.2.3.4:49983", sbx.Annotations[agentsv1alpha1.AnnotationEnvdURL])
+
+	// the access token is kept in a Secret owned by the claim
+	assert.Equal(t, "http://1.2.3.4:49983", claim.Status.Endpoint)
+	assert.Equal(t, &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "claim-access-token"},
+		Key:                  AccessTokenSecretKey,
+	}, claim.Status.AccessTokenSecretRef)
+	secret := &corev1.Secret{}
+	assert.NoError(t, r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "claim-access-token"}, secret))
+	assert.Equal(t, accessToken, string(secret.Data[AccessTokenSecretKey]))
+	assert.True(t, metav1.IsControlledBy(secret, claim))
+
+	// the claimed sandbox becomes running, and the claim is ready
+	_, claim = reconcileAndGet(t, r)
+	cond := meta.FindStatusCondition(claim.Status.Conditions, agentsv1alpha1.SandboxClaimConditionReady)
+	assert.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionTrue, cond.Status)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimReasonSandboxReady, cond.Reason)
+}
+
+func TestReconcile_InitEnvd(t *testing.T) {
+	origin := initEnvd
+	defer func() { initEnvd = origin }()
+	var initErr error
+	var inited []string
+	initEnvd = func(_ context.Context, ip string, port int, accessToken string) error {
+		inited = append(inited, fmt.Sprintf("%s:%d/%s", ip, port, accessToken))
+		return initErr
+	}
+
+	sbx := newAvailableSandbox("sbx")
+	sbx.Annotations = map[string]string{agentsv1alpha1.AnnotationShouldInitEnvd: agentsv1alpha1.True}
+	sbx.Status.PodInfo.PodUID = "pod-1"
+	r := newTestReconciler(newSandboxClaim(), sbx)
+	key := types.NamespacedName{Namespace: "default", Name: "claim"}
+
+	// the endpoint is not published until envd is inited
+	initErr = errors.New("connection refused")
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	assert.Error(t, err)
+	claim := &agentsv1alpha1.SandboxClaim{}
+	assert.NoError(t, r.Get(context.Background(), key, claim))
+	assert.Equal(t, agentsv1alpha1.SandboxClaimBound, claim.Status.Phase)
+	assert.Empty(t, claim.Status.Endpoint)
+	assert.NotNil(t, claim.Status.AccessTokenSecretRef)
+	cond := meta.FindStatusCondition(claim.Status.Conditions, agentsv1alpha1.SandboxClaimConditionReady)
+	assert.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimReasonEnvdInitFailed, cond.Reason)
+
+	initErr = nil
+	_, claim = reconcileAndGet(t, r)
+	assert.Equal(t, "http://1.2.3.4:49983", claim.Status.Endpoint)
+	cond = meta.FindStatusCondition(claim.Status.Conditions, agentsv1alpha1.SandboxClaimConditionReady)
+	assert.Equal(t, metav1.ConditionTrue, cond.Status)
+	secret := &corev1.Secret{}
+	assert.NoError(t, r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "claim-access-token"}, secret))
+	accessToken := string(secret.Data[AccessTokenSecretKey])
+	assert.Equal(t, []string{"1.2.3.4:49983/" + accessToken, "1.2.3.4:49983/" + accessToken}, inited)
+
+	// envd is inited only once
+	_, _ = reconcileAndGet(t, r)
+	assert.Len(t, inited, 2)
+
+	// the pod is recreated, e.g. resumed by kubectl, and the sandbox is not running until envd is re-inited
+	sbx = &agentsv1alpha1.Sandbox{}
+	assert.NoError(t, r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "sbx"}, sbx))
+	assert.Equal(t, "pod-1", sbx.Annotations[agentsv1alpha1.AnnotationInitedPod])
+	sbx.Status.PodInfo = agentsv1alpha1.PodInfo{PodIP: "5.6.7.8", PodUID: "pod-2"}
+	assert.NoError(t, r.Status().Update(context.Background(), sbx))
+	_, claim = reconcileAndGet(t, r)
+	assert.Equal(t, []string{"1.2.3.4:49983/" + accessToken, "1.2.3.4:49983/" + accessToken, "5.6.7.8:49983/" + accessToken}, inited)
+	cond = meta.FindStatusCondition(claim.Status.Conditions, agentsv1alpha1.SandboxClaimConditionReady)
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimReasonSandboxNotReady, cond.Reason)
+	assert.NoError(t, r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "sbx"}, sbx))
+	assert.Equal(t, "pod-2", sbx.Annotations[agentsv1alpha1.AnnotationInitedPod])
+	assert.Equal(t, "http://5.6.7.8:49983", sbx.Annotations[agentsv1alpha1.AnnotationEnvdURL])
+
+	_, claim = reconcileAndGet(t, r)
+	assert.Len(t, inited, 3)
+	assert.Equal(t, "http://5.6.7.8:49983", claim.Status.Endpoint)
+	cond = meta.FindStatusCondition(claim.Status.Conditions, agentsv1alpha1.SandboxClaimConditionReady)
+	assert.Equal(t, metav1.ConditionTrue, cond.Status)
+}
+
+func TestReconcile_NoAvailableSandbox(t *testing.T) {
+	locked := newAvailableSandbox("locked")
+	locked.Annotations = map[string]string{agentsv1alpha1.AnnotationLock: "other"}
+	creating := newAvailableSandbox("creating")
+	creating.Status.Conditions[0].Status = metav1.ConditionFalse
+	other := newAvailableSandbox("other")
+	other.Labels[agentsv1alpha1.LabelSandboxPool] = "other"
+
+	r := newTestReconciler(newSandboxClaim(), locked, creating, other)
+	result, claim := reconcileAndGet(t, r)
+	assert.Equal(t, retryAfterNoAvailable, result.RequeueAfter)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimPending, claim.Status.Phase)
+	assert.Empty(t, claim.Status.SandboxName)
+	cond := meta.FindStatusCondition(claim.Status.Conditions, agentsv1alpha1.SandboxClaimConditionReady)
+	assert.NotNil(t, cond)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimReasonNoAvailableSandbox, cond.Reason)
+}
+
+func TestReconcile_InvalidSpec(t *testing.T) {
+	claim := newSandboxClaim()
+	claim.Spec.Annotations = map[string]string{agentsv1alpha1.AnnotationLock: "hack"}
+	r := newTestReconciler(claim, newAvailableSandbox("sbx"))
+	_, claim = reconcileAndGet(t, r)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimPending, claim.Status.Phase)
+	cond := meta.FindStatusCondition(claim.Status.Conditions, agentsv1alpha1.SandboxClaimConditionReady)
+	assert.NotNil(t, cond)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimReasonInvalidSpec, cond.Reason)
+
+	sbx := &agentsv1alpha1.Sandbox{}
+	assert.NoError(t, r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "sbx"}, sbx))
+	assert.Equal(t, "false", sbx.Labels[agentsv1alpha1.LabelSandboxIsClaimed])
+}
+
+func TestReconcile_Lost(t *testing.T) {
+	r := newTestReconciler(newSandboxClaim(), newAvailableSandbox("sbx"))
+	_, claim := reconcileAndGet(t, r)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimBound, claim.Status.Phase)
+
+	assert.NoError(t, r.Delete(context.Background(), &agentsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sbx"}}))
+	// a lost claim is never bound again
+	assert.NoError(t, r.Create(context.Background(), newAvailableSandbox("another")))
+	_, claim = reconcileAndGet(t, r)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimLost, claim.Status.Phase)
+	assert.Equal(t, "sbx", claim.Status.SandboxName)
+	_, claim = reconcileAndGet(t, r)
+	assert.Equal(t, agentsv1alpha1.SandboxClaimLost, claim.Status.Phase)
+	assert.Equal(t, "sbx", claim.Status.SandboxName)
+}
+
+func TestReconcile_Reclaim(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       agentsv1alpha1.SandboxClaimReclaimPolicy
+		expectExists bool
+	}{
+		{
+			name:   "delete by default",
+			policy: "",
+		},
+		{
+			name:   "delete",
+			policy: agentsv1alpha1.SandboxClaimReclaimDelete,
+		},
+		{
+			name:         "retain",
+			policy:       agentsv1alpha1.SandboxClaimReclaimRetain,
+			expectExists: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim := newSandboxClaim()
+			claim.Spec.ReclaimPolicy = tt.policy
+			r := newTestReconciler(claim, newAvailableSandbox("sbx"))
+			_, claim = reconcileAndGet(t, r)
+			assert.Equal(t, agentsv1alpha1.SandboxClaimBound, claim.Status.Phase)
+
+			assert.NoError(t, r.Delete(context.Background(), claim))
+			_, claim = reconcileAndGet(t, r)
+			assert.Nil(t, claim)
+
+			sbx := &agentsv1alpha1.Sandbox{}
+			err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "sbx"}, sbx)
+			if !tt.expectExists {
+				assert.True(t, apierrors.IsNotFound(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotContains(t, sbx.Labels, agentsv1alpha1.LabelSandboxClaim)
+			// the retained sandbox is still claimed and never returns to the pool
+			assert.Equal(t, "true", sbx.Labels[agentsv1alpha1.LabelSandboxIsClaimed])
+		})
+	}
+}
+
+func TestMapSandboxToClaims(t *testing.T) {
+	pending := newSandboxClaim()
+	bound := newSandboxClaim()
+	bound.Name = "bound"
+	bound.Status.Phase = agentsv1alpha1.SandboxClaimBound
+	otherPool := newSandboxClaim()
+	otherPool.Name = "other-pool"
+	otherPool.Spec.SandboxSetName = "other"
+	r := newTestReconciler(pending, bound, otherPool)
+
+	requests := r.mapSandboxToClaims(context.Background(), newAvailableSandbox("sbx"))
+	assert.Equal(t, []ctrl.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "claim"}}}, requests)
+
+	claimed := newAvailableSandbox("claimed")
+	claimed.Labels[agentsv1alpha1.LabelSandboxClaim] = "bound"
+	requests = r.mapSandboxToClaims(context.Background(), claimed)
+	assert.Equal(t, []ctrl.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "bound"}}}, requests)
+
+	creating := newAvailableSandbox("creating")
+	creating.Status.Conditions[0].Status = metav1.ConditionFalse
+	assert.Empty(t, r.mapSandboxToClaims(context.Background(), creating))
+}
@@ -34,7 +34,10 @@ func (e *SandboxEventHandler) Update(ctx context.Context, evt event.TypedUpdateE
 	}
 	req, ok := getSandboxSetController(evt.ObjectOld)
 	if !ok {
-		return
+		// the usage of the pool changes with the claimed sandboxes
+		if req, ok = getSandboxSetOfClaimed(evt.ObjectNew); !ok {
+			return
+		}
 	}
 	oldSbx, ok := evt.ObjectOld.(*agentsv1alpha1.Sandbox)
 	if !ok {
@@ -67,6 +70,8 @@ func (e *SandboxEventHandler) Delete(_ context.Context, evt event.TypedDeleteEve
 	if req, ok := getSandboxSetController(evt.Object); ok {
 		scaleDownExpectation.ObserveScale(req.String(), expectations.Delete, evt.Object.GetName())
 		w.Add(req)
+	} else if req, ok = getSandboxSetOfClaimed(evt.Object); ok {
+		w.Add(req)
 	}
 }
 
@@ -98,3 +103,17 @@ func getSandboxSetController(obj metav1.Object) (reconcile.Request, bool) {
 	}
 	return req, true
 }
+
+// getSandboxSetOfClaimed returns the sandboxset a claimed sandbox was picked from.
+func getSandboxSetOfClaimed(obj metav1.Object) (reconcile.Request, bool) {
+	if obj == nil {
+		return reconcile.Request{}, false
+	}
+	labels := obj.GetLabels()
+	if labels[agentsv1alpha1.LabelSandboxIsClaimed] != "true" || labels[agentsv1alpha1.LabelSandboxPool] == "" {
+		return reconcile.Request{}, false
+	}
+	return reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: labels[agentsv1alpha1.LabelSandboxPool]},
+	}, true
+}
@@ -232,6 +232,21 @@ func TestSandboxEventHandler_Delete(t *testing.T) {
 			hasExpectation:   false,
 			shouldAddToQueue: false,
 		},
+		{
+			name: "claimed from sandboxset",
+			sandbox: &agentsv1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sandbox",
+					Namespace: "default",
+					Labels: map[string]string{
+						agentsv1alpha1.LabelSandboxPool:      sbs.Name,
+						agentsv1alpha1.LabelSandboxIsClaimed: "true",
+					},
+				},
+			},
+			hasExpectation:   false,
+			shouldAddToQueue: true,
+		},
 	}
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"hash"
 	"hash/fnv"
+	"reflect"
 	"strconv"
 
 	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
@@ -18,11 +19,19 @@ import (
 	"k8s.io/apimachinery/pkg/util/rand"
 )
 
+// revisionAnnotations are the annotations of SandboxSet copied into the created sandboxes, see templateref.SetE2BDefaults.
+var revisionAnnotations = []string{
+	agentsv1alpha1.AnnotationExposedPorts,
+	agentsv1alpha1.AnnotationIdleTimeoutSeconds,
+	agentsv1alpha1.AnnotationExtendTimeoutOnActivity,
+}
+
 // getPatch returns a strategic merge patch that can be applied to restore a StatefulSet to a
-// previous version. If the returned error is nil the patch is valid. The current state that we save is just the
-// PodSpecTemplate. We can modify this later to encompass more state (or less) and remain compatible with previously
-// recorded patches.
-func (r *Reconciler) getPatch(set *agentsv1alpha1.SandboxSet) ([]byte, error) {
+// previous version. If the returned error is nil the patch is valid. The current state that we save is the
+// PodSpecTemplate, and everything else copied into the created sandboxes: the VolumeClaimTemplates, the
+// PersistentContents, the E2B defaults of the SandboxTemplate and the annotations of revisionAnnotations. The latter
+// are recorded only if set, so that the revisions of the SandboxSets without them stay the same.
+func (r *Reconciler) getPatch(set *agentsv1alpha1.SandboxSet, e2b *agentsv1alpha1.SandboxTemplateE2B) ([]byte, error) {
 	str, err := runtime.Encode(r.Codec, set)
 	if err != nil {
 		return nil, err
@@ -38,7 +47,24 @@ func (r *Reconciler) getPatch(set *agentsv1alpha1.SandboxSet) ([]byte, error) {
 	template := spec["template"].(map[string]interface{})
 	specCopy["template"] = template
 	template["$patch"] = "replace"
+	for _, key := range []string{"volumeClaimTemplates", "persistentContents"} {
+		if value, ok := spec[key]; ok {
+			specCopy[key] = value
+		}
+	}
 	objCopy["spec"] = specCopy
+	annotations := make(map[string]interface{})
+	for _, key := range revisionAnnotations {
+		if value, ok := set.Annotations[key]; ok {
+			annotations[key] = value
+		}
+	}
+	if len(annotations) > 0 {
+		objCopy["metadata"] = map[string]interface{}{"annotations": annotations}
+	}
+	if e2b != nil && !reflect.DeepEqual(*e2b, agentsv1alpha1.SandboxTemplateE2B{}) {
+		objCopy["e2b"] = e2b
+	}
 	patch, err := json.Marshal(objCopy)
 	return patch, err
 }
@@ -22,6 +22,7 @@ import (
 	"flag"
 	"fmt"
 	"reflect"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -38,6 +39,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
 	"github.com/openkruise/agents/pkg/discovery"
@@ -49,6 +51,7 @@ import (
 	"github.com/openkruise/agents/pkg/utils/fieldindex"
 	managerutils "github.com/openkruise/agents/pkg/utils/sandbox-manager"
 	stateutils "github.com/openkruise/agents/pkg/utils/sandboxutils"
+	"github.com/openkruise/agents/pkg/utils/templateref"
 )
 
 func init() {
@@ -90,12 +93,18 @@ const (
 	EventCreateSandboxFailed  = "CreateSandboxFailed"
 	EventSandboxScaledDown    = "SandboxScaledDown"
 	EventFailedSandboxDeleted = "FailedSandboxDeleted"
+	EventSandboxSetAutoScaled = "AutoScaled"
+	EventSandboxUpdated       = "SandboxUpdated"
+	EventSandboxRecreated     = "SandboxRecreated"
 )
 
 // +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxsets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxsets/finalizers,verbs=update
 // +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=podtemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxtemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch
 
 func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	totalStart := time.Now()
@@ -111,12 +120,37 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, err
 	}
 
+	// Resolve spec.templateRef into spec of the in-memory object, so that the referenced template is
+	// hashed into the update revision and copied into the created sandboxes.
+	if sbs.Spec.Template == nil && sbs.Spec.TemplateRef == nil {
+		log.Info("sandboxset template is nil, and ignore")
+		return ctrl.Result{}, nil
+	}
+	fromRef := sbs.Spec.Template == nil
+	resolved, err := templateref.ResolveTemplate(ctx, r.Client, sbs.Namespace, &sbs.Spec.EmbeddedSandboxTemplate)
+	if err != nil && !templateref.IsUnresolvable(err) {
+		log.Error(err, "failed to resolve sandboxset template")
+		return ctrl.Result{}, err
+	}
+	if resolved == nil {
+		log.Info("sandboxset templateRef cannot be resolved, wait for the template", "reason", err.Error())
+		newStatus := sbs.Status.DeepCopy()
+		setTemplateResolvedCondition(newStatus, fromRef, err)
+		return ctrl.Result{}, r.updateSandboxSetStatus(ctx, *newStatus, sbs)
+	}
+	sbs.Spec.Template = resolved.Template
+	sbs.Spec.VolumeClaimTemplates = resolved.VolumeClaimTemplates
+	if len(sbs.Spec.PersistentContents) == 0 {
+		sbs.Spec.PersistentContents = resolved.PersistentContents
+	}
+
 	// Preparation
-	newStatus, err := r.initNewStatus(sbs)
+	newStatus, err := r.initNewStatus(sbs, resolved.E2B)
 	if err != nil {
 		log.Error(err, "failed to init new status")
 		return ctrl.Result{}, err
 	}
+	setTemplateResolvedCondition(newStatus, fromRef, nil)
 
 	controllerKey := GetControllerKey(sbs)
 	var requeueAfter time.Duration
@@ -130,6 +164,7 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, err
 	}
 	actualReplicas := saveStatusFromGroup(newStatus, groups)
+	saveUpdateStatusFromGroup(newStatus, groups)
 
 	// Set selector in status for scale subresource
 	if newStatus.Selector == "" {
@@ -150,19 +185,23 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 	// Step 1: perform scale
 	start := time.Now()
-	delta := int(sbs.Spec.Replicas - actualReplicas)
+	r.setDesiredReplicas(ctx, sbs, newStatus)
+	delta := int(newStatus.DesiredReplicas - actualReplicas)
 	if delta > 0 {
 		if !scaleUpSatisfied {
 			log.Info("skip scale up for scaleUpExpectation is not satisfied")
 		} else {
-			err = r.scaleUp(ctx, delta, sbs, newStatus.UpdateRevision)
+			err = r.scaleUp(ctx, delta, sbs, newStatus.UpdateRevision, resolved.E2B)
 		}
 	} else if delta < 0 {
 		if !scaleUpSatisfied || !scaleDownSatisfied {
 			log.Info("skip scale down for scaleUpExpectation or scaleDownExpectation is not satisfied")
 		} else {
-			err = r.scaleDown(ctx, -delta, sbs, groups)
+			err = r.scaleDown(ctx, -delta, sbs, groups, newStatus.UpdateRevision)
 		}
+	} else if scaleUpSatisfied && scaleDownSatisfied {
+		// update the sandboxes of old revisions only when the pool is stable
+		err = r.rollingUpdate(ctx, sbs, groups, newStatus, resolved.E2B)
 	}
 	if err != nil {
 		log.Error(err, "failed to perform scale", "cost", time.Since(start))
@@ -187,12 +226,28 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	return ctrl.Result{RequeueAfter: requeueAfter}, allErrors
 }
 
+// setDesiredReplicas records the number of unused sandboxes sbs is scaling to, the decisions made by
+// spec.autoScaling are also recorded by events.
+func (r *Reconciler) setDesiredReplicas(ctx context.Context, sbs *agentsv1alpha1.SandboxSet, newStatus *agentsv1alpha1.SandboxSetStatus) {
+	current := newStatus.DesiredReplicas
+	desired := calculateDesiredReplicas(ctx, sbs, newStatus.UsedReplicas, current)
+	newStatus.DesiredReplicas = desired
+	if sbs.Spec.AutoScaling == nil || desired == current {
+		return
+	}
+	now := metav1.Now()
+	newStatus.LastScaleTime = &now
+	logf.FromContext(ctx).Info("sandboxset auto scaled", "from", current, "to", desired, "used", newStatus.UsedReplicas)
+	r.Recorder.Eventf(sbs, corev1.EventTypeNormal, EventSandboxSetAutoScaled,
+		"Unused sandboxes scaled from %d to %d, %d sandboxes are used", current, desired, newStatus.UsedReplicas)
+}
+
 // scaleUp is allowed when scaleUpExpectation is satisfied
-func (r *Reconciler) scaleUp(ctx context.Context, count int, sbs *agentsv1alpha1.SandboxSet, revision string) error {
+func (r *Reconciler) scaleUp(ctx context.Context, count int, sbs *agentsv1alpha1.SandboxSet, revision string, e2b *agentsv1alpha1.SandboxTemplateE2B) error {
 	log := logf.FromContext(ctx)
 	log.Info("scale up", "count", count)
 	successes, err := utils.DoItSlowly(count, initialBatchSize, func() error {
-		created, err := r.createSandbox(ctx, sbs, revision)
+		created, err := r.createSandbox(ctx, sbs, revision, e2b)
 		if err != nil {
 			log.Error(err, "failed to create sandbox")
 			return err
@@ -204,14 +259,19 @@ func (r *Reconciler) scaleUp(ctx context.Context, count int, sbs *agentsv1alpha1
 	return err
 }
 
-// scaleDown is allowed when both scaleUpExpectation and scaleDownExpectation are satisfied
-func (r *Reconciler) scaleDown(ctx context.Context, count int, sbs *agentsv1alpha1.SandboxSet, groups GroupedSandboxes) error {
+// scaleDown is allowed when both scaleUpExpectation and scaleDownExpectation are satisfied, the sandboxes of old
+// revisions are deleted first, and then the creating ones.
+func (r *Reconciler) scaleDown(ctx context.Context, count int, sbs *agentsv1alpha1.SandboxSet, groups GroupedSandboxes, revision string) error {
 	log := logf.FromContext(ctx)
 	controllerKey := GetControllerKey(sbs)
 	lock := uuid.New().String()
 	log.Info("scale down", "count", count)
 	var toDelete []client.ObjectKey
-	for _, snapshot := range append(groups.Creating, groups.Available...) {
+	candidates := append(append([]*agentsv1alpha1.Sandbox{}, groups.Creating...), groups.Available...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return !isUpdated(candidates[i], revision) && isUpdated(candidates[j], revision)
+	})
+	for _, snapshot := range candidates {
 		if count <= 0 {
 			break
 		}
@@ -220,7 +280,7 @@ func (r *Reconciler) scaleDown(ctx context.Context, count int, sbs *agentsv1alph
 	}
 	successes, err := utils.DoItSlowlyWithInputs(toDelete, initialBatchSize, func(key client.ObjectKey) error {
 		scaleDownExpectation.ExpectScale(controllerKey, expectations.Delete, key.Name)
-		err := r.scaleDownSandbox(ctx, key, lock)
+		err := r.deleteUnusedSandbox(ctx, key, lock, EventSandboxScaledDown)
 		if err != nil {
 			log.Error(err, "failed to scale down sandbox")
 			scaleDownExpectation.ObserveScale(controllerKey, expectations.Delete, key.Name)
@@ -232,8 +292,12 @@ func (r *Reconciler) scaleDown(ctx context.Context, count int, sbs *agentsv1alph
 
 }
 
-func (r *Reconciler) createSandbox(ctx context.Context, sbs *agentsv1alpha1.SandboxSet, revision string) (*agentsv1alpha1.Sandbox, error) {
+// createSandbox creates a sandbox from the resolved template of sbs, e2b is the E2B defaults of the referenced
+// SandboxTemplate, which are recorded in the annotations of the sandbox.
+func (r *Reconciler) createSandbox(ctx context.Context, sbs *agentsv1alpha1.SandboxSet, revision string, e2b *agentsv1alpha1.SandboxTemplateE2B) (*agentsv1alpha1.Sandbox, error) {
 	generateName := fmt.Sprintf("%s-", sbs.Name)
+	// sbs.Spec.Template has been resolved from templateRef, the sandbox takes a snapshot of it so that it keeps
+	// consistent with the revision recorded in LabelTemplateHash.
 	template := sbs.Spec.Template.DeepCopy()
 	sbx := &agentsv1alpha1.Sandbox{
 		ObjectMeta: metav1.ObjectMeta{
@@ -244,8 +308,7 @@ func (r *Reconciler) createSandbox(ctx context.Context, sbs *agentsv1alpha1.Sand
 		},
 		Spec: agentsv1alpha1.SandboxSpec{
 			PersistentContents: sbs.Spec.PersistentContents,
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
-				TemplateRef:          sbs.Spec.TemplateRef,
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template:             template,
 				VolumeClaimTemplates: sbs.Spec.VolumeClaimTemplates,
 			},
@@ -253,6 +316,9 @@ func (r *Reconciler) createSandbox(ctx context.Context, sbs *agentsv1alpha1.Sand
 	}
 	sbx.Annotations = clearAndInitInnerKeys(sbx.Annotations)
 	sbx.Labels = clearAndInitInnerKeys(sbx.Labels)
+	if err := templateref.SetE2BDefaults(sbx.Annotations, sbs, e2b); err != nil {
+		return nil, err
+	}
 	sbx.Labels[agentsv1alpha1.LabelSandboxPool] = sbs.Name
 	sbx.Labels[agentsv1alpha1.LabelSandboxIsClaimed] = "false"
 	sbx.Labels[agentsv1alpha1.LabelTemplateHash] = revision
@@ -268,7 +334,8 @@ func (r *Reconciler) createSandbox(ctx context.Context, sbs *agentsv1alpha1.Sand
 	return sbx, nil
 }
 
-func (r *Reconciler) scaleDownSandbox(ctx context.Context, key client.ObjectKey, lock string) (err error) {
+// deleteUnusedSandbox locks and deletes an unused sandbox, reason is the reason of the event recorded.
+func (r *Reconciler) deleteUnusedSandbox(ctx context.Context, key client.ObjectKey, lock, reason string) (err error) {
 	log := logf.FromContext(ctx).WithValues("sandbox", key).V(consts.DebugLogLevel)
 	sbx := &agentsv1alpha1.Sandbox{}
 	log.Info("try to scale down sandbox")
@@ -288,7 +355,7 @@ func (r *Reconciler) scaleDownSandbox(ctx context.Context, key client.ObjectKey,
 		return err
 	}
 	log.Info("sandbox locked and deleted")
-	r.Recorder.Eventf(sbx, corev1.EventTypeNormal, EventSandboxScaledDown, "Sandbox %s locked and deleted", klog.KObj(sbx))
+	r.Recorder.Eventf(sbx, corev1.EventTypeNormal, reason, "Sandbox %s locked and deleted", klog.KObj(sbx))
 	return nil
 }
 
@@ -367,6 +434,27 @@ func (r *Reconciler) groupAllSandboxes(ctx context.Context, sbs *agentsv1alpha1.
 		}
 		debugLog.Info("sandbox is grouped", "state", state, "reason", reason)
 	}
+	// claimed sandboxes are released by the sandboxset, they are found by the pool label
+	claimedList := &agentsv1alpha1.SandboxList{}
+	if err := r.List(ctx, claimedList,
+		client.InNamespace(sbs.Namespace),
+		client.MatchingLabels{
+			agentsv1alpha1.LabelSandboxPool:      sbs.Name,
+			agentsv1alpha1.LabelSandboxIsClaimed: "true",
+		},
+		client.UnsafeDisableDeepCopy,
+	); err != nil {
+		return GroupedSandboxes{}, err
+	}
+	for i := range claimedList.Items {
+		sbx := &claimedList.Items[i]
+		if metav1.IsControlledBy(sbx, sbs) {
+			continue
+		}
+		if state, _ := stateutils.GetSandboxState(sbx); state == agentsv1alpha1.SandboxStateRunning || state == agentsv1alpha1.SandboxStatePaused {
+			groups.Used = append(groups.Used, sbx)
+		}
+	}
 	log.Info("sandbox group done", "total", len(sandboxList.Items), "creating", len(groups.Creating),
 		"available", len(groups.Available), "used", len(groups.Used), "failed", len(groups.Dead))
 	return groups, nil
@@ -377,10 +465,33 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
 	controllerName := "sandboxset-controller"
 	r.Recorder = mgr.GetEventRecorderFor(controllerName)
 	r.Codec = serializer.NewCodecFactory(mgr.GetScheme()).LegacyCodec(agentsv1alpha1.SchemeGroupVersion)
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		Named(controllerName).
 		WithOptions(controller.Options{MaxConcurrentReconciles: concurrentReconciles}).
 		Watches(&agentsv1alpha1.SandboxSet{}, &handler.EnqueueRequestForObject{}).
 		Watches(&agentsv1alpha1.Sandbox{}, &SandboxEventHandler{}).
-		Complete(r)
+		Watches(&corev1.PodTemplate{}, handler.EnqueueRequestsFromMapFunc(r.mapTemplateToSandboxSets(templateref.DefaultKind)))
+	if discovery.DiscoverGVK(agentsv1alpha1.SandboxTemplateControllerKind) {
+		b = b.Watches(&agentsv1alpha1.SandboxTemplate{},
+			handler.EnqueueRequestsFromMapFunc(r.mapTemplateToSandboxSets(agentsv1alpha1.SandboxTemplateControllerKind.Kind)))
+	}
+	return b.Complete(r)
+}
+
+// mapTemplateToSandboxSets returns a map func which enqueues all sandboxsets referencing the changed template of kind.
+func (r *Reconciler) mapTemplateToSandboxSets(kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		sbsList := &agentsv1alpha1.SandboxSetList{}
+		if err := r.List(ctx, sbsList, client.InNamespace(obj.GetNamespace()),
+			client.MatchingFields{fieldindex.IndexNameForTemplateRef: templateref.IndexKey(kind, obj.GetName())},
+		); err != nil {
+			logf.FromContext(ctx).Error(err, "failed to list sandboxsets referencing template", "kind", kind, "template", klog.KObj(obj))
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(sbsList.Items))
+		for i := range sbsList.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&sbsList.Items[i])})
+		}
+		return requests
+	}
 }
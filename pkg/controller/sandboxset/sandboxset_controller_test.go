@@ -13,16 +13,21 @@ import (
 	"github.com/openkruise/agents/pkg/utils/fieldindex"
 	utils "github.com/openkruise/agents/pkg/utils/sandbox-manager"
 	"github.com/openkruise/agents/pkg/utils/sandboxutils"
+	"github.com/openkruise/agents/pkg/utils/templateref"
 	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 var testScheme *runtime.Scheme
@@ -32,6 +37,7 @@ func init() {
 	testScheme = runtime.NewScheme()
 	_ = v1alpha1.AddToScheme(testScheme)
 	_ = corev1.AddToScheme(testScheme)
+	_ = apps.AddToScheme(testScheme)
 	codec = serializer.NewCodecFactory(testScheme).LegacyCodec(v1alpha1.SchemeGroupVersion)
 }
 
@@ -47,7 +53,7 @@ func getSandboxSet(replicas int32) *v1alpha1.SandboxSet {
 		},
 		Spec: v1alpha1.SandboxSetSpec{
 			Replicas: replicas,
-			SandboxTemplate: v1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					ObjectMeta: metav1.ObjectMeta{
 						Labels: map[string]string{
@@ -95,6 +101,7 @@ func NewClient() client.Client {
 		WithStatusSubresource(&v1alpha1.SandboxSet{}, &v1alpha1.Sandbox{}).
 		WithLists(&v1alpha1.SandboxSetList{}, &v1alpha1.SandboxList{}).
 		WithIndex(&v1alpha1.Sandbox{}, fieldindex.IndexNameForOwnerRefUID, fieldindex.OwnerIndexFunc).
+		WithIndex(&v1alpha1.SandboxSet{}, fieldindex.IndexNameForTemplateRef, fieldindex.TemplateRefIndexFunc).
 		Build()
 }
 
@@ -295,7 +302,7 @@ func TestReconcile_DeleteDead(t *testing.T) {
 				Codec:    codec,
 			}
 			assert.NoError(t, k8sClient.Create(ctx, sbs))
-			newStatus, err := reconciler.initNewStatus(sbs)
+			newStatus, err := reconciler.initNewStatus(sbs, nil)
 
 			assert.NoError(t, err)
 			sbs.Status = *newStatus
@@ -557,7 +564,7 @@ func TestReconcile_BasicScale(t *testing.T) {
 				Recorder: eventRecorder,
 				Codec:    codec,
 			}
-			newStatus, err := reconciler.initNewStatus(sbs)
+			newStatus, err := reconciler.initNewStatus(sbs, nil)
 
 			assert.NoError(t, err)
 			sbs.Status = *newStatus
@@ -702,6 +709,53 @@ func TestReconcile_ScaleDown(t *testing.T) {
 	}
 }
 
+func TestReconcile_AutoScaling(t *testing.T) {
+	utils.InitLogOutput()
+	ctx := context.Background()
+	k8sClient := NewClient()
+	eventRecorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{
+		Client:   k8sClient,
+		Scheme:   testScheme,
+		Recorder: eventRecorder,
+		Codec:    codec,
+	}
+	sbs := getSandboxSet(0)
+	sbs.Spec.AutoScaling = &v1alpha1.SandboxSetAutoScaling{MinReplicas: 1, MaxReplicas: 10}
+	assert.NoError(t, k8sClient.Create(ctx, sbs))
+	for i := int32(0); i < 4; i++ {
+		// claimed sandboxes are released by the sandboxset
+		sbx := getBaseSandbox(i, "used-", sbs.Status.UpdateRevision)
+		sbx.Labels[v1alpha1.LabelSandboxIsClaimed] = "true"
+		sbx.Status.Phase = v1alpha1.SandboxPaused
+		CreateSandboxWithStatus(t, k8sClient, sbx)
+	}
+	reconcile := func() *v1alpha1.SandboxSet {
+		scaleUpExpectation.DeleteExpectations(GetControllerKey(sbs))
+		scaleDownExpectation.DeleteExpectations(GetControllerKey(sbs))
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(sbs)})
+		assert.NoError(t, err)
+		got := &v1alpha1.SandboxSet{}
+		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(sbs), got))
+		return got
+	}
+
+	// all the 4 sandboxes are used, 3 unused ones are created so that 4 of 7 are used
+	got := reconcile()
+	assert.Equal(t, int32(3), got.Status.DesiredReplicas)
+	assert.Equal(t, int32(4), got.Status.UsedReplicas)
+	assert.NotNil(t, got.Status.LastScaleTime)
+	CheckAllEvents(t, eventRecorder, []string{EventSandboxSetAutoScaled, EventSandboxCreated, EventSandboxCreated, EventSandboxCreated})
+	sandboxes := &v1alpha1.SandboxList{}
+	assert.NoError(t, k8sClient.List(ctx, sandboxes))
+	assert.Len(t, sandboxes.Items, 7)
+
+	// the decision is kept while the usage stays between the water marks
+	got = reconcile()
+	assert.Equal(t, int32(3), got.Status.DesiredReplicas)
+	CheckAllEvents(t, eventRecorder, nil)
+}
+
 func TestSandboxSetReconcile_WithVolumeClaimTemplates(t *testing.T) {
 	type Case struct {
 		name              string
@@ -759,7 +813,7 @@ func TestSandboxSetReconcile_WithVolumeClaimTemplates(t *testing.T) {
 			}
 
 			assert.NoError(t, k8sClient.Create(ctx, sbs))
-			newStatus, err := reconciler.initNewStatus(sbs)
+			newStatus, err := reconciler.initNewStatus(sbs, nil)
 			assert.NoError(t, err)
 			sbs.Status = *newStatus
 
@@ -797,3 +851,183 @@ func TestSandboxSetReconcile_WithVolumeClaimTemplates(t *testing.T) {
 		})
 	}
 }
+
+func TestSandboxSetReconcile_WithTemplateRef(t *testing.T) {
+	utils.InitLogOutput()
+	getPodTemplate := func(image string) *corev1.PodTemplate {
+		return &corev1.PodTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "shared-template",
+				Namespace: "default",
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{newPodKey: "true"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "test", Image: image}},
+				},
+			},
+		}
+	}
+	getRefSandboxSet := func() *v1alpha1.SandboxSet {
+		sbs := getSandboxSet(2)
+		sbs.Spec.Template = nil
+		sbs.Spec.TemplateRef = &v1alpha1.SandboxTemplateRef{Name: "shared-template"}
+		return sbs
+	}
+
+	t.Run("template found", func(t *testing.T) {
+		ctx := context.Background()
+		k8sClient := NewClient()
+		podTemplate := getPodTemplate("image-v1")
+		assert.NoError(t, k8sClient.Create(ctx, podTemplate))
+		sbs := getRefSandboxSet()
+		assert.NoError(t, k8sClient.Create(ctx, sbs))
+		scaleUpExpectation.DeleteExpectations(GetControllerKey(sbs))
+		scaleDownExpectation.DeleteExpectations(GetControllerKey(sbs))
+		reconciler := &Reconciler{
+			Client:   k8sClient,
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+			Codec:    codec,
+		}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(sbs)})
+		assert.NoError(t, err)
+
+		gotSbs := &v1alpha1.SandboxSet{}
+		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(sbs), gotSbs))
+		assert.Nil(t, gotSbs.Spec.Template, "resolved template should not be persisted into sandboxset")
+		cond := meta.FindStatusCondition(gotSbs.Status.Conditions, v1alpha1.SandboxSetConditionTemplateResolved)
+		assert.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionTrue, cond.Status)
+		// the revision of a referenced template equals to the revision of the same inline template
+		inline := getRefSandboxSet()
+		inline.Spec.TemplateRef = nil
+		inline.Spec.Template = podTemplate.Template.DeepCopy()
+		inlineStatus, err := reconciler.initNewStatus(inline, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, inlineStatus.UpdateRevision, gotSbs.Status.UpdateRevision)
+
+		sandboxList := &v1alpha1.SandboxList{}
+		assert.NoError(t, k8sClient.List(ctx, sandboxList, client.InNamespace(sbs.Namespace)))
+		assert.Equal(t, 2, len(sandboxList.Items))
+		for _, sbx := range sandboxList.Items {
+			assert.Nil(t, sbx.Spec.TemplateRef)
+			assert.NotNil(t, sbx.Spec.Template)
+			assert.Equal(t, "image-v1", sbx.Spec.Template.Spec.Containers[0].Image)
+			assert.Equal(t, gotSbs.Status.UpdateRevision, sbx.Labels[v1alpha1.LabelTemplateHash])
+			assert.Equal(t, "true", sbx.Labels[newPodKey])
+		}
+
+		// changes of the referenced template are hashed into update revision
+		podTemplate.Template.Spec.Containers[0].Image = "image-v2"
+		assert.NoError(t, k8sClient.Update(ctx, podTemplate))
+		requests := reconciler.mapTemplateToSandboxSets(templateref.DefaultKind)(ctx, podTemplate)
+		assert.Equal(t, []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(sbs)}}, requests)
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(sbs)})
+		assert.NoError(t, err)
+		newSbs := &v1alpha1.SandboxSet{}
+		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(sbs), newSbs))
+		assert.NotEqual(t, gotSbs.Status.UpdateRevision, newSbs.Status.UpdateRevision)
+	})
+
+	t.Run("template not found", func(t *testing.T) {
+		ctx := context.Background()
+		k8sClient := NewClient()
+		sbs := getRefSandboxSet()
+		assert.NoError(t, k8sClient.Create(ctx, sbs))
+		scaleUpExpectation.DeleteExpectations(GetControllerKey(sbs))
+		scaleDownExpectation.DeleteExpectations(GetControllerKey(sbs))
+		reconciler := &Reconciler{
+			Client:   k8sClient,
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+			Codec:    codec,
+		}
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(sbs)})
+		assert.NoError(t, err)
+
+		gotSbs := &v1alpha1.SandboxSet{}
+		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(sbs), gotSbs))
+		cond := meta.FindStatusCondition(gotSbs.Status.Conditions, v1alpha1.SandboxSetConditionTemplateResolved)
+		assert.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		assert.Equal(t, v1alpha1.SandboxTemplateReasonNotFound, cond.Reason)
+		sandboxList := &v1alpha1.SandboxList{}
+		assert.NoError(t, k8sClient.List(ctx, sandboxList, client.InNamespace(sbs.Namespace)))
+		assert.Equal(t, 0, len(sandboxList.Items))
+	})
+
+	t.Run("sandbox template revision", func(t *testing.T) {
+		ctx := context.Background()
+		k8sClient := NewClient()
+		sbt := &v1alpha1.SandboxTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared-template", Namespace: "default", Generation: 1},
+		}
+		assert.NoError(t, k8sClient.Create(ctx, sbt))
+		data, err := templateref.EncodeRevision(&v1alpha1.SandboxTemplateSpec{
+			Template:           getPodTemplate("image-v1").Template.DeepCopy(),
+			PersistentContents: []string{v1alpha1.PersistentContentMemory},
+			E2B: &v1alpha1.SandboxTemplateE2B{
+				TimeoutSeconds:          ptr.To[int32](600),
+				InitEnvd:                true,
+				ExposedPorts:            []v1alpha1.SandboxPort{{Name: "http", Port: 8080}},
+				IdleTimeoutSeconds:      ptr.To[int32](900),
+				ExtendTimeoutOnActivity: true,
+			},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, k8sClient.Create(ctx, &apps.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "shared-template-v1",
+				Namespace: "default",
+				Labels:    map[string]string{v1alpha1.LabelSandboxTemplate: sbt.Name},
+			},
+			Data: data,
+		}))
+		sbs := getRefSandboxSet()
+		sbs.Spec.TemplateRef.Kind = ptr.To(v1alpha1.SandboxTemplateControllerKind.Kind)
+		sbs.Spec.TemplateRef.APIVersion = ptr.To(v1alpha1.GroupVersion.String())
+		assert.NoError(t, k8sClient.Create(ctx, sbs))
+		scaleUpExpectation.DeleteExpectations(GetControllerKey(sbs))
+		scaleDownExpectation.DeleteExpectations(GetControllerKey(sbs))
+		reconciler := &Reconciler{
+			Client:   k8sClient,
+			Scheme:   testScheme,
+			Recorder: record.NewFakeRecorder(10),
+			Codec:    codec,
+		}
+
+		// the current revision of the template is not recorded yet
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(sbs)})
+		assert.NoError(t, err)
+		gotSbs := &v1alpha1.SandboxSet{}
+		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(sbs), gotSbs))
+		cond := meta.FindStatusCondition(gotSbs.Status.Conditions, v1alpha1.SandboxSetConditionTemplateResolved)
+		assert.NotNil(t, cond)
+		assert.Equal(t, v1alpha1.SandboxTemplateReasonRevisionNotReady, cond.Reason)
+
+		sbt.Status = v1alpha1.SandboxTemplateStatus{ObservedGeneration: 1, CurrentRevision: "shared-template-v1"}
+		assert.NoError(t, k8sClient.Update(ctx, sbt))
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(sbs)})
+		assert.NoError(t, err)
+		assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(sbs), gotSbs))
+		cond = meta.FindStatusCondition(gotSbs.Status.Conditions, v1alpha1.SandboxSetConditionTemplateResolved)
+		assert.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionTrue, cond.Status)
+
+		sandboxList := &v1alpha1.SandboxList{}
+		assert.NoError(t, k8sClient.List(ctx, sandboxList, client.InNamespace(sbs.Namespace)))
+		assert.Equal(t, 2, len(sandboxList.Items))
+		for _, sbx := range sandboxList.Items {
+			assert.Equal(t, "image-v1", sbx.Spec.Template.Spec.Containers[0].Image)
+			assert.Equal(t, []string{v1alpha1.PersistentContentMemory}, sbx.Spec.PersistentContents)
+			assert.Equal(t, v1alpha1.True, sbx.Annotations[v1alpha1.AnnotationShouldInitEnvd])
+			assert.Equal(t, "600", sbx.Annotations[v1alpha1.AnnotationDefaultTimeoutSeconds])
+			assert.JSONEq(t, `[{"name":"http","port":8080},{"name":"envd","port":49983}]`, sbx.Annotations[v1alpha1.AnnotationExposedPorts])
+			assert.Equal(t, "900", sbx.Annotations[v1alpha1.AnnotationIdleTimeoutSeconds])
+			assert.Equal(t, v1alpha1.True, sbx.Annotations[v1alpha1.AnnotationExtendTimeoutOnActivity])
+		}
+	})
+}
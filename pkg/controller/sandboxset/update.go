@@ -0,0 +1,203 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sandboxset
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/utils"
+	"github.com/openkruise/agents/pkg/utils/expectations"
+	"github.com/openkruise/agents/pkg/utils/templateref"
+)
+
+var defaultMaxUnavailable = intstr.FromString("20%")
+
+// isUpdated returns whether the sandbox is of the update revision.
+func isUpdated(sbx *agentsv1alpha1.Sandbox, revision string) bool {
+	return sbx.Labels[agentsv1alpha1.LabelTemplateHash] == revision
+}
+
+func saveUpdateStatusFromGroup(newStatus *agentsv1alpha1.SandboxSetStatus, groups GroupedSandboxes) {
+	newStatus.UpdatedReplicas, newStatus.UpdatedAvailableReplicas = 0, 0
+	for _, sbx := range groups.Creating {
+		if isUpdated(sbx, newStatus.UpdateRevision) {
+			newStatus.UpdatedReplicas++
+		}
+	}
+	for _, sbx := range groups.Available {
+		if isUpdated(sbx, newStatus.UpdateRevision) {
+			newStatus.UpdatedReplicas++
+			newStatus.UpdatedAvailableReplicas++
+		}
+	}
+}
+
+// rollingUpdate updates the unused sandboxes of old revisions to the update revision, except the ones kept by
+// partition. The creating sandboxes are updated first, which are unavailable anyway, and no more available
+// sandboxes are updated once the unavailable ones reach maxUnavailable.
+func (r *Reconciler) rollingUpdate(ctx context.Context, sbs *agentsv1alpha1.SandboxSet, groups GroupedSandboxes,
+	newStatus *agentsv1alpha1.SandboxSetStatus, e2b *agentsv1alpha1.SandboxTemplateE2B) error {
+	log := logf.FromContext(ctx)
+	strategy := sbs.Spec.UpdateStrategy
+	replicas := int(newStatus.DesiredReplicas)
+	partition, err := intstr.GetScaledValueFromIntOrPercent(
+		intstr.ValueOrDefault(strategy.Partition, intstr.FromInt32(0)), replicas, true)
+	if err != nil {
+		return err
+	}
+	maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(
+		intstr.ValueOrDefault(strategy.MaxUnavailable, defaultMaxUnavailable), replicas, true)
+	if err != nil {
+		return err
+	}
+	maxUnavailable = max(maxUnavailable, 1)
+
+	var oldCreating, oldAvailable []*agentsv1alpha1.Sandbox
+	for _, sbx := range groups.Creating {
+		if !isUpdated(sbx, newStatus.UpdateRevision) {
+			oldCreating = append(oldCreating, sbx)
+		}
+	}
+	for _, sbx := range groups.Available {
+		if !isUpdated(sbx, newStatus.UpdateRevision) {
+			oldAvailable = append(oldAvailable, sbx)
+		}
+	}
+	toUpdate := len(oldCreating) + len(oldAvailable) - partition
+	if toUpdate <= 0 {
+		return nil
+	}
+	unavailableQuota := max(maxUnavailable-len(groups.Creating), 0)
+	candidates := append(oldCreating, oldAvailable[:min(unavailableQuota, len(oldAvailable))]...)
+	candidates = candidates[:min(toUpdate, len(candidates))]
+	log.Info("rolling update", "toUpdate", toUpdate, "updating", len(candidates), "partition", partition,
+		"maxUnavailable", maxUnavailable, "revision", newStatus.UpdateRevision)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	lock := uuid.New().String()
+	successes, err := utils.DoItSlowlyWithInputs(candidates, initialBatchSize, func(sbx *agentsv1alpha1.Sandbox) error {
+		if strategy.Type == agentsv1alpha1.InPlaceSandboxSetUpdateStrategyType && inPlaceUpdatable(sbx, sbs) {
+			return r.inPlaceUpdateSandbox(ctx, sbs, sbx, newStatus.UpdateRevision, e2b)
+		}
+		return r.recreateSandbox(ctx, sbs, client.ObjectKeyFromObject(sbx), lock)
+	})
+	log.Info("rolling update finished", "successes", successes, "fails", len(candidates)-successes)
+	return err
+}
+
+// inPlaceUpdateSandbox updates the images and resources of the sandbox to template of sbs, and the pod is updated
+// in place by the sandbox controller.
+func (r *Reconciler) inPlaceUpdateSandbox(ctx context.Context, sbs *agentsv1alpha1.SandboxSet, sbx *agentsv1alpha1.Sandbox,
+	revision string, e2b *agentsv1alpha1.SandboxTemplateE2B) error {
+	log := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(sbx))
+	if sbx.Annotations[agentsv1alpha1.AnnotationLock] != "" {
+		log.Info("sandbox to be updated is locked, skip")
+		return nil
+	}
+	clone := sbx.DeepCopy()
+	template := sbs.Spec.Template
+	for i := range clone.Spec.Template.Spec.InitContainers {
+		c := &clone.Spec.Template.Spec.InitContainers[i]
+		c.Image = template.Spec.InitContainers[i].Image
+		c.Resources = *template.Spec.InitContainers[i].Resources.DeepCopy()
+	}
+	for i := range clone.Spec.Template.Spec.Containers {
+		c := &clone.Spec.Template.Spec.Containers[i]
+		c.Image = template.Spec.Containers[i].Image
+		c.Resources = *template.Spec.Containers[i].Resources.DeepCopy()
+	}
+	clone.Annotations = clearAndInitInnerKeys(clone.Annotations)
+	if err := templateref.SetE2BDefaults(clone.Annotations, sbs, e2b); err != nil {
+		return err
+	}
+	clone.Labels[agentsv1alpha1.LabelTemplateHash] = revision
+	// the update conflicts if the sandbox is claimed in the meantime
+	if err := r.Update(ctx, clone); err != nil {
+		log.Error(err, "failed to update sandbox in place")
+		return err
+	}
+	r.Recorder.Eventf(sbs, corev1.EventTypeNormal, EventSandboxUpdated, "Sandbox %s updated in place to revision %s", klog.KObj(sbx), revision)
+	return nil
+}
+
+// recreateSandbox deletes the sandbox of an old revision, which is replaced by scaling up.
+func (r *Reconciler) recreateSandbox(ctx context.Context, sbs *agentsv1alpha1.SandboxSet, key client.ObjectKey, lock string) error {
+	controllerKey := GetControllerKey(sbs)
+	scaleDownExpectation.ExpectScale(controllerKey, expectations.Delete, key.Name)
+	if err := r.deleteUnusedSandbox(ctx, key, lock, EventSandboxRecreated); err != nil {
+		logf.FromContext(ctx).Error(err, "failed to recreate sandbox", "sandbox", key)
+		scaleDownExpectation.ObserveScale(controllerKey, expectations.Delete, key.Name)
+		return err
+	}
+	return nil
+}
+
+// inPlaceUpdatable returns whether the sandbox can be updated to the template of sbs in place, which is only possible
+// when nothing but the images and resources of containers change. The E2B defaults are re-applied in place, while the
+// changes of VolumeClaimTemplates and PersistentContents require recreating the sandbox.
+func inPlaceUpdatable(sbx *agentsv1alpha1.Sandbox, sbs *agentsv1alpha1.SandboxSet) bool {
+	template := sbs.Spec.Template
+	if sbx.Spec.Template == nil || template == nil {
+		return false
+	}
+	if !apiequality.Semantic.DeepEqual(sbx.Spec.VolumeClaimTemplates, sbs.Spec.VolumeClaimTemplates) ||
+		!apiequality.Semantic.DeepEqual(sbx.Spec.PersistentContents, sbs.Spec.PersistentContents) {
+		return false
+	}
+	return apiequality.Semantic.DeepEqual(templateWithoutImageAndResources(sbx.Spec.Template),
+		templateWithoutImageAndResources(template))
+}
+
+// templateWithoutImageAndResources returns a copy of template without images, resources and the keys of labels and
+// annotations managed by the controllers.
+func templateWithoutImageAndResources(template *corev1.PodTemplateSpec) *corev1.PodTemplateSpec {
+	clone := template.DeepCopy()
+	for i := range clone.Spec.InitContainers {
+		clone.Spec.InitContainers[i].Image = ""
+		clone.Spec.InitContainers[i].Resources = corev1.ResourceRequirements{}
+	}
+	for i := range clone.Spec.Containers {
+		clone.Spec.Containers[i].Image = ""
+		clone.Spec.Containers[i].Resources = corev1.ResourceRequirements{}
+	}
+	clone.Labels = withoutInnerKeys(clone.Labels)
+	clone.Annotations = withoutInnerKeys(clone.Annotations)
+	return clone
+}
+
+func withoutInnerKeys(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if strings.HasPrefix(k, agentsv1alpha1.InternalPrefix) || strings.HasPrefix(k, agentsv1alpha1.E2BPrefix) {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,238 @@
+package sandboxset
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openkruise/agents/api/v1alpha1"
+	utils "github.com/openkruise/agents/pkg/utils/sandbox-manager"
+)
+
+// newUnusedSandbox returns an available sandbox of sbs created from template at revision.
+func newUnusedSandbox(sbs *v1alpha1.SandboxSet, idx int, revision string, template *corev1.PodTemplateSpec) *v1alpha1.Sandbox {
+	sbx := getBaseSandbox(int32(idx), fmt.Sprintf("%s-", revision), revision)
+	sbx.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(sbs, v1alpha1.SandboxSetControllerKind)}
+	sbx.Spec.Template = template.DeepCopy()
+	sbx.Status.Phase = v1alpha1.SandboxRunning
+	sbx.Status.PodInfo.PodIP = "1.2.3.4"
+	sbx.Status.Conditions = []metav1.Condition{{
+		Type:   string(v1alpha1.SandboxConditionReady),
+		Status: metav1.ConditionTrue,
+	}}
+	return sbx
+}
+
+func TestReconcile_RollingUpdate(t *testing.T) {
+	utils.InitLogOutput()
+	oldTemplate := getSandboxSet(0).Spec.Template
+	tests := []struct {
+		name         string
+		strategy     v1alpha1.SandboxSetUpdateStrategy
+		oldSandboxes int
+		newSandboxes int
+		// updatingSandboxes of the new ones are still being updated in place
+		updatingSandboxes int
+		changeTemplate    func(template *corev1.PodTemplateSpec)
+		expectEvents      []string
+		expectOld         int
+		expectInPlace     int
+	}{
+		{
+			name:           "recreate limited by maxUnavailable",
+			oldSandboxes:   3,
+			changeTemplate: func(template *corev1.PodTemplateSpec) { template.Spec.Containers[0].Image = "new" },
+			expectEvents:   []string{EventSandboxRecreated},
+			expectOld:      2,
+		},
+		{
+			name: "recreate limited by partition",
+			strategy: v1alpha1.SandboxSetUpdateStrategy{
+				Partition:      ptr.To(intstr.FromInt32(2)),
+				MaxUnavailable: ptr.To(intstr.FromString("100%")),
+			},
+			oldSandboxes:   3,
+			changeTemplate: func(template *corev1.PodTemplateSpec) { template.Spec.Containers[0].Image = "new" },
+			expectEvents:   []string{EventSandboxRecreated},
+			expectOld:      2,
+		},
+		{
+			name:           "only old sandboxes recreated",
+			oldSandboxes:   1,
+			newSandboxes:   2,
+			changeTemplate: func(template *corev1.PodTemplateSpec) { template.Spec.Containers[0].Image = "new" },
+			expectEvents:   []string{EventSandboxRecreated},
+		},
+		{
+			name: "in place update image and resources",
+			strategy: v1alpha1.SandboxSetUpdateStrategy{
+				Type:           v1alpha1.InPlaceSandboxSetUpdateStrategyType,
+				MaxUnavailable: ptr.To(intstr.FromInt32(2)),
+			},
+			oldSandboxes: 3,
+			changeTemplate: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Containers[0].Image = "new"
+				template.Spec.Containers[0].Resources.Limits = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}
+			},
+			expectEvents:  []string{EventSandboxUpdated, EventSandboxUpdated},
+			expectOld:     1,
+			expectInPlace: 2,
+		},
+		{
+			name: "in place update falls back to recreate",
+			strategy: v1alpha1.SandboxSetUpdateStrategy{
+				Type: v1alpha1.InPlaceSandboxSetUpdateStrategyType,
+			},
+			oldSandboxes: 3,
+			changeTemplate: func(template *corev1.PodTemplateSpec) {
+				template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FOO", Value: "bar"}}
+			},
+			expectEvents: []string{EventSandboxRecreated},
+			expectOld:    2,
+		},
+		{
+			name: "in place updating sandboxes count as unavailable",
+			strategy: v1alpha1.SandboxSetUpdateStrategy{
+				Type:           v1alpha1.InPlaceSandboxSetUpdateStrategyType,
+				MaxUnavailable: ptr.To(intstr.FromInt32(1)),
+			},
+			oldSandboxes:      2,
+			newSandboxes:      1,
+			updatingSandboxes: 1,
+			changeTemplate:    func(template *corev1.PodTemplateSpec) { template.Spec.Containers[0].Image = "new" },
+			expectOld:         2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			k8sClient := NewClient()
+			eventRecorder := record.NewFakeRecorder(10)
+			reconciler := &Reconciler{
+				Client:   k8sClient,
+				Scheme:   testScheme,
+				Recorder: eventRecorder,
+				Codec:    codec,
+			}
+			sbs := getSandboxSet(int32(tt.oldSandboxes + tt.newSandboxes))
+			sbs.Spec.UpdateStrategy = tt.strategy
+			oldStatus, err := reconciler.initNewStatus(sbs, nil)
+			assert.NoError(t, err)
+			tt.changeTemplate(sbs.Spec.Template)
+			newStatus, err := reconciler.initNewStatus(sbs, nil)
+			assert.NoError(t, err)
+			assert.NotEqual(t, oldStatus.UpdateRevision, newStatus.UpdateRevision)
+			assert.NoError(t, k8sClient.Create(ctx, sbs))
+			for i := 0; i < tt.oldSandboxes; i++ {
+				CreateSandboxWithStatus(t, k8sClient, newUnusedSandbox(sbs, i, oldStatus.UpdateRevision, oldTemplate))
+			}
+			for i := 0; i < tt.newSandboxes; i++ {
+				sbx := newUnusedSandbox(sbs, i, newStatus.UpdateRevision, sbs.Spec.Template)
+				if i < tt.updatingSandboxes {
+					sbx.Status.Conditions = append(sbx.Status.Conditions, metav1.Condition{
+						Type:   string(v1alpha1.SandboxConditionInplaceUpdate),
+						Status: metav1.ConditionFalse,
+						Reason: v1alpha1.SandboxInplaceUpdateReasonInplaceUpdating,
+					})
+				}
+				CreateSandboxWithStatus(t, k8sClient, sbx)
+			}
+
+			scaleUpExpectation.DeleteExpectations(GetControllerKey(sbs))
+			scaleDownExpectation.DeleteExpectations(GetControllerKey(sbs))
+			_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(sbs)})
+			assert.NoError(t, err)
+			CheckAllEvents(t, eventRecorder, tt.expectEvents)
+
+			sandboxes := &v1alpha1.SandboxList{}
+			assert.NoError(t, k8sClient.List(ctx, sandboxes))
+			var gotOld, gotInPlace int
+			for _, sbx := range sandboxes.Items {
+				if !isUpdated(&sbx, newStatus.UpdateRevision) {
+					gotOld++
+				} else if sbx.Name[:len(oldStatus.UpdateRevision)] == oldStatus.UpdateRevision {
+					gotInPlace++
+					assert.Equal(t, sbs.Spec.Template.Spec.Containers[0].Image, sbx.Spec.Template.Spec.Containers[0].Image)
+					assert.Equal(t, sbs.Spec.Template.Spec.Containers[0].Resources, sbx.Spec.Template.Spec.Containers[0].Resources)
+				}
+			}
+			assert.Equal(t, tt.expectOld, gotOld)
+			assert.Equal(t, tt.expectInPlace, gotInPlace)
+
+			got := &v1alpha1.SandboxSet{}
+			assert.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(sbs), got))
+			assert.Equal(t, int32(tt.newSandboxes), got.Status.UpdatedReplicas)
+			assert.Equal(t, int32(tt.newSandboxes-tt.updatingSandboxes), got.Status.UpdatedAvailableReplicas)
+		})
+	}
+}
+
+func TestReconcile_ScaleDownOldRevisionFirst(t *testing.T) {
+	utils.InitLogOutput()
+	ctx := context.Background()
+	k8sClient := NewClient()
+	eventRecorder := record.NewFakeRecorder(10)
+	reconciler := &Reconciler{
+		Client:   k8sClient,
+		Scheme:   testScheme,
+		Recorder: eventRecorder,
+		Codec:    codec,
+	}
+	sbs := getSandboxSet(2)
+	newStatus, err := reconciler.initNewStatus(sbs, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, k8sClient.Create(ctx, sbs))
+	CreateSandboxWithStatus(t, k8sClient, newUnusedSandbox(sbs, 0, newStatus.UpdateRevision, sbs.Spec.Template))
+	CreateSandboxWithStatus(t, k8sClient, newUnusedSandbox(sbs, 0, "old", sbs.Spec.Template))
+	CreateSandboxWithStatus(t, k8sClient, newUnusedSandbox(sbs, 1, newStatus.UpdateRevision, sbs.Spec.Template))
+
+	scaleUpExpectation.DeleteExpectations(GetControllerKey(sbs))
+	scaleDownExpectation.DeleteExpectations(GetControllerKey(sbs))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(sbs)})
+	assert.NoError(t, err)
+	CheckAllEvents(t, eventRecorder, []string{EventSandboxScaledDown})
+	sandboxes := &v1alpha1.SandboxList{}
+	assert.NoError(t, k8sClient.List(ctx, sandboxes))
+	assert.Len(t, sandboxes.Items, 2)
+	for _, sbx := range sandboxes.Items {
+		assert.True(t, isUpdated(&sbx, newStatus.UpdateRevision))
+	}
+}
+
+func TestInPlaceUpdatable(t *testing.T) {
+	template := getSandboxSet(0).Spec.Template
+	sbx := newUnusedSandbox(getSandboxSet(0), 0, "old", template)
+	// the labels managed by the sandboxset are ignored
+	sbx.Spec.Template.Labels[v1alpha1.LabelSandboxPool] = "test"
+	sbx.Spec.Template.Labels[v1alpha1.LabelTemplateHash] = "old"
+
+	sbs := getSandboxSet(0)
+	sbs.Spec.Template.Spec.Containers[0].Image = "new"
+	assert.True(t, inPlaceUpdatable(sbx, sbs))
+
+	sbs.Spec.Template.Labels["foo"] = "bar"
+	assert.False(t, inPlaceUpdatable(sbx, sbs))
+
+	sbs = getSandboxSet(0)
+	sbs.Spec.Template.Spec.Containers = append(sbs.Spec.Template.Spec.Containers, corev1.Container{Name: "sidecar", Image: "sidecar"})
+	assert.False(t, inPlaceUpdatable(sbx, sbs))
+
+	// the volumes and persistent contents cannot be changed in place
+	sbs = getSandboxSet(0)
+	sbs.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}
+	assert.False(t, inPlaceUpdatable(sbx, sbs))
+
+	sbs = getSandboxSet(0)
+	sbs.Spec.PersistentContents = []string{"memory"}
+	assert.False(t, inPlaceUpdatable(sbx, sbs))
+}
@@ -2,14 +2,21 @@ package sandboxset
 
 import (
 	"context"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
 	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/sandbox-manager/consts"
 	"github.com/openkruise/agents/pkg/utils/expectations"
+	"github.com/openkruise/agents/pkg/utils/templateref"
 	apps "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -31,9 +38,11 @@ type GroupedSandboxes struct {
 	Dead      []*agentsv1alpha1.Sandbox // Sandboxes should be deleted
 }
 
-func (r *Reconciler) initNewStatus(ss *agentsv1alpha1.SandboxSet) (*agentsv1alpha1.SandboxSetStatus, error) {
+// initNewStatus initializes the status with the update revision, which is hashed from ss whose templateRef has been
+// resolved, and the E2B defaults of the referenced SandboxTemplate.
+func (r *Reconciler) initNewStatus(ss *agentsv1alpha1.SandboxSet, e2b *agentsv1alpha1.SandboxTemplateE2B) (*agentsv1alpha1.SandboxSetStatus, error) {
 	newStatus := ss.Status.DeepCopy()
-	updateRevision, err := r.newRevision(ss, 0, nil)
+	updateRevision, err := r.newRevision(ss, e2b, 0, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -42,52 +51,72 @@ func (r *Reconciler) initNewStatus(ss *agentsv1alpha1.SandboxSet) (*agentsv1alph
 	return newStatus, nil
 }
 
+// setTemplateResolvedCondition records the result of resolving spec.templateRef, resolveErr must be nil or unresolvable.
+// The condition is removed if the sandboxset uses an inline template.
+func setTemplateResolvedCondition(newStatus *agentsv1alpha1.SandboxSetStatus, fromRef bool, resolveErr error) {
+	if !fromRef {
+		meta.RemoveStatusCondition(&newStatus.Conditions, agentsv1alpha1.SandboxSetConditionTemplateResolved)
+		return
+	}
+	cond := metav1.Condition{
+		Type:   agentsv1alpha1.SandboxSetConditionTemplateResolved,
+		Status: metav1.ConditionTrue,
+		Reason: agentsv1alpha1.SandboxTemplateReasonResolved,
+	}
+	if resolveErr != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = templateref.Reason(resolveErr)
+		cond.Message = resolveErr.Error()
+	}
+	meta.SetStatusCondition(&newStatus.Conditions, cond)
+}
+
 func saveStatusFromGroup(newStatus *agentsv1alpha1.SandboxSetStatus, groups GroupedSandboxes) (actualReplicas int32) {
 	newStatus.AvailableReplicas = int32(len(groups.Available))
+	newStatus.UsedReplicas = int32(len(groups.Used))
 	newStatus.Replicas = int32(len(groups.Creating)) + int32(len(groups.Available))
 	return newStatus.Replicas
 }
 
-/* Just Reserved for SandboxAutoScaler
-func calculateExpectPoolSize(ctx context.Context, total, unused int32, sbs *agentsv1alpha1.SandboxSet) (int32, error) {
-	log := klog.FromContext(ctx).V(consts.DebugLogLevel)
-	if sbs.Spec.MaxReplicas == sbs.Spec.MinReplicas {
-		return sbs.Spec.MinReplicas, nil // optimize
+// calculateDesiredReplicas returns the number of unused sandboxes sbs should have. Without spec.autoScaling it is
+// spec.replicas. Otherwise, the current desired replicas is kept as long as the percentage of used sandboxes stays
+// between the water marks, and is resized to bring the percentage back to the middle of the marks once it goes beyond.
+// The percentage is calculated from the desired replicas instead of the actual ones, so that the sandboxes still
+// being created or deleted do not trigger another decision.
+func calculateDesiredReplicas(ctx context.Context, sbs *agentsv1alpha1.SandboxSet, used, current int32) int32 {
+	as := sbs.Spec.AutoScaling
+	if as == nil {
+		return sbs.Spec.Replicas
 	}
-	actualWaterMark := int(total - unused)
-	highWaterMark, err := intstr.GetScaledValueFromIntOrPercent(sbs.Spec.HighWaterMark, int(total), false)
-	if err != nil {
-		return 0, err
-	}
-	lowWaterMark, err := intstr.GetScaledValueFromIntOrPercent(sbs.Spec.LowWaterMark, int(total), true)
-	if err != nil {
-		return 0, err
-	}
-	expectTotal := total
-	if actualWaterMark > highWaterMark {
-		// should scale up
-		expectScaleUp := int32(actualWaterMark - highWaterMark)
-		unusedAfterScaleUp := unused + expectScaleUp
-		actualScaleUp := expectScaleUp
-		if unusedAfterScaleUp > sbs.Spec.Replicas {
-			actualScaleUp = max(0, expectScaleUp-unusedAfterScaleUp-sbs.Spec.Replicas) // just in case
+	log := logf.FromContext(ctx).V(consts.DebugLogLevel)
+	highWaterMark := ptr.Deref(as.HighWaterMark, agentsv1alpha1.DefaultSandboxSetHighWaterMark)
+	lowWaterMark := ptr.Deref(as.LowWaterMark, agentsv1alpha1.DefaultSandboxSetLowWaterMark)
+	desired := current
+	if total := used + current; total > 0 {
+		usage := float64(used) * 100 / float64(total)
+		if usage > float64(highWaterMark) || usage < float64(lowWaterMark) {
+			target := max((highWaterMark+lowWaterMark)/2, 1)
+			desired = int32(math.Ceil(float64(used)*100/float64(target))) - used
 		}
-		log.Info("actual scale up calculated", "actualScaleUp", actualScaleUp, "expectScaleUp", expectScaleUp,
-			"unusedAfterScaleUp", unusedAfterScaleUp, "maxUnused", sbs.Spec.Replicas, "highWaterMark", highWaterMark, "lowWaterMark", lowWaterMark)
-		expectTotal = total + actualScaleUp
+		log.Info("pool usage calculated", "used", used, "total", total, "usage", usage,
+			"highWaterMark", highWaterMark, "lowWaterMark", lowWaterMark)
 	}
-	if actualWaterMark < lowWaterMark {
-		// should scale down
-		expectTotal = total + int32(actualWaterMark-lowWaterMark)
+	minReplicas := as.MinReplicas
+	if minReplicas == 0 && !coldStartEnabled(sbs) {
+		// an empty pool has no usage to scale up from, keep one unused sandbox unless they are created on demand
+		minReplicas = min(1, as.MaxReplicas)
 	}
-	// limit
-	expectTotal = min(expectTotal, sbs.Spec.MaxReplicas)
-	expectTotal = max(expectTotal, sbs.Spec.MinReplicas)
-	log.Info("expect pool size calculated", "expectTotal", expectTotal, "oldTotal", total,
-		"highWaterMark", highWaterMark, "lowWaterMark", lowWaterMark, "actualWaterMark", actualWaterMark)
-	return expectTotal, nil
+	desired = min(desired, as.MaxReplicas)
+	desired = max(desired, minReplicas)
+	log.Info("desired replicas calculated", "current", current, "desired", desired)
+	return desired
+}
+
+// coldStartEnabled returns whether the sandboxes are created on demand when the SandboxSet has no one to claim.
+func coldStartEnabled(sbs *agentsv1alpha1.SandboxSet) bool {
+	seconds, err := strconv.Atoi(sbs.Annotations[agentsv1alpha1.AnnotationColdStartTimeoutSeconds])
+	return err == nil && seconds > 0
 }
-*/
 
 func clearAndInitInnerKeys(m map[string]string) map[string]string {
 	if m == nil {
@@ -105,8 +134,9 @@ func clearAndInitInnerKeys(m map[string]string) map[string]string {
 // The Revision of the returned ControllerRevision is set to revision. If the returned error is nil, the returned
 // ControllerRevision is valid. StatefulSet revisions are stored as patches that re-apply the current state of set
 // to a new StatefulSet using a strategic merge patch to replace the saved state of the new StatefulSet.
-func (r *Reconciler) newRevision(set *agentsv1alpha1.SandboxSet, revision int64, collisionCount *int32) (*apps.ControllerRevision, error) {
-	patch, err := r.getPatch(set)
+func (r *Reconciler) newRevision(set *agentsv1alpha1.SandboxSet, e2b *agentsv1alpha1.SandboxTemplateE2B, revision int64,
+	collisionCount *int32) (*apps.ControllerRevision, error) {
+	patch, err := r.getPatch(set, e2b)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,160 @@
+package sandboxset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/openkruise/agents/api/v1alpha1"
+)
+
+func TestCalculateDesiredReplicas(t *testing.T) {
+	tests := []struct {
+		name        string
+		replicas    int32
+		autoScaling *v1alpha1.SandboxSetAutoScaling
+		used        int32
+		current     int32
+		coldStart   bool
+		expect      int32
+	}{
+		{
+			name:     "fixed replicas",
+			replicas: 3,
+			used:     100,
+			current:  10,
+			expect:   3,
+		},
+		{
+			name:        "empty pool starts from minReplicas",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{MinReplicas: 2, MaxReplicas: 10},
+			expect:      2,
+		},
+		{
+			name:        "empty pool without minReplicas keeps one sandbox",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{MaxReplicas: 10},
+			expect:      1,
+		},
+		{
+			name:        "idle pool without minReplicas keeps one sandbox",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{MaxReplicas: 10},
+			current:     1,
+			expect:      1,
+		},
+		{
+			name:        "empty pool scaled to zero with cold start",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{MaxReplicas: 10},
+			coldStart:   true,
+			expect:      0,
+		},
+		{
+			name:        "pool scaled up from zero with cold start",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{MaxReplicas: 10},
+			used:        3,
+			coldStart:   true,
+			// 3 used should be 60% of the pool, which is 5 in total
+			expect: 2,
+		},
+		{
+			name:        "usage between water marks",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{MinReplicas: 2, MaxReplicas: 10},
+			used:        6,
+			current:     4,
+			expect:      4,
+		},
+		{
+			name:        "usage above high water mark",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{MinReplicas: 2, MaxReplicas: 10},
+			used:        9,
+			current:     1,
+			// 9 used should be 60% of the pool, which is 15 in total
+			expect: 6,
+		},
+		{
+			name:        "scale up limited by maxReplicas",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{MinReplicas: 2, MaxReplicas: 10},
+			used:        90,
+			current:     10,
+			expect:      10,
+		},
+		{
+			name:        "usage below low water mark",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{MinReplicas: 2, MaxReplicas: 10},
+			used:        3,
+			current:     7,
+			// 3 used should be 60% of the pool, which is 5 in total
+			expect: 2,
+		},
+		{
+			name:        "scale down limited by minReplicas",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{MinReplicas: 3, MaxReplicas: 10},
+			used:        0,
+			current:     10,
+			expect:      3,
+		},
+		{
+			name: "custom water marks",
+			autoScaling: &v1alpha1.SandboxSetAutoScaling{
+				MaxReplicas:   200,
+				HighWaterMark: ptr.To[int32](50),
+				LowWaterMark:  ptr.To[int32](10),
+			},
+			used:    60,
+			current: 40,
+			// 60 used should be 30% of the pool, which is 200 in total
+			expect: 140,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sbs := getSandboxSet(tt.replicas)
+			sbs.Spec.AutoScaling = tt.autoScaling
+			if tt.coldStart {
+				sbs.Annotations = map[string]string{v1alpha1.AnnotationColdStartTimeoutSeconds: "30"}
+			}
+			assert.Equal(t, tt.expect, calculateDesiredReplicas(context.TODO(), sbs, tt.used, tt.current))
+		})
+	}
+}
+
+func TestNewRevision(t *testing.T) {
+	reconciler := &Reconciler{Codec: codec}
+	hash := func(sbs *v1alpha1.SandboxSet, e2b *v1alpha1.SandboxTemplateE2B) string {
+		status, err := reconciler.initNewStatus(sbs, e2b)
+		assert.NoError(t, err)
+		return status.UpdateRevision
+	}
+	base := hash(getSandboxSet(1), nil)
+	// the replicas, the unrelated annotations and the empty e2b defaults do not change the revision
+	sbs := getSandboxSet(2)
+	sbs.Annotations = map[string]string{"foo": "bar"}
+	assert.Equal(t, base, hash(sbs, nil))
+	assert.Equal(t, base, hash(getSandboxSet(1), &v1alpha1.SandboxTemplateE2B{}))
+
+	// everything copied into the created sandboxes changes the revision
+	sbs = getSandboxSet(1)
+	sbs.Spec.Template.Spec.Containers[0].Image = "new"
+	assert.NotEqual(t, base, hash(sbs, nil))
+
+	sbs = getSandboxSet(1)
+	sbs.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}
+	assert.NotEqual(t, base, hash(sbs, nil))
+
+	sbs = getSandboxSet(1)
+	sbs.Spec.PersistentContents = []string{"memory"}
+	assert.NotEqual(t, base, hash(sbs, nil))
+
+	assert.NotEqual(t, base, hash(getSandboxSet(1), &v1alpha1.SandboxTemplateE2B{InitEnvd: true}))
+	assert.NotEqual(t, hash(getSandboxSet(1), &v1alpha1.SandboxTemplateE2B{TimeoutSeconds: ptr.To[int32](60)}),
+		hash(getSandboxSet(1), &v1alpha1.SandboxTemplateE2B{TimeoutSeconds: ptr.To[int32](120)}))
+
+	for _, key := range revisionAnnotations {
+		sbs = getSandboxSet(1)
+		sbs.Annotations = map[string]string{key: "1"}
+		assert.NotEqual(t, base, hash(sbs, nil), key)
+	}
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2025 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sandboxtemplate
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/controller/sandboxset"
+	"github.com/openkruise/agents/pkg/discovery"
+	"github.com/openkruise/agents/pkg/features"
+	"github.com/openkruise/agents/pkg/sandbox-manager/consts"
+	"github.com/openkruise/agents/pkg/utils"
+	utilfeature "github.com/openkruise/agents/pkg/utils/feature"
+	"github.com/openkruise/agents/pkg/utils/fieldindex"
+	"github.com/openkruise/agents/pkg/utils/templateref"
+)
+
+func init() {
+	flag.IntVar(&concurrentReconciles, "sandboxtemplate-workers", concurrentReconciles, "Max concurrent workers for SandboxTemplate controller.")
+}
+
+var (
+	concurrentReconciles = 3
+	controllerKind       = agentsv1alpha1.SandboxTemplateControllerKind
+)
+
+func Add(mgr manager.Manager) error {
+	if !utilfeature.DefaultFeatureGate.Enabled(features.SandboxTemplateGate) || !discovery.DiscoverGVK(controllerKind) {
+		return nil
+	}
+	err := (&Reconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr)
+	if err != nil {
+		return err
+	}
+	klog.Infof("start SandboxTemplateReconciler success")
+	return nil
+}
+
+// Reconciler records each spec of a SandboxTemplate as an immutable ControllerRevision
+type Reconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+const (
+	EventRevisionCreated    = "RevisionCreated"
+	EventRevisionRolledBack = "RevisionRolledBack"
+	EventRevisionDeleted    = "RevisionDeleted"
+)
+
+// +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxtemplates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxtemplates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=agents.kruise.io,resources=sandboxtemplates/finalizers,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;update;patch;delete
+
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx).WithValues("sandboxtemplate", req.NamespacedName)
+	ctx = logf.IntoContext(ctx, log)
+	sbt := &agentsv1alpha1.SandboxTemplate{}
+	if err := r.Get(ctx, req.NamespacedName, sbt); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// revisions are garbage collected with the template by their owner references
+	if sbt.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	revisions, err := r.listRevisions(ctx, sbt)
+	if err != nil {
+		log.Error(err, "failed to list revisions")
+		return ctrl.Result{}, err
+	}
+	collisionCount := ptr.Deref(sbt.Status.CollisionCount, 0)
+	current, err := r.syncCurrentRevision(ctx, sbt, revisions, &collisionCount)
+	if err != nil {
+		log.Error(err, "failed to sync current revision")
+		// the collision count must be recorded even if the revision is not created, so that the next try uses a new name
+		if sbt.Status.CollisionCount == nil || *sbt.Status.CollisionCount != collisionCount {
+			newStatus := sbt.Status.DeepCopy()
+			newStatus.CollisionCount = ptr.To(collisionCount)
+			_ = r.updateSandboxTemplateStatus(ctx, *newStatus, sbt)
+		}
+		return ctrl.Result{}, err
+	}
+
+	newStatus := sbt.Status.DeepCopy()
+	newStatus.ObservedGeneration = sbt.Generation
+	newStatus.CurrentRevision = current.Name
+	newStatus.CollisionCount = ptr.To(collisionCount)
+	if err = r.updateSandboxTemplateStatus(ctx, *newStatus, sbt); err != nil {
+		log.Error(err, "failed to update sandboxtemplate status")
+		return ctrl.Result{}, err
+	}
+
+	// revisions have to be listed again, because the current one may be created or rolled back
+	if revisions, err = r.listRevisions(ctx, sbt); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err = r.truncateHistory(ctx, sbt, revisions, current); err != nil {
+		log.Error(err, "failed to truncate revision history")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// listRevisions returns the revisions controlled by sbt, sorted by revision number in ascending order.
+func (r *Reconciler) listRevisions(ctx context.Context, sbt *agentsv1alpha1.SandboxTemplate) ([]*apps.ControllerRevision, error) {
+	revisionList := &apps.ControllerRevisionList{}
+	if err := r.List(ctx, revisionList, client.InNamespace(sbt.Namespace),
+		client.MatchingLabels{agentsv1alpha1.LabelSandboxTemplate: sbt.Name},
+	); err != nil {
+		return nil, err
+	}
+	var revisions []*apps.ControllerRevision
+	for i := range revisionList.Items {
+		if metav1.IsControlledBy(&revisionList.Items[i], sbt) {
+			revisions = append(revisions, &revisionList.Items[i])
+		}
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].Revision < revisions[j].Revision
+	})
+	return revisions, nil
+}
+
+// syncCurrentRevision returns the revision recording the spec of sbt. An existing revision with the same content is
+// reused and becomes the latest one (e.g. the spec is rolled back), otherwise a new revision is created.
+// collisionCount is increased each time the name of the new revision conflicts with a revision of different content.
+func (r *Reconciler) syncCurrentRevision(ctx context.Context, sbt *agentsv1alpha1.SandboxTemplate,
+	revisions []*apps.ControllerRevision, collisionCount *int32) (*apps.ControllerRevision, error) {
+	log := logf.FromContext(ctx)
+	data, err := templateref.EncodeRevision(&sbt.Spec)
+	if err != nil {
+		return nil, err
+	}
+	nextRevision := int64(1)
+	if len(revisions) > 0 {
+		nextRevision = revisions[len(revisions)-1].Revision + 1
+	}
+	candidate, err := r.newRevision(sbt, data, nextRevision, collisionCount)
+	if err != nil {
+		return nil, err
+	}
+
+	var equal *apps.ControllerRevision
+	for _, revision := range revisions {
+		if equalRevision(revision, candidate) {
+			equal = revision
+		}
+	}
+	if equal != nil {
+		if equal.Revision == nextRevision-1 {
+			return equal, nil
+		}
+		clone := equal.DeepCopy()
+		clone.Revision = nextRevision
+		if err = r.Update(ctx, clone); err != nil {
+			return nil, err
+		}
+		log.Info("revision rolled back", "revision", clone.Name, "number", nextRevision)
+		r.Recorder.Eventf(sbt, corev1.EventTypeNormal, EventRevisionRolledBack, "Revision %s rolled back", clone.Name)
+		return clone, nil
+	}
+
+	for {
+		err = r.Create(ctx, candidate)
+		if err == nil {
+			log.Info("revision created", "revision", candidate.Name, "number", nextRevision)
+			r.Recorder.Eventf(sbt, corev1.EventTypeNormal, EventRevisionCreated, "Revision %s created", candidate.Name)
+			return candidate, nil
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+		existing := &apps.ControllerRevision{}
+		if err = r.Get(ctx, client.ObjectKeyFromObject(candidate), existing); err != nil {
+			return nil, err
+		}
+		if metav1.IsControlledBy(existing, sbt) && equalRevision(existing, candidate) {
+			return existing, nil
+		}
+		*collisionCount++
+		log.Info("revision name collided", "revision", candidate.Name, "collisionCount", *collisionCount)
+		if candidate, err = r.newRevision(sbt, data, nextRevision, collisionCount); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *Reconciler) newRevision(sbt *agentsv1alpha1.SandboxTemplate, data runtime.RawExtension, revision int64,
+	collisionCount *int32) (*apps.ControllerRevision, error) {
+	cr, err := sandboxset.NewControllerRevision(sbt, controllerKind,
+		map[string]string{agentsv1alpha1.LabelSandboxTemplate: sbt.Name}, data, revision, collisionCount)
+	if err != nil {
+		return nil, err
+	}
+	cr.Namespace = sbt.Namespace
+	return cr, nil
+}
+
+// equalRevision reports whether the two revisions record the same spec, the hashes are not compared because they
+// differ with the collision count.
+func equalRevision(lhs, rhs *apps.ControllerRevision) bool {
+	return bytes.Equal(lhs.Data.Raw, rhs.Data.Raw)
+}
+
+// truncateHistory deletes the oldest revisions beyond spec.revisionHistoryLimit. The current revision and the
+// revisions pinned by Sandboxes or SandboxSets are never deleted, and they are not counted in the limit.
+func (r *Reconciler) truncateHistory(ctx context.Context, sbt *agentsv1alpha1.SandboxTemplate,
+	revisions []*apps.ControllerRevision, current *apps.ControllerRevision) error {
+	log := logf.FromContext(ctx)
+	pinned, err := r.pinnedRevisions(ctx, sbt)
+	if err != nil {
+		return err
+	}
+	var history []*apps.ControllerRevision
+	for _, revision := range revisions {
+		if revision.Name == current.Name || pinned.Has(revision.Name) {
+			continue
+		}
+		history = append(history, revision)
+	}
+	limit := int(ptr.Deref(sbt.Spec.RevisionHistoryLimit, agentsv1alpha1.DefaultSandboxTemplateRevisionHistoryLimit))
+	if len(history) <= limit {
+		return nil
+	}
+	for _, revision := range history[:len(history)-limit] {
+		if err = r.Delete(ctx, revision); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		log.V(consts.DebugLogLevel).Info("revision deleted", "revision", revision.Name)
+		r.Recorder.Eventf(sbt, corev1.EventTypeNormal, EventRevisionDeleted, "Revision %s deleted", revision.Name)
+	}
+	return nil
+}
+
+// pinnedRevisions returns the names of the revisions of sbt pinned by spec.templateRef.revision of Sandboxes or
+// SandboxSets.
+func (r *Reconciler) pinnedRevisions(ctx context.Context, sbt *agentsv1alpha1.SandboxTemplate) (sets.Set[string], error) {
+	pinned := sets.New[string]()
+	indexKey := templateref.IndexKey(controllerKind.Kind, sbt.Name)
+	sandboxList := &agentsv1alpha1.SandboxList{}
+	if err := r.List(ctx, sandboxList, client.InNamespace(sbt.Namespace),
+		client.MatchingFields{fieldindex.IndexNameForTemplateRef: indexKey},
+	); err != nil {
+		return nil, err
+	}
+	for i := range sandboxList.Items {
+		if ref := sandboxList.Items[i].Spec.TemplateRef; ref != nil && ref.Revision != "" {
+			pinned.Insert(ref.Revision)
+		}
+	}
+	sbsList := &agentsv1alpha1.SandboxSetList{}
+	if err := r.List(ctx, sbsList, client.InNamespace(sbt.Namespace),
+		client.MatchingFields{fieldindex.IndexNameForTemplateRef: indexKey},
+	); err != nil {
+		return nil, err
+	}
+	for i := range sbsList.Items {
+		if ref := sbsList.Items[i].Spec.TemplateRef; ref != nil && ref.Revision != "" {
+			pinned.Insert(ref.Revision)
+		}
+	}
+	return pinned, nil
+}
+
+func (r *Reconciler) updateSandboxTemplateStatus(ctx context.Context, newStatus agentsv1alpha1.SandboxTemplateStatus, sbt *agentsv1alpha1.SandboxTemplate) error {
+	log := logf.FromContext(ctx).V(consts.DebugLogLevel)
+	if reflect.DeepEqual(sbt.Status, newStatus) {
+		return nil
+	}
+	clone := sbt.DeepCopy()
+	clone.Status = newStatus
+	if err := r.Status().Update(ctx, clone); err != nil {
+		return fmt.Errorf("failed to update sandboxtemplate status: %w", err)
+	}
+	log.Info("update sandboxtemplate status success", "status", utils.DumpJson(newStatus))
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	controllerName := "sandboxtemplate-controller"
+	r.Recorder = mgr.GetEventRecorderFor(controllerName)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		WithOptions(controller.Options{MaxConcurrentReconciles: concurrentReconciles}).
+		For(&agentsv1alpha1.SandboxTemplate{}).
+		Owns(&apps.ControllerRevision{}).
+		Complete(r)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2025 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sandboxtemplate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/utils/fieldindex"
+	"github.com/openkruise/agents/pkg/utils/templateref"
+)
+
+func newTestReconciler(objs ...client.Object) *Reconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = agentsv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&agentsv1alpha1.SandboxTemplate{}).
+		WithIndex(&agentsv1alpha1.Sandbox{}, fieldindex.IndexNameForTemplateRef, fieldindex.TemplateRefIndexFunc).
+		WithIndex(&agentsv1alpha1.SandboxSet{}, fieldindex.IndexNameForTemplateRef, fieldindex.TemplateRefIndexFunc).
+		Build()
+	return &Reconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(100)}
+}
+
+func newSandboxTemplate(image string) *agentsv1alpha1.SandboxTemplate {
+	return &agentsv1alpha1.SandboxTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "tmpl", Namespace: "default", UID: types.UID("tmpl-uid"), Generation: 1},
+		Spec: agentsv1alpha1.SandboxTemplateSpec{
+			Template: &corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: image}}},
+			},
+			E2B: &agentsv1alpha1.SandboxTemplateE2B{TimeoutSeconds: ptr.To[int32](600)},
+		},
+	}
+}
+
+// setImage changes the spec of the template and bumps its generation like the api-server does.
+func setImage(t *testing.T, r *Reconciler, image string) {
+	sbt := &agentsv1alpha1.SandboxTemplate{}
+	assert.NoError(t, r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "tmpl"}, sbt))
+	sbt.Spec.Template.Spec.Containers[0].Image = image
+	sbt.Generation++
+	assert.NoError(t, r.Update(context.Background(), sbt))
+}
+
+func reconcileAndGet(t *testing.T, r *Reconciler) *agentsv1alpha1.SandboxTemplate {
+	key := types.NamespacedName{Namespace: "default", Name: "tmpl"}
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	assert.NoError(t, err)
+	sbt := &agentsv1alpha1.SandboxTemplate{}
+	assert.NoError(t, r.Get(context.Background(), key, sbt))
+	return sbt
+}
+
+func TestReconcile_Revisions(t *testing.T) {
+	r := newTestReconciler(newSandboxTemplate("v1"))
+	ctx := context.Background()
+
+	// the first revision is created
+	sbt := reconcileAndGet(t, r)
+	assert.Equal(t, sbt.Generation, sbt.Status.ObservedGeneration)
+	assert.NotEmpty(t, sbt.Status.CurrentRevision)
+	v1 := sbt.Status.CurrentRevision
+	revision := &apps.ControllerRevision{}
+	assert.NoError(t, r.Get(ctx, types.NamespacedName{Namespace: "default", Name: v1}, revision))
+	assert.Equal(t, int64(1), revision.Revision)
+	assert.Equal(t, "tmpl", revision.Labels[agentsv1alpha1.LabelSandboxTemplate])
+	assert.True(t, metav1.IsControlledBy(revision, sbt))
+	spec, err := templateref.DecodeRevision(revision)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, int32(600), *spec.E2B.TimeoutSeconds)
+
+	// reconciling again is a no-op
+	sbt = reconcileAndGet(t, r)
+	assert.Equal(t, v1, sbt.Status.CurrentRevision)
+
+	// a new spec is recorded as a new revision, the old one is kept
+	setImage(t, r, "v2")
+	sbt = reconcileAndGet(t, r)
+	assert.Equal(t, sbt.Generation, sbt.Status.ObservedGeneration)
+	v2 := sbt.Status.CurrentRevision
+	assert.NotEqual(t, v1, v2)
+	assert.NoError(t, r.Get(ctx, types.NamespacedName{Namespace: "default", Name: v2}, revision))
+	assert.Equal(t, int64(2), revision.Revision)
+	assert.NoError(t, r.Get(ctx, types.NamespacedName{Namespace: "default", Name: v1}, revision))
+
+	// rolling back reuses the old revision and makes it the latest one
+	setImage(t, r, "v1")
+	sbt = reconcileAndGet(t, r)
+	assert.Equal(t, v1, sbt.Status.CurrentRevision)
+	assert.NoError(t, r.Get(ctx, types.NamespacedName{Namespace: "default", Name: v1}, revision))
+	assert.Equal(t, int64(3), revision.Revision)
+	revisions, err := r.listRevisions(ctx, sbt)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+}
+
+func TestReconcile_TruncateHistory(t *testing.T) {
+	sbt := newSandboxTemplate("v0")
+	sbt.Spec.RevisionHistoryLimit = ptr.To[int32](1)
+	r := newTestReconciler(sbt)
+	ctx := context.Background()
+
+	v0 := reconcileAndGet(t, r).Status.CurrentRevision
+	var names []string
+	for _, image := range []string{"v1", "v2", "v3"} {
+		setImage(t, r, image)
+		names = append(names, reconcileAndGet(t, r).Status.CurrentRevision)
+		if image == "v1" {
+			// pin the revision of v1
+			assert.NoError(t, r.Create(ctx, &agentsv1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{Name: "pinned", Namespace: "default"},
+				Spec: agentsv1alpha1.SandboxSpec{EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
+					TemplateRef: &agentsv1alpha1.SandboxTemplateRef{
+						Name:       "tmpl",
+						Kind:       ptr.To(controllerKind.Kind),
+						APIVersion: ptr.To(controllerKind.GroupVersion().String()),
+						Revision:   names[0],
+					},
+				}},
+			}))
+		}
+	}
+	sbt = reconcileAndGet(t, r)
+	revisions, err := r.listRevisions(ctx, sbt)
+	assert.NoError(t, err)
+	var got []string
+	for _, revision := range revisions {
+		got = append(got, revision.Name)
+	}
+	// v0 is deleted, v1 is pinned, v2 is kept in history and v3 is current
+	assert.Equal(t, names, got)
+	assert.NotContains(t, got, v0)
+}
@@ -29,11 +29,19 @@ const (
 
 	// SandboxSetGate enable SandboxSet-controller to create a sandbox pod.
 	SandboxSetGate featuregate.Feature = "SandboxSet"
+
+	// SandboxTemplateGate enable SandboxTemplate-controller to record the revisions of sandbox templates.
+	SandboxTemplateGate featuregate.Feature = "SandboxTemplate"
+
+	// SandboxClaimGate enable SandboxClaim-controller to bind sandboxes of SandboxSets to claims.
+	SandboxClaimGate featuregate.Feature = "SandboxClaim"
 )
 
 var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
-	SandboxGate:    {Default: true, PreRelease: featuregate.Alpha},
-	SandboxSetGate: {Default: true, PreRelease: featuregate.Alpha},
+	SandboxGate:         {Default: true, PreRelease: featuregate.Alpha},
+	SandboxSetGate:      {Default: true, PreRelease: featuregate.Alpha},
+	SandboxTemplateGate: {Default: true, PreRelease: featuregate.Alpha},
+	SandboxClaimGate:    {Default: true, PreRelease: featuregate.Alpha},
 }
 
 func init() {
@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openkruise/agents/pkg/servers/web"
+	"k8s.io/klog/v2"
+)
+
+const ActivityAPI = "/activity"
+
+// ActivitySyncInterval is the interval to sync the last activities recorded locally to the peers
+var ActivitySyncInterval = 10 * time.Second
+
+// activities records the last time each sandbox was requested through the proxy
+type activities struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+	// dirty are the sandboxes requested through this proxy since the last sync
+	dirty map[string]struct{}
+	// open counts the requests in progress to each sandbox through this proxy, e.g. the WebSocket sessions
+	open map[string]int
+	// retry are the sandboxes failed to be synced to each peer, which are synced to the peer again in the next sync
+	retry map[string]map[string]struct{}
+}
+
+func newActivities() *activities {
+	return &activities{
+		last:  make(map[string]time.Time),
+		dirty: make(map[string]struct{}),
+		open:  make(map[string]int),
+		retry: make(map[string]map[string]struct{}),
+	}
+}
+
+// RecordActivity records a request to the sandbox at t, which is synced to the peers later.
+func (s *Server) RecordActivity(id string, t time.Time) {
+	s.activities.mu.Lock()
+	defer s.activities.mu.Unlock()
+	if t.After(s.activities.last[id]) {
+		s.activities.last[id] = t
+		s.activities.dirty[id] = struct{}{}
+	}
+}
+
+// trackRequest keeps the sandbox active until the request in progress is done with ctx. A long-lived request, such as
+// a WebSocket session, sends no request headers after the first ones, so it would be taken as idle otherwise.
+func (s *Server) trackRequest(ctx context.Context, id string) {
+	s.activities.mu.Lock()
+	s.activities.open[id]++
+	s.activities.mu.Unlock()
+	context.AfterFunc(ctx, func() {
+		if _, ok := s.LoadRoute(id); ok {
+			s.RecordActivity(id, time.Now())
+		}
+		s.activities.mu.Lock()
+		defer s.activities.mu.Unlock()
+		if s.activities.open[id] > 1 {
+			s.activities.open[id]--
+		} else {
+			delete(s.activities.open, id)
+		}
+	})
+}
+
+// LoadActivity returns the last time the sandbox was requested through any of the proxies.
+func (s *Server) LoadActivity(id string) (time.Time, bool) {
+	s.activities.mu.Lock()
+	defer s.activities.mu.Unlock()
+	t, ok := s.activities.last[id]
+	return t, ok
+}
+
+func (s *Server) deleteActivity(id string) {
+	s.activities.mu.Lock()
+	defer s.activities.mu.Unlock()
+	delete(s.activities.last, id)
+	delete(s.activities.dirty, id)
+	for _, ids := range s.activities.retry {
+		delete(ids, id)
+	}
+}
+
+// mergeActivities merges the activities synced from a peer, which are not synced again.
+func (s *Server) mergeActivities(synced map[string]time.Time) {
+	s.activities.mu.Lock()
+	defer s.activities.mu.Unlock()
+	for id, t := range synced {
+		if _, ok := s.routes.Load(id); !ok {
+			continue
+		}
+		if t.After(s.activities.last[id]) {
+			s.activities.last[id] = t
+		}
+	}
+}
+
+// SyncActivitiesWithPeers sends the activities recorded since the last sync to the peers, the sandboxes with requests
+// in progress are active till now. The activities failed to be sent to a peer are sent to it again in the next sync,
+// or the idle checker of the peer would take the sandboxes as idle.
+func (s *Server) SyncActivitiesWithPeers() error {
+	now := time.Now()
+	peers := s.healthyPeers()
+	known := make(map[string]struct{})
+	for _, peer := range s.ListPeers() {
+		known[peer.IP] = struct{}{}
+	}
+
+	s.activities.mu.Lock()
+	for id := range s.activities.open {
+		if _, ok := s.routes.Load(id); ok && now.After(s.activities.last[id]) {
+			s.activities.last[id] = now
+			s.activities.dirty[id] = struct{}{}
+		}
+	}
+	for ip := range s.activities.retry {
+		if _, ok := known[ip]; !ok {
+			delete(s.activities.retry, ip)
+		}
+	}
+	toSync := make(map[string]map[string]time.Time, len(peers))
+	for _, peer := range peers {
+		synced := make(map[string]time.Time, len(s.activities.dirty)+len(s.activities.retry[peer.IP]))
+		for id := range s.activities.dirty {
+			synced[id] = s.activities.last[id]
+		}
+		for id := range s.activities.retry[peer.IP] {
+			synced[id] = s.activities.last[id]
+		}
+		delete(s.activities.retry, peer.IP)
+		if len(synced) > 0 {
+			toSync[peer.IP] = synced
+		}
+	}
+	s.activities.dirty = make(map[string]struct{})
+	s.activities.mu.Unlock()
+
+	var errs []error
+	for ip, synced := range toSync {
+		body, err := json.Marshal(synced)
+		if err == nil {
+			_, err = s.requestPeer(http.MethodPost, ip, ActivityAPI, body)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("peer %s: %w", ip, err))
+			s.retryActivities(ip, synced)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to sync activities: %v", errs)
+	}
+	return nil
+}
+
+// retryActivities marks the activities failed to be synced to the peer, which are synced to it in the next sync.
+func (s *Server) retryActivities(ip string, synced map[string]time.Time) {
+	s.activities.mu.Lock()
+	defer s.activities.mu.Unlock()
+	ids := s.activities.retry[ip]
+	if ids == nil {
+		ids = make(map[string]struct{}, len(synced))
+		s.activities.retry[ip] = ids
+	}
+	for id := range synced {
+		if _, ok := s.activities.last[id]; ok {
+			ids[id] = struct{}{}
+		}
+	}
+}
+
+func (s *Server) handleActivity(r *http.Request) (web.ApiResponse[struct{}], *web.ApiError) {
+	log := klog.FromContext(r.Context()).V(LogLevel)
+	var synced map[string]time.Time
+	if err := json.NewDecoder(r.Body).Decode(&synced); err != nil {
+		return web.ApiResponse[struct{}]{}, &web.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("failed to unmarshal body: %s", err.Error()),
+		}
+	}
+	s.mergeActivities(synced)
+	log.Info("activities synced", "num", len(synced))
+	return web.ApiResponse[struct{}]{
+		Code: http.StatusNoContent,
+	}, nil
+}
@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+)
+
+func TestServer_RecordActivity(t *testing.T) {
+	s := NewServer(&testRequestAdapter{
+		isSandboxRequest: true,
+		mapResult:        mapResult{sandboxID: "sandbox1", sandboxPort: 8080},
+	})
+	s.SetRoute(Route{ID: "sandbox1", IP: "192.168.1.10", State: agentsv1alpha1.SandboxStateRunning})
+	if _, ok := s.LoadActivity("sandbox1"); ok {
+		t.Errorf("Expected no activity recorded")
+	}
+
+	// recorded by the proxied requests
+	start := time.Now()
+	err := s.Process(&mockProcessServer{reqs: []*extProcPb.ProcessingRequest{
+		{
+			Request: &extProcPb.ProcessingRequest_RequestHeaders{
+				RequestHeaders: &extProcPb.HttpHeaders{
+					Headers: &corev3.HeaderMap{
+						Headers: []*corev3.HeaderValue{
+							{Key: ":scheme", RawValue: []byte("http")},
+							{Key: ":authority", RawValue: []byte("localhost:9002")},
+							{Key: ":path", RawValue: []byte("/sandbox")},
+						},
+					},
+				},
+			},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	last, ok := s.LoadActivity("sandbox1")
+	if !ok || last.Before(start) {
+		t.Errorf("Expected activity recorded after %v, got %v", start, last)
+	}
+
+	// the earlier activity is ignored
+	s.RecordActivity("sandbox1", start.Add(-time.Minute))
+	if got, _ := s.LoadActivity("sandbox1"); !got.Equal(last) {
+		t.Errorf("Expected activity %v, got %v", last, got)
+	}
+
+	// synced to peers and cleared
+	if err = s.SyncActivitiesWithPeers(); err != nil {
+		t.Errorf("SyncActivitiesWithPeers() error = %v", err)
+	}
+	if len(s.activities.dirty) != 0 {
+		t.Errorf("Expected dirty activities cleared, got %v", s.activities.dirty)
+	}
+
+	s.DeleteRoute("sandbox1")
+	if _, ok = s.LoadActivity("sandbox1"); ok {
+		t.Errorf("Expected activity deleted with the route")
+	}
+}
+
+func TestServer_handleActivity(t *testing.T) {
+	s := NewServer(nil)
+	s.SetRoute(Route{ID: "sandbox1", State: agentsv1alpha1.SandboxStateRunning})
+	local := time.Now().Add(-time.Minute)
+	s.RecordActivity("sandbox1", local)
+	s.activities.dirty = map[string]struct{}{}
+
+	synced := time.Now().Truncate(time.Second)
+	body, _ := json.Marshal(map[string]time.Time{
+		"sandbox1": synced,
+		"unknown":  synced,
+	})
+	req := httptest.NewRequest(http.MethodPost, ActivityAPI, bytes.NewReader(body))
+	resp, apiErr := s.handleActivity(req)
+	if apiErr != nil || resp.Code != http.StatusNoContent {
+		t.Fatalf("handleActivity() code = %d, error = %v", resp.Code, apiErr)
+	}
+	if got, _ := s.LoadActivity("sandbox1"); !got.Equal(synced) {
+		t.Errorf("Expected activity %v, got %v", synced, got)
+	}
+	if _, ok := s.LoadActivity("unknown"); ok {
+		t.Errorf("Expected activity of unknown sandbox ignored")
+	}
+	if len(s.activities.dirty) != 0 {
+		t.Errorf("Expected synced activities not synced again, got %v", s.activities.dirty)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, ActivityAPI, bytes.NewReader([]byte("bad")))
+	if _, apiErr = s.handleActivity(req); apiErr == nil || apiErr.Code != http.StatusBadRequest {
+		t.Errorf("Expected bad request, got %v", apiErr)
+	}
+}
+
+func TestServer_trackRequest(t *testing.T) {
+	s := NewServer(nil)
+	s.SetRoute(Route{ID: "sandbox1", State: agentsv1alpha1.SandboxStateRunning})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.trackRequest(ctx, "sandbox1")
+	s.trackRequest(ctx, "sandbox1")
+
+	// the long-lived request keeps the sandbox active without more request headers
+	start := time.Now()
+	if err := s.SyncActivitiesWithPeers(); err != nil {
+		t.Errorf("SyncActivitiesWithPeers() error = %v", err)
+	}
+	if last, ok := s.LoadActivity("sandbox1"); !ok || last.Before(start) {
+		t.Errorf("Expected activity refreshed after %v, got %v", start, last)
+	}
+
+	// the activity is recorded when the request is done
+	start = time.Now()
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.activities.mu.Lock()
+		open := len(s.activities.open)
+		s.activities.mu.Unlock()
+		if open == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected no requests in progress, got %d", open)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if last, _ := s.LoadActivity("sandbox1"); last.Before(start) {
+		t.Errorf("Expected activity recorded after %v, got %v", start, last)
+	}
+}
+
+func TestServer_SyncActivitiesWithPeers_Retry(t *testing.T) {
+	s := NewServer(nil)
+	s.SetRoute(Route{ID: "sandbox1", State: agentsv1alpha1.SandboxStateRunning})
+	s.SetRoute(Route{ID: "sandbox2", State: agentsv1alpha1.SandboxStateRunning})
+	// an address of TEST-NET-1, which is never reachable
+	s.SetPeer("192.0.2.1")
+	s.RecordActivity("sandbox1", time.Now())
+
+	if err := s.SyncActivitiesWithPeers(); err == nil {
+		t.Fatalf("Expected error syncing to the unreachable peer")
+	}
+	if _, ok := s.activities.retry["192.0.2.1"]["sandbox1"]; !ok {
+		t.Errorf("Expected activity kept for the failed peer, got %v", s.activities.retry)
+	}
+
+	// the failed ones are synced again together with the new ones
+	s.RecordActivity("sandbox2", time.Now())
+	if err := s.SyncActivitiesWithPeers(); err == nil {
+		t.Fatalf("Expected error syncing to the unreachable peer")
+	}
+	if got := len(s.activities.retry["192.0.2.1"]); got != 2 {
+		t.Errorf("Expected 2 activities kept for the failed peer, got %v", s.activities.retry)
+	}
+
+	// forgotten with the route or the peer
+	s.DeleteRoute("sandbox1")
+	if _, ok := s.activities.retry["192.0.2.1"]["sandbox1"]; ok {
+		t.Errorf("Expected activity deleted with the route, got %v", s.activities.retry)
+	}
+	s.DeletePeer("192.0.2.1")
+	if err := s.SyncActivitiesWithPeers(); err != nil {
+		t.Errorf("SyncActivitiesWithPeers() error = %v", err)
+	}
+	if len(s.activities.retry) != 0 {
+		t.Errorf("Expected activities of deleted peer dropped, got %v", s.activities.retry)
+	}
+}
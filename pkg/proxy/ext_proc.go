@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	extProcPb "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
@@ -17,6 +18,7 @@ import (
 	"github.com/google/uuid"
 	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
 	"github.com/openkruise/agents/pkg/sandbox-manager/consts"
+	"github.com/openkruise/agents/pkg/servers/web"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"k8s.io/klog/v2"
@@ -61,7 +63,7 @@ func (s *Server) Process(srv extProcPb.ExternalProcessor_ProcessServer) error {
 		switch v := req.Request.(type) {
 		case *extProcPb.ProcessingRequest_RequestHeaders:
 			h := req.Request.(*extProcPb.ProcessingRequest_RequestHeaders)
-			resp = s.handleRequestHeaders(h, log)
+			resp = s.handleRequestHeaders(ctx, h, log)
 
 		default:
 			log.Info("Unknown Request type", "type", v)
@@ -79,51 +81,142 @@ func (s *Server) Process(srv extProcPb.ExternalProcessor_ProcessServer) error {
 
 var OrigDstHeader = "x-envoy-original-dst-host"
 
-func (s *Server) handleRequestHeaders(requestHeaders *extProcPb.ProcessingRequest_RequestHeaders, log logr.Logger) *extProcPb.ProcessingResponse {
+func (s *Server) handleRequestHeaders(ctx context.Context, requestHeaders *extProcPb.ProcessingRequest_RequestHeaders, log logr.Logger) *extProcPb.ProcessingResponse {
 	scheme, authority, path, port, headers := parseRequest(requestHeaders.RequestHeaders)
 	log = log.WithValues("requestID", headers["x-request-id"])
 	log.Info("envoy ext processor parsed request", "scheme", scheme, "authority", authority, "path", path, "port", port, "headers", headers)
-	if !s.adapter.IsSandboxRequest(authority, path, port) {
-		return s.logAndCreateDstResponse(requestHeaders.RequestHeaders, map[string]string{
+	extraHeaders, redirect, apiErr := s.resolveRequest(ctx, scheme, authority, path, port, headers, log)
+	if apiErr != nil {
+		return s.logAndCreateErrorResponse(apiErr.Code, apiErr.Message, log)
+	}
+	if redirect != nil {
+		return createRedirectResponse(redirect)
+	}
+	return s.logAndCreateDstResponse(requestHeaders.RequestHeaders, extraHeaders, log)
+}
+
+// resolveRequest decides where a request goes, which is shared by all the data planes. It returns the headers to set
+// in the request, whose OrigDstHeader is the address to forward to, and ":path" is the rewritten path if set, or the
+// redirect to answer the request with instead. ctx is done when the request is, e.g. the ext_proc stream lives as long
+// as the HTTP stream, so that the sandbox stays active during the long-lived requests.
+func (s *Server) resolveRequest(ctx context.Context, scheme, authority, path string, port int, headers map[string]string,
+	log logr.Logger) (map[string]string, *Redirect, *web.ApiError) {
+	if !s.isSandboxRequest(authority, path, port, headers) {
+		return map[string]string{
 			OrigDstHeader: s.LBEntry,
-		}, log)
+		}, nil, nil
 	}
 	sandboxID, sandboxPort, extraHeaders, err := s.adapter.Map(scheme, authority, path, port, headers)
 	if err != nil {
 		// Return error response instead of gRPC error
 		log.Error(err, "failed to map request to sandbox")
 		errorMsg := fmt.Sprintf("failed to map request to sandbox, URL=%s://%s%s", scheme, authority, path)
-		return s.logAndCreateErrorResponse(http.StatusInternalServerError, errorMsg, log)
+		return nil, nil, &web.ApiError{Code: http.StatusInternalServerError, Message: errorMsg}
 	}
 	if sandboxPort < 0 || sandboxPort > 65535 {
 		errorMsg := fmt.Sprintf("invalid sandbox port: %d", sandboxPort)
-		return s.logAndCreateErrorResponse(http.StatusBadRequest, errorMsg, log)
+		return nil, nil, &web.ApiError{Code: http.StatusBadRequest, Message: errorMsg}
 	}
 	log.Info("request mapped", "sandboxID", sandboxID, "sandboxPort", sandboxPort, "extraHeaders", extraHeaders)
 
 	route, ok := s.LoadRoute(sandboxID)
 	if !ok {
 		errorMsg := fmt.Sprintf("route for sandbox %s not found", sandboxID)
-		return s.logAndCreateErrorResponse(http.StatusNotFound, errorMsg, log)
+		return nil, nil, &web.ApiError{Code: http.StatusNotFound, Message: errorMsg}
 	}
-	if route.State == agentsv1alpha1.SandboxStatePaused {
-		return s.logAndCreateErrorResponse(http.StatusForbidden, "sandbox is paused", log)
+	if _, ok = route.ExposedPort(sandboxPort); !ok {
+		errorMsg := fmt.Sprintf("port %d is not exposed by sandbox %s", sandboxPort, sandboxID)
+		return nil, nil, &web.ApiError{Code: http.StatusForbidden, Message: errorMsg}
+	}
+	authorized, redirect := s.authorizeRequest(route, sandboxPort, path, headers, extraHeaders)
+	if !authorized {
+		errorMsg := fmt.Sprintf("missing or invalid access token for sandbox %s", sandboxID)
+		return nil, nil, &web.ApiError{Code: http.StatusUnauthorized, Message: errorMsg}
+	}
+	if redirect != nil {
+		log.Info("request redirected to move the credential into a cookie", "sandboxID", sandboxID, "location", redirect.Location)
+		return nil, redirect, nil
 	}
 	if extraHeaders == nil {
 		extraHeaders = make(map[string]string)
 	}
+	s.stripCredentialCookie(headers, extraHeaders)
+	if route.State == agentsv1alpha1.SandboxStatePaused {
+		if s.waker == nil {
+			return nil, nil, &web.ApiError{Code: http.StatusForbidden, Message: "sandbox is paused"}
+		}
+		if route, err = s.wakeSandbox(ctx, sandboxID); err != nil {
+			errorMsg := fmt.Sprintf("failed to wake up sandbox: %s", err)
+			return nil, nil, &web.ApiError{Code: http.StatusServiceUnavailable, Message: errorMsg}
+		}
+		log.Info("paused sandbox woken up", "sandboxID", sandboxID, "ip", route.IP)
+	}
+	s.RecordActivity(sandboxID, time.Now())
+	s.trackRequest(ctx, sandboxID)
 	for k, v := range route.ExtraHeaders {
 		extraHeaders[k] = v
 	}
 	extraHeaders[OrigDstHeader] = fmt.Sprintf("%s:%d", route.IP, sandboxPort)
-	return s.logAndCreateDstResponse(requestHeaders.RequestHeaders, extraHeaders, log)
+	return extraHeaders, nil, nil
+}
+
+func (s *Server) isSandboxRequest(authority, path string, port int, headers map[string]string) bool {
+	if adapter, ok := s.adapter.(HeaderAwareAdapter); ok {
+		return adapter.IsSandboxRequestWithHeaders(authority, path, port, headers)
+	}
+	return s.adapter.IsSandboxRequest(authority, path, port)
+}
+
+// authorizeRequest checks the access token of the route, or asks the adapter for other credentials.
+func (s *Server) authorizeRequest(route Route, sandboxPort int, path string, headers, extraHeaders map[string]string) (bool, *Redirect) {
+	if route.Authorize(sandboxPort, headers[AccessTokenHeader]) {
+		return true, nil
+	}
+	authorizer, ok := s.adapter.(RequestAuthorizer)
+	if !ok {
+		return false, nil
+	}
+	if rewritten, ok := extraHeaders[":path"]; ok {
+		path = rewritten
+	}
+	return authorizer.Authorize(route.ID, sandboxPort, path, headers)
+}
+
+// stripCredentialCookie removes the cookie carrying the credential of the RequestAuthorizer from the request, so that
+// it is never forwarded to the sandbox. The cookie header is set to empty if no other cookie is left, which removes it.
+func (s *Server) stripCredentialCookie(headers, extraHeaders map[string]string) {
+	authorizer, ok := s.adapter.(RequestAuthorizer)
+	if !ok || authorizer.CredentialCookie() == "" || headers["cookie"] == "" {
+		return
+	}
+	name := authorizer.CredentialCookie()
+	var kept []string
+	stripped := false
+	for _, part := range strings.Split(headers["cookie"], ";") {
+		part = strings.TrimSpace(part)
+		if cookieName, _, _ := strings.Cut(part, "="); cookieName == name {
+			stripped = true
+			continue
+		}
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	if stripped {
+		extraHeaders["cookie"] = strings.Join(kept, "; ")
+	}
 }
 
 func (s *Server) logAndCreateDstResponse(requestHeaders *extProcPb.HttpHeaders,
 	extraHeaders map[string]string, log logr.Logger) *extProcPb.ProcessingResponse {
 	log.Info("will modify request headers", "headers", extraHeaders)
 	setHeaders := make([]*configPb.HeaderValueOption, 0, len(extraHeaders))
+	var removeHeaders []string
 	for k, v := range extraHeaders {
+		if k == "cookie" && v == "" {
+			removeHeaders = append(removeHeaders, k)
+			continue
+		}
 		setHeaders = append(setHeaders, &configPb.HeaderValueOption{
 			Header: &configPb.HeaderValue{
 				Key:      k,
@@ -136,7 +229,8 @@ func (s *Server) logAndCreateDstResponse(requestHeaders *extProcPb.HttpHeaders,
 			RequestHeaders: &extProcPb.HeadersResponse{
 				Response: &extProcPb.CommonResponse{
 					HeaderMutation: &extProcPb.HeaderMutation{
-						SetHeaders: setHeaders,
+						SetHeaders:    setHeaders,
+						RemoveHeaders: removeHeaders,
 					},
 				},
 			},
@@ -144,7 +238,7 @@ func (s *Server) logAndCreateDstResponse(requestHeaders *extProcPb.HttpHeaders,
 	}
 	resp.Response.(*extProcPb.ProcessingResponse_RequestHeaders).RequestHeaders.Response.HeaderMutation.SetHeaders = append(
 		resp.Response.(*extProcPb.ProcessingResponse_RequestHeaders).RequestHeaders.Response.HeaderMutation.SetHeaders,
-		headerModifiers("request-header-modifier", requestHeaders, log)...)
+		headerModifiers(HeaderModifierKey, requestHeaders, log)...)
 	return resp
 }
 
@@ -162,6 +256,24 @@ func (s *Server) logAndCreateErrorResponse(statusCode int, message string, log l
 	}
 }
 
+func createRedirectResponse(redirect *Redirect) *extProcPb.ProcessingResponse {
+	return &extProcPb.ProcessingResponse{
+		Response: &extProcPb.ProcessingResponse_ImmediateResponse{
+			ImmediateResponse: &extProcPb.ImmediateResponse{
+				Status: &types.HttpStatus{
+					Code: types.StatusCode_TemporaryRedirect,
+				},
+				Headers: &extProcPb.HeaderMutation{
+					SetHeaders: []*configPb.HeaderValueOption{
+						{Header: &configPb.HeaderValue{Key: "location", RawValue: []byte(redirect.Location)}},
+						{Header: &configPb.HeaderValue{Key: "set-cookie", RawValue: []byte(redirect.SetCookie)}},
+					},
+				},
+			},
+		},
+	}
+}
+
 func parseRequest(httpHeaders *extProcPb.HttpHeaders) (scheme, authority, path string, port int, headers map[string]string) {
 	var host string
 	headers = make(map[string]string, len(httpHeaders.Headers.Headers))
@@ -218,20 +330,26 @@ func headerModifiers(key string, in *extProcPb.HttpHeaders, log logr.Logger) []*
 			break
 		}
 	}
-	if value != "" {
-		unmarshalled := map[string]string{}
-		if err := json.Unmarshal([]byte(value), &unmarshalled); err != nil {
-			log.Error(err, "failed to unmarshall header-modifier", "value", value)
-			return modifiers
-		}
-		for k, v := range unmarshalled {
-			modifiers = append(modifiers, &configPb.HeaderValueOption{
-				Header: &configPb.HeaderValue{
-					Key:      k,
-					RawValue: []byte(v),
-				},
-			})
-		}
+	for k, v := range parseHeaderModifier(value, log) {
+		modifiers = append(modifiers, &configPb.HeaderValueOption{
+			Header: &configPb.HeaderValue{
+				Key:      k,
+				RawValue: []byte(v),
+			},
+		})
 	}
 	return modifiers
 }
+
+// parseHeaderModifier parses the headers to set from the json value of a header modifier
+func parseHeaderModifier(value string, log logr.Logger) map[string]string {
+	if value == "" {
+		return nil
+	}
+	unmarshalled := map[string]string{}
+	if err := json.Unmarshal([]byte(value), &unmarshalled); err != nil {
+		log.Error(err, "failed to unmarshall header-modifier", "value", value)
+		return nil
+	}
+	return unmarshalled
+}
@@ -21,6 +21,8 @@ type testRequestAdapter struct {
 	isSandboxRequest bool
 	mapResult        mapResult
 	authorizeResult  bool
+	redirect         *Redirect
+	credentialCookie string
 }
 
 type mapResult struct {
@@ -39,6 +41,14 @@ func (t *testRequestAdapter) IsSandboxRequest(string, string, int) bool {
 	return t.isSandboxRequest
 }
 
+func (t *testRequestAdapter) Authorize(string, int, string, map[string]string) (bool, *Redirect) {
+	return t.authorizeResult, t.redirect
+}
+
+func (t *testRequestAdapter) CredentialCookie() string {
+	return t.credentialCookie
+}
+
 func (t *testRequestAdapter) Entry() string {
 	return t.entry
 }
@@ -83,13 +93,15 @@ func (m *mockProcessServer) Recv() (*extProcPb.ProcessingRequest, error) {
 
 func TestServer_Process(t *testing.T) {
 	tests := []struct {
-		name        string
-		setupRoutes []Route
-		adapter     *testRequestAdapter
-		requests    []*extProcPb.ProcessingRequest
-		serverError error
-		expectError bool
-		expectResp  []*extProcPb.ProcessingResponse
+		name          string
+		setupRoutes   []Route
+		paused        bool // routes are set paused
+		wakeOnRequest bool
+		adapter       *testRequestAdapter
+		requests      []*extProcPb.ProcessingRequest
+		serverError   error
+		expectError   bool
+		expectResp    []*extProcPb.ProcessingResponse
 	}{
 		{
 			name: "normal",
@@ -405,6 +417,434 @@ func TestServer_Process(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "paused sandbox rejected",
+			setupRoutes: []Route{
+				{ID: "sandbox1", IP: "192.168.1.10", Owner: "user1"},
+			},
+			paused: true,
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult: mapResult{
+					sandboxID:   "sandbox1",
+					sandboxPort: 8080,
+					user:        "user1",
+				},
+				entry: "127.0.0.1:8080",
+			},
+			requests: []*extProcPb.ProcessingRequest{
+				{
+					Request: &extProcPb.ProcessingRequest_RequestHeaders{
+						RequestHeaders: &extProcPb.HttpHeaders{
+							Headers: &corev3.HeaderMap{
+								Headers: []*corev3.HeaderValue{
+									{Key: ":scheme", RawValue: []byte("http")},
+									{Key: ":authority", RawValue: []byte("localhost:9002")},
+									{Key: ":path", RawValue: []byte("/sandbox")},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectResp: []*extProcPb.ProcessingResponse{
+				{
+					Response: &extProcPb.ProcessingResponse_ImmediateResponse{
+						ImmediateResponse: &extProcPb.ImmediateResponse{
+							Status: &types.HttpStatus{
+								Code: types.StatusCode(403),
+							},
+							Body: []byte("sandbox is paused"),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "paused sandbox woken up",
+			setupRoutes: []Route{
+				{ID: "sandbox1", IP: "192.168.1.10", Owner: "user1"},
+			},
+			paused:        true,
+			wakeOnRequest: true,
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult: mapResult{
+					sandboxID:   "sandbox1",
+					sandboxPort: 8080,
+					user:        "user1",
+				},
+				entry: "127.0.0.1:8080",
+			},
+			requests: []*extProcPb.ProcessingRequest{
+				{
+					Request: &extProcPb.ProcessingRequest_RequestHeaders{
+						RequestHeaders: &extProcPb.HttpHeaders{
+							Headers: &corev3.HeaderMap{
+								Headers: []*corev3.HeaderValue{
+									{Key: ":scheme", RawValue: []byte("http")},
+									{Key: ":authority", RawValue: []byte("localhost:9002")},
+									{Key: ":path", RawValue: []byte("/sandbox")},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectResp: []*extProcPb.ProcessingResponse{
+				{
+					Response: &extProcPb.ProcessingResponse_RequestHeaders{
+						RequestHeaders: &extProcPb.HeadersResponse{
+							Response: &extProcPb.CommonResponse{
+								HeaderMutation: &extProcPb.HeaderMutation{
+									SetHeaders: []*corev3.HeaderValueOption{
+										{
+											Header: &corev3.HeaderValue{
+												Key:      OrigDstHeader,
+												RawValue: []byte("192.168.1.11:8080"),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "secure sandbox without access token",
+			setupRoutes: []Route{
+				{ID: "sandbox1", IP: "192.168.1.10", Owner: "user1", AccessTokenHash: HashAccessToken("token1"), ExposedPorts: []ExposedPort{{Port: 8080}, {Port: 9222, Public: true}}},
+			},
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult: mapResult{
+					sandboxID:   "sandbox1",
+					sandboxPort: 8080,
+					user:        "user1",
+				},
+				entry: "127.0.0.1:8080",
+			},
+			requests: []*extProcPb.ProcessingRequest{
+				{
+					Request: &extProcPb.ProcessingRequest_RequestHeaders{
+						RequestHeaders: &extProcPb.HttpHeaders{
+							Headers: &corev3.HeaderMap{
+								Headers: []*corev3.HeaderValue{
+									{Key: ":scheme", RawValue: []byte("http")},
+									{Key: ":authority", RawValue: []byte("localhost:9002")},
+									{Key: ":path", RawValue: []byte("/sandbox")},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectResp: []*extProcPb.ProcessingResponse{
+				{
+					Response: &extProcPb.ProcessingResponse_ImmediateResponse{
+						ImmediateResponse: &extProcPb.ImmediateResponse{
+							Status: &types.HttpStatus{
+								Code: types.StatusCode(401),
+							},
+							Body: []byte("missing or invalid access token for sandbox sandbox1"),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "port not exposed",
+			setupRoutes: []Route{
+				{ID: "sandbox1", IP: "192.168.1.10", Owner: "user1", AccessTokenHash: HashAccessToken("token1"), ExposedPorts: []ExposedPort{{Port: 8080}, {Port: 9222, Public: true}}},
+			},
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult: mapResult{
+					sandboxID:   "sandbox1",
+					sandboxPort: 22,
+					user:        "user1",
+				},
+				entry: "127.0.0.1:8080",
+			},
+			requests: []*extProcPb.ProcessingRequest{
+				{
+					Request: &extProcPb.ProcessingRequest_RequestHeaders{
+						RequestHeaders: &extProcPb.HttpHeaders{
+							Headers: &corev3.HeaderMap{
+								Headers: []*corev3.HeaderValue{
+									{Key: ":scheme", RawValue: []byte("http")},
+									{Key: ":authority", RawValue: []byte("localhost:9002")},
+									{Key: ":path", RawValue: []byte("/sandbox")},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectResp: []*extProcPb.ProcessingResponse{
+				{
+					Response: &extProcPb.ProcessingResponse_ImmediateResponse{
+						ImmediateResponse: &extProcPb.ImmediateResponse{
+							Status: &types.HttpStatus{
+								Code: types.StatusCode(403),
+							},
+							Body: []byte("port 22 is not exposed by sandbox sandbox1"),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "secure sandbox with invalid access token",
+			setupRoutes: []Route{
+				{ID: "sandbox1", IP: "192.168.1.10", Owner: "user1", AccessTokenHash: HashAccessToken("token1"), ExposedPorts: []ExposedPort{{Port: 8080}, {Port: 9222, Public: true}}},
+			},
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult: mapResult{
+					sandboxID:   "sandbox1",
+					sandboxPort: 8080,
+					user:        "user1",
+				},
+				entry: "127.0.0.1:8080",
+			},
+			requests: []*extProcPb.ProcessingRequest{
+				{
+					Request: &extProcPb.ProcessingRequest_RequestHeaders{
+						RequestHeaders: &extProcPb.HttpHeaders{
+							Headers: &corev3.HeaderMap{
+								Headers: []*corev3.HeaderValue{
+									{Key: ":scheme", RawValue: []byte("http")},
+									{Key: ":authority", RawValue: []byte("localhost:9002")},
+									{Key: ":path", RawValue: []byte("/sandbox")},
+									{Key: AccessTokenHeader, RawValue: []byte("token2")},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectResp: []*extProcPb.ProcessingResponse{
+				{
+					Response: &extProcPb.ProcessingResponse_ImmediateResponse{
+						ImmediateResponse: &extProcPb.ImmediateResponse{
+							Status: &types.HttpStatus{
+								Code: types.StatusCode(401),
+							},
+							Body: []byte("missing or invalid access token for sandbox sandbox1"),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "secure sandbox with valid access token",
+			setupRoutes: []Route{
+				{ID: "sandbox1", IP: "192.168.1.10", Owner: "user1", AccessTokenHash: HashAccessToken("token1"), ExposedPorts: []ExposedPort{{Port: 8080}, {Port: 9222, Public: true}}},
+			},
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult: mapResult{
+					sandboxID:   "sandbox1",
+					sandboxPort: 8080,
+					user:        "user1",
+				},
+				entry: "127.0.0.1:8080",
+			},
+			requests: []*extProcPb.ProcessingRequest{
+				{
+					Request: &extProcPb.ProcessingRequest_RequestHeaders{
+						RequestHeaders: &extProcPb.HttpHeaders{
+							Headers: &corev3.HeaderMap{
+								Headers: []*corev3.HeaderValue{
+									{Key: ":scheme", RawValue: []byte("http")},
+									{Key: ":authority", RawValue: []byte("localhost:9002")},
+									{Key: ":path", RawValue: []byte("/sandbox")},
+									{Key: AccessTokenHeader, RawValue: []byte("token1")},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectResp: []*extProcPb.ProcessingResponse{
+				{
+					Response: &extProcPb.ProcessingResponse_RequestHeaders{
+						RequestHeaders: &extProcPb.HeadersResponse{
+							Response: &extProcPb.CommonResponse{
+								HeaderMutation: &extProcPb.HeaderMutation{
+									SetHeaders: []*corev3.HeaderValueOption{
+										{
+											Header: &corev3.HeaderValue{
+												Key:      OrigDstHeader,
+												RawValue: []byte("192.168.1.10:8080"),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "secure sandbox authorized by adapter",
+			setupRoutes: []Route{
+				{ID: "sandbox1", IP: "192.168.1.10", Owner: "user1", AccessTokenHash: HashAccessToken("token1"), ExposedPorts: []ExposedPort{{Port: 8080}, {Port: 9222, Public: true}}},
+			},
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				authorizeResult:  true,
+				mapResult: mapResult{
+					sandboxID:   "sandbox1",
+					sandboxPort: 8080,
+					user:        "user1",
+				},
+				entry: "127.0.0.1:8080",
+			},
+			requests: []*extProcPb.ProcessingRequest{
+				{
+					Request: &extProcPb.ProcessingRequest_RequestHeaders{
+						RequestHeaders: &extProcPb.HttpHeaders{
+							Headers: &corev3.HeaderMap{
+								Headers: []*corev3.HeaderValue{
+									{Key: ":scheme", RawValue: []byte("http")},
+									{Key: ":authority", RawValue: []byte("localhost:9002")},
+									{Key: ":path", RawValue: []byte("/sandbox")},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectResp: []*extProcPb.ProcessingResponse{
+				{
+					Response: &extProcPb.ProcessingResponse_RequestHeaders{
+						RequestHeaders: &extProcPb.HeadersResponse{
+							Response: &extProcPb.CommonResponse{
+								HeaderMutation: &extProcPb.HeaderMutation{
+									SetHeaders: []*corev3.HeaderValueOption{
+										{
+											Header: &corev3.HeaderValue{
+												Key:      OrigDstHeader,
+												RawValue: []byte("192.168.1.10:8080"),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "credential cookie removed",
+			setupRoutes: []Route{
+				{ID: "sandbox1", IP: "192.168.1.10", Owner: "user1", AccessTokenHash: HashAccessToken("token1"), ExposedPorts: []ExposedPort{{Port: 8080}}},
+			},
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				authorizeResult:  true,
+				credentialCookie: "signature",
+				mapResult: mapResult{
+					sandboxID:   "sandbox1",
+					sandboxPort: 8080,
+					user:        "user1",
+				},
+				entry: "127.0.0.1:8080",
+			},
+			requests: []*extProcPb.ProcessingRequest{
+				{
+					Request: &extProcPb.ProcessingRequest_RequestHeaders{
+						RequestHeaders: &extProcPb.HttpHeaders{
+							Headers: &corev3.HeaderMap{
+								Headers: []*corev3.HeaderValue{
+									{Key: ":scheme", RawValue: []byte("http")},
+									{Key: ":authority", RawValue: []byte("localhost:9002")},
+									{Key: ":path", RawValue: []byte("/sandbox")},
+									{Key: "cookie", RawValue: []byte("signature=signed")},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectResp: []*extProcPb.ProcessingResponse{
+				{
+					Response: &extProcPb.ProcessingResponse_RequestHeaders{
+						RequestHeaders: &extProcPb.HeadersResponse{
+							Response: &extProcPb.CommonResponse{
+								HeaderMutation: &extProcPb.HeaderMutation{
+									SetHeaders: []*corev3.HeaderValueOption{
+										{
+											Header: &corev3.HeaderValue{
+												Key:      OrigDstHeader,
+												RawValue: []byte("192.168.1.10:8080"),
+											},
+										},
+									},
+									RemoveHeaders: []string{"cookie"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "public port of secure sandbox without access token",
+			setupRoutes: []Route{
+				{ID: "sandbox1", IP: "192.168.1.10", Owner: "user1", AccessTokenHash: HashAccessToken("token1"), ExposedPorts: []ExposedPort{{Port: 8080}, {Port: 9222, Public: true}}},
+			},
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult: mapResult{
+					sandboxID:   "sandbox1",
+					sandboxPort: 9222,
+					user:        "user1",
+				},
+				entry: "127.0.0.1:8080",
+			},
+			requests: []*extProcPb.ProcessingRequest{
+				{
+					Request: &extProcPb.ProcessingRequest_RequestHeaders{
+						RequestHeaders: &extProcPb.HttpHeaders{
+							Headers: &corev3.HeaderMap{
+								Headers: []*corev3.HeaderValue{
+									{Key: ":scheme", RawValue: []byte("http")},
+									{Key: ":authority", RawValue: []byte("localhost:9002")},
+									{Key: ":path", RawValue: []byte("/sandbox")},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectResp: []*extProcPb.ProcessingResponse{
+				{
+					Response: &extProcPb.ProcessingResponse_RequestHeaders{
+						RequestHeaders: &extProcPb.HeadersResponse{
+							Response: &extProcPb.CommonResponse{
+								HeaderMutation: &extProcPb.HeaderMutation{
+									SetHeaders: []*corev3.HeaderValueOption{
+										{
+											Header: &corev3.HeaderValue{
+												Key:      OrigDstHeader,
+												RawValue: []byte("192.168.1.10:9222"),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -415,8 +855,20 @@ func TestServer_Process(t *testing.T) {
 			// Setup routes
 			for _, route := range tt.setupRoutes {
 				route.State = agentsv1alpha1.SandboxStateRunning
+				if tt.paused {
+					route.State = agentsv1alpha1.SandboxStatePaused
+				}
 				server.SetRoute(route)
 			}
+			if tt.wakeOnRequest {
+				server.EnableWakeOnRequest(func(ctx context.Context, sandboxID string) error {
+					route, _ := server.LoadRoute(sandboxID)
+					route.IP = "192.168.1.11"
+					route.State = agentsv1alpha1.SandboxStateRunning
+					server.SetRoute(route)
+					return nil
+				}, time.Second)
+			}
 
 			// Create mock processing server
 			mockServer := &mockProcessServer{
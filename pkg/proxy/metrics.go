@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// RouteSyncFailures tracks the failed route synchronizations with peers
+	RouteSyncFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sandbox_route_sync_failures_total",
+			Help: "Total number of failed route synchronizations with peers",
+		},
+		[]string{"operation"}, // "push", "digest" or "pull"
+	)
+
+	// RouteSyncRepairs tracks the routes repaired by the anti-entropy synchronization
+	RouteSyncRepairs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "sandbox_route_sync_repairs_total",
+			Help: "Total number of routes repaired from peers by the anti-entropy synchronization",
+		},
+	)
+
+	// RouteSyncLag tracks the time since the route table was last synchronized with each peer
+	RouteSyncLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sandbox_route_sync_lag_seconds",
+			Help: "Seconds since the route table was last synchronized with the peer",
+		},
+		[]string{"peer"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(RouteSyncFailures, RouteSyncRepairs, RouteSyncLag)
+}
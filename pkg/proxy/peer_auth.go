@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openkruise/agents/pkg/servers/web"
+)
+
+const (
+	PeerTimestampHeader = "X-Peer-Timestamp"
+	PeerSignatureHeader = "X-Peer-Signature"
+)
+
+// PeerAuthMaxSkew is the max difference between the clocks of peers, the signed requests older than it are rejected
+var PeerAuthMaxSkew = time.Minute
+
+// SetPeerSecret makes the requests between peers signed with HMAC-SHA256 by the secret shared by all the peers, and
+// the unsigned ones rejected. It should be called before Run.
+func (s *Server) SetPeerSecret(secret []byte) {
+	s.peerSecret = secret
+}
+
+// signPeerRequest signs the method, path, timestamp and body of the request.
+func signPeerRequest(secret []byte, r *http.Request, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	r.Header.Set(PeerTimestampHeader, timestamp)
+	r.Header.Set(PeerSignatureHeader, hex.EncodeToString(peerSignature(secret, r.Method, r.URL.Path, timestamp, body)))
+}
+
+func peerSignature(secret []byte, method, path, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "\n"))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// peerAuth is the middleware verifying the requests from peers.
+func (s *Server) peerAuth(ctx context.Context, r *http.Request) (context.Context, *web.ApiError) {
+	if len(s.peerSecret) == 0 {
+		return ctx, nil
+	}
+	unauthorized := func(message string) *web.ApiError {
+		return &web.ApiError{Code: http.StatusUnauthorized, Message: message}
+	}
+	timestamp := r.Header.Get(PeerTimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ctx, unauthorized("missing or invalid peer timestamp")
+	}
+	if skew := time.Since(time.Unix(seconds, 0)); skew > PeerAuthMaxSkew || skew < -PeerAuthMaxSkew {
+		return ctx, unauthorized("peer request expired")
+	}
+	signature, err := hex.DecodeString(r.Header.Get(PeerSignatureHeader))
+	if err != nil {
+		return ctx, unauthorized("missing or invalid peer signature")
+	}
+	var body []byte
+	if r.Body != nil {
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return ctx, &web.ApiError{Code: http.StatusBadRequest, Message: "failed to read body"}
+		}
+		// the body is read again by the handler
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if !hmac.Equal(signature, peerSignature(s.peerSecret, r.Method, r.URL.Path, timestamp, body)) {
+		return ctx, unauthorized("invalid peer signature")
+	}
+	return ctx, nil
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openkruise/agents/pkg/servers/web"
+)
+
+func TestPeerAuth(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"id":"sandbox1"}`)
+	tests := []struct {
+		name       string
+		secret     []byte
+		sign       func(r *http.Request)
+		expectCode int
+	}{
+		{
+			name:   "signed",
+			secret: secret,
+			sign: func(r *http.Request) {
+				signPeerRequest(secret, r, body)
+			},
+			expectCode: http.StatusNoContent,
+		},
+		{
+			name:       "unsigned",
+			secret:     secret,
+			sign:       func(r *http.Request) {},
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "signed by another secret",
+			secret: secret,
+			sign: func(r *http.Request) {
+				signPeerRequest([]byte("another"), r, body)
+			},
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "body tampered",
+			secret: secret,
+			sign: func(r *http.Request) {
+				signPeerRequest(secret, r, []byte(`{"id":"sandbox2"}`))
+			},
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "expired",
+			secret: secret,
+			sign: func(r *http.Request) {
+				timestamp := strconv.FormatInt(time.Now().Add(-2*PeerAuthMaxSkew).Unix(), 10)
+				r.Header.Set(PeerTimestampHeader, timestamp)
+				r.Header.Set(PeerSignatureHeader, hex.EncodeToString(peerSignature(secret, r.Method, r.URL.Path, timestamp, body)))
+			},
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "authentication disabled",
+			sign:       func(r *http.Request) {},
+			expectCode: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(nil)
+			s.SetPeerSecret(tt.secret)
+			mux := http.NewServeMux()
+			web.RegisterRoute(mux, http.MethodPost, RefreshAPI, s.handleRefresh, s.peerAuth)
+			req := httptest.NewRequest(http.MethodPost, RefreshAPI, bytes.NewReader(body))
+			tt.sign(req)
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, req)
+			if recorder.Code != tt.expectCode {
+				t.Errorf("expected code %d, got %d: %s", tt.expectCode, recorder.Code, recorder.Body.String())
+			}
+			_, ok := s.LoadRoute("sandbox1")
+			if ok != (tt.expectCode == http.StatusNoContent) {
+				t.Errorf("route refreshed = %v, expected code %d", ok, tt.expectCode)
+			}
+		})
+	}
+}
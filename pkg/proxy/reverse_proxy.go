@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+)
+
+// HeaderModifierKey is the header carrying the json of headers to set in the request, the same as the one in Envoy.
+const HeaderModifierKey = "request-header-modifier"
+
+// EnableReverseProxy serves the requests on port by the embedded reverse proxy, which routes the requests the same as
+// ext_proc, so that no Envoy is required. It should be called before Run.
+func (s *Server) EnableReverseProxy(port int) {
+	s.reverseProxyPort = port
+}
+
+// newReverseProxy creates the server of the embedded reverse proxy. WebSocket upgrades are forwarded, while gRPC is
+// not supported for the upstreams are requested in HTTP/1.1.
+func (s *Server) newReverseProxy() *http.Server {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			klog.FromContext(r.Context()).Error(err, "failed to proxy request", "host", r.Host, "path", r.URL.Path)
+			http.Error(w, fmt.Sprintf("failed to proxy request: %s", err), http.StatusBadGateway)
+		},
+	}
+	return &http.Server{
+		Addr: fmt.Sprintf(":%d", s.reverseProxyPort),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.serveReverseProxy(proxy, w, r)
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+func (s *Server) serveReverseProxy(proxy *httputil.ReverseProxy, w http.ResponseWriter, r *http.Request) {
+	log := klog.LoggerWithValues(klog.Background(), "contextID", uuid.NewString()).V(LogLevel)
+	scheme, authority, path, port, headers := parseHTTPRequest(r)
+	delete(headers, HeaderModifierKey)
+	log = log.WithValues("requestID", headers["x-request-id"])
+	log.Info("reverse proxy parsed request", "scheme", scheme, "authority", authority, "path", path, "port", port, "headers", headers)
+	extraHeaders, redirect, apiErr := s.resolveRequest(r.Context(), scheme, authority, path, port, headers, log)
+	if apiErr != nil {
+		log.Error(apiErr, "create error response", "code", apiErr.Code)
+		http.Error(w, apiErr.Message, apiErr.Code)
+		return
+	}
+	if redirect != nil {
+		w.Header().Set("Location", redirect.Location)
+		w.Header().Set("Set-Cookie", redirect.SetCookie)
+		w.WriteHeader(http.StatusTemporaryRedirect)
+		return
+	}
+	log.Info("will modify request headers", "headers", extraHeaders)
+
+	out := r.Clone(klog.NewContext(r.Context(), log))
+	// Without Envoy in front, the header modifier comes straight from the client and could override the destination,
+	// so it is neither honored nor forwarded.
+	out.Header.Del(HeaderModifierKey)
+	out.URL.Scheme = "http"
+	for k, v := range extraHeaders {
+		switch k {
+		case OrigDstHeader:
+			out.URL.Host = v
+		case ":path":
+			rewritten, err := out.URL.Parse(v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid path: %s", v), http.StatusBadRequest)
+				return
+			}
+			out.URL.Path, out.URL.RawPath, out.URL.RawQuery = rewritten.Path, rewritten.RawPath, rewritten.RawQuery
+		case "cookie":
+			// an empty cookie is dropped like Envoy does
+			if v == "" {
+				out.Header.Del("Cookie")
+			} else {
+				out.Header.Set("Cookie", v)
+			}
+		default:
+			out.Header.Set(k, v)
+		}
+	}
+	proxy.ServeHTTP(w, out)
+}
+
+// parseHTTPRequest parses the request like parseRequest, whose headers are lower-cased with the pseudo ones of HTTP/2.
+func parseHTTPRequest(r *http.Request) (scheme, authority, path string, port int, headers map[string]string) {
+	scheme = "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := r.Header.Get("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+	authority, path = r.Host, r.URL.RequestURI()
+	headers = make(map[string]string, len(r.Header)+3)
+	for k, v := range r.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	headers[":scheme"], headers[":authority"], headers[":path"] = scheme, authority, path
+	headers["host"] = authority
+
+	if _, p, err := net.SplitHostPort(authority); err == nil {
+		port, _ = strconv.Atoi(p)
+	} else if scheme == "https" {
+		port = 443
+	} else {
+		port = 80
+	}
+	return scheme, authority, path, port, headers
+}
@@ -0,0 +1,265 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+)
+
+func TestReverseProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "%s %s user=%s modified=%s cookie=%s", r.Host, r.URL.RequestURI(),
+			r.Header.Get("x-user"), r.Header.Get("x-modified"), r.Header.Get("Cookie"))
+	}))
+	defer backend.Close()
+	backendHost, backendPort, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	port, _ := strconv.Atoi(backendPort)
+
+	tests := []struct {
+		name       string
+		adapter    *testRequestAdapter
+		route      *Route
+		headers    map[string]string
+		expectCode int
+		expectBody string
+		// expectLocation and expectCookie are the headers of the redirect
+		expectLocation string
+		expectCookie   string
+	}{
+		{
+			name: "sandbox request",
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult: mapResult{
+					sandboxID:    "sandbox1",
+					sandboxPort:  port,
+					extraHeaders: map[string]string{":path": "/rewritten?a=b"},
+				},
+			},
+			route: &Route{
+				IP: backendHost, ID: "sandbox1", State: agentsv1alpha1.SandboxStateRunning,
+				ExtraHeaders: map[string]string{"x-user": "alice"},
+			},
+			headers:    map[string]string{"x-modified": "yes"},
+			expectCode: http.StatusOK,
+			expectBody: "sandbox.example.com /rewritten?a=b user=alice modified=yes cookie=",
+		},
+		{
+			name: "header modifier cannot override the destination",
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult:        mapResult{sandboxID: "sandbox1", sandboxPort: port},
+			},
+			route: &Route{IP: backendHost, ID: "sandbox1", State: agentsv1alpha1.SandboxStateRunning},
+			headers: map[string]string{
+				HeaderModifierKey: `{"` + OrigDstHeader + `":"10.0.0.5:22",":path":"/admin","host":"evil","x-modified":"yes"}`,
+			},
+			expectCode: http.StatusOK,
+			expectBody: "sandbox.example.com /index.html user= modified= cookie=",
+		},
+		{
+			name: "header modifier cannot bypass route lookup",
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult:        mapResult{sandboxID: "nonexistent", sandboxPort: port},
+			},
+			headers: map[string]string{
+				HeaderModifierKey: `{"` + OrigDstHeader + `":"` + backend.Listener.Addr().String() + `"}`,
+			},
+			expectCode: http.StatusNotFound,
+			expectBody: "route for sandbox nonexistent not found\n",
+		},
+		{
+			name: "api request",
+			adapter: &testRequestAdapter{
+				entry:            backend.Listener.Addr().String(),
+				isSandboxRequest: false,
+			},
+			expectCode: http.StatusOK,
+			expectBody: "sandbox.example.com /index.html user= modified= cookie=",
+		},
+		{
+			name: "route not found",
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult:        mapResult{sandboxID: "nonexistent", sandboxPort: port},
+			},
+			expectCode: http.StatusNotFound,
+			expectBody: "route for sandbox nonexistent not found\n",
+		},
+		{
+			name: "paused sandbox",
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult:        mapResult{sandboxID: "sandbox1", sandboxPort: port},
+			},
+			route:      &Route{IP: backendHost, ID: "sandbox1", State: agentsv1alpha1.SandboxStatePaused},
+			expectCode: http.StatusForbidden,
+			expectBody: "sandbox is paused\n",
+		},
+		{
+			name: "secure sandbox without token",
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult:        mapResult{sandboxID: "sandbox1", sandboxPort: port},
+			},
+			route: &Route{
+				IP: backendHost, ID: "sandbox1", State: agentsv1alpha1.SandboxStateRunning, AccessTokenHash: HashAccessToken("token"),
+			},
+			expectCode: http.StatusUnauthorized,
+			expectBody: "missing or invalid access token for sandbox sandbox1\n",
+		},
+		{
+			name: "secure sandbox redirected by adapter",
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult:        mapResult{sandboxID: "sandbox1", sandboxPort: port},
+				authorizeResult:  true,
+				redirect:         &Redirect{Location: "/index.html", SetCookie: "signature=signed; Path=/"},
+			},
+			route: &Route{
+				IP: backendHost, ID: "sandbox1", State: agentsv1alpha1.SandboxStateRunning, AccessTokenHash: HashAccessToken("token"),
+			},
+			expectCode:     http.StatusTemporaryRedirect,
+			expectLocation: "/index.html",
+			expectCookie:   "signature=signed; Path=/",
+		},
+		{
+			name: "credential cookie stripped",
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult:        mapResult{sandboxID: "sandbox1", sandboxPort: port},
+				authorizeResult:  true,
+				credentialCookie: "signature",
+			},
+			route: &Route{
+				IP: backendHost, ID: "sandbox1", State: agentsv1alpha1.SandboxStateRunning, AccessTokenHash: HashAccessToken("token"),
+			},
+			headers:    map[string]string{"Cookie": "a=1; signature=signed; b=2"},
+			expectCode: http.StatusOK,
+			expectBody: "sandbox.example.com /index.html user= modified= cookie=a=1; b=2",
+		},
+		{
+			name: "only credential cookie stripped",
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult:        mapResult{sandboxID: "sandbox1", sandboxPort: port},
+				authorizeResult:  true,
+				credentialCookie: "signature",
+			},
+			route: &Route{
+				IP: backendHost, ID: "sandbox1", State: agentsv1alpha1.SandboxStateRunning, AccessTokenHash: HashAccessToken("token"),
+			},
+			headers:    map[string]string{"Cookie": "signature=signed"},
+			expectCode: http.StatusOK,
+			expectBody: "sandbox.example.com /index.html user= modified= cookie=",
+		},
+		{
+			name: "backend unreachable",
+			adapter: &testRequestAdapter{
+				isSandboxRequest: true,
+				mapResult:        mapResult{sandboxID: "sandbox1", sandboxPort: 1},
+			},
+			route:      &Route{IP: backendHost, ID: "sandbox1", State: agentsv1alpha1.SandboxStateRunning},
+			expectCode: http.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(tt.adapter)
+			if tt.route != nil {
+				server.SetRoute(*tt.route)
+			}
+			server.EnableReverseProxy(8080)
+			srv := server.newReverseProxy()
+			req := httptest.NewRequest(http.MethodGet, "http://sandbox.example.com/index.html", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			recorder := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(recorder, req)
+			resp := recorder.Result()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectCode {
+				t.Errorf("expected code %d, got %d: %s", tt.expectCode, resp.StatusCode, body)
+			}
+			if tt.expectBody != "" && string(body) != tt.expectBody {
+				t.Errorf("expected body %q, got %q", tt.expectBody, body)
+			}
+			if location := resp.Header.Get("Location"); location != tt.expectLocation {
+				t.Errorf("expected location %q, got %q", tt.expectLocation, location)
+			}
+			if cookie := resp.Header.Get("Set-Cookie"); cookie != tt.expectCookie {
+				t.Errorf("expected cookie %q, got %q", tt.expectCookie, cookie)
+			}
+		})
+	}
+}
+
+func TestParseHTTPRequest(t *testing.T) {
+	tests := []struct {
+		name            string
+		url             string
+		tls             bool
+		forwardedProto  string
+		expectScheme    string
+		expectAuthority string
+		expectPath      string
+		expectPort      int
+	}{
+		{
+			name:            "http",
+			url:             "http://3000-sandbox1.example.com/index.html?a=b",
+			expectScheme:    "http",
+			expectAuthority: "3000-sandbox1.example.com",
+			expectPath:      "/index.html?a=b",
+			expectPort:      80,
+		},
+		{
+			name:            "https",
+			url:             "https://example.com/kruise/sandbox1/3000/",
+			tls:             true,
+			expectScheme:    "https",
+			expectAuthority: "example.com",
+			expectPath:      "/kruise/sandbox1/3000/",
+			expectPort:      443,
+		},
+		{
+			name:            "explicit port behind a load balancer",
+			url:             "http://example.com:8443/",
+			forwardedProto:  "https",
+			expectScheme:    "https",
+			expectAuthority: "example.com:8443",
+			expectPath:      "/",
+			expectPort:      8443,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if !tt.tls {
+				req.TLS = nil
+			} else if req.TLS == nil {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if tt.forwardedProto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+			}
+			scheme, authority, path, port, headers := parseHTTPRequest(req)
+			if scheme != tt.expectScheme || authority != tt.expectAuthority || path != tt.expectPath || port != tt.expectPort {
+				t.Errorf("expected %s %s %s %d, got %s %s %s %d", tt.expectScheme, tt.expectAuthority, tt.expectPath,
+					tt.expectPort, scheme, authority, path, port)
+			}
+			if headers[":authority"] != tt.expectAuthority || headers[":path"] != tt.expectPath {
+				t.Errorf("unexpected pseudo headers: %v", headers)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openkruise/agents/pkg/servers/web"
+	"k8s.io/klog/v2"
+)
+
+const (
+	RoutesAPI      = "/routes"
+	RouteDigestAPI = "/route-digest"
+)
+
+var (
+	// RouteSyncInterval is the interval to compare the route tables with the peers and repair the divergence
+	RouteSyncInterval = 30 * time.Second
+	// RouteTombstoneTTL is how long a deleted route is kept from being repaired by the stale peers
+	RouteTombstoneTTL = 5 * time.Minute
+)
+
+// RouteDigest summarizes the route table, the tables are consistent if the digests are the same
+type RouteDigest struct {
+	Digest string `json:"digest"`
+	Routes int    `json:"routes"`
+}
+
+// RouteDigest returns the digest of the route table.
+func (s *Server) RouteDigest() RouteDigest {
+	routes := s.ListRoutes()
+	slices.SortFunc(routes, func(a, b Route) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	hash := sha256.New()
+	encoder := json.NewEncoder(hash)
+	for _, route := range routes {
+		// the keys of maps are sorted by json, so the digest is stable
+		_ = encoder.Encode(route)
+	}
+	return RouteDigest{
+		Digest: hex.EncodeToString(hash.Sum(nil)),
+		Routes: len(routes),
+	}
+}
+
+// mergeRoutes repairs the routes with the newer ones of a peer, and returns the number of repaired routes. The routes
+// deleted recently are not repaired, which may be stale in the peer.
+func (s *Server) mergeRoutes(routes []Route) int {
+	repaired := 0
+	for _, route := range routes {
+		if _, deleted := s.tombstones.Load(route.ID); deleted {
+			continue
+		}
+		if local, ok := s.LoadRoute(route.ID); ok && route.Version <= local.Version {
+			continue
+		}
+		s.SetRoute(route)
+		repaired++
+	}
+	return repaired
+}
+
+// purgeTombstones forgets the routes deleted before RouteTombstoneTTL
+func (s *Server) purgeTombstones() {
+	s.tombstones.Range(func(key, value any) bool {
+		if time.Since(value.(time.Time)) > RouteTombstoneTTL {
+			s.tombstones.Delete(key)
+		}
+		return true
+	})
+}
+
+// SyncRoutesWithPeers compares the digest of the route table with each peer, and pulls the routes of the divergent
+// ones to repair the local table. As all the peers do the same, the tables converge to the newest routes.
+func (s *Server) SyncRoutesWithPeers() error {
+	s.purgeTombstones()
+	local := s.RouteDigest()
+	var errs []error
+	for _, peer := range s.healthyPeers() {
+		if err := s.syncRoutesWithPeer(peer, local); err != nil {
+			errs = append(errs, fmt.Errorf("peer %s: %w", peer.IP, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to sync routes: %v", errs)
+	}
+	return nil
+}
+
+func (s *Server) syncRoutesWithPeer(peer Peer, local RouteDigest) error {
+	raw, err := s.requestPeer(http.MethodGet, peer.IP, RouteDigestAPI, nil)
+	if err != nil {
+		RouteSyncFailures.WithLabelValues("digest").Inc()
+		return err
+	}
+	var remote RouteDigest
+	if err = json.Unmarshal(raw, &remote); err != nil {
+		RouteSyncFailures.WithLabelValues("digest").Inc()
+		return err
+	}
+	if remote.Digest != local.Digest {
+		if raw, err = s.requestPeer(http.MethodGet, peer.IP, RoutesAPI, nil); err != nil {
+			RouteSyncFailures.WithLabelValues("pull").Inc()
+			return err
+		}
+		var routes []Route
+		if err = json.Unmarshal(raw, &routes); err != nil {
+			RouteSyncFailures.WithLabelValues("pull").Inc()
+			return err
+		}
+		if repaired := s.mergeRoutes(routes); repaired > 0 {
+			RouteSyncRepairs.Add(float64(repaired))
+			klog.V(klog.Level(LogLevel)).InfoS("routes repaired from peer", "ip", peer.IP, "repaired", repaired)
+		}
+	}
+	s.peerMu.Lock()
+	if p, ok := s.peers[peer.IP]; ok {
+		p.LastSynced = time.Now()
+		s.peers[peer.IP] = p
+	}
+	s.peerMu.Unlock()
+	return nil
+}
+
+// updateSyncLag exports the time since the last consistent sync with each peer
+func (s *Server) updateSyncLag() {
+	for _, peer := range s.ListPeers() {
+		if !peer.LastSynced.IsZero() {
+			RouteSyncLag.WithLabelValues(peer.IP).Set(time.Since(peer.LastSynced).Seconds())
+		}
+	}
+}
+
+func (s *Server) handleRouteDigest(*http.Request) (web.ApiResponse[RouteDigest], *web.ApiError) {
+	return web.ApiResponse[RouteDigest]{
+		Code: http.StatusOK,
+		Body: s.RouteDigest(),
+	}, nil
+}
+
+func (s *Server) handleListRoutes(*http.Request) (web.ApiResponse[[]Route], *web.ApiError) {
+	return web.ApiResponse[[]Route]{
+		Code: http.StatusOK,
+		Body: s.ListRoutes(),
+	}, nil
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSetRoute_Version(t *testing.T) {
+	s := NewServer(nil)
+	s.SetRoute(Route{ID: "sandbox1", IP: "1.1.1.1", Version: 2})
+	// the older route is ignored
+	s.SetRoute(Route{ID: "sandbox1", IP: "2.2.2.2", Version: 1})
+	if route, _ := s.LoadRoute("sandbox1"); route.IP != "1.1.1.1" {
+		t.Errorf("expected ip 1.1.1.1, got %s", route.IP)
+	}
+	s.SetRoute(Route{ID: "sandbox1", IP: "3.3.3.3", Version: 2})
+	if route, _ := s.LoadRoute("sandbox1"); route.IP != "3.3.3.3" {
+		t.Errorf("expected ip 3.3.3.3, got %s", route.IP)
+	}
+}
+
+func TestRouteDigest(t *testing.T) {
+	s1, s2 := NewServer(nil), NewServer(nil)
+	routes := []Route{
+		{ID: "sandbox1", IP: "1.1.1.1", Version: 1, ExtraHeaders: map[string]string{"a": "1", "b": "2"}},
+		{ID: "sandbox2", IP: "2.2.2.2", Version: 1},
+	}
+	for i := range routes {
+		s1.SetRoute(routes[i])
+		s2.SetRoute(routes[len(routes)-1-i])
+	}
+	d1, d2 := s1.RouteDigest(), s2.RouteDigest()
+	if d1 != d2 || d1.Routes != 2 {
+		t.Errorf("expected the same digests of 2 routes, got %v and %v", d1, d2)
+	}
+	s2.SetRoute(Route{ID: "sandbox2", IP: "3.3.3.3", Version: 2})
+	if s1.RouteDigest() == s2.RouteDigest() {
+		t.Errorf("expected different digests")
+	}
+}
+
+func TestMergeRoutes(t *testing.T) {
+	s := NewServer(nil)
+	s.SetRoute(Route{ID: "sandbox1", IP: "1.1.1.1", Version: 2})
+	s.SetRoute(Route{ID: "sandbox2", IP: "2.2.2.2", Version: 2})
+	s.SetRoute(Route{ID: "sandbox3", IP: "3.3.3.3", Version: 2})
+	s.DeleteRoute("sandbox3")
+
+	repaired := s.mergeRoutes([]Route{
+		{ID: "sandbox1", IP: "1.1.1.2", Version: 3}, // newer
+		{ID: "sandbox2", IP: "2.2.2.3", Version: 1}, // stale
+		{ID: "sandbox3", IP: "3.3.3.3", Version: 2}, // deleted locally
+		{ID: "sandbox4", IP: "4.4.4.4", Version: 1}, // missed
+	})
+	if repaired != 2 {
+		t.Errorf("expected 2 routes repaired, got %d", repaired)
+	}
+	expected := map[string]string{"sandbox1": "1.1.1.2", "sandbox2": "2.2.2.2", "sandbox4": "4.4.4.4"}
+	for id, ip := range expected {
+		if route, ok := s.LoadRoute(id); !ok || route.IP != ip {
+			t.Errorf("expected route %s with ip %s, got %v", id, ip, route)
+		}
+	}
+	if _, ok := s.LoadRoute("sandbox3"); ok {
+		t.Errorf("deleted route sandbox3 should not be repaired")
+	}
+
+	// the tombstone expires
+	s.tombstones.Store("sandbox3", time.Now().Add(-2*RouteTombstoneTTL))
+	s.purgeTombstones()
+	if repaired = s.mergeRoutes([]Route{{ID: "sandbox3", IP: "3.3.3.3", Version: 2}}); repaired != 1 {
+		t.Errorf("expected sandbox3 repaired after the tombstone expired, got %d", repaired)
+	}
+}
+
+func TestRouteSyncHandlers(t *testing.T) {
+	s := NewServer(nil)
+	s.SetRoute(Route{ID: "sandbox1", IP: "1.1.1.1", Version: 1})
+	digest, apiErr := s.handleRouteDigest(httptest.NewRequest("GET", RouteDigestAPI, nil))
+	if apiErr != nil || digest.Body != s.RouteDigest() {
+		t.Errorf("unexpected digest response: %v, %v", digest, apiErr)
+	}
+	routes, apiErr := s.handleListRoutes(httptest.NewRequest("GET", RoutesAPI, nil))
+	if apiErr != nil || len(routes.Body) != 1 || routes.Body[0].ID != "sandbox1" {
+		t.Errorf("unexpected routes response: %v, %v", routes, apiErr)
+	}
+}
+
+func TestPeerHealth(t *testing.T) {
+	s := NewServer(nil)
+	s.SetPeer("10.0.0.1")
+	s.SetPeer("10.0.0.2")
+	s.setPeerHealth("10.0.0.2", errors.New("connection refused"))
+	// a deleted peer is not added back by the heartbeat
+	s.setPeerHealth("10.0.0.3", nil)
+
+	healthy := s.healthyPeers()
+	if len(healthy) != 1 || healthy[0].IP != "10.0.0.1" {
+		t.Errorf("expected healthy peer 10.0.0.1, got %v", healthy)
+	}
+	if peers := s.ListPeers(); len(peers) != 2 {
+		t.Errorf("expected the unhealthy peer kept, got %v", peers)
+	}
+
+	// the peer recovers
+	s.setPeerHealth("10.0.0.2", nil)
+	if healthy = s.healthyPeers(); len(healthy) != 2 {
+		t.Errorf("expected 2 healthy peers, got %v", healthy)
+	}
+	s.DeletePeer("10.0.0.1")
+	if peers := s.ListPeers(); len(peers) != 1 || peers[0].IP != "10.0.0.2" || peers[0].LastError != "" {
+		t.Errorf("unexpected peers: %v", peers)
+	}
+}
+
+func TestHandleHello(t *testing.T) {
+	s := NewServer(nil)
+	s.SetPeer("10.0.0.1")
+	s.setPeerHealth("10.0.0.1", errors.New("connection refused"))
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		req := httptest.NewRequest("GET", HelloAPI, nil)
+		req.Header.Set("X-Real-IP", ip)
+		if _, apiErr := s.handleHello(req); apiErr != nil {
+			t.Errorf("unexpected error for hello from %s: %v", ip, apiErr)
+		}
+	}
+	// the known peer is refreshed, while the unknown one, e.g. a terminating pod, is not added
+	if peers := s.ListPeers(); len(peers) != 1 || peers[0].IP != "10.0.0.1" || !peers[0].Healthy {
+		t.Errorf("unexpected peers: %v", peers)
+	}
+}
@@ -1,12 +1,20 @@
 package proxy
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 )
 
+// AccessTokenHeader carries the access token of a secure sandbox, which is the same one checked by envd.
+const AccessTokenHeader = "x-access-token"
+
 // Route represents an internal sandbox routing rule
 type Route struct {
 	IP           string            `json:"ip"`
@@ -14,10 +22,68 @@ type Route struct {
 	Owner        string            `json:"owner"`
 	State        string            `json:"state"`
 	ExtraHeaders map[string]string `json:"extra_headers"`
+	// AccessTokenHash is the hash of the access token required in the requests to the sandbox if not empty, except
+	// the ones to the public ports. The token itself is never kept, for the routes are exposed by debug and peers.
+	AccessTokenHash string `json:"access_token_hash,omitempty"`
+	// ExposedPorts are the only ports that can be requested if not empty.
+	ExposedPorts []ExposedPort `json:"exposed_ports,omitempty"`
+	// Version orders the routes of the same sandbox, e.g. the resource version, the older ones are ignored
+	Version int64 `json:"version,omitempty"`
+	// Dynamic routes are always processed by ext_proc even if xDS is enabled, e.g. the ones whose activities are tracked.
+	Dynamic bool `json:"dynamic,omitempty"`
+}
+
+// ExposedPort is a port of the sandbox that can be requested through the proxy
+type ExposedPort struct {
+	Port int `json:"port"`
+	// Protocol is a hint of the application protocol, such as HTTP, WebSocket and GRPC
+	Protocol string `json:"protocol,omitempty"`
+	// Public ports can be requested without the access token
+	Public bool `json:"public,omitempty"`
+}
+
+// ExposedPort returns the declaration of the port, all the ports are exposed if none is declared.
+func (r Route) ExposedPort(port int) (ExposedPort, bool) {
+	if len(r.ExposedPorts) == 0 {
+		return ExposedPort{Port: port}, true
+	}
+	idx := slices.IndexFunc(r.ExposedPorts, func(p ExposedPort) bool {
+		return p.Port == port
+	})
+	if idx < 0 {
+		return ExposedPort{}, false
+	}
+	return r.ExposedPorts[idx], true
+}
+
+// Authorize returns whether a request to the port of the sandbox carrying the token is allowed.
+func (r Route) Authorize(port int, token string) bool {
+	if r.AccessTokenHash == "" {
+		return true
+	}
+	if exposed, ok := r.ExposedPort(port); ok && exposed.Public {
+		return true
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(r.AccessTokenHash), []byte(HashAccessToken(token))) == 1
+}
+
+// HashAccessToken returns the hash of the access token kept in the routes.
+func HashAccessToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
+// SetRoute sets the route of the sandbox, unless the current one is newer.
 func (s *Server) SetRoute(route Route) {
+	if old, ok := s.LoadRoute(route.ID); ok && route.Version < old.Version {
+		return
+	}
+	s.tombstones.Delete(route.ID)
 	s.routes.Store(route.ID, route)
+	s.notifyXDS()
 }
 
 func (s *Server) SyncRouteWithPeers(route Route) error {
@@ -26,13 +92,12 @@ func (s *Server) SyncRouteWithPeers(route Route) error {
 		return err
 	}
 	var errStrings []string
-	s.peerMu.RLock()
-	for ip := range s.peers {
-		if err = requestPeer(http.MethodPost, ip, RefreshAPI, body); err != nil {
+	for _, peer := range s.healthyPeers() {
+		if _, err = s.requestPeer(http.MethodPost, peer.IP, RefreshAPI, body); err != nil {
+			RouteSyncFailures.WithLabelValues("push").Inc()
 			errStrings = append(errStrings, err.Error())
 		}
 	}
-	s.peerMu.RUnlock()
 	if len(errStrings) == 0 {
 		return nil
 	}
@@ -66,8 +131,25 @@ func (s *Server) ListPeers() []Peer {
 	return peers
 }
 
+// healthyPeers returns the peers to synchronize with, the unhealthy ones are repaired by SyncRoutesWithPeers after
+// they recover.
+func (s *Server) healthyPeers() []Peer {
+	peers := make([]Peer, 0)
+	s.peerMu.RLock()
+	defer s.peerMu.RUnlock()
+	for _, peer := range s.peers {
+		if peer.Healthy {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
 func (s *Server) DeleteRoute(id string) {
 	s.routes.Delete(id)
+	s.tombstones.Store(id, time.Now())
+	s.deleteActivity(id)
+	s.notifyXDS()
 }
 
 // RequestAdapter is used to register the mapping from business-side sandbox requests to internal logic
@@ -80,3 +162,29 @@ type RequestAdapter interface {
 	// Entry gets the entry address of the service process, such as "127.0.0.1:8080"
 	Entry() string
 }
+
+// HeaderAwareAdapter is optionally implemented by a RequestAdapter which decides whether a request is a sandbox
+// request by the headers as well, it takes precedence over IsSandboxRequest.
+type HeaderAwareAdapter interface {
+	IsSandboxRequestWithHeaders(authority, path string, port int, headers map[string]string) bool
+}
+
+// RequestAuthorizer is optionally implemented by a RequestAdapter to authorize the requests to secure sandboxes
+// without the access token, e.g. with signed URLs. The path is the one forwarded to the sandbox. The authorized
+// request is answered by the redirect instead of being forwarded if not nil.
+type RequestAuthorizer interface {
+	Authorize(sandboxID string, sandboxPort int, path string, headers map[string]string) (bool, *Redirect)
+	// CredentialCookie is the name of the cookie carrying the credential, which is stripped from the requests
+	// forwarded to the sandbox.
+	CredentialCookie() string
+}
+
+// Redirect moves the credential of a request into a cookie, e.g. the token of a signed URL in the query, so that the
+// following requests of the page, such as the assets, carry it as well. The cookie is named by
+// RequestAuthorizer.CredentialCookie, so that it is stripped before the requests are forwarded to the sandbox.
+type Redirect struct {
+	// Location is the URL requested without the credential
+	Location string
+	// SetCookie is the cookie carrying the credential
+	SetCookie string
+}
@@ -51,6 +51,11 @@ func (s *healthServer) Watch(*grpc_health_v1.HealthCheckRequest, grpc_health_v1.
 type Peer struct {
 	IP            string
 	LastHeartBeat time.Time
+	// Healthy is false if the last heartbeat to the peer failed, whose synchronizations are skipped
+	Healthy   bool
+	LastError string
+	// LastSynced is when the route table was last synchronized with the peer
+	LastSynced time.Time
 }
 
 // Server implements the Envoy external processing server.
@@ -66,6 +71,18 @@ type Server struct {
 	peerMu          sync.RWMutex
 	heartBeatTicker *time.Ticker
 	heartBeatStopCh chan struct{}
+	// waker resumes the paused sandboxes on request, nil if disabled
+	waker      *waker
+	activities *activities
+	// xds serves the routes to Envoy, nil if disabled
+	xds *xdsControlPlane
+	// reverseProxyPort is the port of the embedded reverse proxy, 0 if disabled
+	reverseProxyPort int
+	reverseProxySrv  *http.Server
+	// peerSecret signs the requests between peers if not empty
+	peerSecret []byte
+	// tombstones are the deletion time of the routes deleted recently
+	tombstones sync.Map
 }
 
 func NewServer(adapter RequestAdapter) *Server {
@@ -73,6 +90,7 @@ func NewServer(adapter RequestAdapter) *Server {
 		adapter:         adapter,
 		peers:           make(map[string]Peer),
 		heartBeatStopCh: make(chan struct{}),
+		activities:      newActivities(),
 	}
 	if adapter != nil {
 		s.LBEntry = adapter.Entry()
@@ -80,13 +98,37 @@ func NewServer(adapter RequestAdapter) *Server {
 	return s
 }
 
+// SetPeer adds the peer, or marks it healthy if known.
 func (s *Server) SetPeer(ip string) {
 	s.peerMu.Lock()
 	defer s.peerMu.Unlock()
-	s.peers[ip] = Peer{
-		IP:            ip,
-		LastHeartBeat: time.Now(),
+	peer := s.peers[ip]
+	peer.IP, peer.LastHeartBeat, peer.Healthy, peer.LastError = ip, time.Now(), true, ""
+	s.peers[ip] = peer
+}
+
+// DeletePeer forgets the peer, e.g. when it is shutting down.
+func (s *Server) DeletePeer(ip string) {
+	s.peerMu.Lock()
+	defer s.peerMu.Unlock()
+	delete(s.peers, ip)
+	RouteSyncLag.DeleteLabelValues(ip)
+}
+
+// setPeerHealth records the result of a heartbeat to the peer, if it is still a peer.
+func (s *Server) setPeerHealth(ip string, err error) {
+	s.peerMu.Lock()
+	defer s.peerMu.Unlock()
+	peer, ok := s.peers[ip]
+	if !ok {
+		return
+	}
+	if err != nil {
+		peer.Healthy, peer.LastError = false, err.Error()
+	} else {
+		peer.Healthy, peer.LastError, peer.LastHeartBeat = true, "", time.Now()
 	}
+	s.peers[ip] = peer
 }
 
 func (s *Server) Run() error {
@@ -98,8 +140,11 @@ func (s *Server) Run() error {
 
 	// HTTP
 	mux := http.NewServeMux()
-	web.RegisterRoute(mux, http.MethodPost, RefreshAPI, s.handleRefresh)
-	web.RegisterRoute(mux, http.MethodGet, HelloAPI, s.handleHello)
+	web.RegisterRoute(mux, http.MethodPost, RefreshAPI, s.handleRefresh, s.peerAuth)
+	web.RegisterRoute(mux, http.MethodGet, HelloAPI, s.handleHello, s.peerAuth)
+	web.RegisterRoute(mux, http.MethodPost, ActivityAPI, s.handleActivity, s.peerAuth)
+	web.RegisterRoute(mux, http.MethodGet, RouteDigestAPI, s.handleRouteDigest, s.peerAuth)
+	web.RegisterRoute(mux, http.MethodGet, RoutesAPI, s.handleListRoutes, s.peerAuth)
 	s.httpSrv = &http.Server{
 		Addr:              fmt.Sprintf(":%d", SystemPort),
 		Handler:           mux,
@@ -115,8 +160,15 @@ func (s *Server) Run() error {
 	s.grpcSrv = grpc.NewServer(grpc.MaxConcurrentStreams(1000))
 	extProcPb.RegisterExternalProcessorServer(s.grpcSrv, s)
 	grpc_health_v1.RegisterHealthServer(s.grpcSrv, &healthServer{})
+	if s.xds != nil {
+		s.registerXDS(s.grpcSrv, s.heartBeatStopCh)
+	}
 	klog.InfoS("Starting envoy ext-proc gRPC server", "address", lis.Addr())
 
+	if s.reverseProxyPort > 0 {
+		s.reverseProxySrv = s.newReverseProxy()
+	}
+
 	s.peerMu.Unlock()
 
 	// Start servers
@@ -127,6 +179,15 @@ func (s *Server) Run() error {
 		}
 	}()
 
+	if s.reverseProxySrv != nil {
+		go func() {
+			klog.InfoS("Starting embedded reverse proxy", "address", s.reverseProxySrv.Addr)
+			if err := s.reverseProxySrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				klog.Fatalf("Reverse proxy failed to start: %v", err)
+			}
+		}()
+	}
+
 	go func() {
 		klog.InfoS("Starting proxy gRPC server", "address", lis.Addr())
 		if err := s.grpcSrv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
@@ -139,45 +200,60 @@ func (s *Server) Run() error {
 		for {
 			select {
 			case <-s.heartBeatTicker.C:
-				s.peerMu.Lock()
-				peersToCheck := make([]Peer, 0, len(s.peers))
-				peersToDelete := make([]string, 0)
-
-				for ip, peer := range s.peers {
-					if time.Since(peer.LastHeartBeat) > 5*HeartBeatInterval {
-						peersToDelete = append(peersToDelete, ip)
-					} else {
-						peersToCheck = append(peersToCheck, peer)
+				// the peers are deleted when they leave, the unhealthy ones are kept until they recover
+				for _, peer := range s.ListPeers() {
+					_, err := s.requestPeer(http.MethodGet, peer.IP, HelloAPI, nil)
+					if err != nil {
+						log.Error(err, "failed to send heartbeat to peer", "ip", peer.IP)
 					}
+					s.setPeerHealth(peer.IP, err)
 				}
-				s.peerMu.Unlock()
+			case <-s.heartBeatStopCh:
+				return
+			}
 
-				if len(peersToDelete) > 0 {
-					s.peerMu.Lock()
-					for _, ip := range peersToDelete {
-						delete(s.peers, ip)
-						log.Info("peer deleted for heartbeat timeout", "ip", ip)
-					}
-					s.peerMu.Unlock()
-				}
+		}
+	}(logs.NewContext("component", "PeerHeartBeat"))
 
-				for _, peer := range peersToCheck {
-					if err := requestPeer(http.MethodGet, peer.IP, HelloAPI, nil); err != nil {
-						log.Error(err, "failed to send heartbeat to peer", "ip", peer.IP)
-					}
+	go func(ctx context.Context) {
+		log := klog.FromContext(ctx).V(consts.DebugLogLevel)
+		ticker := time.NewTicker(ActivitySyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.SyncActivitiesWithPeers(); err != nil {
+					log.Error(err, "failed to sync activities with peers")
 				}
 			case <-s.heartBeatStopCh:
 				return
 			}
+		}
+	}(logs.NewContext("component", "ActivitySync"))
 
+	go func(ctx context.Context) {
+		log := klog.FromContext(ctx).V(consts.DebugLogLevel)
+		ticker := time.NewTicker(RouteSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.SyncRoutesWithPeers(); err != nil {
+					log.Error(err, "failed to sync routes with peers")
+				}
+				s.updateSyncLag()
+			case <-s.heartBeatStopCh:
+				return
+			}
 		}
-	}(logs.NewContext("component", "PeerHeartBeat"))
+	}(logs.NewContext("component", "RouteSync"))
 
 	return nil
 }
 
 func (s *Server) HelloPeer(ip string) error {
-	return requestPeer(http.MethodGet, ip, HelloAPI, nil)
+	_, err := s.requestPeer(http.MethodGet, ip, HelloAPI, nil)
+	return err
 }
 
 func (s *Server) Stop() {
@@ -191,14 +267,19 @@ func (s *Server) Stop() {
 	if s.httpSrv != nil {
 		_ = s.httpSrv.Shutdown(context.Background())
 	}
+	if s.reverseProxySrv != nil {
+		_ = s.reverseProxySrv.Shutdown(context.Background())
+	}
 }
 
+// handleHello refreshes the peer saying hello if it is still a peer. The peers are only added by the informer, so a
+// hello from a terminating peer never adds it back.
 func (s *Server) handleHello(r *http.Request) (web.ApiResponse[struct{}], *web.ApiError) {
 	log := klog.FromContext(r.Context()).V(LogLevel + 1)
 	ip := getRealIP(r)
 	if ip != "" {
 		log.Info("hello", "ip", ip)
-		s.SetPeer(ip)
+		s.setPeerHealth(ip, nil)
 		return web.ApiResponse[struct{}]{
 			Code: http.StatusNoContent,
 		}, nil
@@ -14,25 +14,36 @@ var requestPeerClient = &http.Client{
 	Timeout: 1 * time.Second,
 }
 
-func requestPeer(method, ip, path string, body []byte) error {
+// requestPeer sends a request signed by the peer secret to the system server of the peer, and returns the body of the
+// response.
+func (s *Server) requestPeer(method, ip, path string, body []byte) ([]byte, error) {
 	var buf io.Reader
 	if len(body) > 0 {
 		buf = bytes.NewReader(body)
 	}
 	request, err := http.NewRequest(method, fmt.Sprintf("http://%s:%d%s", ip, SystemPort, path), buf)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(s.peerSecret) > 0 {
+		signPeerRequest(s.peerSecret, request, body)
 	}
 
 	resp, err := requestPeerClient.Do(request)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		_ = Body.Close()
 	}(resp.Body)
-
-	return nil
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("peer %s responded %d: %s", ip, resp.StatusCode, respBody)
+	}
+	return respBody, nil
 }
 
 func getRealIP(r *http.Request) string {
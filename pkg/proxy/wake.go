@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/sandbox-manager/logs"
+	"k8s.io/klog/v2"
+)
+
+// RouteWaitInterval is the interval to check whether the route of a woken sandbox is running
+var RouteWaitInterval = 50 * time.Millisecond
+
+// ResumeFunc resumes a paused sandbox, and returns after the sandbox is running.
+type ResumeFunc func(ctx context.Context, sandboxID string) error
+
+// resumeCall is a resume in flight, shared by all the requests to the same sandbox.
+type resumeCall struct {
+	done chan struct{}
+	err  error
+}
+
+type waker struct {
+	resume  ResumeFunc
+	timeout time.Duration
+
+	mu    sync.Mutex
+	calls map[string]*resumeCall
+}
+
+// EnableWakeOnRequest makes the requests to a paused sandbox resume it, instead of being rejected. The requests are
+// held for timeout at most, and forwarded once the route of the sandbox is running.
+func (s *Server) EnableWakeOnRequest(resume ResumeFunc, timeout time.Duration) {
+	s.waker = &waker{
+		resume:  resume,
+		timeout: timeout,
+		calls:   make(map[string]*resumeCall),
+	}
+}
+
+// wakeSandbox resumes the paused sandbox and waits until its route is running.
+func (s *Server) wakeSandbox(ctx context.Context, sandboxID string) (Route, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.waker.timeout)
+	defer cancel()
+	call := s.waker.startResume(sandboxID)
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return Route{}, call.err
+		}
+	case <-ctx.Done():
+		return Route{}, fmt.Errorf("timeout waiting for sandbox %s to be resumed", sandboxID)
+	}
+
+	// the route is refreshed asynchronously by the informer
+	ticker := time.NewTicker(RouteWaitInterval)
+	defer ticker.Stop()
+	for {
+		route, ok := s.LoadRoute(sandboxID)
+		if !ok {
+			return Route{}, fmt.Errorf("route for sandbox %s not found", sandboxID)
+		}
+		if route.State == agentsv1alpha1.SandboxStateRunning {
+			return route, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return Route{}, fmt.Errorf("timeout waiting for route of sandbox %s to be running", sandboxID)
+		}
+	}
+}
+
+// startResume starts resuming the sandbox, or joins the resume in flight.
+func (w *waker) startResume(sandboxID string) *resumeCall {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if call, ok := w.calls[sandboxID]; ok {
+		return call
+	}
+	call := &resumeCall{done: make(chan struct{})}
+	w.calls[sandboxID] = call
+	go func() {
+		// not bound to any request, which may give up waiting before the others
+		ctx := logs.NewContext("sandboxID", sandboxID)
+		log := klog.FromContext(ctx)
+		log.Info("waking up paused sandbox on request")
+		start := time.Now()
+		call.err = w.resume(ctx, sandboxID)
+		if call.err != nil {
+			log.Error(call.err, "failed to wake up sandbox")
+		} else {
+			log.Info("sandbox woken up", "cost", time.Since(start))
+		}
+		w.mu.Lock()
+		delete(w.calls, sandboxID)
+		w.mu.Unlock()
+		close(call.done)
+	}()
+	return call
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+)
+
+func TestServer_wakeSandbox(t *testing.T) {
+	tests := []struct {
+		name        string
+		resumeDelay time.Duration
+		resumeErr   error
+		flipRoute   bool
+		expectError bool
+	}{
+		{
+			name:        "woken up",
+			resumeDelay: 50 * time.Millisecond,
+			flipRoute:   true,
+		},
+		{
+			name:        "resume failed",
+			resumeDelay: 50 * time.Millisecond,
+			resumeErr:   errors.New("resume failed"),
+			expectError: true,
+		},
+		{
+			name:        "resume timeout",
+			resumeDelay: time.Second,
+			flipRoute:   true,
+			expectError: true,
+		},
+		{
+			name:        "route not running in time",
+			resumeDelay: 50 * time.Millisecond,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(nil)
+			s.SetRoute(Route{ID: "sandbox1", IP: "192.168.1.10", State: agentsv1alpha1.SandboxStatePaused})
+			var calls atomic.Int32
+			s.EnableWakeOnRequest(func(ctx context.Context, sandboxID string) error {
+				calls.Add(1)
+				time.Sleep(tt.resumeDelay)
+				if tt.flipRoute {
+					s.SetRoute(Route{ID: sandboxID, IP: "192.168.1.11", State: agentsv1alpha1.SandboxStateRunning})
+				}
+				return tt.resumeErr
+			}, 300*time.Millisecond)
+
+			// concurrent requests share a single resume
+			var wg sync.WaitGroup
+			routes := make([]Route, 5)
+			errs := make([]error, 5)
+			for i := range routes {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					routes[i], errs[i] = s.wakeSandbox(context.Background(), "sandbox1")
+				}(i)
+			}
+			wg.Wait()
+			if calls.Load() != 1 {
+				t.Errorf("Expected resume called once, got %d", calls.Load())
+			}
+			for i := range routes {
+				if (errs[i] != nil) != tt.expectError {
+					t.Errorf("wakeSandbox() error = %v, expectError %v", errs[i], tt.expectError)
+				}
+				if !tt.expectError && routes[i].IP != "192.168.1.11" {
+					t.Errorf("Expected route to the resumed pod, got %s", routes[i].IP)
+				}
+			}
+		})
+	}
+}
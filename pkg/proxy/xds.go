@@ -0,0 +1,324 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	configPb "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	extProcFilterPb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
+	upstreamHttpPb "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	clusterservice "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
+	discoverygrpc "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	routeservice "github.com/envoyproxy/go-control-plane/envoy/service/route/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	serverv3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/sandbox-manager/logs"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"k8s.io/klog/v2"
+)
+
+// The names referred by the Envoy bootstrap, whose listener loads the RouteConfiguration through ADS and runs the
+// ext_proc filter named ExtProcFilterName before the router.
+const (
+	XDSRouteConfigName   = "sandbox-routes"
+	OriginalDstCluster   = "sandbox-original-dst"
+	OriginalDstH2Cluster = "sandbox-original-dst-h2"
+	ExtProcFilterName    = "envoy.filters.http.ext_proc"
+)
+
+// XDSDebounceInterval is how long the route changes are batched before a new snapshot is pushed
+var XDSDebounceInterval = 100 * time.Millisecond
+
+// XDSMatch describes how the requests to a port of a sandbox are matched by Envoy.
+type XDSMatch struct {
+	// Domain of the virtual host, "*" matches all
+	Domain string
+	// PathPrefix matches the requests under it, which is rewritten to "/" if Rewrite is true
+	PathPrefix string
+	Rewrite    bool
+}
+
+// XDSRouteAdapter is optionally implemented by a RequestAdapter to serve the routes of sandboxes through xDS, which
+// is the reverse of RequestAdapter.Map.
+type XDSRouteAdapter interface {
+	XDSMatches(sandboxID string, sandboxPort int) []XDSMatch
+	// XDSDefaultPorts are the ports routed for the sandboxes declaring no ports. Any port of them can be requested,
+	// while only these ones are routed by Envoy directly, the others are still processed by ext_proc.
+	XDSDefaultPorts() []ExposedPort
+}
+
+// xdsControlPlane pushes the route table to Envoy, so that the requests to running sandboxes are forwarded without
+// ext_proc. The other requests fall through to ext_proc, such as the ones to paused sandboxes with wake-on-request,
+// to the ports of secure sandboxes requiring access tokens, or to the ports not declared.
+type xdsControlPlane struct {
+	cache   cachev3.SnapshotCache
+	version atomic.Int64
+	// changed is notified when the routes are changed
+	changed chan struct{}
+}
+
+// xdsNodeGroup is the only node group, all the Envoys share the same snapshot
+type xdsNodeGroup struct{}
+
+func (xdsNodeGroup) ID(*configPb.Node) string {
+	return "sandbox-gateway"
+}
+
+// klogAdapter logs the control plane with klog
+type klogAdapter struct{}
+
+func (klogAdapter) Debugf(format string, args ...any) {
+	klog.V(klog.Level(LogLevel+1)).InfofDepth(1, format, args...)
+}
+
+func (klogAdapter) Infof(format string, args ...any) {
+	klog.V(klog.Level(LogLevel)).InfofDepth(1, format, args...)
+}
+
+func (klogAdapter) Warnf(format string, args ...any) {
+	klog.WarningfDepth(1, format, args...)
+}
+
+func (klogAdapter) Errorf(format string, args ...any) {
+	klog.ErrorfDepth(1, format, args...)
+}
+
+// EnableXDS serves the routes to Envoy through xDS on the ext_proc port, it should be called before Run. The adapter
+// should implement XDSRouteAdapter, or all the requests are still processed by ext_proc.
+func (s *Server) EnableXDS() {
+	s.xds = &xdsControlPlane{
+		cache:   cachev3.NewSnapshotCache(true, xdsNodeGroup{}, klogAdapter{}),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// registerXDS registers the discovery services and starts pushing snapshots until stopCh is closed.
+func (s *Server) registerXDS(grpcSrv *grpc.Server, stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(logs.NewContext("component", "XDS"))
+	srv := serverv3.NewServer(ctx, s.xds.cache, nil)
+	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(grpcSrv, srv)
+	routeservice.RegisterRouteDiscoveryServiceServer(grpcSrv, srv)
+	clusterservice.RegisterClusterDiscoveryServiceServer(grpcSrv, srv)
+	if err := s.pushSnapshot(ctx); err != nil {
+		klog.FromContext(ctx).Error(err, "failed to push initial xDS snapshot")
+	}
+	go func() {
+		defer cancel()
+		log := klog.FromContext(ctx)
+		for {
+			select {
+			case <-s.xds.changed:
+				// batch the changes in a short period, e.g. a pool scaled up
+				select {
+				case <-time.After(XDSDebounceInterval):
+				case <-stopCh:
+					return
+				}
+				if err := s.pushSnapshot(ctx); err != nil {
+					log.Error(err, "failed to push xDS snapshot")
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// notifyXDS triggers a new snapshot if xDS is enabled.
+func (s *Server) notifyXDS() {
+	if s.xds == nil {
+		return
+	}
+	select {
+	case s.xds.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Server) pushSnapshot(ctx context.Context) error {
+	version := strconv.FormatInt(s.xds.version.Add(1), 10)
+	snapshot, err := cachev3.NewSnapshot(version, map[resourcev3.Type][]types.Resource{
+		resourcev3.ClusterType: xdsClusters(),
+		resourcev3.RouteType:   {s.xdsRouteConfig()},
+	})
+	if err != nil {
+		return err
+	}
+	// the consistency is not checked, for the listeners referring the routes are in the bootstrap of Envoy
+	if err = s.xds.cache.SetSnapshot(ctx, xdsNodeGroup{}.ID(nil), snapshot); err != nil {
+		return err
+	}
+	klog.FromContext(ctx).V(LogLevel).Info("xDS snapshot pushed", "version", version)
+	return nil
+}
+
+// xdsClusters forwards the requests to the address in OrigDstHeader, the same as the ext_proc does.
+func xdsClusters() []types.Resource {
+	newCluster := func(name string) *clusterv3.Cluster {
+		return &clusterv3.Cluster{
+			Name:                 name,
+			ClusterDiscoveryType: &clusterv3.Cluster_Type{Type: clusterv3.Cluster_ORIGINAL_DST},
+			LbPolicy:             clusterv3.Cluster_CLUSTER_PROVIDED,
+			ConnectTimeout:       durationpb.New(5 * time.Second),
+			LbConfig: &clusterv3.Cluster_OriginalDstLbConfig_{
+				OriginalDstLbConfig: &clusterv3.Cluster_OriginalDstLbConfig{
+					UseHttpHeader:  true,
+					HttpHeaderName: OrigDstHeader,
+				},
+			},
+		}
+	}
+	h2 := newCluster(OriginalDstH2Cluster)
+	options, _ := anypb.New(&upstreamHttpPb.HttpProtocolOptions{
+		UpstreamProtocolOptions: &upstreamHttpPb.HttpProtocolOptions_ExplicitHttpConfig_{
+			ExplicitHttpConfig: &upstreamHttpPb.HttpProtocolOptions_ExplicitHttpConfig{
+				ProtocolConfig: &upstreamHttpPb.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
+					Http2ProtocolOptions: &configPb.Http2ProtocolOptions{},
+				},
+			},
+		},
+	})
+	h2.TypedExtensionProtocolOptions = map[string]*anypb.Any{
+		"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": options,
+	}
+	return []types.Resource{newCluster(OriginalDstCluster), h2}
+}
+
+// xdsRouteConfig generates the RouteConfiguration from the route table. Each match of a port gets a route, and the
+// requests matching none of them fall through to the routes processed by ext_proc. The sandboxes declaring no ports
+// get the routes of the default ports of the adapter only.
+func (s *Server) xdsRouteConfig() *routev3.RouteConfiguration {
+	adapter, _ := s.adapter.(XDSRouteAdapter)
+	routes := s.ListRoutes()
+	slices.SortFunc(routes, func(a, b Route) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	vhosts := make(map[string]*routev3.VirtualHost)
+	var domains []string
+	for _, route := range routes {
+		if adapter == nil || route.Dynamic {
+			continue
+		}
+		ports := route.ExposedPorts
+		if len(ports) == 0 {
+			ports = adapter.XDSDefaultPorts()
+		}
+		for _, port := range ports {
+			for _, match := range adapter.XDSMatches(route.ID, port.Port) {
+				vhost, ok := vhosts[match.Domain]
+				if !ok {
+					vhost = &routev3.VirtualHost{
+						Name:    match.Domain,
+						Domains: []string{match.Domain},
+					}
+					vhosts[match.Domain] = vhost
+					domains = append(domains, match.Domain)
+				}
+				vhost.Routes = append(vhost.Routes, xdsRoutes(route, port, match, s.waker != nil)...)
+			}
+		}
+	}
+	if _, ok := vhosts["*"]; !ok {
+		vhosts["*"] = &routev3.VirtualHost{Name: "*", Domains: []string{"*"}}
+		domains = append(domains, "*")
+	}
+	config := &routev3.RouteConfiguration{Name: XDSRouteConfigName}
+	for _, domain := range domains {
+		vhost := vhosts[domain]
+		vhost.Routes = append(vhost.Routes, extProcRoute())
+		config.VirtualHosts = append(config.VirtualHosts, vhost)
+	}
+	return config
+}
+
+// xdsRoutes generates the routes of a port of the sandbox. Only the ports declared or defaulted are served, so that the
+// ones not exposed are rejected by ext_proc.
+func xdsRoutes(route Route, port ExposedPort, match XDSMatch, wakeOnRequest bool) []*routev3.Route {
+	prefix := match.PathPrefix
+	if prefix == "" {
+		prefix = "/"
+	}
+	name := fmt.Sprintf("%s-%d", route.ID, port.Port)
+	switch route.State {
+	case agentsv1alpha1.SandboxStateRunning:
+	case agentsv1alpha1.SandboxStatePaused:
+		if wakeOnRequest {
+			return nil
+		}
+		return []*routev3.Route{{
+			Name:  name,
+			Match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: prefix}},
+			Action: &routev3.Route_DirectResponse{DirectResponse: &routev3.DirectResponseAction{
+				Status: 403,
+				Body:   &configPb.DataSource{Specifier: &configPb.DataSource_InlineString{InlineString: "sandbox is paused"}},
+			}},
+		}}
+	default:
+		return nil
+	}
+
+	if route.AccessTokenHash != "" && !port.Public {
+		// only the hash of the token is known, which Envoy cannot check, so the requests are authorized by ext_proc
+		return nil
+	}
+	routeMatch := &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: prefix}}
+	headers := []*configPb.HeaderValueOption{{
+		Header:       &configPb.HeaderValue{Key: OrigDstHeader, Value: fmt.Sprintf("%s:%d", route.IP, port.Port)},
+		AppendAction: configPb.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+	}}
+	for k, v := range route.ExtraHeaders {
+		headers = append(headers, &configPb.HeaderValueOption{
+			Header:       &configPb.HeaderValue{Key: k, Value: v},
+			AppendAction: configPb.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		})
+	}
+	slices.SortFunc(headers, func(a, b *configPb.HeaderValueOption) int {
+		return strings.Compare(a.Header.Key, b.Header.Key)
+	})
+	action := &routev3.RouteAction{
+		ClusterSpecifier: &routev3.RouteAction_Cluster{Cluster: OriginalDstCluster},
+	}
+	switch port.Protocol {
+	case string(agentsv1alpha1.SandboxPortProtocolGRPC):
+		action.ClusterSpecifier = &routev3.RouteAction_Cluster{Cluster: OriginalDstH2Cluster}
+	case string(agentsv1alpha1.SandboxPortProtocolWebSocket):
+		action.UpgradeConfigs = []*routev3.RouteAction_UpgradeConfig{{UpgradeType: "websocket"}}
+	}
+	if match.Rewrite {
+		action.PrefixRewrite = "/"
+	}
+	disabled, _ := anypb.New(&extProcFilterPb.ExtProcPerRoute{
+		Override: &extProcFilterPb.ExtProcPerRoute_Disabled{Disabled: true},
+	})
+	return []*routev3.Route{{
+		Name:                 name,
+		Match:                routeMatch,
+		Action:               &routev3.Route_Route{Route: action},
+		RequestHeadersToAdd:  headers,
+		TypedPerFilterConfig: map[string]*anypb.Any{ExtProcFilterName: disabled},
+	}}
+}
+
+// extProcRoute is the last route of each virtual host, whose destination is set by ext_proc.
+func extProcRoute() *routev3.Route {
+	return &routev3.Route{
+		Name:  "ext-proc",
+		Match: &routev3.RouteMatch{PathSpecifier: &routev3.RouteMatch_Prefix{Prefix: "/"}},
+		Action: &routev3.Route_Route{Route: &routev3.RouteAction{
+			ClusterSpecifier: &routev3.RouteAction_Cluster{Cluster: OriginalDstCluster},
+			UpgradeConfigs:   []*routev3.RouteAction_UpgradeConfig{{UpgradeType: "websocket"}},
+		}},
+	}
+}
@@ -0,0 +1,232 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"google.golang.org/grpc"
+)
+
+// testXDSAdapter matches the requests by the host like the native E2B adapter
+type testXDSAdapter struct {
+	testRequestAdapter
+}
+
+func (t *testXDSAdapter) XDSMatches(sandboxID string, sandboxPort int) []XDSMatch {
+	return []XDSMatch{
+		{Domain: fmt.Sprintf("%d-%s.*", sandboxPort, sandboxID), PathPrefix: "/"},
+		{Domain: "*", PathPrefix: fmt.Sprintf("/sandbox/%s/%d/", sandboxID, sandboxPort), Rewrite: true},
+	}
+}
+
+func (t *testXDSAdapter) XDSDefaultPorts() []ExposedPort {
+	return []ExposedPort{{Port: 3000}}
+}
+
+func findRoute(config *routev3.RouteConfiguration, domain, name string) *routev3.Route {
+	for _, vhost := range config.VirtualHosts {
+		if vhost.Name != domain {
+			continue
+		}
+		for _, route := range vhost.Routes {
+			if route.Name == name {
+				return route
+			}
+		}
+	}
+	return nil
+}
+
+func TestXDSRouteConfig(t *testing.T) {
+	ports := []ExposedPort{
+		{Port: 3000, Protocol: string(agentsv1alpha1.SandboxPortProtocolHTTP)},
+		{Port: 8080, Protocol: string(agentsv1alpha1.SandboxPortProtocolGRPC), Public: true},
+		{Port: 9000, Protocol: string(agentsv1alpha1.SandboxPortProtocolWebSocket)},
+	}
+	tests := []struct {
+		name          string
+		route         Route
+		wakeOnRequest bool
+		// expectRoutes are the names of the routes in the domain of port 3000
+		expectRoutes []string
+		check        func(t *testing.T, config *routev3.RouteConfiguration)
+	}{
+		{
+			name: "running sandbox",
+			route: Route{
+				IP: "1.2.3.4", ID: "sbx", State: agentsv1alpha1.SandboxStateRunning,
+				ExtraHeaders: map[string]string{"x-user": "alice"},
+				ExposedPorts: ports,
+			},
+			expectRoutes: []string{"sbx-3000", "ext-proc"},
+			check: func(t *testing.T, config *routev3.RouteConfiguration) {
+				route := findRoute(config, "3000-sbx.*", "sbx-3000")
+				headers := route.RequestHeadersToAdd
+				if len(headers) != 2 || headers[0].Header.Key != OrigDstHeader ||
+					headers[0].Header.Value != "1.2.3.4:3000" || headers[1].Header.Key != "x-user" {
+					t.Errorf("unexpected headers: %v", headers)
+				}
+				if _, ok := route.TypedPerFilterConfig[ExtProcFilterName]; !ok {
+					t.Errorf("ext_proc is not disabled")
+				}
+				if len(route.Match.Headers) != 0 {
+					t.Errorf("unexpected header matchers: %v", route.Match.Headers)
+				}
+				custom := findRoute(config, "*", "sbx-3000")
+				if custom == nil || custom.Match.GetPrefix() != "/sandbox/sbx/3000/" ||
+					custom.GetRoute().PrefixRewrite != "/" {
+					t.Errorf("unexpected customized route: %v", custom)
+				}
+				if cluster := findRoute(config, "8080-sbx.*", "sbx-8080").GetRoute().GetCluster(); cluster != OriginalDstH2Cluster {
+					t.Errorf("expected cluster %s for GRPC, got %s", OriginalDstH2Cluster, cluster)
+				}
+				if upgrades := findRoute(config, "9000-sbx.*", "sbx-9000").GetRoute().UpgradeConfigs; len(upgrades) != 1 {
+					t.Errorf("expected websocket upgrade, got %v", upgrades)
+				}
+			},
+		},
+		{
+			name: "secure sandbox",
+			route: Route{
+				IP: "1.2.3.4", ID: "sbx", State: agentsv1alpha1.SandboxStateRunning,
+				AccessTokenHash: HashAccessToken("token"),
+				ExposedPorts:    ports,
+			},
+			expectRoutes: []string{"ext-proc"},
+			check: func(t *testing.T, config *routev3.RouteConfiguration) {
+				if route := findRoute(config, "8080-sbx.*", "sbx-8080"); route == nil || len(route.Match.Headers) != 0 {
+					t.Errorf("public port should be served without the token: %v", route)
+				}
+			},
+		},
+		{
+			name: "paused sandbox",
+			route: Route{
+				IP: "1.2.3.4", ID: "sbx", State: agentsv1alpha1.SandboxStatePaused,
+				ExposedPorts: ports,
+			},
+			expectRoutes: []string{"sbx-3000", "ext-proc"},
+			check: func(t *testing.T, config *routev3.RouteConfiguration) {
+				response := findRoute(config, "3000-sbx.*", "sbx-3000").GetDirectResponse()
+				if response == nil || response.Status != 403 {
+					t.Errorf("expected 403 direct response, got %v", response)
+				}
+			},
+		},
+		{
+			name: "paused sandbox with wake-on-request",
+			route: Route{
+				IP: "1.2.3.4", ID: "sbx", State: agentsv1alpha1.SandboxStatePaused,
+				ExposedPorts: ports,
+			},
+			wakeOnRequest: true,
+			expectRoutes:  []string{"ext-proc"},
+		},
+		{
+			name: "dynamic sandbox",
+			route: Route{
+				IP: "1.2.3.4", ID: "sbx", State: agentsv1alpha1.SandboxStateRunning,
+				ExposedPorts: ports,
+				Dynamic:      true,
+			},
+		},
+		{
+			name: "sandbox without exposed ports",
+			route: Route{
+				IP: "1.2.3.4", ID: "sbx", State: agentsv1alpha1.SandboxStateRunning,
+			},
+			// only the default ports are routed, the others fall through to ext_proc
+			expectRoutes: []string{"sbx-3000", "ext-proc"},
+			check: func(t *testing.T, config *routev3.RouteConfiguration) {
+				if route := findRoute(config, "8080-sbx.*", "sbx-8080"); route != nil {
+					t.Errorf("unexpected route of the port not defaulted: %v", route)
+				}
+			},
+		},
+		{
+			name: "creating sandbox",
+			route: Route{
+				ID: "sbx", State: agentsv1alpha1.SandboxStateCreating,
+				ExposedPorts: ports,
+			},
+			expectRoutes: []string{"ext-proc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(&testXDSAdapter{})
+			server.EnableXDS()
+			if tt.wakeOnRequest {
+				server.EnableWakeOnRequest(func(context.Context, string) error { return nil }, time.Second)
+			}
+			server.SetRoute(tt.route)
+			config := server.xdsRouteConfig()
+			if config.Name != XDSRouteConfigName {
+				t.Errorf("expected name %s, got %s", XDSRouteConfigName, config.Name)
+			}
+			var names []string
+			for _, vhost := range config.VirtualHosts {
+				if vhost.Name == "3000-sbx.*" {
+					for _, route := range vhost.Routes {
+						names = append(names, route.Name)
+					}
+				}
+				if last := vhost.Routes[len(vhost.Routes)-1]; last.Name != "ext-proc" {
+					t.Errorf("the last route of %s should be ext-proc, got %s", vhost.Name, last.Name)
+				}
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.expectRoutes) {
+				t.Errorf("expected routes %v, got %v", tt.expectRoutes, names)
+			}
+			if tt.check != nil {
+				tt.check(t, config)
+			}
+		})
+	}
+}
+
+func TestXDSSnapshot(t *testing.T) {
+	oldInterval := XDSDebounceInterval
+	XDSDebounceInterval = 10 * time.Millisecond
+	defer func() { XDSDebounceInterval = oldInterval }()
+
+	server := NewServer(&testXDSAdapter{})
+	server.EnableXDS()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	// the initial snapshot is pushed on registering
+	server.registerXDS(grpc.NewServer(), stopCh)
+	snapshot, err := server.xds.cache.GetSnapshot(xdsNodeGroup{}.ID(nil))
+	if err != nil {
+		t.Fatalf("failed to get snapshot: %v", err)
+	}
+	if version := snapshot.GetVersion(resourcev3.RouteType); version != "1" {
+		t.Errorf("expected version 1, got %s", version)
+	}
+	if clusters := snapshot.GetResources(resourcev3.ClusterType); len(clusters) != 2 {
+		t.Errorf("expected 2 clusters, got %d", len(clusters))
+	}
+
+	// route changes are pushed in background
+	server.SetRoute(Route{
+		IP: "1.2.3.4", ID: "sbx", State: agentsv1alpha1.SandboxStateRunning,
+		ExposedPorts: []ExposedPort{{Port: 3000}},
+	})
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		snapshot, _ = server.xds.cache.GetSnapshot(xdsNodeGroup{}.ID(nil))
+		if snapshot.GetVersion(resourcev3.RouteType) != "1" {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	config := snapshot.GetResources(resourcev3.RouteType)[XDSRouteConfigName].(*routev3.RouteConfiguration)
+	if findRoute(config, "3000-sbx.*", "sbx-3000") == nil {
+		t.Errorf("route of the sandbox is not pushed")
+	}
+}
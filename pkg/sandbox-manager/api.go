@@ -33,11 +33,19 @@ func (m *SandboxManager) ClaimSandbox(ctx context.Context, user, template string
 	SandboxCreationResponses.WithLabelValues("success").Inc()
 	// Requirement: Only measure the latency when no error exists
 	SandboxCreationLatency.Observe(float64(time.Since(start).Milliseconds()))
+	if sandbox.GetAnnotations()[v1alpha1.AnnotationColdStart] == v1alpha1.True {
+		SandboxClaims.WithLabelValues("cold").Inc()
+		SandboxColdStartLatency.Observe(float64(time.Since(start).Milliseconds()))
+	} else {
+		SandboxClaims.WithLabelValues("warm").Inc()
+	}
 
 	log.Info("sandbox claimed", "sandbox", klog.KObj(sandbox), "cost", time.Since(start))
 
 	startSync := time.Now()
 	route := sandbox.GetRoute()
+	// set at once instead of waiting for the informer, so that a secure sandbox is never routed without its token
+	m.proxy.SetRoute(route)
 	err = m.proxy.SyncRouteWithPeers(route)
 	if err != nil {
 		log.Error(err, "failed to sync route with peers", "cost", time.Since(startSync))
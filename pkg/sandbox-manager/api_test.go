@@ -30,7 +30,7 @@ func ConvertPodToSandboxCR(pod *corev1.Pod) *agentsv1alpha1.Sandbox {
 	sbx := &agentsv1alpha1.Sandbox{
 		ObjectMeta: pod.ObjectMeta,
 		Spec: agentsv1alpha1.SandboxSpec{
-			SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					Spec: pod.Spec,
 				},
@@ -157,7 +157,7 @@ func TestSandboxManager_ClaimSandbox(t *testing.T) {
 					},
 				},
 				Spec: agentsv1alpha1.SandboxSpec{
-					SandboxTemplate: agentsv1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -10,16 +10,9 @@ import (
 	"github.com/openkruise/agents/pkg/sandbox-manager/consts"
 	"github.com/openkruise/agents/pkg/sandbox-manager/infra"
 	"github.com/openkruise/agents/pkg/sandbox-manager/infra/sandboxcr"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
 )
 
-// means 2 min timeout
-const (
-	PeerInitInterval   = 6 * time.Second
-	PeerInitMaxRetries = 20
-)
-
 type SandboxManager struct {
 	Namespace string
 
@@ -38,7 +31,7 @@ func NewSandboxManager(client *clients.ClientSet, adapter proxy.RequestAdapter,
 	var err error
 	switch infra {
 	case consts.InfraSandboxCR:
-		m.infra, err = sandboxcr.NewInfra(client.SandboxClient, m.proxy)
+		m.infra, err = sandboxcr.NewInfra(client.SandboxClient, client.K8sClient, m.proxy)
 	default:
 		err = fmt.Errorf("infra must be one of: [%s]",
 			consts.InfraSandboxCR)
@@ -47,7 +40,6 @@ func NewSandboxManager(client *clients.ClientSet, adapter proxy.RequestAdapter,
 }
 
 func (m *SandboxManager) Run(ctx context.Context, sysNs, peerSelector string) error {
-	log := klog.FromContext(ctx)
 	go func() {
 		klog.InfoS("starting proxy")
 		err := m.proxy.Run()
@@ -55,46 +47,9 @@ func (m *SandboxManager) Run(ctx context.Context, sysNs, peerSelector string) er
 			klog.Error(err, "proxy stopped")
 		}
 	}()
-	// TODO peer system is not optimized
-	var peerInited bool
-	log.Info("start to find peers")
-	for i := 0; i < PeerInitMaxRetries; i++ {
-		peerList, err := m.client.CoreV1().Pods(sysNs).List(ctx, metav1.ListOptions{
-			LabelSelector: peerSelector,
-		})
-		if err != nil {
-			return err
-		}
-		log.Info("peer pods listed", "num", len(peerList.Items))
-		var peers []string
-		for _, peer := range peerList.Items {
-			ip := peer.Status.PodIP
-			if ip == "" {
-				log.Info("peer pod has no ip", "peer", peer.Name)
-				continue
-			}
-			log.Info("try to say hello to peer", "peer", peer.Name, "ip", ip)
-			if helloErr := m.proxy.HelloPeer(ip); helloErr == nil {
-				peers = append(peers, ip)
-				log.Info("found peer", "peer", peer.Name, "ip", ip)
-			} else {
-				log.Info("peer is not ready", "peer", peer.Name, "ip", ip, "error", helloErr)
-			}
-		}
-		if len(peers) == len(peerList.Items) {
-			log.Info("all peers are ready")
-			for _, ip := range peers {
-				m.proxy.SetPeer(ip)
-			}
-			peerInited = true
-			break
-		} else {
-			log.Info("waiting for peers to start", "ready", len(peers), "total", len(peerList.Items))
-			time.Sleep(PeerInitInterval)
-		}
-	}
-	if !peerInited {
-		return fmt.Errorf("failed to init peers")
+	// the peers are watched instead of waited, so that the rolling restarts never block on the leaving ones
+	if err := m.watchPeers(ctx, sysNs, peerSelector); err != nil {
+		return err
 	}
 	if err := m.infra.Run(ctx); err != nil {
 		return err
@@ -110,3 +65,39 @@ func (m *SandboxManager) Stop() {
 func (m *SandboxManager) GetInfra() infra.Infrastructure {
 	return m.infra
 }
+
+// EnableWakeOnRequest makes the proxy resume a paused sandbox with resume on its first request, and hold the
+// requests for timeout at most.
+func (m *SandboxManager) EnableWakeOnRequest(resume proxy.ResumeFunc, timeout time.Duration) {
+	m.proxy.EnableWakeOnRequest(resume, timeout)
+}
+
+// SetPeerSecret makes the requests between the peers signed by the secret.
+func (m *SandboxManager) SetPeerSecret(secret []byte) {
+	m.proxy.SetPeerSecret(secret)
+}
+
+// EnableXDS makes the proxy serve the routes of sandboxes to Envoy through xDS.
+func (m *SandboxManager) EnableXDS() {
+	m.proxy.EnableXDS()
+}
+
+// EnableReverseProxy makes the proxy serve the requests to sandboxes on port without Envoy.
+func (m *SandboxManager) EnableReverseProxy(port int) {
+	m.proxy.EnableReverseProxy(port)
+}
+
+// RecordActivity records the sandbox as active now, e.g. when it is resumed through the API.
+func (m *SandboxManager) RecordActivity(sandboxID string) {
+	m.proxy.RecordActivity(sandboxID, time.Now())
+}
+
+// GetLastActivity returns the last time the sandbox was requested through the proxies.
+func (m *SandboxManager) GetLastActivity(sandboxID string) (time.Time, bool) {
+	return m.proxy.LoadActivity(sandboxID)
+}
+
+// ListRoutes lists the routes of all the sandboxes known by the proxy.
+func (m *SandboxManager) ListRoutes() []proxy.Route {
+	return m.proxy.ListRoutes()
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/openkruise/agents/pkg/proxy"
@@ -13,6 +14,15 @@ import (
 type ClaimSandboxOptions struct {
 	Modifier func(sandbox Sandbox)
 	Image    string
+	// Resources overrides the cpu / memory of the first container, which is resized in-place
+	Resources corev1.ResourceList
+	// ColdStartTimeout creates a Sandbox on demand and waits for it to be ready for the duration at most if the pool
+	// has no stock, which overrides the cold start timeout of the pool
+	ColdStartTimeout time.Duration
+	// MaxWait waits in the claim queue of the pool for the duration at most if no sandbox is available
+	MaxWait time.Duration
+	// Weight schedules the waiting claims of the users by weighted fair queueing, instead of in the order they arrive
+	Weight int
 }
 
 type SandboxResource struct {
@@ -31,8 +41,13 @@ type Infrastructure interface {
 	LoadDebugInfo() map[string]any
 	SelectSandboxes(user string, limit int, filter func(sandbox Sandbox) bool) ([]Sandbox, error) // Select Sandboxes based on the options provided
 	GetSandbox(ctx context.Context, sandboxID string) (Sandbox, error)                            // Get a Sandbox interface by its ID
+	OnSandboxReinit(handler SandboxReinitHandler)                                                 // Register the handler called when the recreated pod of a claimed Sandbox is ready
 }
 
+// SandboxReinitHandler replays the claim-time init of a claimed Sandbox whose pod is recreated and ready again, e.g. by
+// resuming or the restart policy, and then marks the pod by AnnotationInitedPod, which makes the Sandbox running.
+type SandboxReinitHandler func(ctx context.Context, sbx Sandbox) error
+
 type SandboxPool interface {
 	GetName() string
 	GetAnnotations() map[string]string
@@ -50,13 +65,17 @@ type Sandbox interface {
 	GetTemplate() string          // Get the template name of the Sandbox
 	GetResource() SandboxResource // Get the CPU / Memory requirements of the Sandbox
 	SetTimeout(ttl time.Duration)
+	SetAutoPause(pausedRetention time.Duration) // Pause instead of delete the Sandbox when timeout, and delete it after paused for pausedRetention
 	SaveTimeout(ctx context.Context, ttl time.Duration) error
+	SaveAnnotations(ctx context.Context, annotations map[string]string) error // Merge the annotations into the Sandbox resource
 	SetImage(image string)
 	GetImage() string
+	SetResources(resources corev1.ResourceList) // Set the cpu / memory requests of the first container
 	GetTimeout() time.Time
 	GetClaimTime() (time.Time, error)
 	Kill(ctx context.Context) error                                         // Delete the Sandbox resource
 	InplaceRefresh(deepcopy bool) error                                     // Update the Sandbox resource object to the latest
 	Request(r *http.Request, path string, port int) (*http.Response, error) // Make a request to the Sandbox
 	CSIMount(ctx context.Context, driver string, request string) error      // request is base64 encoded csi.NodePublishVolumeRequest
+	GetPodUID() string                                                      // Get the UID of the current pod of the Sandbox
 }
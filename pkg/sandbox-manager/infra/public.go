@@ -11,6 +11,8 @@ type BaseInfra struct {
 	Namespace   string
 	TemplateDir string
 	Pools       sync.Map
+
+	ReinitHandler SandboxReinitHandler
 }
 
 func (i *BaseInfra) GetPoolByObject(sbx metav1.Object) (pool SandboxPool, ok bool) {
@@ -29,3 +31,7 @@ func (i *BaseInfra) GetPoolByTemplate(name string) (pool SandboxPool, ok bool) {
 func (i *BaseInfra) AddPool(name string, pool SandboxPool) {
 	i.Pools.Store(name, pool)
 }
+
+func (i *BaseInfra) OnSandboxReinit(handler SandboxReinitHandler) {
+	i.ReinitHandler = handler
+}
@@ -0,0 +1,196 @@
+package sandboxcr
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/sandbox-manager/consts"
+	"github.com/openkruise/agents/pkg/sandbox-manager/infra"
+	utils "github.com/openkruise/agents/pkg/utils/sandbox-manager"
+	stateutils "github.com/openkruise/agents/pkg/utils/sandboxutils"
+	"github.com/openkruise/agents/pkg/utils/templateref"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+const WaitActionColdStart WaitAction = "ColdStart"
+
+// coldStartTimeout returns how long a claim waits for a sandbox created on demand, zero means cold start is disabled.
+func (p *Pool) coldStartTimeout(opts infra.ClaimSandboxOptions) time.Duration {
+	if opts.ColdStartTimeout > 0 {
+		return opts.ColdStartTimeout
+	}
+	seconds, err := strconv.Atoi(p.Annotations[agentsv1alpha1.AnnotationColdStartTimeoutSeconds])
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// coldStartSandbox creates a sandbox claimed by owner from the template of the SandboxSet, and waits for it to be
+// ready. The modifier is applied once the sandbox is ready, since it may depend on the IP of the sandbox. It fails
+// with the no stock error if the template cannot be resolved for now.
+func (p *Pool) coldStartSandbox(ctx context.Context, lock, owner string, opts infra.ClaimSandboxOptions, timeout time.Duration) (*Sandbox, error) {
+	log := klog.FromContext(ctx).WithValues("pool", p.Namespace+"/"+p.Name)
+	sbs, err := p.client.ApiV1alpha1().SandboxSets(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := templateref.ResolveTemplate(ctx, p.templates, sbs.Namespace, &sbs.Spec.EmbeddedSandboxTemplate)
+	if err != nil || resolved == nil {
+		// the same as no cold start, the SandboxSet reports why its template is unresolved
+		log.Info("template of SandboxSet unresolved, skip cold start", "reason", err)
+		return nil, NoAvailableError(p.Name, "no stock")
+	}
+	newSbx, err := newColdStartSandbox(sbs, resolved, lock, owner, timeout)
+	if err != nil {
+		return nil, err
+	}
+	sbx := AsSandbox(newSbx, p.cache, p.client)
+	if opts.Image != "" {
+		sbx.SetImage(opts.Image)
+	}
+	if len(opts.Resources) > 0 {
+		sbx.SetResources(opts.Resources)
+	}
+	created, err := p.client.ApiV1alpha1().Sandboxes(p.Namespace).Create(ctx, sbx.Sandbox, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	log = log.WithValues("sandbox", klog.KObj(created))
+	log.Info("sandbox cold started, waiting for it to be ready", "timeout", timeout)
+	start := time.Now()
+	if err = p.cache.WaitForSandboxSatisfied(ctx, created, WaitActionColdStart, checkSandboxColdStarted, timeout); err != nil {
+		log.Error(err, "failed to wait for cold started sandbox")
+		p.deleteColdStartSandbox(ctx, created)
+		return nil, err
+	}
+	log.Info("cold started sandbox ready", "cost", time.Since(start))
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest, err := p.client.ApiV1alpha1().Sandboxes(p.Namespace).Get(ctx, created.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		sbx = AsSandbox(latest, p.cache, p.client)
+		// the shutdown time only reclaims the sandbox abandoned before ready, the caller decides the real one
+		sbx.Spec.ShutdownTime = nil
+		if opts.Modifier != nil {
+			opts.Modifier(sbx)
+		}
+		updated, err := p.client.ApiV1alpha1().Sandboxes(p.Namespace).Update(ctx, sbx.Sandbox, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		sbx.Sandbox = updated
+		return nil
+	})
+	if err != nil {
+		log.Error(err, "failed to modify cold started sandbox")
+		p.deleteColdStartSandbox(ctx, created)
+		return nil, err
+	}
+	utils.ResourceVersionExpectationExpect(sbx)
+	return sbx, nil
+}
+
+func (p *Pool) deleteColdStartSandbox(ctx context.Context, sbx *agentsv1alpha1.Sandbox) {
+	if p.Annotations[agentsv1alpha1.AnnotationReserveFailedSandbox] == agentsv1alpha1.True {
+		return
+	}
+	log := klog.FromContext(ctx).WithValues("sandbox", klog.KObj(sbx))
+	// the context of the claim may be done already
+	err := p.client.ApiV1alpha1().Sandboxes(sbx.Namespace).Delete(context.Background(), sbx.Name, metav1.DeleteOptions{})
+	if err != nil {
+		log.Error(err, "failed to delete cold started sandbox")
+	} else {
+		log.Info("cold started sandbox deleted")
+	}
+}
+
+// discardColdStartSandbox deletes the sandbox cold started in vain, since another one has been claimed.
+func (p *Pool) discardColdStartSandbox(ctx context.Context, sbx infra.Sandbox) {
+	log := klog.FromContext(ctx).WithValues("sandbox", klog.KObj(sbx))
+	// the context of the claim may be done already
+	err := p.client.ApiV1alpha1().Sandboxes(sbx.GetNamespace()).Delete(context.Background(), sbx.GetName(), metav1.DeleteOptions{})
+	if err != nil {
+		log.Error(err, "failed to delete discarded cold started sandbox")
+	} else {
+		log.Info("discarded cold started sandbox deleted")
+	}
+}
+
+// newColdStartSandbox builds a sandbox from the resolved template of sbs the same as the SandboxSet controller, except
+// that it is claimed by owner already and not controlled by sbs. The shutdown time reclaims the sandbox if the claim
+// is abandoned before it is ready.
+func newColdStartSandbox(sbs *agentsv1alpha1.SandboxSet, resolved *agentsv1alpha1.SandboxTemplateSpec, lock, owner string,
+	timeout time.Duration) (*agentsv1alpha1.Sandbox, error) {
+	template := resolved.Template.DeepCopy()
+	labels := make(map[string]string, len(template.Labels)+3)
+	for k, v := range template.Labels {
+		if !strings.HasPrefix(k, agentsv1alpha1.E2BPrefix) {
+			labels[k] = v
+		}
+	}
+	annotations := make(map[string]string, len(template.Annotations)+5)
+	for k, v := range template.Annotations {
+		if !strings.HasPrefix(k, agentsv1alpha1.E2BPrefix) {
+			annotations[k] = v
+		}
+	}
+	if err := templateref.SetE2BDefaults(annotations, sbs, resolved.E2B); err != nil {
+		return nil, err
+	}
+	labels[agentsv1alpha1.LabelSandboxPool] = sbs.Name
+	labels[agentsv1alpha1.LabelSandboxIsClaimed] = "true"
+	if sbs.Status.UpdateRevision != "" {
+		labels[agentsv1alpha1.LabelTemplateHash] = sbs.Status.UpdateRevision
+	}
+	persistentContents := sbs.Spec.PersistentContents
+	if len(persistentContents) == 0 {
+		persistentContents = resolved.PersistentContents
+	}
+	now := time.Now()
+	annotations[agentsv1alpha1.AnnotationClaimTime] = now.Format(time.RFC3339)
+	annotations[agentsv1alpha1.AnnotationColdStart] = agentsv1alpha1.True
+	sbx := &agentsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-%s", sbs.Name, utilrand.String(5)),
+			Namespace:   sbs.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: agentsv1alpha1.SandboxSpec{
+			PersistentContents: persistentContents,
+			ShutdownTime:       ptr.To(metav1.NewTime(now.Add(timeout))),
+			EmbeddedSandboxTemplate: agentsv1alpha1.EmbeddedSandboxTemplate{
+				Template:             template,
+				VolumeClaimTemplates: resolved.VolumeClaimTemplates,
+			},
+		},
+	}
+	utils.LockSandbox(sbx, lock, owner)
+	return sbx, nil
+}
+
+// checkSandboxColdStarted stops waiting early if the sandbox will never be ready.
+func checkSandboxColdStarted(sbx *agentsv1alpha1.Sandbox) (bool, error) {
+	log := klog.Background().WithValues("sandbox", klog.KObj(sbx)).V(consts.DebugLogLevel)
+	if sbx.DeletionTimestamp != nil {
+		return false, fmt.Errorf("cold started sandbox %s is deleted", sbx.Name)
+	}
+	switch sbx.Status.Phase {
+	case agentsv1alpha1.SandboxFailed, agentsv1alpha1.SandboxSucceeded, agentsv1alpha1.SandboxTerminating:
+		return false, fmt.Errorf("cold started sandbox %s is %s", sbx.Name, sbx.Status.Phase)
+	}
+	ready := sbx.Status.Phase == agentsv1alpha1.SandboxRunning && stateutils.IsSandboxReady(sbx)
+	log.Info("cold started sandbox watched", "phase", sbx.Status.Phase, "ready", ready)
+	return ready, nil
+}
@@ -2,6 +2,8 @@ package sandboxcr
 
 import (
 	"context"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/openkruise/agents/api/v1alpha1"
@@ -9,8 +11,11 @@ import (
 	informers "github.com/openkruise/agents/client/informers/externalversions"
 	"github.com/openkruise/agents/pkg/proxy"
 	"github.com/openkruise/agents/pkg/sandbox-manager/infra"
+	"github.com/openkruise/agents/pkg/sandbox-manager/logs"
 	utils "github.com/openkruise/agents/pkg/utils/sandbox-manager"
+	stateutils "github.com/openkruise/agents/pkg/utils/sandboxutils"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	k8scache "k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 )
@@ -21,9 +26,14 @@ type Infra struct {
 	Cache  *Cache
 	Client sandboxclient.Interface
 	Proxy  *proxy.Server
+
+	// templates resolves the templateRef of SandboxSets for cold start
+	templates templateReader
+	// reiniting is the set of sandboxes whose init is being replayed by the ReinitHandler
+	reiniting sync.Map
 }
 
-func NewInfra(client sandboxclient.Interface, proxy *proxy.Server) (*Infra, error) {
+func NewInfra(client sandboxclient.Interface, k8sClient kubernetes.Interface, proxy *proxy.Server) (*Infra, error) {
 	informerFactory := informers.NewSharedInformerFactory(client, time.Minute*10)
 	sandboxInformer := informerFactory.Api().V1alpha1().Sandboxes().Informer()
 	sandboxSetInformer := informerFactory.Api().V1alpha1().SandboxSets().Informer()
@@ -37,6 +47,7 @@ func NewInfra(client sandboxclient.Interface, proxy *proxy.Server) (*Infra, erro
 		Cache:     cache,
 		Client:    client,
 		Proxy:     proxy,
+		templates: templateReader{client: client, k8sClient: k8sClient},
 	}
 
 	cache.AddSandboxEventHandler(k8scache.ResourceEventHandlerFuncs{
@@ -67,6 +78,7 @@ func (i *Infra) NewPool(name, namespace string, annotations map[string]string) i
 		Annotations: annotations,
 		client:      i.Client,
 		cache:       i.Cache,
+		templates:   i.templates,
 	}
 }
 
@@ -111,13 +123,16 @@ func (i *Infra) onSandboxAdd(obj any) {
 	if !ok {
 		return
 	}
-	_, ok = i.GetPoolByObject(sbx)
+	pool, ok := i.GetPoolByObject(sbx)
 	if !ok {
 		return
 	}
-	route := AsSandbox(sbx, i.Cache, i.Client).GetRoute()
-	i.Proxy.SetRoute(route)
+	sandbox := AsSandbox(sbx, i.Cache, i.Client)
+	i.Proxy.SetRoute(sandbox.GetRoute())
 	utils.ResourceVersionExpectationObserve(sbx)
+	// e.g. the sandbox manager restarted during the replay
+	i.startReinit(sandbox)
+	notifyClaimQueue(pool, nil, sbx)
 }
 
 func (i *Infra) onSandboxDelete(obj any) {
@@ -129,23 +144,74 @@ func (i *Infra) onSandboxDelete(obj any) {
 	utils.ResourceVersionExpectationDelete(sbx)
 }
 
-func (i *Infra) onSandboxUpdate(_, newObj any) {
+func (i *Infra) onSandboxUpdate(oldObj, newObj any) {
 	newSbx, ok := newObj.(*v1alpha1.Sandbox)
 	if !ok {
 		return
 	}
-	_, ok = i.GetPoolByObject(newSbx)
+	pool, ok := i.GetPoolByObject(newSbx)
 	if !ok {
 		return
 	}
-	i.refreshRoute(AsSandbox(newSbx, i.Cache, i.Client))
+	sbx := AsSandbox(newSbx, i.Cache, i.Client)
+	i.refreshRoute(sbx)
 	utils.ResourceVersionExpectationObserve(newSbx)
+	i.startReinit(sbx)
+	oldSbx, _ := oldObj.(*v1alpha1.Sandbox)
+	notifyClaimQueue(pool, oldSbx, newSbx)
+}
+
+// notifyClaimQueue wakes up the claims waiting for the pool once the sandbox becomes claimable.
+func notifyClaimQueue(pool infra.SandboxPool, oldSbx, newSbx *v1alpha1.Sandbox) {
+	p, ok := pool.(*Pool)
+	if !ok || !isClaimable(newSbx) || (oldSbx != nil && isClaimable(oldSbx)) {
+		return
+	}
+	p.onSandboxAvailable()
+}
+
+func isClaimable(sbx *v1alpha1.Sandbox) bool {
+	state, _ := stateutils.GetSandboxState(sbx)
+	return state == v1alpha1.SandboxStateAvailable && sbx.Annotations[v1alpha1.AnnotationLock] == ""
+}
+
+// startReinit replays the claim-time init of the sandbox by the ReinitHandler in background if its recreated pod is
+// ready, once at a time for each pod. The failed ones are retried on the next update or resync of the sandbox.
+func (i *Infra) startReinit(sbx *Sandbox) {
+	if i.ReinitHandler == nil || !shouldReinit(sbx.Sandbox) {
+		return
+	}
+	podUID := string(sbx.Status.PodInfo.PodUID)
+	if _, loaded := i.reiniting.LoadOrStore(podUID, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer i.reiniting.Delete(podUID)
+		ctx := logs.NewContext("sandboxID", sbx.GetSandboxID())
+		log := klog.FromContext(ctx)
+		log.Info("replaying the init of the sandbox in the recreated pod", "ip", sbx.Status.PodInfo.PodIP,
+			"restartCount", sbx.Status.RestartCount)
+		if err := i.ReinitHandler(ctx, sbx); err != nil {
+			log.Error(err, "failed to re-init sandbox, will retry on the next update")
+		}
+	}()
+}
+
+// shouldReinit returns whether the recreated pod of the claimed sandbox is ready but not inited yet, except the ones
+// bound by SandboxClaims, which are re-inited by the SandboxClaim controller.
+func shouldReinit(sbx *v1alpha1.Sandbox) bool {
+	if sbx.Labels[v1alpha1.LabelSandboxClaim] != "" {
+		return false
+	}
+	state, _ := stateutils.GetSandboxState(sbx)
+	return state == v1alpha1.SandboxStateCreating && stateutils.IsSandboxReady(sbx) && stateutils.IsSandboxReiniting(sbx)
 }
 
 func (i *Infra) refreshRoute(sbx infra.Sandbox) {
 	oldRoute, _ := i.Proxy.LoadRoute(sbx.GetName())
 	newRoute := sbx.GetRoute()
-	if newRoute.State != oldRoute.State || newRoute.IP != oldRoute.IP {
+	if newRoute.State != oldRoute.State || newRoute.IP != oldRoute.IP || newRoute.AccessTokenHash != oldRoute.AccessTokenHash ||
+		newRoute.Dynamic != oldRoute.Dynamic || !slices.Equal(newRoute.ExposedPorts, oldRoute.ExposedPorts) {
 		i.Proxy.SetRoute(newRoute)
 	}
 }
@@ -2,6 +2,8 @@ package sandboxcr
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	stateutils "github.com/openkruise/agents/pkg/utils/sandboxutils"
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 )
 
 func createTestSandbox(name, user string, phase v1alpha1.SandboxPhase, ready bool) *v1alpha1.Sandbox {
@@ -44,7 +47,7 @@ func createTestSandbox(name, user string, phase v1alpha1.SandboxPhase, ready boo
 //goland:noinspection GoDeprecation
 func NewTestInfra(t *testing.T) (*Infra, *fake.Clientset) {
 	client := fake.NewSimpleClientset()
-	infraInstance, err := NewInfra(client, proxy.NewServer(nil))
+	infraInstance, err := NewInfra(client, k8sfake.NewSimpleClientset(), proxy.NewServer(nil))
 	assert.NoError(t, err)
 	assert.NoError(t, infraInstance.Run(context.Background()))
 	return infraInstance, client
@@ -472,3 +475,128 @@ func TestInfra_onSandboxUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestInfra_onSandboxReinit(t *testing.T) {
+	restarting := func() *v1alpha1.Sandbox {
+		sbx := createTestSandboxWithDefaults("test-sandbox", "default")
+		sbx.Labels = map[string]string{v1alpha1.LabelSandboxPool: "test-pool"}
+		sbx.Annotations = map[string]string{v1alpha1.AnnotationInitedPod: "old-pod"}
+		sbx.Status.Conditions[0].Status = metav1.ConditionFalse
+		sbx.Status.Conditions[0].Reason = v1alpha1.SandboxReadyReasonRestarting
+		return sbx
+	}
+	recreated := func() *v1alpha1.Sandbox {
+		sbx := createTestSandboxWithDefaults("test-sandbox", "default")
+		sbx.Labels = map[string]string{v1alpha1.LabelSandboxPool: "test-pool"}
+		sbx.Annotations = map[string]string{v1alpha1.AnnotationInitedPod: "old-pod"}
+		sbx.Status.PodInfo.PodIP = "10.0.0.2"
+		sbx.Status.PodInfo.PodUID = "new-pod"
+		sbx.Status.RestartCount = 1
+		return sbx
+	}
+	reinited := func() *v1alpha1.Sandbox {
+		sbx := recreated()
+		sbx.Annotations[v1alpha1.AnnotationInitedPod] = "new-pod"
+		return sbx
+	}
+	boundByClaim := func() *v1alpha1.Sandbox {
+		sbx := recreated()
+		sbx.Labels[v1alpha1.LabelSandboxClaim] = "test-claim"
+		return sbx
+	}
+	tests := []struct {
+		name          string
+		oldSandbox    *v1alpha1.Sandbox
+		newSandbox    *v1alpha1.Sandbox
+		expectHandled bool
+		expectState   string
+	}{
+		{
+			name:          "recreated pod ready",
+			oldSandbox:    restarting(),
+			newSandbox:    recreated(),
+			expectHandled: true,
+			expectState:   v1alpha1.SandboxStateCreating,
+		},
+		{
+			name:          "resumed by kubectl",
+			oldSandbox:    recreated(),
+			newSandbox:    recreated(),
+			expectHandled: true,
+			expectState:   v1alpha1.SandboxStateCreating,
+		},
+		{
+			name:        "still restarting",
+			oldSandbox:  restarting(),
+			newSandbox:  restarting(),
+			expectState: v1alpha1.SandboxStateCreating,
+		},
+		{
+			name:        "reinited sandbox updated",
+			oldSandbox:  recreated(),
+			newSandbox:  reinited(),
+			expectState: v1alpha1.SandboxStateRunning,
+		},
+		{
+			name:        "bound by sandbox claim",
+			oldSandbox:  restarting(),
+			newSandbox:  boundByClaim(),
+			expectState: v1alpha1.SandboxStateCreating,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			infraInstance, _ := NewTestInfra(t)
+			infraInstance.AddPool("test-pool", infraInstance.NewPool("test-pool", "default", nil))
+			handled := make(chan infra.Sandbox, 1)
+			infraInstance.OnSandboxReinit(func(ctx context.Context, sbx infra.Sandbox) error {
+				handled <- sbx
+				return nil
+			})
+
+			infraInstance.onSandboxUpdate(tt.oldSandbox, tt.newSandbox)
+			select {
+			case sbx := <-handled:
+				assert.True(t, tt.expectHandled)
+				assert.Equal(t, "10.0.0.2", sbx.GetRoute().IP)
+				assert.Equal(t, "new-pod", sbx.GetPodUID())
+			case <-time.After(100 * time.Millisecond):
+				assert.False(t, tt.expectHandled)
+			}
+			route, ok := infraInstance.Proxy.LoadRoute(stateutils.GetSandboxID(tt.newSandbox))
+			assert.True(t, ok)
+			assert.Equal(t, tt.newSandbox.Status.PodInfo.PodIP, route.IP)
+			// the route is not running until the init is replayed
+			assert.Equal(t, tt.expectState, route.State)
+		})
+	}
+}
+
+func TestInfra_startReinit_Deduplicated(t *testing.T) {
+	infraInstance, _ := NewTestInfra(t)
+	infraInstance.AddPool("test-pool", infraInstance.NewPool("test-pool", "default", nil))
+	sbx := createTestSandboxWithDefaults("test-sandbox", "default")
+	sbx.Labels = map[string]string{v1alpha1.LabelSandboxPool: "test-pool"}
+	sbx.Annotations = map[string]string{v1alpha1.AnnotationInitedPod: "old-pod"}
+	sbx.Status.PodInfo.PodUID = "new-pod"
+	release := make(chan struct{})
+	var calls atomic.Int32
+	infraInstance.OnSandboxReinit(func(ctx context.Context, sbx infra.Sandbox) error {
+		calls.Add(1)
+		<-release
+		return errors.New("envd not ready")
+	})
+
+	infraInstance.onSandboxUpdate(sbx, sbx)
+	infraInstance.onSandboxUpdate(sbx, sbx)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load())
+	close(release)
+
+	// the failed one is retried on the next update
+	assert.Eventually(t, func() bool {
+		infraInstance.onSandboxUpdate(sbx, sbx)
+		return calls.Load() > 1
+	}, time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,39 @@
+package sandboxcr
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// ClaimQueueLength tracks the claims waiting for available sandboxes of each pool
+	ClaimQueueLength = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sandbox_claim_queue_length",
+			Help: "Number of claims waiting for available sandboxes of the pool",
+		},
+		[]string{"pool"},
+	)
+
+	// ClaimQueueWaitTime tracks the time claims spend in the queue
+	ClaimQueueWaitTime = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "sandbox_claim_queue_wait_ms",
+			Help:    "Time claims spend waiting for available sandboxes in milliseconds",
+			Buckets: prometheus.ExponentialBuckets(10, 2, 14), // 10ms to ~80s
+		},
+	)
+
+	// ClaimQueueTimeouts tracks the claims giving up waiting for available sandboxes
+	ClaimQueueTimeouts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sandbox_claim_queue_timeouts_total",
+			Help: "Total number of claims timed out waiting for available sandboxes of the pool",
+		},
+		[]string{"pool"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(ClaimQueueLength, ClaimQueueWaitTime, ClaimQueueTimeouts)
+}
@@ -20,6 +20,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type Pool struct {
@@ -28,8 +29,10 @@ type Pool struct {
 	Annotations map[string]string
 
 	// Should init fields
-	client sandboxclient.Interface
-	cache  *Cache
+	client    sandboxclient.Interface
+	cache     *Cache
+	queue     claimQueue
+	templates client.Reader
 }
 
 type retriableError struct {
@@ -60,15 +63,105 @@ const (
 	InplaceUpdateTimeout = time.Minute
 )
 
-// ClaimSandbox claims a Sandbox CR as Sandbox from SandboxSet
+// ClaimSandbox claims a Sandbox CR as Sandbox from SandboxSet. The claim waits in the queue of the pool for
+// opts.MaxWait at most if no sandbox is available, or the earlier claims are still waiting. The claims not willing to
+// wait take the stock directly. If cold start is enabled, a sandbox is created on demand in the meantime.
 func (p *Pool) ClaimSandbox(ctx context.Context, user string, candidateCounts int, opts infra.ClaimSandboxOptions) (infra.Sandbox, error) {
 	lock := uuid.New().String()
 	log := klog.FromContext(ctx).WithValues("pool", p.Namespace+"/"+p.Name)
+
+	var claimedSandbox infra.Sandbox
+	var err error
+	if opts.MaxWait <= 0 || p.queue.len() == 0 {
+		claimedSandbox, err = p.claim(ctx, lock, user, candidateCounts, opts, nil)
+	} else {
+		// the waiting claims are served first
+		err = NoAvailableError(p.Name, "claims waiting")
+	}
+	if !isNoAvailableError(p.Name, err) {
+		return claimedSandbox, err
+	}
+	timeout := p.coldStartTimeout(opts)
+	switch {
+	case opts.MaxWait > 0 && timeout > 0:
+		log.Info("no stock, wait in claim queue and try to cold start sandbox")
+		return p.claimInQueueOrColdStart(ctx, lock, user, candidateCounts, opts, timeout, err)
+	case opts.MaxWait > 0:
+		return p.claimInQueue(ctx, lock, user, candidateCounts, opts, err)
+	case timeout > 0:
+		log.Info("no stock, try to cold start sandbox")
+		sbx, err := p.coldStartSandbox(ctx, lock, user, opts, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return sbx, nil
+	}
+	return nil, err
+}
+
+// claimInQueueOrColdStart waits in the claim queue and cold starts a sandbox at the same time, whichever is claimed
+// first is returned and the other is cancelled. The cold started sandbox is deleted if both are claimed.
+func (p *Pool) claimInQueueOrColdStart(ctx context.Context, lock, user string, candidateCounts int,
+	opts infra.ClaimSandboxOptions, timeout time.Duration, lastErr error) (infra.Sandbox, error) {
+	type result struct {
+		sbx infra.Sandbox
+		err error
+	}
+	queueCtx, cancelQueue := context.WithCancel(ctx)
+	defer cancelQueue()
+	coldStartCtx, cancelColdStart := context.WithCancel(ctx)
+	defer cancelColdStart()
+	queued, coldStarted := make(chan result, 1), make(chan result, 1)
+	go func() {
+		sbx, err := p.claimInQueue(queueCtx, lock, user, candidateCounts, opts, lastErr)
+		queued <- result{sbx: sbx, err: err}
+	}()
+	go func() {
+		sbx, err := p.coldStartSandbox(coldStartCtx, lock, user, opts, timeout)
+		if err != nil {
+			coldStarted <- result{err: err}
+			return
+		}
+		coldStarted <- result{sbx: sbx}
+	}()
+
+	select {
+	case r := <-queued:
+		if r.err == nil {
+			cancelColdStart()
+			go func() {
+				if c := <-coldStarted; c.err == nil {
+					p.discardColdStartSandbox(ctx, c.sbx)
+				}
+			}()
+			return r.sbx, nil
+		}
+		// the cold start is the last resort
+		c := <-coldStarted
+		return c.sbx, c.err
+	case c := <-coldStarted:
+		cancelQueue()
+		// a sandbox may be claimed from the queue while cancelling, which is kept rather than released
+		r := <-queued
+		if r.err == nil {
+			if c.err == nil {
+				p.discardColdStartSandbox(ctx, c.sbx)
+			}
+			return r.sbx, nil
+		}
+		return c.sbx, c.err
+	}
+}
+
+// claim picks and locks an available sandbox, retrying on conflicts. locked is called once the sandbox is locked
+// if not nil.
+func (p *Pool) claim(ctx context.Context, lock, user string, candidateCounts int, opts infra.ClaimSandboxOptions, locked func()) (infra.Sandbox, error) {
+	log := klog.FromContext(ctx).WithValues("pool", p.Namespace+"/"+p.Name)
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	retries := -1
 	var claimedSandbox infra.Sandbox
-	return claimedSandbox, retry.OnError(wait.Backoff{
+	err := retry.OnError(wait.Backoff{
 		Steps:    LockMaxRetries,
 		Duration: 0,
 		Factor:   LockBackoffFactor,
@@ -100,10 +193,13 @@ func (p *Pool) ClaimSandbox(ctx context.Context, user string, candidateCounts in
 		}
 		utils.ResourceVersionExpectationExpect(sbx)
 		claimLog.Info("sandbox locked")
+		if locked != nil {
+			locked()
+		}
 
-		if opts.Image != "" {
+		if opts.Image != "" || len(opts.Resources) > 0 {
 			updateStart := time.Now()
-			claimLog.Info("waiting for inplace update", "oldImage", sbx.GetImage(), "newImage", opts.Image)
+			claimLog.Info("waiting for inplace update", "newImage", opts.Image, "newResources", opts.Resources)
 			if err = p.waitForInplaceUpdate(ctx, sbx, InplaceUpdateTimeout); err != nil {
 				claimLog.Error(err, "failed to wait for inplace update")
 				return err
@@ -113,6 +209,74 @@ func (p *Pool) ClaimSandbox(ctx context.Context, user string, candidateCounts in
 		claimedSandbox = sbx
 		return nil
 	})
+	return claimedSandbox, err
+}
+
+// claimInQueue waits in the queue of the pool for opts.MaxWait at most, and claims once it is the turn. The turn is
+// passed to the next waiter once a sandbox is locked, or back to the queue if still no sandbox is available. lastErr
+// is returned wrapped if no sandbox is claimed in time.
+func (p *Pool) claimInQueue(ctx context.Context, lock, user string, candidateCounts int, opts infra.ClaimSandboxOptions, lastErr error) (infra.Sandbox, error) {
+	log := klog.FromContext(ctx).WithValues("pool", p.Namespace+"/"+p.Name)
+	start := time.Now()
+	timer := time.NewTimer(opts.MaxWait)
+	defer timer.Stop()
+	w := p.queue.enqueue(user, opts.Weight)
+	ClaimQueueLength.WithLabelValues(p.Name).Set(float64(p.queue.len()))
+	log.Info("waiting in claim queue", "maxWait", opts.MaxWait, "weight", opts.Weight)
+	defer func() {
+		ClaimQueueLength.WithLabelValues(p.Name).Set(float64(p.queue.len()))
+		ClaimQueueWaitTime.Observe(float64(time.Since(start).Milliseconds()))
+	}()
+	for {
+		select {
+		case <-w.turn:
+			passed := false
+			passTurn := func() {
+				if !passed {
+					passed = true
+					p.queue.notify()
+				}
+			}
+			sbx, err := p.claim(ctx, lock, user, candidateCounts, opts, passTurn)
+			if err == nil || !isNoAvailableError(p.Name, err) {
+				passTurn()
+				log.Info("claim queue left", "cost", time.Since(start), "success", err == nil)
+				return sbx, err
+			}
+			lastErr = err
+			p.queue.requeue(w)
+		case <-timer.C:
+			p.leaveQueue(w)
+			ClaimQueueTimeouts.WithLabelValues(p.Name).Inc()
+			log.Info("timeout waiting in claim queue", "maxWait", opts.MaxWait)
+			return nil, fmt.Errorf("timeout waiting %s in claim queue: %w", opts.MaxWait, lastErr)
+		case <-ctx.Done():
+			p.leaveQueue(w)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// leaveQueue removes a waiter giving up, and passes its turn to the next one if the turn has come.
+func (p *Pool) leaveQueue(w *claimWaiter) {
+	if !p.queue.remove(w) {
+		p.queue.notify()
+	}
+}
+
+// onSandboxAvailable gives the turn to the first claim waiting for the pool.
+func (p *Pool) onSandboxAvailable() {
+	p.queue.notify()
+}
+
+// isNoAvailableError returns whether err means no sandbox of the pool can be claimed for now.
+func isNoAvailableError(pool string, err error) bool {
+	for _, reason := range []string{"no stock", "no candidate", "claims waiting"} {
+		if errors.Is(err, NoAvailableError(pool, reason)) {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *Pool) pickAnAvailableSandbox(ctx context.Context, cnt int, r *rand.Rand) (*Sandbox, error) {
@@ -156,6 +320,10 @@ func (p *Pool) modifyPickedSandbox(sbx *Sandbox, opts infra.ClaimSandboxOptions)
 		// should perform an inplace update
 		sbx.SetImage(opts.Image)
 	}
+	if len(opts.Resources) > 0 {
+		// should perform an inplace resize
+		sbx.SetResources(opts.Resources)
+	}
 	// claim sandbox
 	sbx.SetOwnerReferences([]metav1.OwnerReference{}) // make SandboxSet scale up
 	labels := sbx.GetLabels()
@@ -193,25 +361,37 @@ func (p *Pool) checkSandboxInplaceUpdate(ctx context.Context, sbx *v1alpha1.Sand
 	}
 	cond := GetSandboxCondition(sbx, v1alpha1.SandboxConditionReady)
 	if cond.Reason == v1alpha1.SandboxReadyReasonStartContainerFailed {
-		err := retriableError{Message: fmt.Sprintf("sandbox inplace update failed: %s", cond.Message)}
-		log.Error(err, "sandbox inplace update failed")
-		if p.Annotations[v1alpha1.AnnotationReserveFailedSandbox] != v1alpha1.True {
-			go func() {
-				err := p.client.ApiV1alpha1().Sandboxes(sbx.Namespace).Delete(context.Background(), sbx.Name, metav1.DeleteOptions{})
-				if err != nil {
-					log.Error(err, "failed to delete failed sandbox")
-				} else {
-					log.Info("sandbox deleted")
-				}
-			}()
-		}
-		return false, err // stop early
+		return false, p.failInplaceUpdate(ctx, sbx, cond.Message) // stop early
+	}
+	// the sandbox keeps running with the resources before if the resize is infeasible, which is not what is claimed
+	if cond := GetSandboxCondition(sbx, v1alpha1.SandboxConditionInplaceUpdate); cond.Reason == v1alpha1.SandboxInplaceUpdateReasonFailed &&
+		cond.ObservedGeneration == sbx.Generation {
+		return false, p.failInplaceUpdate(ctx, sbx, cond.Message)
 	}
 	state, reason := stateutils.GetSandboxState(sbx)
 	log.Info("sandbox update watched", "state", state, "reason", reason)
 	return state == v1alpha1.SandboxStateRunning, nil
 }
 
+// failInplaceUpdate deletes the sandbox failed to be updated in place for the claim, unless the failed sandboxes are
+// reserved by the pool.
+func (p *Pool) failInplaceUpdate(ctx context.Context, sbx *v1alpha1.Sandbox, message string) error {
+	log := klog.FromContext(ctx).WithValues("sandbox", klog.KObj(sbx)).V(consts.DebugLogLevel)
+	err := retriableError{Message: fmt.Sprintf("sandbox inplace update failed: %s", message)}
+	log.Error(err, "sandbox inplace update failed")
+	if p.Annotations[v1alpha1.AnnotationReserveFailedSandbox] != v1alpha1.True {
+		go func() {
+			err := p.client.ApiV1alpha1().Sandboxes(sbx.Namespace).Delete(context.Background(), sbx.Name, metav1.DeleteOptions{})
+			if err != nil {
+				log.Error(err, "failed to delete failed sandbox")
+			} else {
+				log.Info("sandbox deleted")
+			}
+		}()
+	}
+	return err
+}
+
 func (p *Pool) GetAnnotations() map[string]string {
 	return p.Annotations
 }
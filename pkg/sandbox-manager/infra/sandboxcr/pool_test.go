@@ -3,10 +3,13 @@ package sandboxcr
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/openkruise/agents/api/v1alpha1"
 	"github.com/openkruise/agents/client/clientset/versioned"
 	"github.com/openkruise/agents/client/clientset/versioned/fake"
@@ -14,10 +17,20 @@ import (
 	"github.com/openkruise/agents/pkg/sandbox-manager/infra"
 	utils "github.com/openkruise/agents/pkg/utils/sandbox-manager"
 	"github.com/openkruise/agents/pkg/utils/sandboxutils"
+	"github.com/openkruise/agents/pkg/utils/templateref"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	k8scache "k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
 )
 
 func GetSbsOwnerReference() []metav1.OwnerReference {
@@ -56,6 +69,7 @@ func NewTestPool(t *testing.T) (*Pool, versioned.Interface) {
 		Namespace: "default",
 		client:    client,
 		cache:     c,
+		templates: templateReader{client: client, k8sClient: k8sfake.NewSimpleClientset()},
 	}, client
 }
 
@@ -111,6 +125,16 @@ func TestPool_ClaimSandbox(t *testing.T) {
 				assert.Equal(t, "new-image", sbx.(*Sandbox).Spec.Template.Spec.Containers[0].Image)
 			},
 		},
+		{
+			name:      "claim with resources",
+			available: 1,
+			options: infra.ClaimSandboxOptions{
+				Resources: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			},
+			postCheck: func(t *testing.T, sbx infra.Sandbox) {
+				assert.Equal(t, int64(2000), sbx.GetResource().CPUMilli)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -129,7 +153,7 @@ func TestPool_ClaimSandbox(t *testing.T) {
 						OwnerReferences: GetSbsOwnerReference(),
 					},
 					Spec: v1alpha1.SandboxSpec{
-						SandboxTemplate: v1alpha1.SandboxTemplate{
+						EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 							Template: &corev1.PodTemplateSpec{
 								Spec: corev1.PodSpec{
 									Containers: []corev1.Container{
@@ -193,6 +217,8 @@ func TestPool_checkSandboxInplaceUpdate(t *testing.T) {
 		condStatus             metav1.ConditionStatus
 		condReason             string
 		condMessage            string
+		// updateFailedGeneration is the generation of the failed InplaceUpdate condition if not zero
+		updateFailedGeneration int64
 		expectResult           bool
 		expectError            error
 		expectDeleted          bool
@@ -244,6 +270,26 @@ func TestPool_checkSandboxInplaceUpdate(t *testing.T) {
 			expectError:            retriableError{Message: "sandbox inplace update failed: by test"},
 			expectDeleted:          false,
 		},
+		{
+			name:                   "not satisfied: resize infeasible, deleted",
+			generation:             2,
+			observedGeneration:     2,
+			condStatus:             metav1.ConditionTrue,
+			condReason:             v1alpha1.SandboxReadyReasonPodReady,
+			updateFailedGeneration: 2,
+			expectResult:           false,
+			expectError:            retriableError{Message: "sandbox inplace update failed: infeasible by test"},
+			expectDeleted:          true,
+		},
+		{
+			name:                   "success: resize of the last generation infeasible",
+			generation:             2,
+			observedGeneration:     2,
+			condStatus:             metav1.ConditionTrue,
+			condReason:             v1alpha1.SandboxReadyReasonPodReady,
+			updateFailedGeneration: 1,
+			expectResult:           true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -278,6 +324,15 @@ func TestPool_checkSandboxInplaceUpdate(t *testing.T) {
 					ObservedGeneration: tt.observedGeneration,
 				},
 			}
+			if tt.updateFailedGeneration != 0 {
+				sbx.Status.Conditions = append(sbx.Status.Conditions, metav1.Condition{
+					Type:               string(v1alpha1.SandboxConditionInplaceUpdate),
+					Status:             metav1.ConditionFalse,
+					Reason:             v1alpha1.SandboxInplaceUpdateReasonFailed,
+					Message:            "infeasible by test",
+					ObservedGeneration: tt.updateFailedGeneration,
+				})
+			}
 			CreateSandboxWithStatus(t, client, sbx)
 			time.Sleep(10 * time.Millisecond)
 
@@ -304,3 +359,353 @@ func TestPool_checkSandboxInplaceUpdate(t *testing.T) {
 		})
 	}
 }
+
+//goland:noinspection GoDeprecation
+func TestPool_ClaimSandbox_ColdStart(t *testing.T) {
+	inlineTemplate := func(sbs *v1alpha1.SandboxSet) {
+		sbs.Spec.Template = &corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"app": "test"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "main", Image: "old-image"}},
+			},
+		}
+	}
+	tests := []struct {
+		name          string
+		annotations   map[string]string
+		options       infra.ClaimSandboxOptions
+		sandboxSet    func(sbs *v1alpha1.SandboxSet)
+		templates     func(t *testing.T, reader templateReader)
+		neverReady    bool
+		expectError   string
+		expectDeleted bool
+		postCheck     func(t *testing.T, sbx infra.Sandbox)
+	}{
+		{
+			name:        "cold start disabled",
+			sandboxSet:  inlineTemplate,
+			expectError: "no stock",
+		},
+		{
+			name:        "cold start with pool timeout",
+			annotations: map[string]string{v1alpha1.AnnotationColdStartTimeoutSeconds: "10"},
+			sandboxSet:  inlineTemplate,
+			postCheck: func(t *testing.T, sbx infra.Sandbox) {
+				assert.Equal(t, "test", sbx.GetLabels()["app"])
+				assert.Nil(t, sbx.(*Sandbox).Spec.ShutdownTime)
+			},
+		},
+		{
+			name:       "cold start with request timeout and options",
+			sandboxSet: inlineTemplate,
+			options: infra.ClaimSandboxOptions{
+				ColdStartTimeout: 10 * time.Second,
+				Image:            "new-image",
+				Modifier: func(sbx infra.Sandbox) {
+					annotations := sbx.GetAnnotations()
+					annotations["ip"] = sbx.GetRoute().IP
+					sbx.SetAnnotations(annotations)
+					sbx.SetTimeout(time.Minute)
+				},
+			},
+			postCheck: func(t *testing.T, sbx infra.Sandbox) {
+				assert.Equal(t, "new-image", sbx.(*Sandbox).Spec.Template.Spec.Containers[0].Image)
+				assert.Equal(t, "1.2.3.4", sbx.GetAnnotations()["ip"])
+				assert.NotNil(t, sbx.(*Sandbox).Spec.ShutdownTime)
+			},
+		},
+		{
+			name:        "cold start with unresolved templateRef",
+			annotations: map[string]string{v1alpha1.AnnotationColdStartTimeoutSeconds: "10"},
+			sandboxSet: func(sbs *v1alpha1.SandboxSet) {
+				sbs.Spec.TemplateRef = &v1alpha1.SandboxTemplateRef{Name: "test-template"}
+			},
+			expectError: "no stock",
+		},
+		{
+			name:        "cold start with PodTemplate",
+			annotations: map[string]string{v1alpha1.AnnotationColdStartTimeoutSeconds: "10"},
+			sandboxSet: func(sbs *v1alpha1.SandboxSet) {
+				sbs.Spec.TemplateRef = &v1alpha1.SandboxTemplateRef{Name: "test-template"}
+			},
+			templates: func(t *testing.T, reader templateReader) {
+				_, err := reader.k8sClient.CoreV1().PodTemplates("default").Create(t.Context(), &corev1.PodTemplate{
+					ObjectMeta: metav1.ObjectMeta{Name: "test-template", Namespace: "default"},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "pod-template"}},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "image"}}},
+					},
+				}, metav1.CreateOptions{})
+				assert.NoError(t, err)
+			},
+			postCheck: func(t *testing.T, sbx infra.Sandbox) {
+				assert.Equal(t, "pod-template", sbx.GetLabels()["app"])
+			},
+		},
+		{
+			name:        "cold start with E2B defaults of SandboxTemplate",
+			annotations: map[string]string{v1alpha1.AnnotationColdStartTimeoutSeconds: "10"},
+			sandboxSet: func(sbs *v1alpha1.SandboxSet) {
+				sbs.Annotations = map[string]string{v1alpha1.AnnotationExposedPorts: `[{"port":3000}]`}
+				sbs.Spec.TemplateRef = &v1alpha1.SandboxTemplateRef{
+					APIVersion: ptr.To(v1alpha1.GroupVersion.String()),
+					Kind:       ptr.To("SandboxTemplate"),
+					Name:       "test-template",
+					Revision:   "test-template-1",
+				}
+			},
+			templates: func(t *testing.T, reader templateReader) {
+				data, err := templateref.EncodeRevision(&v1alpha1.SandboxTemplateSpec{
+					Template: &corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{v1alpha1.AnnotationShouldInitEnvd: "false", "app": "sandbox-template"},
+						},
+						Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "image"}}},
+					},
+					E2B: &v1alpha1.SandboxTemplateE2B{InitEnvd: true},
+				})
+				assert.NoError(t, err)
+				_, err = reader.k8sClient.AppsV1().ControllerRevisions("default").Create(t.Context(), &apps.ControllerRevision{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "test-template-1",
+						Namespace: "default",
+						Labels:    map[string]string{v1alpha1.LabelSandboxTemplate: "test-template"},
+					},
+					Data: data,
+				}, metav1.CreateOptions{})
+				assert.NoError(t, err)
+			},
+			postCheck: func(t *testing.T, sbx infra.Sandbox) {
+				annotations := sbx.GetAnnotations()
+				assert.Equal(t, "sandbox-template", annotations["app"])
+				assert.Equal(t, v1alpha1.True, annotations[v1alpha1.AnnotationShouldInitEnvd])
+				assert.JSONEq(t, `[{"port":3000},{"name":"envd","port":49983}]`, annotations[v1alpha1.AnnotationExposedPorts])
+			},
+		},
+		{
+			name:          "cold start timeout",
+			sandboxSet:    inlineTemplate,
+			options:       infra.ClaimSandboxOptions{ColdStartTimeout: 100 * time.Millisecond},
+			neverReady:    true,
+			expectError:   "double check failed",
+			expectDeleted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, client := NewTestPool(t)
+			pool.Annotations = tt.annotations
+			sbs := &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      pool.Name,
+					Namespace: pool.Namespace,
+				},
+			}
+			tt.sandboxSet(sbs)
+			_, err := client.ApiV1alpha1().SandboxSets(pool.Namespace).Create(t.Context(), sbs, metav1.CreateOptions{})
+			assert.NoError(t, err)
+			if tt.templates != nil {
+				tt.templates(t, pool.templates.(templateReader))
+			}
+
+			if !tt.neverReady {
+				// plays the sandbox controller
+				go func() {
+					for t.Context().Err() == nil {
+						list, err := client.ApiV1alpha1().Sandboxes(pool.Namespace).List(t.Context(), metav1.ListOptions{})
+						if err == nil && len(list.Items) > 0 {
+							sbx := list.Items[0].DeepCopy()
+							sbx.Status = v1alpha1.SandboxStatus{
+								Phase: v1alpha1.SandboxRunning,
+								Conditions: []metav1.Condition{{
+									Type:   string(v1alpha1.SandboxConditionReady),
+									Status: metav1.ConditionTrue,
+								}},
+								PodInfo: v1alpha1.PodInfo{PodIP: "1.2.3.4"},
+							}
+							_, _ = client.ApiV1alpha1().Sandboxes(pool.Namespace).UpdateStatus(t.Context(), sbx, metav1.UpdateOptions{})
+							return
+						}
+						time.Sleep(10 * time.Millisecond)
+					}
+				}()
+			}
+
+			user := "test-user"
+			sbx, err := pool.ClaimSandbox(t.Context(), user, 1, tt.options)
+			if tt.expectError != "" {
+				assert.Error(t, err)
+				assert.Nil(t, sbx)
+				assert.Contains(t, err.Error(), tt.expectError)
+				list, err := client.ApiV1alpha1().Sandboxes(pool.Namespace).List(t.Context(), metav1.ListOptions{})
+				assert.NoError(t, err)
+				if tt.expectDeleted {
+					assert.Empty(t, list.Items)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, sbx)
+			annotations := sbx.GetAnnotations()
+			assert.Equal(t, v1alpha1.True, annotations[v1alpha1.AnnotationColdStart])
+			assert.NotEmpty(t, annotations[v1alpha1.AnnotationLock])
+			assert.NotEmpty(t, annotations[v1alpha1.AnnotationClaimTime])
+			assert.Equal(t, user, annotations[v1alpha1.AnnotationOwner])
+			assert.Equal(t, "true", sbx.GetLabels()[v1alpha1.LabelSandboxIsClaimed])
+			assert.Equal(t, pool.Name, sbx.GetLabels()[v1alpha1.LabelSandboxPool])
+			assert.Empty(t, sbx.GetOwnerReferences())
+			state, reason := sbx.GetState()
+			assert.Equal(t, v1alpha1.SandboxStateRunning, state, "reason", reason)
+			if tt.postCheck != nil {
+				tt.postCheck(t, sbx)
+			}
+		})
+	}
+}
+
+func newAvailableSandbox(name, pool string) *v1alpha1.Sandbox {
+	return &v1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			UID:             types.UID(uuid.NewString()),
+			Labels:          map[string]string{v1alpha1.LabelSandboxPool: pool},
+			Annotations:     map[string]string{},
+			OwnerReferences: GetSbsOwnerReference(),
+		},
+		Spec: v1alpha1.SandboxSpec{
+			EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+				Template: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "main", Image: "old-image"}},
+					},
+				},
+			},
+		},
+		Status: v1alpha1.SandboxStatus{
+			Phase: v1alpha1.SandboxRunning,
+			Conditions: []metav1.Condition{{
+				Type:   string(v1alpha1.SandboxConditionReady),
+				Status: metav1.ConditionTrue,
+			}},
+			PodInfo: v1alpha1.PodInfo{PodIP: "1.2.3.4"},
+		},
+	}
+}
+
+//goland:noinspection GoDeprecation
+func TestPool_ClaimSandbox_Queue(t *testing.T) {
+	pool, client := NewTestPool(t)
+	// bumps the resource versions like the API server, so that a claimed sandbox is never picked from the stale cache
+	var resourceVersion atomic.Int64
+	client.(*fake.Clientset).PrependReactor("*", "sandboxes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if a, ok := action.(k8stesting.CreateAction); ok {
+			a.GetObject().(metav1.Object).SetResourceVersion(strconv.FormatInt(resourceVersion.Add(1), 10))
+		}
+		return false, nil, nil
+	})
+	pool.cache.AddSandboxEventHandler(k8scache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			notifyClaimQueue(pool, nil, obj.(*v1alpha1.Sandbox))
+		},
+	})
+
+	type result struct {
+		user string
+		sbx  infra.Sandbox
+		err  error
+	}
+	results := make(chan result, 3)
+	claim := func(user string, maxWait time.Duration) {
+		sbx, err := pool.ClaimSandbox(t.Context(), user, 1, infra.ClaimSandboxOptions{MaxWait: maxWait})
+		results <- result{user: user, sbx: sbx, err: err}
+	}
+	// the waiters are served in the order they arrive
+	go claim("first", 10*time.Second)
+	assert.Eventually(t, func() bool { return pool.queue.len() == 1 }, time.Second, 10*time.Millisecond)
+	go claim("second", 10*time.Second)
+	assert.Eventually(t, func() bool { return pool.queue.len() == 2 }, time.Second, 10*time.Millisecond)
+
+	// a claim not willing to wait is not rejected by the waiting ones, but finds no stock
+	_, err := pool.ClaimSandbox(t.Context(), "impatient", 1, infra.ClaimSandboxOptions{})
+	assert.ErrorContains(t, err, "no stock")
+	assert.Equal(t, 2, pool.queue.len())
+
+	CreateSandboxWithStatus(t, client, newAvailableSandbox("sbx-0", pool.Name))
+	got := <-results
+	assert.NoError(t, got.err)
+	assert.Equal(t, "first", got.user)
+	assert.Equal(t, "first", got.sbx.GetAnnotations()[v1alpha1.AnnotationOwner])
+	// the turn is passed to the second one, which finds no stock and goes back to the queue
+	assert.Eventually(t, func() bool { return pool.queue.len() == 1 }, time.Second, 10*time.Millisecond)
+
+	CreateSandboxWithStatus(t, client, newAvailableSandbox("sbx-1", pool.Name))
+	got = <-results
+	assert.NoError(t, got.err)
+	assert.Equal(t, "second", got.user)
+	assert.Equal(t, 0, pool.queue.len())
+	// the fake client keeps no resource version, so wait for the claimed sandboxes to leave the cache
+	assert.Eventually(t, func() bool {
+		available, err := pool.cache.ListAvailableSandboxes(pool.Name)
+		return err == nil && len(available) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// timeout
+	timeouts := testutil.ToFloat64(ClaimQueueTimeouts.WithLabelValues(pool.Name))
+	go claim("third", 100*time.Millisecond)
+	got = <-results
+	assert.Nil(t, got.sbx)
+	assert.ErrorContains(t, got.err, "timeout waiting")
+	assert.ErrorContains(t, got.err, "no stock")
+	assert.Equal(t, timeouts+1, testutil.ToFloat64(ClaimQueueTimeouts.WithLabelValues(pool.Name)))
+	assert.Equal(t, 0, pool.queue.len())
+}
+
+func TestPool_ClaimSandbox_QueueAndColdStart(t *testing.T) {
+	pool, client := NewTestPool(t)
+	sbs := &v1alpha1.SandboxSet{
+		ObjectMeta: metav1.ObjectMeta{Name: pool.Name, Namespace: pool.Namespace},
+		Spec: v1alpha1.SandboxSetSpec{
+			EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+				Template: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "old-image"}}},
+				},
+			},
+		},
+	}
+	_, err := client.ApiV1alpha1().SandboxSets(pool.Namespace).Create(t.Context(), sbs, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	// plays the sandbox controller
+	go func() {
+		for t.Context().Err() == nil {
+			list, err := client.ApiV1alpha1().Sandboxes(pool.Namespace).List(t.Context(), metav1.ListOptions{})
+			if err == nil && len(list.Items) > 0 {
+				sbx := list.Items[0].DeepCopy()
+				sbx.Status = v1alpha1.SandboxStatus{
+					Phase: v1alpha1.SandboxRunning,
+					Conditions: []metav1.Condition{{
+						Type:   string(v1alpha1.SandboxConditionReady),
+						Status: metav1.ConditionTrue,
+					}},
+					PodInfo: v1alpha1.PodInfo{PodIP: "1.2.3.4"},
+				}
+				_, _ = client.ApiV1alpha1().Sandboxes(pool.Namespace).UpdateStatus(t.Context(), sbx, metav1.UpdateOptions{})
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	// the cold start is not delayed by the long wait in the queue
+	start := time.Now()
+	sbx, err := pool.ClaimSandbox(t.Context(), "test-user", 1, infra.ClaimSandboxOptions{
+		MaxWait:          time.Minute,
+		ColdStartTimeout: 10 * time.Second,
+	})
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Equal(t, v1alpha1.True, sbx.GetAnnotations()[v1alpha1.AnnotationColdStart])
+	assert.Eventually(t, func() bool { return pool.queue.len() == 0 }, time.Second, 10*time.Millisecond)
+}
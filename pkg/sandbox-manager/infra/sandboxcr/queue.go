@@ -0,0 +1,139 @@
+package sandboxcr
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// claimQueue orders the claims waiting for available sandboxes of a pool. The waiters without weight are served
+// in the order they arrive, and the ones with weight are served by weighted fair queueing among their users, so that
+// a user with many waiting claims never starves the others. The zero value is ready to use.
+type claimQueue struct {
+	mu      sync.Mutex
+	waiters waiterHeap
+	seq     uint64
+	// virtual is the tag of the waiter served last, lastTag is the largest tag ever assigned
+	virtual float64
+	lastTag float64
+	// userTags are the tags of the last waiters of the weighted users
+	userTags map[string]float64
+	// signaled records a notification when no one is waiting, so that a waiter requeued right after the sandbox
+	// became available does not miss it
+	signaled bool
+}
+
+type claimWaiter struct {
+	user  string
+	tag   float64
+	seq   uint64
+	index int
+	// turn is closed when it is the turn of the waiter to claim
+	turn chan struct{}
+}
+
+// enqueue adds a waiter of user, whose turn comes at once if the queue was notified with no one waiting.
+func (q *claimQueue) enqueue(user string, weight int) *claimWaiter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	w := &claimWaiter{user: user, seq: q.seq, turn: make(chan struct{})}
+	if weight > 0 {
+		if q.userTags == nil {
+			q.userTags = map[string]float64{}
+		}
+		w.tag = max(q.virtual, q.userTags[user]) + 1/float64(weight)
+		q.userTags[user] = w.tag
+	} else {
+		w.tag = max(q.virtual, q.lastTag)
+	}
+	q.lastTag = max(q.lastTag, w.tag)
+	heap.Push(&q.waiters, w)
+	q.consumeSignal()
+	return w
+}
+
+// requeue puts a waiter whose claim failed back with its original position.
+func (q *claimQueue) requeue(w *claimWaiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	w.turn = make(chan struct{})
+	heap.Push(&q.waiters, w)
+	q.consumeSignal()
+}
+
+// remove removes a waiter giving up, false is returned if its turn has come already.
+func (q *claimQueue) remove(w *claimWaiter) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if w.index < 0 {
+		return false
+	}
+	heap.Remove(&q.waiters, w.index)
+	return true
+}
+
+// notify gives the turn to the first waiter.
+func (q *claimQueue) notify() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.waiters.Len() == 0 {
+		q.signaled = true
+		return
+	}
+	q.serve()
+}
+
+func (q *claimQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.waiters.Len()
+}
+
+func (q *claimQueue) consumeSignal() {
+	if q.signaled {
+		q.signaled = false
+		q.serve()
+	}
+}
+
+func (q *claimQueue) serve() {
+	w := heap.Pop(&q.waiters).(*claimWaiter)
+	q.virtual = max(q.virtual, w.tag)
+	close(w.turn)
+}
+
+// waiterHeap orders the waiters by tag, and then by arrival
+type waiterHeap []*claimWaiter
+
+func (h waiterHeap) Len() int {
+	return len(h)
+}
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].tag != h[j].tag {
+		return h[i].tag < h[j].tag
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x any) {
+	w := x.(*claimWaiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() any {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+	return w
+}
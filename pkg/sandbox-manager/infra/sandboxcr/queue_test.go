@@ -0,0 +1,96 @@
+package sandboxcr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func served(w *claimWaiter) bool {
+	select {
+	case <-w.turn:
+		return true
+	default:
+		return false
+	}
+}
+
+// serveAll notifies the queue until all the waiters are served, and returns the users in the order served.
+func serveAll(q *claimQueue, waiters []*claimWaiter) []string {
+	var order []string
+	recorded := map[*claimWaiter]bool{}
+	for range waiters {
+		q.notify()
+		for _, w := range waiters {
+			if served(w) && !recorded[w] {
+				recorded[w] = true
+				order = append(order, w.user)
+			}
+		}
+	}
+	return order
+}
+
+func TestClaimQueue_FIFO(t *testing.T) {
+	q := &claimQueue{}
+	var waiters []*claimWaiter
+	for _, user := range []string{"a", "a", "a", "b", "c"} {
+		waiters = append(waiters, q.enqueue(user, 0))
+	}
+	assert.Equal(t, 5, q.len())
+	assert.Equal(t, []string{"a", "a", "a", "b", "c"}, serveAll(q, waiters))
+	assert.Equal(t, 0, q.len())
+}
+
+func TestClaimQueue_Weighted(t *testing.T) {
+	q := &claimQueue{}
+	var waiters []*claimWaiter
+	for _, user := range []string{"a", "a", "a", "a", "b", "b", "c"} {
+		weight := 1
+		if user == "b" {
+			weight = 2
+		}
+		waiters = append(waiters, q.enqueue(user, weight))
+	}
+	// a: 1, 2, 3, 4; b: 0.5, 1; c: 1
+	assert.Equal(t, []string{"b", "a", "b", "c", "a", "a", "a"}, serveAll(q, waiters))
+}
+
+func TestClaimQueue_Requeue(t *testing.T) {
+	q := &claimQueue{}
+	first := q.enqueue("a", 0)
+	second := q.enqueue("b", 0)
+	q.notify()
+	assert.True(t, served(first))
+	assert.False(t, served(second))
+
+	// the first waiter failed to claim and keeps its position
+	q.requeue(first)
+	third := q.enqueue("c", 0)
+	q.notify()
+	assert.True(t, served(first))
+	assert.False(t, served(second))
+	assert.False(t, served(third))
+}
+
+func TestClaimQueue_Remove(t *testing.T) {
+	q := &claimQueue{}
+	first := q.enqueue("a", 0)
+	second := q.enqueue("b", 0)
+	assert.True(t, q.remove(first))
+	q.notify()
+	assert.False(t, served(first))
+	assert.True(t, served(second))
+	// the turn has come already
+	assert.False(t, q.remove(second))
+}
+
+func TestClaimQueue_Signaled(t *testing.T) {
+	q := &claimQueue{}
+	q.notify()
+	w := q.enqueue("a", 0)
+	assert.True(t, served(w))
+	assert.Equal(t, 0, q.len())
+	w = q.enqueue("a", 0)
+	assert.False(t, served(w))
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
@@ -32,6 +33,7 @@ import (
 	"github.com/openkruise/agents/pkg/utils/sandbox-manager/proxyutils"
 	stateutils "github.com/openkruise/agents/pkg/utils/sandboxutils"
 	"github.com/openkruise/agents/proto/envd/process"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/retry"
@@ -125,18 +127,49 @@ func (s *Sandbox) GetSandboxID() string {
 
 func (s *Sandbox) GetRoute() proxy.Route {
 	state, _ := s.GetState()
-	return proxy.Route{
+	annotations := s.GetAnnotations()
+	route := proxy.Route{
 		IP:    s.Status.PodInfo.PodIP,
 		ID:    s.GetSandboxID(),
-		Owner: s.GetAnnotations()[agentsv1alpha1.AnnotationOwner],
+		Owner: annotations[agentsv1alpha1.AnnotationOwner],
 		State: state,
 	}
+	// resource versions are integers in practice, the route is never ignored if not
+	route.Version, _ = strconv.ParseInt(s.GetResourceVersion(), 10, 64)
+	if annotations[agentsv1alpha1.AnnotationSecure] == agentsv1alpha1.True {
+		route.AccessTokenHash = proxy.HashAccessToken(annotations[agentsv1alpha1.AnnotationEnvdAccessToken])
+	}
+	// the activities are recorded by ext_proc
+	_, route.Dynamic = annotations[agentsv1alpha1.AnnotationIdleTimeoutSeconds]
+	ports, err := stateutils.GetExposedPorts(annotations)
+	if err != nil {
+		klog.ErrorS(err, "failed to get exposed ports", "sandbox", klog.KObj(s.Sandbox))
+	}
+	for _, port := range ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = agentsv1alpha1.SandboxPortProtocolHTTP
+		}
+		route.ExposedPorts = append(route.ExposedPorts, proxy.ExposedPort{
+			Port:     int(port.Port),
+			Protocol: string(protocol),
+			Public:   port.Auth == agentsv1alpha1.SandboxPortAuthNone,
+		})
+	}
+	return route
 }
 
 func (s *Sandbox) SetTimeout(ttl time.Duration) {
 	s.Spec.ShutdownTime = ptr.To(metav1.NewTime(time.Now().Add(ttl)))
 }
 
+// SetAutoPause makes the sandbox paused instead of deleted when timeout, a paused sandbox is retained for
+// pausedRetention at most.
+func (s *Sandbox) SetAutoPause(pausedRetention time.Duration) {
+	s.Spec.ShutdownPolicy = agentsv1alpha1.SandboxShutdownPause
+	s.Spec.PausedRetentionSeconds = ptr.To(int32(pausedRetention.Seconds()))
+}
+
 // SetImage sets the image of the first container
 func (s *Sandbox) SetImage(image string) {
 	if s.Spec.Template != nil {
@@ -144,6 +177,27 @@ func (s *Sandbox) SetImage(image string) {
 	}
 }
 
+// SetResources sets the resource requests of the first container. The limits are set to the same value when
+// they equal to (or default) the requests, or raised to the requests when they are lower, so that the QoS class is
+// not changed.
+func (s *Sandbox) SetResources(resources corev1.ResourceList) {
+	if s.Spec.Template == nil || len(resources) == 0 {
+		return
+	}
+	container := &s.Spec.Template.Spec.Containers[0]
+	if container.Resources.Requests == nil {
+		container.Resources.Requests = corev1.ResourceList{}
+	}
+	for name, quantity := range resources {
+		request, hasRequest := container.Resources.Requests[name]
+		if limit, ok := container.Resources.Limits[name]; ok &&
+			(!hasRequest || limit.Cmp(request) == 0 || limit.Cmp(quantity) < 0) {
+			container.Resources.Limits[name] = quantity.DeepCopy()
+		}
+		container.Resources.Requests[name] = quantity.DeepCopy()
+	}
+}
+
 func (s *Sandbox) GetImage() string {
 	if s.Spec.Template != nil {
 		return s.Spec.Template.Spec.Containers[0].Image
@@ -157,6 +211,17 @@ func (s *Sandbox) SaveTimeout(ctx context.Context, ttl time.Duration) error {
 	})
 }
 
+func (s *Sandbox) SaveAnnotations(ctx context.Context, annotations map[string]string) error {
+	return s.retryUpdate(ctx, s.Update, func(sbx *agentsv1alpha1.Sandbox) {
+		if sbx.Annotations == nil {
+			sbx.Annotations = make(map[string]string, len(annotations))
+		}
+		for k, v := range annotations {
+			sbx.Annotations[k] = v
+		}
+	})
+}
+
 func (s *Sandbox) GetTimeout() time.Time {
 	if s.Spec.ShutdownTime == nil {
 		return time.Time{}
@@ -250,6 +315,10 @@ func (s *Sandbox) GetState() (string, string) {
 	return stateutils.GetSandboxState(s.Sandbox)
 }
 
+func (s *Sandbox) GetPodUID() string {
+	return string(s.Status.PodInfo.PodUID)
+}
+
 func (s *Sandbox) GetClaimTime() (time.Time, error) {
 	claimTimestamp := s.GetAnnotations()[agentsv1alpha1.AnnotationClaimTime]
 	return time.Parse(time.RFC3339, claimTimestamp)
@@ -12,6 +12,7 @@ import (
 	"github.com/openkruise/agents/pkg/proxy"
 	"github.com/openkruise/agents/pkg/sandbox-manager/infra"
 	utils2 "github.com/openkruise/agents/pkg/utils"
+	"github.com/openkruise/agents/pkg/utils/templateref"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -42,7 +43,7 @@ func ConvertPodToSandboxCR(pod *corev1.Pod) *v1alpha1.Sandbox {
 	sbx := &v1alpha1.Sandbox{
 		ObjectMeta: pod.ObjectMeta,
 		Spec: v1alpha1.SandboxSpec{
-			SandboxTemplate: v1alpha1.SandboxTemplate{
+			EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 				Template: &corev1.PodTemplateSpec{
 					Spec: pod.Spec,
 				},
@@ -224,6 +225,62 @@ func TestSandbox_GetResource(t *testing.T) {
 	}
 }
 
+func TestSandbox_SetResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources corev1.ResourceRequirements
+		override  corev1.ResourceList
+		want      corev1.ResourceRequirements
+	}{
+		{
+			name: "requests only",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+			override: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			want: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+		},
+		{
+			name: "guaranteed limits follow requests",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+			override: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+			want: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+			},
+		},
+		{
+			name: "burstable limits are kept if not lower than requests",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+			override: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+			want: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "main", Resources: tt.resources}},
+				},
+			}
+			s := AsSandboxForTest(ConvertPodToSandboxCR(pod), nil, nil)
+			s.SetResources(tt.override)
+			assert.Equal(t, tt.want, s.Spec.Template.Spec.Containers[0].Resources)
+		})
+	}
+}
+
 func TestSandbox_InplaceRefresh(t *testing.T) {
 	initialSandbox := &v1alpha1.Sandbox{
 		ObjectMeta: metav1.ObjectMeta{
@@ -566,6 +623,76 @@ func TestSandbox_GetRoute(t *testing.T) {
 				State: v1alpha1.SandboxStateRunning,
 			},
 		},
+		{
+			name: "secure sandbox with access token",
+			sandbox: &v1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "running-sandbox",
+					Namespace: "default",
+					Annotations: map[string]string{
+						v1alpha1.AnnotationSecure:          v1alpha1.True,
+						v1alpha1.AnnotationEnvdAccessToken: "token",
+						v1alpha1.AnnotationExposedPorts:    `[{"name":"envd","port":49983},{"name":"cdp","port":9222,"protocol":"WebSocket","auth":"None"}]`,
+					},
+				},
+				Status: v1alpha1.SandboxStatus{
+					Phase: v1alpha1.SandboxRunning,
+					Conditions: []metav1.Condition{
+						{
+							Type:   string(v1alpha1.SandboxConditionReady),
+							Status: metav1.ConditionTrue,
+						},
+					},
+					PodInfo: v1alpha1.PodInfo{
+						PodIP: "10.0.0.3",
+					},
+				},
+			},
+			expectedRoute: proxy.Route{
+				IP:              "10.0.0.3",
+				ID:              "default--running-sandbox",
+				State:           v1alpha1.SandboxStateRunning,
+				AccessTokenHash: proxy.HashAccessToken("token"),
+				ExposedPorts: []proxy.ExposedPort{
+					{Port: 49983, Protocol: "HTTP"},
+					{Port: 9222, Protocol: "WebSocket", Public: true},
+				},
+			},
+		},
+		{
+			name: "insecure sandbox with access token",
+			sandbox: &v1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "running-sandbox",
+					Namespace: "default",
+					Annotations: map[string]string{
+						v1alpha1.AnnotationEnvdAccessToken: "token",
+						v1alpha1.AnnotationExposedPorts:    `[{"name":"envd","port":49983},{"name":"cdp","port":9222,"protocol":"WebSocket","auth":"None"}]`,
+					},
+				},
+				Status: v1alpha1.SandboxStatus{
+					Phase: v1alpha1.SandboxRunning,
+					Conditions: []metav1.Condition{
+						{
+							Type:   string(v1alpha1.SandboxConditionReady),
+							Status: metav1.ConditionTrue,
+						},
+					},
+					PodInfo: v1alpha1.PodInfo{
+						PodIP: "10.0.0.3",
+					},
+				},
+			},
+			expectedRoute: proxy.Route{
+				IP:    "10.0.0.3",
+				ID:    "default--running-sandbox",
+				State: v1alpha1.SandboxStateRunning,
+				ExposedPorts: []proxy.ExposedPort{
+					{Port: 49983, Protocol: "HTTP"},
+					{Port: 9222, Protocol: "WebSocket", Public: true},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -580,6 +707,23 @@ func TestSandbox_GetRoute(t *testing.T) {
 	}
 }
 
+// TestSandbox_GetRoute_IdlePolicyOfSandboxSet makes sure the requests to a sandbox with the idle policy declared by
+// its SandboxSet are processed by ext_proc when xDS is enabled, or its activities would never be recorded.
+func TestSandbox_GetRoute_IdlePolicyOfSandboxSet(t *testing.T) {
+	sbs := &v1alpha1.SandboxSet{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{v1alpha1.AnnotationIdleTimeoutSeconds: "600"},
+	}}
+	annotations := map[string]string{}
+	assert.NoError(t, templateref.SetE2BDefaults(annotations, sbs, nil))
+	s := &Sandbox{Sandbox: &v1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "sbx", Namespace: "default", Annotations: annotations},
+	}}
+	assert.True(t, s.GetRoute().Dynamic)
+
+	s.Annotations = nil
+	assert.False(t, s.GetRoute().Dynamic)
+}
+
 func TestSandbox_CSIMount(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -0,0 +1,54 @@
+package sandboxcr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	sandboxclient "github.com/openkruise/agents/client/clientset/versioned"
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// templateReader reads the objects referenced by the templateRef of SandboxSets with the clientsets, so that the
+// templates are resolved the same as the SandboxSet controller.
+type templateReader struct {
+	client    sandboxclient.Interface
+	k8sClient kubernetes.Interface
+}
+
+var _ client.Reader = templateReader{}
+
+func (r templateReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+	switch o := obj.(type) {
+	case *corev1.PodTemplate:
+		got, err := r.k8sClient.CoreV1().PodTemplates(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		*o = *got
+	case *apps.ControllerRevision:
+		got, err := r.k8sClient.AppsV1().ControllerRevisions(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		*o = *got
+	case *agentsv1alpha1.SandboxTemplate:
+		got, err := r.client.ApiV1alpha1().SandboxTemplates(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		*o = *got
+	default:
+		return fmt.Errorf("unsupported template object %T", obj)
+	}
+	return nil
+}
+
+func (r templateReader) List(context.Context, client.ObjectList, ...client.ListOption) error {
+	return errors.New("listing templates is not supported")
+}
@@ -23,9 +23,27 @@ var (
 		},
 		[]string{"result"}, // "success" or "failure"
 	)
+
+	// SandboxClaims tracks the claimed sandboxes by whether they are taken from the pool or created on demand
+	SandboxClaims = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sandbox_claims_total",
+			Help: "Total number of claimed sandboxes, warm ones are taken from the pool and cold ones are created on demand",
+		},
+		[]string{"type"}, // "warm" or "cold"
+	)
+
+	// SandboxColdStartLatency tracks the time from request to return of the sandboxes created on demand
+	SandboxColdStartLatency = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "sandbox_cold_start_latency_ms",
+			Help:    "Latency of sandbox creation in milliseconds when created on demand",
+			Buckets: prometheus.ExponentialBuckets(500, 2, 10), // 500ms to ~4min
+		},
+	)
 )
 
 func init() {
 	// Register custom metrics with the global prometheus registry
-	metrics.Registry.MustRegister(SandboxCreationLatency, SandboxCreationResponses)
+	metrics.Registry.MustRegister(SandboxCreationLatency, SandboxCreationResponses, SandboxClaims, SandboxColdStartLatency)
 }
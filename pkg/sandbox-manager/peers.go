@@ -0,0 +1,96 @@
+package sandbox_manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// watchPeers keeps the peers of the proxy the same as the ready sandbox-manager pods selected by peerSelector, until
+// ctx is done. The pods join once ready, and leave once not ready or terminating, so a rolling restart never waits
+// for the old pods.
+func (m *SandboxManager) watchPeers(ctx context.Context, sysNs, peerSelector string) error {
+	if _, err := labels.Parse(peerSelector); err != nil {
+		return fmt.Errorf("invalid peer selector %q: %w", peerSelector, err)
+	}
+	log := klog.FromContext(ctx)
+	factory := informers.NewSharedInformerFactoryWithOptions(m.client.K8sClient, 0,
+		informers.WithNamespace(sysNs),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = peerSelector
+		}))
+	informer := factory.Core().V1().Pods().Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			m.onPeerPod(ctx, obj)
+		},
+		UpdateFunc: func(_, newObj any) {
+			m.onPeerPod(ctx, newObj)
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok && pod.Status.PodIP != "" {
+				log.Info("peer left", "peer", pod.Name, "ip", pod.Status.PodIP)
+				m.proxy.DeletePeer(pod.Status.PodIP)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return errors.New("failed to sync peer pods")
+	}
+	log.Info("peer pods synced", "peers", len(m.proxy.ListPeers()))
+	return nil
+}
+
+func (m *SandboxManager) onPeerPod(ctx context.Context, obj any) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Status.PodIP == "" {
+		return
+	}
+	log := klog.FromContext(ctx)
+	ip := pod.Status.PodIP
+	if isPeerReady(pod) {
+		if !m.hasPeer(ip) {
+			log.Info("peer joined", "peer", pod.Name, "ip", ip)
+			m.proxy.SetPeer(ip)
+		}
+	} else if m.hasPeer(ip) {
+		log.Info("peer left", "peer", pod.Name, "ip", ip)
+		m.proxy.DeletePeer(ip)
+	}
+}
+
+func (m *SandboxManager) hasPeer(ip string) bool {
+	for _, peer := range m.proxy.ListPeers() {
+		if peer.IP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// isPeerReady returns whether the pod is ready and not terminating
+func isPeerReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package sandbox_manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openkruise/agents/pkg/proxy"
+	"github.com/openkruise/agents/pkg/sandbox-manager/clients"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func newPeerPod(name, ip string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "sandbox-system",
+			Labels:    map[string]string{"component": "sandbox-manager"},
+		},
+		Status: corev1.PodStatus{
+			PodIP:      ip,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func peerIPs(m *SandboxManager) []string {
+	var ips []string
+	for _, peer := range m.proxy.ListPeers() {
+		ips = append(ips, peer.IP)
+	}
+	return ips
+}
+
+func TestWatchPeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := clients.NewFakeClientSet()
+	pods := client.CoreV1().Pods("sandbox-system")
+	_, err := pods.Create(ctx, newPeerPod("peer-1", "10.0.0.1", true), metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = pods.Create(ctx, newPeerPod("peer-2", "10.0.0.2", false), metav1.CreateOptions{})
+	require.NoError(t, err)
+	other := newPeerPod("other", "10.0.0.3", true)
+	other.Labels = nil
+	_, err = pods.Create(ctx, other, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	m := &SandboxManager{client: client, proxy: proxy.NewServer(nil)}
+	// the not ready peers never block the start
+	require.NoError(t, m.watchPeers(ctx, "sandbox-system", "component=sandbox-manager"))
+	assert.ElementsMatch(t, []string{"10.0.0.1"}, peerIPs(m))
+
+	// a peer joins once ready
+	_, err = pods.UpdateStatus(ctx, newPeerPod("peer-2", "10.0.0.2", true), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.ElementsMatch(c, []string{"10.0.0.1", "10.0.0.2"}, peerIPs(m))
+	}, time.Second, 10*time.Millisecond)
+
+	// a terminating peer leaves
+	terminating := newPeerPod("peer-1", "10.0.0.1", true)
+	terminating.DeletionTimestamp = ptr.To(metav1.Now())
+	_, err = pods.Update(ctx, terminating, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.ElementsMatch(c, []string{"10.0.0.2"}, peerIPs(m))
+	}, time.Second, 10*time.Millisecond)
+
+	// a deleted peer leaves
+	require.NoError(t, pods.Delete(ctx, "peer-2", metav1.DeleteOptions{}))
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Empty(c, peerIPs(m))
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWatchPeers_InvalidSelector(t *testing.T) {
+	m := &SandboxManager{client: clients.NewFakeClientSet(), proxy: proxy.NewServer(nil)}
+	assert.ErrorContains(t, m.watchPeers(context.Background(), "sandbox-system", "a in (b"), "invalid peer selector")
+}
@@ -0,0 +1,344 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/openkruise/agents/pkg/proxy"
+	"github.com/openkruise/agents/pkg/sandbox-manager/logs"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	// RuleRefreshInterval is how long a change of the rules takes to be loaded at most
+	RuleRefreshInterval = 30 * time.Second
+	// DefaultRuleConfigMapKey is the key of the rules in the ConfigMap
+	DefaultRuleConfigMapKey = "rules.yaml"
+)
+
+// RuleConfig is the config of RuleAdapter in yaml or json, for example:
+//
+//	rules:
+//	- name: header
+//	  headers:
+//	    x-sandbox-id: '^(?P<id>.+)$'
+//	    x-sandbox-port: '^(?P<port>\d+)$'
+//	- name: team
+//	  authority: '^(?P<port>\d+)-(?P<id>[a-z0-9-]+)\.team\.example\.com(:\d+)?$'
+//	- name: prefix
+//	  path: '^/sandboxes/(?P<id>[a-z0-9-]+)/(?P<port>\d+)(?P<rest>/.*)?$'
+//	  rewrite: '${rest}'
+//	- name: api
+//	  authority: '^api\.'
+//	  api: true
+type RuleConfig struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule matches a request when all of its patterns match. The named groups captured by the patterns are used to
+// expand SandboxID, Port and Rewrite, as $name or ${name}.
+type Rule struct {
+	Name string `json:"name"`
+	// Authority, Path and Headers are regular expressions matching the request, empty ones match all
+	Authority string            `json:"authority,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	// API routes the request to the API server instead of a sandbox
+	API bool `json:"api,omitempty"`
+	// SandboxID defaults to ${id}
+	SandboxID string `json:"sandboxID,omitempty"`
+	// Port defaults to ${port}
+	Port string `json:"port,omitempty"`
+	// Rewrite is the path forwarded to the sandbox if not empty, the query is kept if it has none
+	Rewrite string `json:"rewrite,omitempty"`
+}
+
+// compiledRule is a Rule with the patterns compiled
+type compiledRule struct {
+	Rule
+	authority *regexp.Regexp
+	path      *regexp.Regexp
+	headers   map[string]*regexp.Regexp
+}
+
+// RuleSource loads the raw RuleConfig
+type RuleSource interface {
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// FileRuleSource loads the rules from a file, e.g. a mounted ConfigMap
+type FileRuleSource struct {
+	Path string
+}
+
+func (s FileRuleSource) Load(context.Context) ([]byte, error) {
+	return os.ReadFile(s.Path)
+}
+
+// ConfigMapRuleSource loads the rules from a ConfigMap through the API server
+type ConfigMapRuleSource struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+	Key       string
+}
+
+func (s ConfigMapRuleSource) Load(ctx context.Context) ([]byte, error) {
+	cm, err := s.Client.CoreV1().ConfigMaps(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	key := s.Key
+	if key == "" {
+		key = DefaultRuleConfigMapKey
+	}
+	raw, ok := cm.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found in configmap %s/%s", key, s.Namespace, s.Name)
+	}
+	return []byte(raw), nil
+}
+
+// RuleAdapter is a proxy.RequestAdapter routing the requests by the rules in order, the first matched one wins. The
+// requests matching no rule are routed by Fallback, which also provides the entry of the API server. The rules are
+// reloaded periodically, and the loaded ones are kept if the new ones are invalid. The rules are not served through
+// xDS, so all the requests are processed by ext_proc.
+type RuleAdapter struct {
+	Source   RuleSource
+	Fallback proxy.RequestAdapter
+	Stop     chan struct{}
+
+	rules atomic.Pointer[[]compiledRule]
+	// raw is the last loaded config, to skip compiling the same rules
+	raw atomic.Pointer[string]
+}
+
+func NewRuleAdapter(source RuleSource, fallback proxy.RequestAdapter) *RuleAdapter {
+	return &RuleAdapter{
+		Source:   source,
+		Fallback: fallback,
+		Stop:     make(chan struct{}),
+	}
+}
+
+// Init loads the rules, which must be valid.
+func (a *RuleAdapter) Init(ctx context.Context) error {
+	return a.refresh(ctx)
+}
+
+func (a *RuleAdapter) refresh(ctx context.Context) error {
+	raw, err := a.Source.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if last := a.raw.Load(); last != nil && *last == string(raw) {
+		return nil
+	}
+	rules, err := parseRules(raw)
+	if err != nil {
+		return err
+	}
+	a.rules.Store(&rules)
+	a.raw.Store(ptr.To(string(raw)))
+	klog.FromContext(ctx).Info("request rules loaded", "rules", len(rules))
+	return nil
+}
+
+func (a *RuleAdapter) Run() {
+	go func() {
+		ticker := time.NewTicker(RuleRefreshInterval)
+		ctx := logs.NewContext()
+		log := klog.FromContext(ctx)
+		for {
+			select {
+			case <-ticker.C:
+				if err := a.refresh(ctx); err != nil {
+					log.Error(err, "failed to refresh request rules")
+				}
+			case <-a.Stop:
+				ticker.Stop()
+				log.Info("request rules refreshing stopped")
+				return
+			}
+		}
+	}()
+}
+
+// parseRules parses and validates the RuleConfig.
+func parseRules(raw []byte) ([]compiledRule, error) {
+	var config RuleConfig
+	if err := yaml.UnmarshalStrict(raw, &config); err != nil {
+		return nil, fmt.Errorf("invalid rule config: %w", err)
+	}
+	rules := make([]compiledRule, 0, len(config.Rules))
+	for i, rule := range config.Rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %d %q: %w", i, rule.Name, err)
+		}
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+func compileRule(rule Rule) (compiledRule, error) {
+	compiled := compiledRule{Rule: rule, headers: make(map[string]*regexp.Regexp, len(rule.Headers))}
+	var err error
+	if rule.Authority != "" {
+		if compiled.authority, err = regexp.Compile(rule.Authority); err != nil {
+			return compiled, err
+		}
+	}
+	if rule.Path != "" {
+		if compiled.path, err = regexp.Compile(rule.Path); err != nil {
+			return compiled, err
+		}
+	}
+	for name, pattern := range rule.Headers {
+		if compiled.headers[strings.ToLower(name)], err = regexp.Compile(pattern); err != nil {
+			return compiled, err
+		}
+	}
+	if compiled.authority == nil && compiled.path == nil && len(compiled.headers) == 0 {
+		return compiled, errors.New("at least one of authority, path and headers is required")
+	}
+	if rule.API {
+		return compiled, nil
+	}
+	if compiled.SandboxID == "" {
+		compiled.SandboxID = "${id}"
+	}
+	if compiled.Port == "" {
+		compiled.Port = "${port}"
+	}
+	return compiled, nil
+}
+
+// match returns the named groups captured if the rule matches the request.
+func (r *compiledRule) match(authority, path string, headers map[string]string) (map[string]string, bool) {
+	captures := make(map[string]string)
+	capture := func(pattern *regexp.Regexp, value string) bool {
+		if pattern == nil {
+			return true
+		}
+		matches := pattern.FindStringSubmatch(value)
+		if matches == nil {
+			return false
+		}
+		for i, name := range pattern.SubexpNames() {
+			if name != "" && matches[i] != "" {
+				captures[name] = matches[i]
+			}
+		}
+		return true
+	}
+	if !capture(r.authority, authority) {
+		return nil, false
+	}
+	if !capture(r.path, path) {
+		return nil, false
+	}
+	for name, pattern := range r.headers {
+		value, ok := headers[name]
+		if !ok || !capture(pattern, value) {
+			return nil, false
+		}
+	}
+	return captures, true
+}
+
+// matchRule returns the first rule matching the request
+func (a *RuleAdapter) matchRule(authority, path string, headers map[string]string) (*compiledRule, map[string]string) {
+	rules := a.rules.Load()
+	if rules == nil {
+		return nil, nil
+	}
+	for i := range *rules {
+		if captures, ok := (*rules)[i].match(authority, path, headers); ok {
+			return &(*rules)[i], captures
+		}
+	}
+	return nil, nil
+}
+
+func (a *RuleAdapter) Map(scheme, authority, path string, port int, headers map[string]string) (
+	sandboxID string, sandboxPort int, extraHeaders map[string]string, err error) {
+	rule, captures := a.matchRule(authority, path, headers)
+	if rule == nil {
+		return a.Fallback.Map(scheme, authority, path, port, headers)
+	}
+	if rule.API {
+		err = fmt.Errorf("request %s%s is an API request by rule %s", authority, path, rule.Name)
+		return
+	}
+	expand := func(template string) string {
+		return os.Expand(template, func(name string) string {
+			return captures[name]
+		})
+	}
+	if sandboxID = expand(rule.SandboxID); sandboxID == "" {
+		err = fmt.Errorf("no sandbox ID captured by rule %s", rule.Name)
+		return
+	}
+	if sandboxPort, err = strconv.Atoi(expand(rule.Port)); err != nil {
+		err = fmt.Errorf("invalid port captured by rule %s: %w", rule.Name, err)
+		return
+	}
+	if rule.Rewrite != "" {
+		rewritten := expand(rule.Rewrite)
+		if !strings.HasPrefix(rewritten, "/") {
+			rewritten = "/" + rewritten
+		}
+		if idx := strings.Index(path, "?"); idx >= 0 && !strings.Contains(rewritten, "?") {
+			rewritten += path[idx:]
+		}
+		extraHeaders = map[string]string{":path": rewritten}
+	}
+	return
+}
+
+func (a *RuleAdapter) IsSandboxRequest(authority, path string, port int) bool {
+	return a.IsSandboxRequestWithHeaders(authority, path, port, nil)
+}
+
+// IsSandboxRequestWithHeaders implements proxy.HeaderAwareAdapter, so that the rules matching headers are applied.
+func (a *RuleAdapter) IsSandboxRequestWithHeaders(authority, path string, port int, headers map[string]string) bool {
+	rule, _ := a.matchRule(authority, path, headers)
+	if rule == nil {
+		return a.Fallback.IsSandboxRequest(authority, path, port)
+	}
+	return !rule.API
+}
+
+func (a *RuleAdapter) Entry() string {
+	return a.Fallback.Entry()
+}
+
+// Authorize implements proxy.RequestAuthorizer by the Fallback, e.g. with the signed URLs.
+func (a *RuleAdapter) Authorize(sandboxID string, sandboxPort int, path string, headers map[string]string) (bool, *proxy.Redirect) {
+	authorizer, ok := a.Fallback.(proxy.RequestAuthorizer)
+	if !ok {
+		return false, nil
+	}
+	return authorizer.Authorize(sandboxID, sandboxPort, path, headers)
+}
+
+// CredentialCookie implements proxy.RequestAuthorizer by the Fallback.
+func (a *RuleAdapter) CredentialCookie() string {
+	authorizer, ok := a.Fallback.(proxy.RequestAuthorizer)
+	if !ok {
+		return ""
+	}
+	return authorizer.CredentialCookie()
+}
@@ -0,0 +1,208 @@
+package adapters
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testRules = `
+rules:
+- name: header
+  headers:
+    X-Sandbox-ID: '^(?P<id>.+)$'
+    x-sandbox-port: '^(?P<port>\d+)$'
+- name: team
+  authority: '^(?P<port>\d+)-(?P<id>[a-z0-9-]+)\.team\.example\.com(:\d+)?$'
+- name: prefix
+  path: '^/sandboxes/(?P<id>[a-z0-9-]+)/(?P<port>\d+)(?P<rest>/[^?]*)?'
+  rewrite: '${rest}'
+- name: fixed-port
+  path: '^/browser/(?P<id>[a-z0-9-]+)/'
+  sandboxID: 'default--${id}'
+  port: '9222'
+- name: api
+  authority: '^api\.team\.example\.com$'
+  api: true
+`
+
+func TestRuleAdapter_Map(t *testing.T) {
+	adapter := NewRuleAdapter(FileRuleSource{}, NewE2BAdapter(8080))
+	rules, err := parseRules([]byte(testRules))
+	require.NoError(t, err)
+	adapter.rules.Store(&rules)
+
+	tests := []struct {
+		name          string
+		authority     string
+		path          string
+		headers       map[string]string
+		expectSandbox bool
+		expectID      string
+		expectPort    int
+		expectHeaders map[string]string
+		expectErr     bool
+	}{
+		{
+			name:          "header rule",
+			authority:     "gateway.example.com",
+			path:          "/index.html",
+			headers:       map[string]string{"x-sandbox-id": "sandbox1", "x-sandbox-port": "3000"},
+			expectSandbox: true,
+			expectID:      "sandbox1",
+			expectPort:    3000,
+		},
+		{
+			name:          "authority rule",
+			authority:     "3000-sandbox1.team.example.com:443",
+			path:          "/",
+			expectSandbox: true,
+			expectID:      "sandbox1",
+			expectPort:    3000,
+		},
+		{
+			name:          "path rule with rewrite",
+			authority:     "gateway.example.com",
+			path:          "/sandboxes/sandbox1/3000/app/index.html?a=b",
+			expectSandbox: true,
+			expectID:      "sandbox1",
+			expectPort:    3000,
+			expectHeaders: map[string]string{":path": "/app/index.html?a=b"},
+		},
+		{
+			name:          "path rule rewritten to root",
+			authority:     "gateway.example.com",
+			path:          "/sandboxes/sandbox1/3000",
+			expectSandbox: true,
+			expectID:      "sandbox1",
+			expectPort:    3000,
+			expectHeaders: map[string]string{":path": "/"},
+		},
+		{
+			name:          "templates",
+			authority:     "gateway.example.com",
+			path:          "/browser/sandbox1/json",
+			expectSandbox: true,
+			expectID:      "default--sandbox1",
+			expectPort:    9222,
+		},
+		{
+			name:      "api rule",
+			authority: "api.team.example.com",
+			path:      "/sandboxes",
+			expectErr: true,
+		},
+		{
+			name:          "fallback to native",
+			authority:     "3000-sandbox1.example.com",
+			path:          "/",
+			expectSandbox: true,
+			expectID:      "sandbox1",
+			expectPort:    3000,
+		},
+		{
+			name:      "fallback to api",
+			authority: "api.example.com",
+			path:      "/sandboxes",
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectSandbox, adapter.IsSandboxRequestWithHeaders(tt.authority, tt.path, 443, tt.headers))
+			sandboxID, sandboxPort, extraHeaders, err := adapter.Map("https", tt.authority, tt.path, 443, tt.headers)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectID, sandboxID)
+			assert.Equal(t, tt.expectPort, sandboxPort)
+			assert.Equal(t, tt.expectHeaders, extraHeaders)
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		expectErr string
+	}{
+		{
+			name: "valid",
+			raw:  testRules,
+		},
+		{
+			name:      "unknown field",
+			raw:       "rules:\n- name: a\n  host: x\n",
+			expectErr: "invalid rule config",
+		},
+		{
+			name:      "invalid pattern",
+			raw:       "rules:\n- name: a\n  path: '('\n",
+			expectErr: `invalid rule 0 "a"`,
+		},
+		{
+			name:      "no pattern",
+			raw:       "rules:\n- name: a\n  api: true\n",
+			expectErr: "at least one of authority, path and headers is required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseRules([]byte(tt.raw))
+			if tt.expectErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestRuleAdapter_Reload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n- name: a\n  path: '^/a/(?P<id>[^/]+)/(?P<port>\\d+)'\n"), 0o600))
+	adapter := NewRuleAdapter(FileRuleSource{Path: path}, NewE2BAdapter(8080))
+	require.NoError(t, adapter.Init(ctx))
+	assert.True(t, adapter.IsSandboxRequestWithHeaders("example.com", "/a/sandbox1/3000", 80, nil))
+	sandboxID, _, _, err := adapter.Map("http", "example.com", "/a/sandbox1/3000", 80, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "sandbox1", sandboxID)
+
+	// the new rules are loaded
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n- name: b\n  path: '^/b/(?P<id>[^/]+)/(?P<port>\\d+)'\n"), 0o600))
+	require.NoError(t, adapter.refresh(ctx))
+	sandboxID, _, _, err = adapter.Map("http", "example.com", "/b/sandbox2/3000", 80, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "sandbox2", sandboxID)
+
+	// invalid rules are rejected, and the loaded ones are kept
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n- name: c\n  path: '('\n"), 0o600))
+	assert.Error(t, adapter.refresh(ctx))
+	sandboxID, _, _, err = adapter.Map("http", "example.com", "/b/sandbox2/3000", 80, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "sandbox2", sandboxID)
+}
+
+func TestConfigMapRuleSource(t *testing.T) {
+	client := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "rules", Namespace: "sandbox-system"},
+		Data:       map[string]string{DefaultRuleConfigMapKey: testRules},
+	})
+	raw, err := ConfigMapRuleSource{Client: client, Namespace: "sandbox-system", Name: "rules"}.Load(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, testRules, string(raw))
+
+	_, err = ConfigMapRuleSource{Client: client, Namespace: "sandbox-system", Name: "rules", Key: "other"}.Load(context.Background())
+	assert.EqualError(t, err, "key other not found in configmap sandbox-system/rules")
+}
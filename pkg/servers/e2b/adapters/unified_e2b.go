@@ -2,9 +2,17 @@ package adapters
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
 	"strings"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/proxy"
 )
 
+// SignedURLParam is the query parameter or cookie carrying the token of a signed URL
+const SignedURLParam = "kruise_signature"
+
 // E2BMapper is part of proxy.RequestAdapter
 type E2BMapper interface {
 	Map(scheme, authority, path string, port int, headers map[string]string) (
@@ -12,8 +20,15 @@ type E2BMapper interface {
 	IsSandboxRequest(authority, path string, port int) bool
 }
 
+// URLVerifier verifies the tokens of signed URLs
+type URLVerifier interface {
+	Verify(token, sandboxID string, port int, path string) error
+}
+
 type E2BAdapter struct {
-	Port       int
+	Port int
+	// Verifier authorizes the requests with signed URLs if set
+	Verifier   URLVerifier
 	native     *NativeE2BAdapter
 	customized *CustomizedE2BAdapter
 }
@@ -45,3 +60,99 @@ func (a *E2BAdapter) ChooseAdapter(path string) E2BMapper {
 	}
 	return a.native
 }
+
+// XDSMatches implements proxy.XDSRouteAdapter, which matches the requests mapped by both the native and customized
+// adapters.
+func (a *E2BAdapter) XDSMatches(sandboxID string, sandboxPort int) []proxy.XDSMatch {
+	return []proxy.XDSMatch{
+		{
+			Domain:     fmt.Sprintf("%d-%s.*", sandboxPort, sandboxID),
+			PathPrefix: "/",
+		},
+		{
+			Domain:     "*",
+			PathPrefix: fmt.Sprintf("%s/%s/%d/", CustomPrefix, sandboxID, sandboxPort),
+			Rewrite:    true,
+		},
+	}
+}
+
+// XDSDefaultPorts implements proxy.XDSRouteAdapter, the envd port is always requested by the E2B SDK, while the other
+// ports are known only if declared by the SandboxTemplate or SandboxSet.
+func (a *E2BAdapter) XDSDefaultPorts() []proxy.ExposedPort {
+	return []proxy.ExposedPort{{Port: agentsv1alpha1.DefaultEnvdPort}}
+}
+
+// Authorize implements proxy.RequestAuthorizer, which accepts the signed URLs. The token in the query is moved into
+// a cookie scoped to the sandbox port by a redirect, so that the following requests of the page are authorized too.
+func (a *E2BAdapter) Authorize(sandboxID string, sandboxPort int, path string, headers map[string]string) (bool, *proxy.Redirect) {
+	if a.Verifier == nil {
+		return false, nil
+	}
+	token, inQuery := signedURLToken(path, headers)
+	if token == "" || a.Verifier.Verify(token, sandboxID, sandboxPort, path) != nil {
+		return false, nil
+	}
+	if !inQuery {
+		return true, nil
+	}
+	requested := headers[":path"]
+	cookie := &http.Cookie{
+		Name:     SignedURLParam,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   headers[":scheme"] == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+	if a.ChooseAdapter(requested) == a.customized {
+		cookie.Path = fmt.Sprintf("%s/%s/%d/", CustomPrefix, sandboxID, sandboxPort)
+	}
+	return true, &proxy.Redirect{
+		Location:  withoutSignedURLParam(requested),
+		SetCookie: cookie.String(),
+	}
+}
+
+// CredentialCookie implements proxy.RequestAuthorizer, the token of the signed URLs is never forwarded to the sandbox.
+func (a *E2BAdapter) CredentialCookie() string {
+	return SignedURLParam
+}
+
+// signedURLToken gets the token from the query parameter, or the cookie if absent
+func signedURLToken(path string, headers map[string]string) (token string, inQuery bool) {
+	if idx := strings.Index(path, "?"); idx >= 0 {
+		if query, err := url.ParseQuery(path[idx+1:]); err == nil {
+			if token := query.Get(SignedURLParam); token != "" {
+				return token, true
+			}
+		}
+	}
+	cookies, err := http.ParseCookie(headers["cookie"])
+	if err != nil {
+		return "", false
+	}
+	for _, cookie := range cookies {
+		if cookie.Name == SignedURLParam {
+			return cookie.Value, false
+		}
+	}
+	return "", false
+}
+
+// withoutSignedURLParam removes the token of the signed URL from the query of path
+func withoutSignedURLParam(path string) string {
+	idx := strings.Index(path, "?")
+	if idx < 0 {
+		return path
+	}
+	query, err := url.ParseQuery(path[idx+1:])
+	if err != nil {
+		return path[:idx]
+	}
+	query.Del(SignedURLParam)
+	if len(query) == 0 {
+		return path[:idx]
+	}
+	return path[:idx] + "?" + query.Encode()
+}
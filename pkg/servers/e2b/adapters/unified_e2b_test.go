@@ -2,6 +2,8 @@ package adapters
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/openkruise/agents/pkg/proxy"
@@ -64,7 +66,7 @@ func TestMap(t *testing.T) {
 			expectErr:         false,
 		},
 		{
-			name:              "native e2b adapter - valid authority with regular port and invalid token",
+			name:              "native e2b adapter - valid authority with regular port and invalid token checked later by the proxy",
 			authority:         "3000-sandbox5678.example.com",
 			path:              "/",
 			headers:           map[string]string{"x-access-token": "invalid-token"},
@@ -100,7 +102,7 @@ func TestMap(t *testing.T) {
 			expectErr:         false,
 		},
 		{
-			name:              "customized e2b adapter - valid path with regular port and invalid token",
+			name:              "customized e2b adapter - valid path with regular port and invalid token checked later by the proxy",
 			authority:         "",
 			path:              "/kruise/sandbox1234/3000/some/path",
 			headers:           map[string]string{"x-access-token": "invalid-token"},
@@ -220,3 +222,109 @@ func TestIsSandboxRequest(t *testing.T) {
 		})
 	}
 }
+
+type fakeVerifier struct {
+	token string
+}
+
+func (f *fakeVerifier) Verify(token, _ string, _ int, _ string) error {
+	if token != f.token {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier URLVerifier
+		path     string
+		headers  map[string]string
+		expect   bool
+		// expectRedirect is the location redirected to, empty means not redirected
+		expectRedirect string
+		expectCookie   string
+	}{
+		{
+			name:           "token in query",
+			verifier:       &fakeVerifier{token: "signed"},
+			path:           "/index.html?a=b&" + SignedURLParam + "=signed",
+			headers:        map[string]string{":path": "/index.html?a=b&" + SignedURLParam + "=signed", ":scheme": "https"},
+			expect:         true,
+			expectRedirect: "/index.html?a=b",
+			expectCookie:   SignedURLParam + "=signed; Path=/; HttpOnly; Secure; SameSite=Lax",
+		},
+		{
+			name:     "token in query of customized path",
+			verifier: &fakeVerifier{token: "signed"},
+			path:     "/index.html?" + SignedURLParam + "=signed",
+			headers: map[string]string{
+				":path":   CustomPrefix + "/sandbox1/3000/index.html?" + SignedURLParam + "=signed",
+				":scheme": "http",
+			},
+			expect:         true,
+			expectRedirect: CustomPrefix + "/sandbox1/3000/index.html",
+			expectCookie:   SignedURLParam + "=signed; Path=" + CustomPrefix + "/sandbox1/3000/; HttpOnly; SameSite=Lax",
+		},
+		{
+			name:     "token in cookie",
+			verifier: &fakeVerifier{token: "signed"},
+			path:     "/static/app.js",
+			headers:  map[string]string{"cookie": "a=b; " + SignedURLParam + "=signed"},
+			expect:   true,
+		},
+		{
+			name:     "invalid token",
+			verifier: &fakeVerifier{token: "signed"},
+			path:     "/index.html?" + SignedURLParam + "=invalid",
+			expect:   false,
+		},
+		{
+			name:     "no token",
+			verifier: &fakeVerifier{token: "signed"},
+			path:     "/index.html",
+			headers:  map[string]string{"cookie": "a=b"},
+			expect:   false,
+		},
+		{
+			name:   "no verifier",
+			path:   "/index.html?" + SignedURLParam + "=signed",
+			expect: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := NewE2BAdapter(8080)
+			adapter.Verifier = tt.verifier
+			ok, redirect := adapter.Authorize("sandbox1", 3000, tt.path, tt.headers)
+			assert.Equal(t, tt.expect, ok)
+			if tt.expectRedirect == "" {
+				assert.Nil(t, redirect)
+				return
+			}
+			if assert.NotNil(t, redirect) {
+				assert.Equal(t, tt.expectRedirect, redirect.Location)
+				assert.Equal(t, tt.expectCookie, redirect.SetCookie)
+			}
+		})
+	}
+}
+
+func TestXDSMatches(t *testing.T) {
+	adapter := NewE2BAdapter(8080)
+	matches := adapter.XDSMatches("default--sandbox1", 3000)
+	assert.Equal(t, []proxy.XDSMatch{
+		{Domain: "3000-default--sandbox1.*", PathPrefix: "/"},
+		{Domain: "*", PathPrefix: "/kruise/default--sandbox1/3000/", Rewrite: true},
+	}, matches)
+	// the requests matched are mapped to the same sandbox port
+	for _, match := range matches {
+		authority := strings.Replace(match.Domain, "*", "example.com", 1)
+		path := match.PathPrefix + "index.html"
+		sandboxID, sandboxPort, _, err := adapter.Map("http", authority, path, 80, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "default--sandbox1", sandboxID)
+		assert.Equal(t, 3000, sandboxPort)
+	}
+	assert.Equal(t, []proxy.ExposedPort{{Port: 49983}}, adapter.XDSDefaultPorts())
+}
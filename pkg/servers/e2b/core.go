@@ -10,6 +10,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/openkruise/agents/pkg/proxy"
 	sandbox_manager "github.com/openkruise/agents/pkg/sandbox-manager"
 	"github.com/openkruise/agents/pkg/sandbox-manager/clients"
 	"github.com/openkruise/agents/pkg/sandbox-manager/logs"
@@ -28,9 +29,25 @@ type Controller struct {
 	client       *clients.ClientSet
 	clientConfig *rest.Config
 	domain       string
+	sysNs        string
 	manager      *sandbox_manager.SandboxManager
 	keys         *keys.SecretKeyStorage
+	signingKeys  *keys.SigningKeyStorage
 	maxTimeout   int
+	// wakeOnRequestTimeout enables resuming paused sandboxes on proxy requests if positive
+	wakeOnRequestTimeout time.Duration
+	// enableXDS serves the routes of sandboxes to Envoy through xDS
+	enableXDS bool
+	// reverseProxyPort serves the requests to sandboxes without Envoy if positive
+	reverseProxyPort int
+	// rules routes the requests by the rules before the E2B schemes if set
+	rules *adapters.RuleAdapter
+	// claimMaxWait is how long a claim waits for an available sandbox if not specified by the request
+	claimMaxWait time.Duration
+	// claimWeights are the weights of the API keys to share the available sandboxes between the waiting claims
+	claimWeights map[string]int
+	// startTime is when the controller starts, before which the proxy activities are unknown
+	startTime time.Time
 }
 
 // NewController creates a new E2B Controller
@@ -39,9 +56,16 @@ func NewController(domain, adminKey string, sysNs string, maxTimeout int, port i
 		mux:          http.NewServeMux(),
 		client:       clientSet,
 		domain:       domain,
+		sysNs:        sysNs,
 		clientConfig: clientSet.Config,
 		port:         port,
 		maxTimeout:   maxTimeout,
+		startTime:    time.Now(),
+		signingKeys: &keys.SigningKeyStorage{
+			Namespace: sysNs,
+			Client:    clientSet.K8sClient,
+			Stop:      make(chan struct{}),
+		},
 	}
 
 	sc.server = &http.Server{
@@ -61,16 +85,80 @@ func NewController(domain, adminKey string, sysNs string, maxTimeout int, port i
 	return sc
 }
 
+// EnableWakeOnRequest makes the first request to a paused sandbox through the proxy resume it, the requests are held
+// for timeout at most. It should be called before Init.
+func (sc *Controller) EnableWakeOnRequest(timeout time.Duration) {
+	sc.wakeOnRequestTimeout = timeout
+}
+
+// EnableXDS makes the requests to running sandboxes routed by Envoy with the routes pushed through xDS, instead of
+// looked up by ext_proc per request. Only the declared ports and the envd port are routed through xDS, see
+// config/envoy/bootstrap.yaml for the Envoy bootstrap. It should be called before Init.
+func (sc *Controller) EnableXDS() {
+	sc.enableXDS = true
+}
+
+// EnableReverseProxy serves the requests to sandboxes on port by the embedded reverse proxy, for the deployments
+// without Envoy. It should be called before Init.
+func (sc *Controller) EnableReverseProxy(port int) {
+	sc.reverseProxyPort = port
+}
+
+// EnableRequestRules routes the requests to sandboxes by the rules loaded from source, the ones matching no rule are
+// routed by the E2B schemes. It should be called before Init.
+func (sc *Controller) EnableRequestRules(source adapters.RuleSource) {
+	sc.rules = adapters.NewRuleAdapter(source, nil)
+}
+
+// EnableClaimQueue makes the claims wait for maxWait at most if no sandbox is available, instead of failing at once.
+// The waiting claims are served in the order they arrive if weights is empty, otherwise shared between the API keys
+// by their weights, keyed by the ID of the API key and default to 1.
+func (sc *Controller) EnableClaimQueue(maxWait time.Duration, weights map[string]int) {
+	sc.claimMaxWait = maxWait
+	sc.claimWeights = weights
+}
+
 func (sc *Controller) Init(infrastructure string) error {
 	ctx := logs.NewContext()
 	log := klog.FromContext(ctx)
 	log.Info("init controller", "infra", infrastructure)
+	if err := sc.signingKeys.Init(ctx); err != nil {
+		return err
+	}
 	adapter := adapters.NewE2BAdapter(sc.port)
-	sandboxManager, err := sandbox_manager.NewSandboxManager(sc.client, adapter, infrastructure)
+	adapter.Verifier = sc.signingKeys
+	var requestAdapter proxy.RequestAdapter = adapter
+	if sc.rules != nil {
+		sc.rules.Fallback = adapter
+		if err := sc.rules.Init(ctx); err != nil {
+			return err
+		}
+		requestAdapter = sc.rules
+	}
+	sandboxManager, err := sandbox_manager.NewSandboxManager(sc.client, requestAdapter, infrastructure)
 	if err != nil {
 		return err
 	}
 	sc.manager = sandboxManager
+	peerSecret, err := keys.LoadPeerSecret(ctx, sc.client.K8sClient, sc.sysNs)
+	if err != nil {
+		return err
+	}
+	sc.manager.SetPeerSecret(peerSecret)
+	sc.manager.GetInfra().OnSandboxReinit(sc.reinitSandbox)
+	if sc.wakeOnRequestTimeout > 0 {
+		log.Info("wake-on-request enabled", "timeout", sc.wakeOnRequestTimeout)
+		sc.manager.EnableWakeOnRequest(sc.wakeSandbox, sc.wakeOnRequestTimeout)
+	}
+	if sc.enableXDS {
+		// the ports not declared by the sandboxes are still looked up by ext_proc, except the envd port
+		log.Info("xDS enabled, only the declared ports and the envd port of sandboxes are routed through xDS")
+		sc.manager.EnableXDS()
+	}
+	if sc.reverseProxyPort > 0 {
+		log.Info("embedded reverse proxy enabled", "port", sc.reverseProxyPort)
+		sc.manager.EnableReverseProxy(sc.reverseProxyPort)
+	}
 	sc.registerRoutes()
 	if sc.keys == nil {
 		return nil
@@ -113,6 +201,13 @@ func (sc *Controller) Run(sysNs, peerSelector string) (context.Context, error) {
 		klog.InfoS("Server exited")
 	}()
 
+	// the idle checker stops with the controller
+	go sc.runIdleChecker(ctx)
+
+	sc.signingKeys.Run()
+	if sc.rules != nil {
+		sc.rules.Run()
+	}
 	if sc.keys != nil {
 		sc.keys.Run()
 	}
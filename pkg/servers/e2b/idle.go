@@ -0,0 +1,102 @@
+package e2b
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/sandbox-manager/infra"
+	"k8s.io/klog/v2"
+)
+
+// IdleCheckInterval is the interval to check the idle sandboxes
+var IdleCheckInterval = 30 * time.Second
+
+// idlePolicy is recorded in the sandbox from the annotations of its SandboxSet or its SandboxTemplate when created.
+type idlePolicy struct {
+	timeout          time.Duration
+	extendOnActivity bool
+}
+
+// idlePolicyOf returns the idle policy of the sandbox. It is only read from the sandbox, which also decides whether
+// the requests to the sandbox go through ext_proc to record the activities, see Sandbox.GetRoute.
+func idlePolicyOf(sbx infra.Sandbox) (idlePolicy, bool) {
+	annotations := sbx.GetAnnotations()
+	seconds, err := strconv.Atoi(annotations[v1alpha1.AnnotationIdleTimeoutSeconds])
+	if err != nil || seconds <= 0 {
+		return idlePolicy{}, false
+	}
+	return idlePolicy{
+		timeout:          time.Duration(seconds) * time.Second,
+		extendOnActivity: annotations[v1alpha1.AnnotationExtendTimeoutOnActivity] == v1alpha1.True,
+	}, true
+}
+
+// lastActiveTime returns the last time the sandbox was requested through the proxies, or claimed, or the
+// sandbox-manager started, whichever is the latest. The activities are not persisted across restarts.
+func (sc *Controller) lastActiveTime(sbx infra.Sandbox) time.Time {
+	last := sc.startTime
+	if claimTime, err := sbx.GetClaimTime(); err == nil && claimTime.After(last) {
+		last = claimTime
+	}
+	if activity, ok := sc.manager.GetLastActivity(sbx.GetSandboxID()); ok && activity.After(last) {
+		last = activity
+	}
+	return last
+}
+
+// runIdleChecker checks the idle sandboxes periodically until ctx is done.
+func (sc *Controller) runIdleChecker(ctx context.Context) {
+	ticker := time.NewTicker(IdleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sc.checkIdleSandboxes(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkIdleSandboxes pauses the running sandboxes idle for longer than the idle timeout, and extends the timeout of
+// the active ones if required.
+func (sc *Controller) checkIdleSandboxes(ctx context.Context) {
+	log := klog.FromContext(ctx)
+	for _, route := range sc.manager.ListRoutes() {
+		if route.State != v1alpha1.SandboxStateRunning {
+			continue
+		}
+		sbx, err := sc.manager.GetInfra().GetSandbox(ctx, route.ID)
+		if err != nil {
+			continue
+		}
+		policy, ok := idlePolicyOf(sbx)
+		if !ok {
+			continue
+		}
+		lastActive := sc.lastActiveTime(sbx)
+		idle := time.Since(lastActive)
+		if idle >= policy.timeout {
+			log.Info("pausing idle sandbox", "sandboxID", route.ID, "idle", idle, "idleTimeout", policy.timeout)
+			if err = sbx.Pause(ctx); err != nil {
+				log.Error(err, "failed to pause idle sandbox", "sandboxID", route.ID)
+			}
+			continue
+		}
+		if !policy.extendOnActivity {
+			continue
+		}
+		// the sandbox without timeout is never shut down
+		deadline := lastActive.Add(policy.timeout)
+		if timeout := sbx.GetTimeout(); timeout.IsZero() || !timeout.Before(deadline) {
+			continue
+		}
+		if err = sbx.SaveTimeout(ctx, time.Until(deadline)); err != nil {
+			log.Error(err, "failed to extend timeout of active sandbox", "sandboxID", route.ID)
+			continue
+		}
+		log.Info("timeout of active sandbox extended", "sandboxID", route.ID, "timeout", deadline)
+	}
+}
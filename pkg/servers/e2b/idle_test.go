@@ -0,0 +1,99 @@
+package e2b
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/sandbox-manager/infra/sandboxcr"
+	"github.com/openkruise/agents/pkg/servers/e2b/keys"
+	"github.com/openkruise/agents/pkg/servers/e2b/models"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIdlePolicyOf(t *testing.T) {
+	tests := []struct {
+		name               string
+		sandboxAnnotations map[string]string
+		expect             idlePolicy
+		expectOK           bool
+	}{
+		{
+			name: "no idle policy",
+		},
+		{
+			name: "recorded from template",
+			sandboxAnnotations: map[string]string{
+				agentsv1alpha1.AnnotationIdleTimeoutSeconds:      "900",
+				agentsv1alpha1.AnnotationExtendTimeoutOnActivity: agentsv1alpha1.True,
+			},
+			expect:   idlePolicy{timeout: 15 * time.Minute, extendOnActivity: true},
+			expectOK: true,
+		},
+		{
+			name:               "invalid",
+			sandboxAnnotations: map[string]string{agentsv1alpha1.AnnotationIdleTimeoutSeconds: "abc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sbx := &sandboxcr.Sandbox{Sandbox: &agentsv1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.sandboxAnnotations},
+			}}
+			policy, ok := idlePolicyOf(sbx)
+			assert.Equal(t, tt.expectOK, ok)
+			assert.Equal(t, tt.expect, policy)
+		})
+	}
+}
+
+func TestCheckIdleSandboxes(t *testing.T) {
+	templateName := "test-template"
+	controller, client, teardown := Setup(t)
+	defer teardown()
+	cleanup := CreateSandboxPool(t, client.SandboxClient, templateName, 2)
+	defer cleanup()
+	user := &models.CreatedTeamAPIKey{
+		ID:   keys.AdminKeyID,
+		Key:  InitKey,
+		Name: "admin",
+	}
+	ctx := context.Background()
+	controller.startTime = time.Now().Add(-time.Hour)
+
+	var sandboxIDs []string
+	for i := 0; i < 2; i++ {
+		createResp, apiErr := controller.CreateSandbox(NewRequest(t, nil, models.NewSandboxRequest{
+			TemplateID: templateName,
+			Timeout:    60,
+		}, nil, user))
+		assert.Nil(t, apiErr)
+		sandboxIDs = append(sandboxIDs, createResp.Body.SandboxID)
+		sbx := GetSandbox(t, createResp.Body.SandboxID, client.SandboxClient)
+		sbx.Annotations[agentsv1alpha1.AnnotationIdleTimeoutSeconds] = strconv.Itoa(600)
+		sbx.Annotations[agentsv1alpha1.AnnotationExtendTimeoutOnActivity] = agentsv1alpha1.True
+		sbx.Annotations[agentsv1alpha1.AnnotationClaimTime] = time.Now().Add(-time.Hour).Format(time.RFC3339)
+		_, err := client.ApiV1alpha1().Sandboxes(sbx.Namespace).Update(ctx, sbx, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+	}
+	idleID, activeID := sandboxIDs[0], sandboxIDs[1]
+	controller.manager.RecordActivity(activeID)
+	time.Sleep(50 * time.Millisecond)
+
+	describeResp, apiErr := controller.DescribeSandbox(NewRequest(t, nil, nil, map[string]string{"sandboxID": idleID}, user))
+	assert.Nil(t, apiErr)
+	assert.NotNil(t, describeResp.Body.IdleSeconds)
+	if describeResp.Body.IdleSeconds != nil {
+		assert.GreaterOrEqual(t, *describeResp.Body.IdleSeconds, int64(3600))
+	}
+
+	controller.checkIdleSandboxes(ctx)
+	idle := GetSandbox(t, idleID, client.SandboxClient)
+	assert.True(t, idle.Spec.Paused)
+	active := GetSandbox(t, activeID, client.SandboxClient)
+	assert.False(t, active.Spec.Paused)
+	assert.True(t, active.Spec.ShutdownTime.After(time.Now().Add(9*time.Minute)))
+}
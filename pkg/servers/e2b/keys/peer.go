@@ -0,0 +1,55 @@
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+var PeerSecretName = "sandbox-manager-peer-secret"
+
+const (
+	peerSecretKey  = "secret"
+	peerSecretSize = 32
+)
+
+// LoadPeerSecret returns the secret shared by the sandbox-manager peers to sign the requests between them, which is
+// generated on the first start. The secret can be rotated by deleting it and restarting all the peers.
+func LoadPeerSecret(ctx context.Context, client kubernetes.Interface, namespace string) ([]byte, error) {
+	log := klog.FromContext(ctx)
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, PeerSecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		key := make([]byte, peerSecretSize)
+		if _, err = rand.Read(key); err != nil {
+			return nil, err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      PeerSecretName,
+				Namespace: namespace,
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{peerSecretKey: key},
+		}
+		// all replicas does the same operation, and the one created first is used by all of them.
+		if _, err = client.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err == nil {
+			log.Info("peer secret created")
+		} else if apierrors.IsAlreadyExists(err) {
+			secret, err = client.CoreV1().Secrets(namespace).Get(ctx, PeerSecretName, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	key := secret.Data[peerSecretKey]
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key %s not found in secret %s/%s", peerSecretKey, namespace, PeerSecretName)
+	}
+	return key, nil
+}
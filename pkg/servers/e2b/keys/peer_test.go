@@ -0,0 +1,32 @@
+package keys
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadPeerSecret(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset()
+	secret, err := LoadPeerSecret(ctx, client, "default")
+	require.NoError(t, err)
+	assert.Len(t, secret, peerSecretSize)
+
+	// the other peers load the same secret
+	loaded, err := LoadPeerSecret(ctx, client, "default")
+	require.NoError(t, err)
+	assert.Equal(t, secret, loaded)
+
+	// a secret without the key is rejected
+	client = fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: PeerSecretName, Namespace: "default"},
+	})
+	_, err = LoadPeerSecret(ctx, client, "default")
+	assert.EqualError(t, err, "key secret not found in secret default/"+PeerSecretName)
+}
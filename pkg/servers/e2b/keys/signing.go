@@ -0,0 +1,226 @@
+package keys
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openkruise/agents/pkg/sandbox-manager/logs"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+var (
+	SigningKeySecretName = "e2b-signing-keys"
+	// SigningKeyRefreshInterval is how long a rotated signing key takes to be observed by all the replicas at most
+	SigningKeyRefreshInterval = time.Minute
+)
+
+const signingKeySize = 32
+
+// SigningKeyStorage signs and verifies the URLs of sandboxes with the HMAC keys in a k8s secret, keyed by key IDs.
+// The key with the greatest ID signs, and all the keys verify, so a key is rotated by adding a new one with a greater
+// ID, and deleting the old one after the URLs signed by it expire.
+type SigningKeyStorage struct {
+	Namespace string
+
+	Client kubernetes.Interface
+	Stop   chan struct{}
+
+	mu      sync.RWMutex
+	keys    map[string][]byte
+	current string
+}
+
+// signedPayload is the scope of a signed URL
+type signedPayload struct {
+	SandboxID  string `json:"sid"`
+	Port       int    `json:"port"`
+	PathPrefix string `json:"path,omitempty"`
+	ExpiresAt  int64  `json:"exp"`
+}
+
+// Init creates the secret with a generated key if not exists, and loads the keys.
+func (k *SigningKeyStorage) Init(ctx context.Context) error {
+	log := klog.FromContext(ctx)
+	log.Info("ensuring signing key secret")
+	_, err := k.Client.CoreV1().Secrets(k.Namespace).Get(ctx, SigningKeySecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		key := make([]byte, signingKeySize)
+		if _, err = rand.Read(key); err != nil {
+			return err
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      SigningKeySecretName,
+				Namespace: k.Namespace,
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				time.Now().UTC().Format("20060102150405"): key,
+			},
+		}
+		// all replicas does the same operation, no matter who eventually wins the race.
+		_, err = k.Client.CoreV1().Secrets(k.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			err = nil
+		} else if err == nil {
+			log.Info("signing key secret created")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return k.refresh(ctx)
+}
+
+func (k *SigningKeyStorage) refresh(ctx context.Context) error {
+	secret, err := k.Client.CoreV1().Secrets(k.Namespace).Get(ctx, SigningKeySecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	keys := make(map[string][]byte, len(secret.Data))
+	current := ""
+	for id, key := range secret.Data {
+		if len(key) == 0 {
+			continue
+		}
+		keys[id] = key
+		if id > current {
+			current = id
+		}
+	}
+	if current == "" {
+		return fmt.Errorf("no signing key found in secret %s/%s", k.Namespace, SigningKeySecretName)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if current != k.current {
+		klog.FromContext(ctx).Info("signing key rotated", "keyID", current, "keys", len(keys))
+	}
+	k.keys, k.current = keys, current
+	return nil
+}
+
+func (k *SigningKeyStorage) Run() {
+	go func() {
+		ticker := time.NewTicker(SigningKeyRefreshInterval)
+		ctx := logs.NewContext()
+		log := klog.FromContext(ctx)
+		for {
+			select {
+			case <-ticker.C:
+				if err := k.refresh(ctx); err != nil {
+					log.Error(err, "failed to refresh signing keys")
+				}
+			case <-k.Stop:
+				ticker.Stop()
+				log.Info("signing key refreshing stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Sign returns a token granting access to the port of the sandbox until expiresAt. The access is limited to the paths
+// under pathPrefix if not empty.
+func (k *SigningKeyStorage) Sign(sandboxID string, port int, pathPrefix string, expiresAt time.Time) (string, error) {
+	k.mu.RLock()
+	keyID, key := k.current, k.keys[k.current]
+	k.mu.RUnlock()
+	if keyID == "" {
+		return "", errors.New("signing key is not loaded")
+	}
+	raw, err := json.Marshal(signedPayload{
+		SandboxID:  sandboxID,
+		Port:       port,
+		PathPrefix: pathPrefix,
+		ExpiresAt:  expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := keyID + "." + base64.RawURLEncoding.EncodeToString(raw)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(key, signed)), nil
+}
+
+// Verify checks whether the token grants access to the path on the port of the sandbox.
+func (k *SigningKeyStorage) Verify(token, sandboxID string, port int, path string) error {
+	// key IDs may contain dots, so the token is parsed from the end
+	idx := strings.LastIndex(token, ".")
+	if idx < 0 {
+		return errors.New("malformed token")
+	}
+	signed, signature := token[:idx], token[idx+1:]
+	idx = strings.LastIndex(signed, ".")
+	if idx < 0 {
+		return errors.New("malformed token")
+	}
+	keyID, encoded := signed[:idx], signed[idx+1:]
+
+	k.mu.RLock()
+	key, ok := k.keys[keyID]
+	k.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown signing key %s", keyID)
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, sign(key, signed)) {
+		return errors.New("invalid signature")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	var payload signedPayload
+	if err = json.Unmarshal(raw, &payload); err != nil {
+		return errors.New("malformed token")
+	}
+	if time.Now().Unix() >= payload.ExpiresAt {
+		return errors.New("token expired")
+	}
+	if payload.SandboxID != sandboxID || payload.Port != port {
+		return errors.New("token is not for the sandbox port")
+	}
+	if !matchPathPrefix(path, payload.PathPrefix) {
+		return errors.New("token is not for the path")
+	}
+	return nil
+}
+
+func sign(key []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// matchPathPrefix returns whether the path, with the query ignored, is under the prefix. The path is unescaped and
+// cleaned first, so that the dot segments, even escaped ones like "/app/%2e%2e/admin", cannot escape the prefix.
+func matchPathPrefix(requested, prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	if idx := strings.IndexAny(requested, "?#"); idx >= 0 {
+		requested = requested[:idx]
+	}
+	unescaped, err := url.PathUnescape(requested)
+	if err != nil || strings.Contains(unescaped, "\\") {
+		return false
+	}
+	requested = path.Clean("/" + unescaped)
+	prefix = strings.TrimSuffix(prefix, "/")
+	return requested == prefix || strings.HasPrefix(requested, prefix+"/")
+}
@@ -0,0 +1,192 @@
+package keys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSigningKeyStorage_SignAndVerify(t *testing.T) {
+	storage := &SigningKeyStorage{
+		Namespace: "default",
+		Client:    fake.NewClientset(),
+		Stop:      make(chan struct{}),
+	}
+	require.NoError(t, storage.Init(context.Background()))
+	secret, err := storage.Client.CoreV1().Secrets("default").Get(context.Background(), SigningKeySecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Len(t, secret.Data, 1)
+
+	token, err := storage.Sign("sandbox1", 3000, "/app", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	expired, err := storage.Sign("sandbox1", 3000, "", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		token     string
+		sandboxID string
+		port      int
+		path      string
+		expectErr string
+	}{
+		{
+			name:      "valid",
+			token:     token,
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/app/index.html?kruise_signature=xxx",
+		},
+		{
+			name:      "valid with prefix itself",
+			token:     token,
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/app",
+		},
+		{
+			name:      "path out of prefix",
+			token:     token,
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/apple",
+			expectErr: "token is not for the path",
+		},
+		{
+			name:      "dot segments out of prefix",
+			token:     token,
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/app/../admin",
+			expectErr: "token is not for the path",
+		},
+		{
+			name:      "escaped dot segments out of prefix",
+			token:     token,
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/app/%2e%2e/admin?kruise_signature=xxx",
+			expectErr: "token is not for the path",
+		},
+		{
+			name:      "dot segments within prefix",
+			token:     token,
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/app/assets/../index.html",
+		},
+		{
+			name:      "malformed escape",
+			token:     token,
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/app/%zz",
+			expectErr: "token is not for the path",
+		},
+		{
+			name:      "another sandbox",
+			token:     token,
+			sandboxID: "sandbox2",
+			port:      3000,
+			path:      "/app",
+			expectErr: "token is not for the sandbox port",
+		},
+		{
+			name:      "another port",
+			token:     token,
+			sandboxID: "sandbox1",
+			port:      3001,
+			path:      "/app",
+			expectErr: "token is not for the sandbox port",
+		},
+		{
+			name:      "expired",
+			token:     expired,
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/",
+			expectErr: "token expired",
+		},
+		{
+			name:      "tampered",
+			token:     token[:len(token)-2] + "xx",
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/app",
+			expectErr: "invalid signature",
+		},
+		{
+			name:      "unknown key",
+			token:     "unknown.e30.xxx",
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/app",
+			expectErr: "unknown signing key unknown",
+		},
+		{
+			name:      "malformed",
+			token:     "malformed",
+			sandboxID: "sandbox1",
+			port:      3000,
+			path:      "/app",
+			expectErr: "malformed token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := storage.Verify(tt.token, tt.sandboxID, tt.port, tt.path)
+			if tt.expectErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectErr)
+			}
+		})
+	}
+}
+
+func TestSigningKeyStorage_Rotate(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset()
+	storage := &SigningKeyStorage{
+		Namespace: "default",
+		Client:    client,
+		Stop:      make(chan struct{}),
+	}
+	require.NoError(t, storage.Init(ctx))
+	oldKeyID := storage.current
+	oldToken, err := storage.Sign("sandbox1", 3000, "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	// a new key with a greater id is added
+	secret, err := client.CoreV1().Secrets("default").Get(ctx, SigningKeySecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	secret.Data["99990101000000"] = []byte("new-key")
+	_, err = client.CoreV1().Secrets("default").Update(ctx, secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, storage.refresh(ctx))
+	assert.Equal(t, "99990101000000", storage.current)
+	newToken, err := storage.Sign("sandbox1", 3000, "", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.NoError(t, storage.Verify(oldToken, "sandbox1", 3000, "/"))
+	assert.NoError(t, storage.Verify(newToken, "sandbox1", 3000, "/"))
+
+	// the old key is deleted
+	delete(secret.Data, oldKeyID)
+	_, err = client.CoreV1().Secrets("default").Update(ctx, secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, storage.refresh(ctx))
+	assert.Error(t, storage.Verify(oldToken, "sandbox1", 3000, "/"))
+	assert.NoError(t, storage.Verify(newToken, "sandbox1", 3000, "/"))
+
+	// a secret without keys is rejected, and the loaded keys are kept
+	secret.Data = nil
+	_, err = client.CoreV1().Secrets("default").Update(ctx, secret, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.Error(t, storage.refresh(ctx))
+	assert.NoError(t, storage.Verify(newToken, "sandbox1", 3000, "/"))
+}
@@ -2,4 +2,12 @@ package models
 
 const (
 	DefaultMaxTimeout = 2592000 // 30 days
+	// DefaultTimeout is the timeout of a sandbox if not specified by the request or the SandboxTemplate
+	DefaultTimeout = 300
+	// DefaultPausedRetention is how long an auto-paused sandbox is retained, the same as the E2B hosted service
+	DefaultPausedRetention = 2592000 // 30 days
+	// MaxColdStartTimeout is the max seconds a request waits for a sandbox created on demand
+	MaxColdStartTimeout = 600
+	// MaxClaimWait is the max seconds a request waits for an available sandbox
+	MaxClaimWait = 600
 )
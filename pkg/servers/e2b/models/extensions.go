@@ -3,11 +3,14 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/distribution/reference"
 	"github.com/openkruise/agents/api/v1alpha1"
 	"github.com/openkruise/agents/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 // Extension keys are annotations used by sandbox-manager only.
@@ -15,6 +18,13 @@ import (
 const (
 	ExtensionKeyClaimWithImage    = v1alpha1.E2BPrefix + "image"
 	ExtensionKeyClaimWithCSIMount = v1alpha1.E2BPrefix + "csi"
+	ExtensionKeyClaimWithCPU      = v1alpha1.E2BPrefix + "cpu"
+	ExtensionKeyClaimWithMemory   = v1alpha1.E2BPrefix + "memory"
+	// ExtensionKeyClaimColdStartTimeout creates a sandbox on demand and waits for it for the seconds at most if the
+	// template has no available sandbox, which overrides the cold start timeout of the SandboxSet
+	ExtensionKeyClaimColdStartTimeout = v1alpha1.E2BPrefix + "cold-start-timeout"
+	// ExtensionKeyClaimMaxWait waits for an available sandbox for the seconds at most if the template has none
+	ExtensionKeyClaimMaxWait = v1alpha1.E2BPrefix + "max-wait"
 )
 
 // Extensions for NewSandboxRequest
@@ -31,6 +41,22 @@ func (r *NewSandboxRequest) ParseExtensions() error {
 			if err := r.parseExtensionCSIMount(v); err != nil {
 				return err
 			}
+		case ExtensionKeyClaimWithCPU:
+			if err := r.parseExtensionResource(corev1.ResourceCPU, v); err != nil {
+				return err
+			}
+		case ExtensionKeyClaimWithMemory:
+			if err := r.parseExtensionResource(corev1.ResourceMemory, v); err != nil {
+				return err
+			}
+		case ExtensionKeyClaimColdStartTimeout:
+			if err := r.parseExtensionColdStartTimeout(v); err != nil {
+				return err
+			}
+		case ExtensionKeyClaimMaxWait:
+			if err := r.parseExtensionMaxWait(v); err != nil {
+				return err
+			}
 		default:
 			isExtension = false
 		}
@@ -49,6 +75,39 @@ func (r *NewSandboxRequest) parseExtensionImage(image string) error {
 	return nil
 }
 
+func (r *NewSandboxRequest) parseExtensionResource(name corev1.ResourceName, raw string) error {
+	quantity, err := resource.ParseQuantity(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %s [%s]: %v", name, raw, err)
+	}
+	if quantity.Sign() <= 0 {
+		return fmt.Errorf("invalid %s [%s]: must be positive", name, raw)
+	}
+	if r.Extensions.Resources == nil {
+		r.Extensions.Resources = corev1.ResourceList{}
+	}
+	r.Extensions.Resources[name] = quantity
+	return nil
+}
+
+func (r *NewSandboxRequest) parseExtensionColdStartTimeout(raw string) error {
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 || seconds > MaxColdStartTimeout {
+		return fmt.Errorf("invalid cold start timeout [%s]: must be an integer between 1 and %d", raw, MaxColdStartTimeout)
+	}
+	r.Extensions.ColdStartTimeout = seconds
+	return nil
+}
+
+func (r *NewSandboxRequest) parseExtensionMaxWait(raw string) error {
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 || seconds > MaxClaimWait {
+		return fmt.Errorf("invalid max wait [%s]: must be an integer between 1 and %d", raw, MaxClaimWait)
+	}
+	r.Extensions.MaxWait = seconds
+	return nil
+}
+
 func (r *NewSandboxRequest) parseExtensionCSIMount(raw string) error {
 	if err := json.Unmarshal([]byte(raw), &r.Extensions.CSIMount); err != nil {
 		return fmt.Errorf("cannot unmarshal storage-mount extension into go map: %s", err.Error())
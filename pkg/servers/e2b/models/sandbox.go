@@ -1,6 +1,8 @@
 // Package models provides data models for the E2B sandbox API.
 package models
 
+import corev1 "k8s.io/api/core/v1"
+
 const (
 	SandboxStateRunning = "running"
 	SandboxStatePaused  = "paused"
@@ -22,6 +24,8 @@ type Sandbox struct {
 	Alias           string            `json:"alias"`
 	Metadata        map[string]string `json:"metadata"`
 	State           string            `json:"state"`
+	// IdleSeconds is how long the running sandbox has not been requested through the proxy
+	IdleSeconds *int64 `json:"idleSeconds,omitempty"`
 }
 
 // NewSandboxRequest represents a request to create a new sandbox
@@ -37,8 +41,13 @@ type NewSandboxRequest struct {
 }
 
 type NewSandboxRequestExtension struct {
-	Image    string
-	CSIMount CSIMountExtension
+	Image     string
+	Resources corev1.ResourceList
+	CSIMount  CSIMountExtension
+	// ColdStartTimeout is in seconds, zero follows the SandboxSet
+	ColdStartTimeout int
+	// MaxWait is in seconds, zero follows the sandbox-manager
+	MaxWait int
 }
 
 type CSIMountExtension struct {
@@ -55,10 +64,3 @@ type EnvVars map[string]string
 type SetTimeoutRequest struct {
 	TimeoutSeconds int `json:"timeout"`
 }
-
-const (
-	// EnvdPort is the port used for envd communication
-	EnvdPort = 49983
-	// CDPPort is the port used for CDP (Chrome DevTools Port) communication
-	CDPPort = 9222
-)
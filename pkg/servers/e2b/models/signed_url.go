@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+const (
+	// DefaultSignedURLExpiry is the expiry of a signed URL if not specified
+	DefaultSignedURLExpiry = 3600
+	// MaxSignedURLExpiry is the max expiry of a signed URL
+	MaxSignedURLExpiry = 604800 // 7 days
+)
+
+// NewSignedURLRequest represents a request to sign a URL of a sandbox port
+type NewSignedURLRequest struct {
+	Port int `json:"port"`
+	// PathPrefix limits the access to the paths under it if not empty
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// Expiry is the seconds the URL is valid for
+	Expiry int `json:"expiry,omitempty"`
+}
+
+// SignedURL grants access to a sandbox port without API keys or access tokens until it expires
+type SignedURL struct {
+	URL       string    `json:"url"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
@@ -1,8 +1,10 @@
 package e2b
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/openkruise/agents/api/v1alpha1"
 	"github.com/openkruise/agents/pkg/servers/e2b/models"
@@ -69,6 +71,7 @@ func (sc *Controller) ResumeSandbox(r *http.Request) (web.ApiResponse[struct{}],
 		}
 	}
 	log.Info("sandbox resumed")
+	sc.manager.RecordActivity(sbx.GetSandboxID())
 	return web.ApiResponse[struct{}]{
 		Code: http.StatusNoContent,
 	}, nil
@@ -100,6 +103,7 @@ func (sc *Controller) ConnectSandbox(r *http.Request) (web.ApiResponse[*models.S
 		}
 		statusCode = http.StatusCreated
 		log.Info("sandbox resumed")
+		sc.manager.RecordActivity(sbx.GetSandboxID())
 	} else {
 		log.Info("sandbox is not paused, skip resuming", "state", state, "reason", reason)
 	}
@@ -114,3 +118,26 @@ func (sc *Controller) ConnectSandbox(r *http.Request) (web.ApiResponse[*models.S
 		Body: sc.convertToE2BSandbox(sbx, sbx.GetAnnotations()[v1alpha1.AnnotationEnvdAccessToken]),
 	}, nil
 }
+
+// wakeSandbox resumes a paused sandbox on its request through the proxy. The timeout of the sandbox is reset to the
+// default one, otherwise it would be paused again immediately after an auto pause.
+func (sc *Controller) wakeSandbox(ctx context.Context, sandboxID string) error {
+	log := klog.FromContext(ctx)
+	sbx, err := sc.manager.GetInfra().GetSandbox(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	if state, _ := sbx.GetState(); state == v1alpha1.SandboxStateRunning {
+		return nil
+	}
+	timeout := defaultTimeoutOf(sbx.GetAnnotations(), models.DefaultTimeout, sc.maxTimeout)
+	if err = sbx.SaveTimeout(ctx, time.Duration(timeout)*time.Second); err != nil {
+		log.Error(err, "failed to reset sandbox timeout")
+		return err
+	}
+	if err = sbx.Resume(ctx); err != nil {
+		return err
+	}
+	sc.manager.RecordActivity(sandboxID)
+	return nil
+}
@@ -314,3 +314,56 @@ func TestResumeSandbox(t *testing.T) {
 		})
 	}
 }
+
+func TestWakeSandbox(t *testing.T) {
+	templateName := "test-template"
+	controller, client, teardown := Setup(t)
+	defer teardown()
+	cleanup := CreateSandboxPool(t, client.SandboxClient, templateName, 1)
+	defer cleanup()
+	user := &models.CreatedTeamAPIKey{
+		ID:   keys.AdminKeyID,
+		Key:  InitKey,
+		Name: "admin",
+	}
+	createResp, apiErr := controller.CreateSandbox(NewRequest(t, nil, models.NewSandboxRequest{
+		TemplateID: templateName,
+		AutoPause:  true,
+	}, nil, user))
+	assert.Nil(t, apiErr)
+	ctx := context.Background()
+	sandboxID := createResp.Body.SandboxID
+
+	// running sandbox is not touched
+	before := GetSandbox(t, sandboxID, client.SandboxClient)
+	assert.NoError(t, controller.wakeSandbox(ctx, sandboxID))
+	after := GetSandbox(t, sandboxID, client.SandboxClient)
+	assert.Equal(t, before.ResourceVersion, after.ResourceVersion)
+
+	// paused sandbox is resumed
+	req := NewRequest(t, nil, nil, map[string]string{"sandboxID": sandboxID}, user)
+	_, apiErr = controller.PauseSandbox(req)
+	assert.Nil(t, apiErr)
+	sbx := GetSandbox(t, sandboxID, client.SandboxClient)
+	sbx.Spec.ShutdownTime = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+	sbx, err := client.ApiV1alpha1().Sandboxes(sbx.Namespace).Update(ctx, sbx, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	sbx.Status.Phase = agentsv1alpha1.SandboxPaused
+	sbx.Status.Conditions = append(sbx.Status.Conditions, metav1.Condition{
+		Type:   string(agentsv1alpha1.SandboxConditionPaused),
+		Status: metav1.ConditionTrue,
+	})
+	_, err = client.ApiV1alpha1().Sandboxes(sbx.Namespace).UpdateStatus(ctx, sbx, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	time.AfterFunc(150*time.Millisecond, func() {
+		sbx := GetSandbox(t, sandboxID, client.SandboxClient)
+		sbx.Status.Phase = agentsv1alpha1.SandboxRunning
+		_, _ = client.ApiV1alpha1().Sandboxes(sbx.Namespace).UpdateStatus(context.Background(), sbx, metav1.UpdateOptions{})
+	})
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, controller.wakeSandbox(ctx, sandboxID))
+	sbx = GetSandbox(t, sandboxID, client.SandboxClient)
+	assert.False(t, sbx.Spec.Paused)
+
+	assert.Error(t, controller.wakeSandbox(ctx, "default--not-exist"))
+}
@@ -0,0 +1,149 @@
+package e2b
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/sandbox-manager/infra"
+	"github.com/openkruise/agents/pkg/servers/e2b/models"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// InitSecretSuffix is the suffix of the Secret which keeps the claim-time init inputs of a sandbox.
+	InitSecretSuffix = "-init"
+
+	initSecretKeyEnvVars  = "envVars"
+	initSecretKeyCSIMount = "csiMount"
+)
+
+// initInputs are the inputs of envd init and CSI mount at claim time, which are lost when the pod is recreated.
+type initInputs struct {
+	EnvVars  models.EnvVars
+	CSIMount models.CSIMountExtension
+}
+
+func initSecretName(sbx infra.Sandbox) string {
+	return sbx.GetName() + InitSecretSuffix
+}
+
+// shouldInitEnvd returns whether envd of the sandbox is initialized at claim time.
+func shouldInitEnvd(pool infra.SandboxPool, sbx infra.Sandbox) bool {
+	return (pool != nil && pool.GetAnnotations()[v1alpha1.AnnotationShouldInitEnvd] == v1alpha1.True) ||
+		sbx.GetAnnotations()[v1alpha1.AnnotationShouldInitEnvd] == v1alpha1.True
+}
+
+// saveInitInputs keeps the init inputs in a Secret owned by the sandbox, since env vars may contain credentials.
+func (sc *Controller) saveInitInputs(ctx context.Context, sbx infra.Sandbox, inputs initInputs) error {
+	data := make(map[string][]byte, 2)
+	if len(inputs.EnvVars) > 0 {
+		raw, err := json.Marshal(inputs.EnvVars)
+		if err != nil {
+			return err
+		}
+		data[initSecretKeyEnvVars] = raw
+	}
+	if inputs.CSIMount.Driver != "" {
+		raw, err := json.Marshal(inputs.CSIMount)
+		if err != nil {
+			return err
+		}
+		data[initSecretKeyCSIMount] = raw
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      initSecretName(sbx),
+			Namespace: sbx.GetNamespace(),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(sbx, v1alpha1.GroupVersion.WithKind("Sandbox")),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	secrets := sc.client.K8sClient.CoreV1().Secrets(sbx.GetNamespace())
+	_, err := secrets.Create(ctx, secret, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		// left by a deleted sandbox with the same name and not garbage collected yet
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+func (sc *Controller) loadInitInputs(ctx context.Context, sbx infra.Sandbox) (initInputs, error) {
+	inputs := initInputs{}
+	secret, err := sc.client.K8sClient.CoreV1().Secrets(sbx.GetNamespace()).Get(ctx, initSecretName(sbx), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return inputs, nil
+	}
+	if err != nil {
+		return inputs, err
+	}
+	if raw, ok := secret.Data[initSecretKeyEnvVars]; ok {
+		if err = json.Unmarshal(raw, &inputs.EnvVars); err != nil {
+			return inputs, err
+		}
+	}
+	if raw, ok := secret.Data[initSecretKeyCSIMount]; ok {
+		if err = json.Unmarshal(raw, &inputs.CSIMount); err != nil {
+			return inputs, err
+		}
+	}
+	return inputs, nil
+}
+
+// reinitSandbox replays the claim-time envd init and CSI mount in the new pod of a resumed or restarted sandbox, and
+// then marks the pod inited, which makes the sandbox running. It is the SandboxReinitHandler of the infra, so that the
+// sandboxes resumed without the E2B API, e.g. by kubectl, are re-inited as well.
+func (sc *Controller) reinitSandbox(ctx context.Context, sbx infra.Sandbox) error {
+	log := klog.FromContext(ctx).WithValues("sandboxID", sbx.GetSandboxID())
+	annotations := sbx.GetAnnotations()
+	accessToken, ok := annotations[v1alpha1.AnnotationEnvdAccessToken]
+	if !ok {
+		// not claimed by e2b
+		return nil
+	}
+	route := sbx.GetRoute()
+	envdURL := fmt.Sprintf("http://%s:%d", route.IP, envdPortOf(sbx))
+	if err := sbx.SaveAnnotations(ctx, map[string]string{
+		v1alpha1.AnnotationEnvdURL: envdURL,
+	}); err != nil {
+		log.Error(err, "failed to save envd url")
+		return err
+	}
+	inputs, err := sc.loadInitInputs(ctx, sbx)
+	if err != nil {
+		log.Error(err, "failed to load init inputs")
+		return err
+	}
+	pool, _ := sc.manager.GetInfra().GetPoolByObject(sbx)
+	if shouldInitEnvd(pool, sbx) {
+		if err = sc.initEnvd(ctx, sbx, inputs.EnvVars, accessToken); err != nil {
+			return err
+		}
+	}
+	if inputs.CSIMount.Driver != "" {
+		if err = sbx.CSIMount(ctx, inputs.CSIMount.Driver, inputs.CSIMount.Request); err != nil {
+			log.Error(err, "failed to mount storage")
+			return err
+		}
+	}
+	// the envd url is saved again, in case the cache has not observed the former update yet
+	if err = sbx.SaveAnnotations(ctx, map[string]string{
+		v1alpha1.AnnotationEnvdURL:   envdURL,
+		v1alpha1.AnnotationInitedPod: sbx.GetPodUID(),
+	}); err != nil {
+		log.Error(err, "failed to mark the pod inited")
+		return err
+	}
+	log.Info("sandbox re-inited")
+	return nil
+}
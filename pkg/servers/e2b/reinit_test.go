@@ -0,0 +1,105 @@
+package e2b
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/servers/e2b/keys"
+	"github.com/openkruise/agents/pkg/servers/e2b/models"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInitInputs(t *testing.T) {
+	templateName := "test-template"
+	controller, client, teardown := Setup(t)
+	defer teardown()
+	cleanup := CreateSandboxPool(t, client.SandboxClient, templateName, 2)
+	defer cleanup()
+	user := &models.CreatedTeamAPIKey{
+		ID:   keys.AdminKeyID,
+		Key:  InitKey,
+		Name: "admin",
+	}
+	createResp, apiErr := controller.CreateSandbox(NewRequest(t, nil, models.NewSandboxRequest{
+		TemplateID: templateName,
+		EnvVars:    models.EnvVars{"TEST_ENV": "test-value"},
+	}, nil, user))
+	assert.Nil(t, apiErr)
+	ctx := context.Background()
+	sbx, err := controller.manager.GetInfra().GetSandbox(ctx, createResp.Body.SandboxID)
+	assert.NoError(t, err)
+
+	// envd is not inited in the pool, so nothing to be replayed
+	_, err = client.K8sClient.CoreV1().Secrets(sbx.GetNamespace()).Get(ctx, sbx.GetName()+InitSecretSuffix, metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	inputs, err := controller.loadInitInputs(ctx, sbx)
+	assert.NoError(t, err)
+	assert.Equal(t, initInputs{}, inputs)
+
+	expected := initInputs{
+		EnvVars:  models.EnvVars{"TEST_ENV": "test-value"},
+		CSIMount: models.CSIMountExtension{Driver: "test-driver", Request: "dGVzdA=="},
+	}
+	assert.NoError(t, controller.saveInitInputs(ctx, sbx, expected))
+	secret, err := client.K8sClient.CoreV1().Secrets(sbx.GetNamespace()).Get(ctx, sbx.GetName()+InitSecretSuffix, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, secret.OwnerReferences, 1)
+	assert.Equal(t, sbx.GetName(), secret.OwnerReferences[0].Name)
+	inputs, err = controller.loadInitInputs(ctx, sbx)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, inputs)
+
+	// saved again by a sandbox with the same name
+	expected.CSIMount = models.CSIMountExtension{}
+	assert.NoError(t, controller.saveInitInputs(ctx, sbx, expected))
+	inputs, err = controller.loadInitInputs(ctx, sbx)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, inputs)
+}
+
+func TestReinitSandbox(t *testing.T) {
+	templateName := "test-template"
+	controller, client, teardown := Setup(t)
+	defer teardown()
+	cleanup := CreateSandboxPool(t, client.SandboxClient, templateName, 2)
+	defer cleanup()
+	user := &models.CreatedTeamAPIKey{
+		ID:   keys.AdminKeyID,
+		Key:  InitKey,
+		Name: "admin",
+	}
+	createResp, apiErr := controller.CreateSandbox(NewRequest(t, nil, models.NewSandboxRequest{
+		TemplateID: templateName,
+	}, nil, user))
+	assert.Nil(t, apiErr)
+	ctx := context.Background()
+
+	// the pod is recreated with a new ip, e.g. resumed by kubectl
+	obj := GetSandbox(t, createResp.Body.SandboxID, client.SandboxClient)
+	assert.Contains(t, obj.Annotations, agentsv1alpha1.AnnotationInitedPod)
+	obj.Status.PodInfo.PodIP = "5.6.7.8"
+	obj.Status.PodInfo.PodUID = "recreated-pod"
+	_, err := client.SandboxClient.ApiV1alpha1().Sandboxes(obj.Namespace).UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	// re-inited by the update handler, which makes the sandbox running again
+	assert.Eventually(t, func() bool {
+		obj = GetSandbox(t, createResp.Body.SandboxID, client.SandboxClient)
+		return obj.Annotations[agentsv1alpha1.AnnotationInitedPod] == "recreated-pod"
+	}, time.Second, 20*time.Millisecond)
+	assert.Equal(t, fmt.Sprintf("http://5.6.7.8:%d", agentsv1alpha1.DefaultEnvdPort), obj.Annotations[agentsv1alpha1.AnnotationEnvdURL])
+	assert.Equal(t, createResp.Body.EnvdAccessToken, obj.Annotations[agentsv1alpha1.AnnotationEnvdAccessToken])
+	assert.Eventually(t, func() bool {
+		sbx, err := controller.manager.GetInfra().GetSandbox(ctx, createResp.Body.SandboxID)
+		if err != nil {
+			return false
+		}
+		state, _ := sbx.GetState()
+		return state == agentsv1alpha1.SandboxStateRunning
+	}, time.Second, 20*time.Millisecond)
+}
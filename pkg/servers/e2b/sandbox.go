@@ -8,13 +8,17 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/openkruise/agents/api/v1alpha1"
 	"github.com/openkruise/agents/pkg/sandbox-manager/infra"
 	"github.com/openkruise/agents/pkg/servers/e2b/models"
 	"github.com/openkruise/agents/pkg/servers/web"
+	"github.com/openkruise/agents/pkg/utils/sandboxutils"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 )
 
 var (
@@ -38,6 +42,17 @@ func (sc *Controller) getSandboxOfUser(ctx context.Context, sandboxID string) (i
 	return sbx, nil
 }
 
+// envdPortOf returns the envd port declared by the exposed ports of the sandbox, or the default one.
+func envdPortOf(sbx infra.Sandbox) int {
+	return int(sandboxutils.GetPortByName(sbx.GetAnnotations(), v1alpha1.SandboxPortNameEnvd, v1alpha1.DefaultEnvdPort))
+}
+
+// cdpPortOf returns the CDP (Chrome DevTools Protocol) port declared by the exposed ports of the sandbox, or the
+// default one.
+func cdpPortOf(sbx infra.Sandbox) int {
+	return int(sandboxutils.GetPortByName(sbx.GetAnnotations(), v1alpha1.SandboxPortNameCDP, v1alpha1.DefaultCDPPort))
+}
+
 func (sc *Controller) initEnvd(ctx context.Context, sbx infra.Sandbox, envVars models.EnvVars, accessToken string) error {
 	start := time.Now()
 	log := klog.FromContext(ctx).WithValues("sandboxID", sbx.GetName(), "envVars", envVars)
@@ -54,7 +69,7 @@ func (sc *Controller) initEnvd(ctx context.Context, sbx infra.Sandbox, envVars m
 		log.Error(err, "failed to create init request")
 		return err
 	}
-	_, err = sbx.Request(request, "/init", models.EnvdPort)
+	_, err = sbx.Request(request, "/init", envdPortOf(sbx))
 	if err != nil {
 		log.Error(err, "failed to init envd")
 		return err
@@ -72,6 +87,9 @@ func (sc *Controller) convertToE2BSandbox(sbx infra.Sandbox, accessToken string)
 		EnvdAccessToken: accessToken,
 	}
 	sandbox.State, _ = sbx.GetState()
+	if sandbox.State == v1alpha1.SandboxStateRunning {
+		sandbox.IdleSeconds = ptr.To(int64(time.Since(sc.lastActiveTime(sbx)).Seconds()))
+	}
 	annotations := sbx.GetAnnotations()
 	labels := sbx.GetLabels()
 
@@ -104,6 +122,16 @@ func (sc *Controller) convertToE2BSandbox(sbx infra.Sandbox, accessToken string)
 	return sandbox
 }
 
+// defaultTimeoutOf returns the default timeout recorded in the annotations of a sandbox created from a SandboxTemplate,
+// it falls back to fallback if not recorded or invalid, and is limited to maxTimeout.
+func defaultTimeoutOf(annotations map[string]string, fallback, maxTimeout int) int {
+	timeout, err := strconv.Atoi(annotations[v1alpha1.AnnotationDefaultTimeoutSeconds])
+	if err != nil || timeout < 30 {
+		return fallback
+	}
+	return min(timeout, maxTimeout)
+}
+
 func ValidateMetadataKey(key string) bool {
 	for _, prefix := range BlackListPrefix {
 		if strings.HasPrefix(key, prefix) {
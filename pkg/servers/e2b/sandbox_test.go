@@ -2,6 +2,9 @@ package e2b
 
 import (
 	"testing"
+
+	"github.com/openkruise/agents/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestReplacer(t *testing.T) {
@@ -11,3 +14,22 @@ func TestReplacer(t *testing.T) {
 		t.Errorf("Expected %s, got %s", "ws://hello-world/devtools/browser/12345678-1234-1234-1234-123456789012", url)
 	}
 }
+
+func TestDefaultTimeoutOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expect      int
+	}{
+		{name: "not recorded", annotations: nil, expect: 300},
+		{name: "recorded", annotations: map[string]string{v1alpha1.AnnotationDefaultTimeoutSeconds: "600"}, expect: 600},
+		{name: "limited to max timeout", annotations: map[string]string{v1alpha1.AnnotationDefaultTimeoutSeconds: "9000"}, expect: 7200},
+		{name: "too small", annotations: map[string]string{v1alpha1.AnnotationDefaultTimeoutSeconds: "10"}, expect: 300},
+		{name: "invalid", annotations: map[string]string{v1alpha1.AnnotationDefaultTimeoutSeconds: "abc"}, expect: 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, defaultTimeoutOf(tt.annotations, 300, 7200))
+		})
+	}
+}
@@ -14,7 +14,6 @@ import (
 	"github.com/openkruise/agents/pkg/sandbox-manager/infra"
 	"github.com/openkruise/agents/pkg/servers/e2b/models"
 	"github.com/openkruise/agents/pkg/servers/web"
-	"github.com/openkruise/agents/pkg/utils"
 	managerutils "github.com/openkruise/agents/pkg/utils/sandbox-manager"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/klog/v2"
@@ -62,8 +61,10 @@ func (sc *Controller) CreateSandbox(r *http.Request) (web.ApiResponse[*models.Sa
 		}
 	}
 
-	if request.Timeout == 0 {
-		request.Timeout = 300
+	// the default timeout may be overridden by the SandboxTemplate of the claimed sandbox
+	timeoutDefaulted := request.Timeout == 0
+	if timeoutDefaulted {
+		request.Timeout = models.DefaultTimeout
 	}
 
 	if request.Timeout < 30 || request.Timeout > sc.maxTimeout {
@@ -73,24 +74,48 @@ func (sc *Controller) CreateSandbox(r *http.Request) (web.ApiResponse[*models.Sa
 		}
 	}
 
+	maxWait := sc.claimMaxWait
+	if request.Extensions.MaxWait > 0 {
+		maxWait = time.Duration(request.Extensions.MaxWait) * time.Second
+	}
+	var weight int
+	if len(sc.claimWeights) > 0 {
+		weight = max(sc.claimWeights[user.ID.String()], 1)
+	}
+
 	accessToken := uuid.NewString()
 	claimStart := time.Now()
 	sbx, err := sc.manager.ClaimSandbox(ctx, user.ID.String(), request.TemplateID, infra.ClaimSandboxOptions{
 		Modifier: func(sbx infra.Sandbox) {
-			sbx.SetTimeout(time.Duration(request.Timeout) * time.Second)
 			annotations := sbx.GetAnnotations()
 			if annotations == nil {
 				annotations = make(map[string]string)
 			}
+			timeout := request.Timeout
+			if timeoutDefaulted {
+				timeout = defaultTimeoutOf(annotations, timeout, sc.maxTimeout)
+			}
+			sbx.SetTimeout(time.Duration(timeout) * time.Second)
+			if request.AutoPause {
+				sbx.SetAutoPause(models.DefaultPausedRetention * time.Second)
+			}
 			for k, v := range request.Metadata {
 				annotations[k] = v
 			}
 			annotations[v1alpha1.AnnotationEnvdAccessToken] = accessToken
+			annotations[v1alpha1.AnnotationInitedPod] = sbx.GetPodUID()
+			if request.Secure {
+				annotations[v1alpha1.AnnotationSecure] = v1alpha1.True
+			}
 			route := sbx.GetRoute()
-			annotations[v1alpha1.AnnotationEnvdURL] = fmt.Sprintf("http://%s:%d", route.IP, models.EnvdPort)
+			annotations[v1alpha1.AnnotationEnvdURL] = fmt.Sprintf("http://%s:%d", route.IP, envdPortOf(sbx))
 			sbx.SetAnnotations(annotations)
 		},
-		Image: request.Extensions.Image,
+		Image:            request.Extensions.Image,
+		Resources:        request.Extensions.Resources,
+		ColdStartTimeout: time.Duration(request.Extensions.ColdStartTimeout) * time.Second,
+		MaxWait:          maxWait,
+		Weight:           weight,
 	})
 	if err != nil {
 		return web.ApiResponse[*models.Sandbox]{}, &web.ApiError{
@@ -108,7 +133,9 @@ func (sc *Controller) CreateSandbox(r *http.Request) (web.ApiResponse[*models.Sa
 			Message: "Failed to get sandbox pool",
 		}
 	}
-	if pool.GetAnnotations()[v1alpha1.AnnotationShouldInitEnvd] == utils.True {
+	inputs := initInputs{CSIMount: request.Extensions.CSIMount}
+	if shouldInitEnvd(pool, sbx) {
+		inputs.EnvVars = request.EnvVars
 		if err = sc.initEnvd(ctx, sbx, request.EnvVars, accessToken); err != nil {
 			log.Error(err, "failed to init envd")
 			return web.ApiResponse[*models.Sandbox]{}, &web.ApiError{
@@ -138,6 +165,17 @@ func (sc *Controller) CreateSandbox(r *http.Request) (web.ApiResponse[*models.Sa
 		mountCost = time.Since(mountStart)
 		log.Info("storage mounted")
 	}
+
+	// the init inputs are replayed when the sandbox is resumed or restarted with a new pod
+	if err = sc.saveInitInputs(ctx, sbx, inputs); err != nil {
+		log.Error(err, "failed to save init inputs")
+		if err := sbx.Kill(ctx); err != nil {
+			log.Error(err, "failed to kill sandbox", "id", sbx.GetSandboxID())
+		}
+		return web.ApiResponse[*models.Sandbox]{}, &web.ApiError{
+			Message: err.Error(),
+		}
+	}
 	log.Info("sandbox allocated", "id", sbx.GetSandboxID(), "sbx", klog.KObj(sbx), "totalCost", time.Since(start),
 		"claimCost", claimCost, "initEnvdCost", initEnvdCost, "mountCost", mountCost)
 	return web.ApiResponse[*models.Sandbox]{
@@ -266,10 +304,11 @@ func (sc *Controller) BrowserUse(r *http.Request) (web.ApiResponse[*browserHandS
 		return web.ApiResponse[*browserHandShake]{}, apiErr
 	}
 
-	resp, err := sbx.Request(r, "/json/version", models.CDPPort)
+	cdpPort := cdpPortOf(sbx)
+	resp, err := sbx.Request(r, "/json/version", cdpPort)
 	if err != nil {
 		return web.ApiResponse[*browserHandShake]{}, &web.ApiError{
-			Message: fmt.Sprintf("Failed to proxy request to sandbox port %d: %v", models.CDPPort, err),
+			Message: fmt.Sprintf("Failed to proxy request to sandbox port %d: %v", cdpPort, err),
 		}
 	}
 	body, err := io.ReadAll(resp.Body)
@@ -286,7 +325,7 @@ func (sc *Controller) BrowserUse(r *http.Request) (web.ApiResponse[*browserHandS
 	}
 
 	h.WebSocketDebuggerURL = browserWebSocketReplacer.ReplaceAllString(h.WebSocketDebuggerURL,
-		fmt.Sprintf("wss://%s", managerutils.GetSandboxAddress(sandboxID, sc.domain, models.CDPPort)))
+		fmt.Sprintf("wss://%s", managerutils.GetSandboxAddress(sandboxID, sc.domain, int32(cdpPort))))
 	return web.ApiResponse[*browserHandShake]{
 		Code: resp.StatusCode,
 		Body: &h,
@@ -8,10 +8,13 @@ import (
 	"time"
 
 	"github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/proxy"
+	"github.com/openkruise/agents/pkg/sandbox-manager/infra/sandboxcr"
 	"github.com/openkruise/agents/pkg/servers/e2b/keys"
 	"github.com/openkruise/agents/pkg/servers/e2b/models"
 	"github.com/openkruise/agents/pkg/servers/web"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
 )
 
 func imageChecker(image string, controller *Controller) func(t *testing.T, resp *models.Sandbox) {
@@ -22,6 +25,33 @@ func imageChecker(image string, controller *Controller) func(t *testing.T, resp
 	}
 }
 
+func autoPauseChecker(autoPause bool, controller *Controller) func(t *testing.T, resp *models.Sandbox) {
+	return func(t *testing.T, resp *models.Sandbox) {
+		sbx, err := controller.manager.GetClaimedSandbox(t.Context(), keys.AdminKeyID.String(), resp.SandboxID)
+		assert.NoError(t, err)
+		cr := sbx.(*sandboxcr.Sandbox)
+		if !autoPause {
+			assert.Empty(t, cr.Spec.ShutdownPolicy)
+			return
+		}
+		assert.Equal(t, v1alpha1.SandboxShutdownPause, cr.Spec.ShutdownPolicy)
+		assert.Equal(t, ptr.To[int32](models.DefaultPausedRetention), cr.Spec.PausedRetentionSeconds)
+	}
+}
+
+func secureChecker(secure bool, controller *Controller) func(t *testing.T, resp *models.Sandbox) {
+	return func(t *testing.T, resp *models.Sandbox) {
+		sbx, err := controller.manager.GetClaimedSandbox(t.Context(), keys.AdminKeyID.String(), resp.SandboxID)
+		assert.NoError(t, err)
+		route := sbx.GetRoute()
+		if !secure {
+			assert.Empty(t, route.AccessTokenHash)
+			return
+		}
+		assert.Equal(t, proxy.HashAccessToken(resp.EnvdAccessToken), route.AccessTokenHash)
+	}
+}
+
 func TestCreateSandbox(t *testing.T) {
 	controller, client, teardown := Setup(t)
 	defer teardown()
@@ -61,6 +91,16 @@ func TestCreateSandbox(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "success with auto pause",
+			available: 2,
+			userName:  "test-user",
+			request: models.NewSandboxRequest{
+				TemplateID: templateName,
+				AutoPause:  true,
+			},
+			postCheck: autoPauseChecker(true, controller),
+		},
 		{
 			name:      "success with minimum timeout",
 			available: 2,
@@ -69,6 +109,26 @@ func TestCreateSandbox(t *testing.T) {
 				TemplateID: templateName,
 				Timeout:    30,
 			},
+			postCheck: autoPauseChecker(false, controller),
+		},
+		{
+			name:      "success with secure",
+			available: 2,
+			userName:  "test-user",
+			request: models.NewSandboxRequest{
+				TemplateID: templateName,
+				Secure:     true,
+			},
+			postCheck: secureChecker(true, controller),
+		},
+		{
+			name:      "success without secure",
+			available: 2,
+			userName:  "test-user",
+			request: models.NewSandboxRequest{
+				TemplateID: templateName,
+			},
+			postCheck: secureChecker(false, controller),
 		},
 		{
 			name:      "success with maximum timeout",
@@ -186,6 +246,67 @@ func TestCreateSandbox(t *testing.T) {
 				Message: "Bad extension param: invalid image [bad-@@-image]: invalid reference format",
 			},
 		},
+		{
+			name:      "claim with resources",
+			available: 1,
+			userName:  "test-user",
+			request: models.NewSandboxRequest{
+				TemplateID: templateName,
+				Metadata: map[string]string{
+					models.ExtensionKeyClaimWithCPU:    "2",
+					models.ExtensionKeyClaimWithMemory: "4Gi",
+				},
+			},
+			postCheck: func(t *testing.T, resp *models.Sandbox) {
+				assert.Equal(t, int64(2), resp.CPUCount)
+				assert.Equal(t, int64(4096), resp.MemoryMB)
+			},
+		},
+		{
+			name:      "claim with bad cpu",
+			available: 1,
+			userName:  "test-user",
+			request: models.NewSandboxRequest{
+				TemplateID: templateName,
+				Metadata: map[string]string{
+					models.ExtensionKeyClaimWithCPU: "-1",
+				},
+			},
+			expectError: &web.ApiError{
+				Code:    http.StatusBadRequest,
+				Message: "Bad extension param: invalid cpu [-1]: must be positive",
+			},
+		},
+		{
+			name:      "claim with bad cold start timeout",
+			available: 1,
+			userName:  "test-user",
+			request: models.NewSandboxRequest{
+				TemplateID: templateName,
+				Metadata: map[string]string{
+					models.ExtensionKeyClaimColdStartTimeout: "3600",
+				},
+			},
+			expectError: &web.ApiError{
+				Code:    http.StatusBadRequest,
+				Message: "Bad extension param: invalid cold start timeout [3600]: must be an integer between 1 and 600",
+			},
+		},
+		{
+			name:      "claim with bad max wait",
+			available: 1,
+			userName:  "test-user",
+			request: models.NewSandboxRequest{
+				TemplateID: templateName,
+				Metadata: map[string]string{
+					models.ExtensionKeyClaimMaxWait: "abc",
+				},
+			},
+			expectError: &web.ApiError{
+				Code:    http.StatusBadRequest,
+				Message: "Bad extension param: invalid max wait [abc]: must be an integer between 1 and 600",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -228,6 +349,9 @@ func TestCreateSandbox(t *testing.T) {
 				assert.NoError(t, err)
 				assert.WithinDuration(t, startedAt.Add(time.Duration(timeout)*time.Second), endAt, 5*time.Second)
 				assert.Equal(t, models.SandboxStateRunning, sbx.State)
+				if tt.postCheck != nil {
+					tt.postCheck(t, sbx)
+				}
 			}
 		})
 	}
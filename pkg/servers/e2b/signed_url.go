@@ -0,0 +1,79 @@
+package e2b
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openkruise/agents/pkg/servers/e2b/adapters"
+	"github.com/openkruise/agents/pkg/servers/e2b/models"
+	"github.com/openkruise/agents/pkg/servers/web"
+	managerutils "github.com/openkruise/agents/pkg/utils/sandbox-manager"
+	"k8s.io/klog/v2"
+)
+
+// CreateSignedURL mints an expiring URL of a sandbox port, which can be shared with the ones without API keys.
+func (sc *Controller) CreateSignedURL(r *http.Request) (web.ApiResponse[*models.SignedURL], *web.ApiError) {
+	ctx := r.Context()
+	log := klog.FromContext(ctx)
+	var request models.NewSignedURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return web.ApiResponse[*models.SignedURL]{}, &web.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
+	if request.Port <= 0 || request.Port > 65535 {
+		return web.ApiResponse[*models.SignedURL]{}, &web.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("invalid port: %d", request.Port),
+		}
+	}
+	if request.PathPrefix != "" && !strings.HasPrefix(request.PathPrefix, "/") {
+		return web.ApiResponse[*models.SignedURL]{}, &web.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: "pathPrefix should start with /",
+		}
+	}
+	if request.Expiry == 0 {
+		request.Expiry = models.DefaultSignedURLExpiry
+	}
+	if request.Expiry < 0 || request.Expiry > models.MaxSignedURLExpiry {
+		return web.ApiResponse[*models.SignedURL]{}, &web.ApiError{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("expiry should between 1 and %d", models.MaxSignedURLExpiry),
+		}
+	}
+
+	id := r.PathValue("sandboxID")
+	if _, apiErr := sc.getSandboxOfUser(ctx, id); apiErr != nil {
+		return web.ApiResponse[*models.SignedURL]{}, apiErr
+	}
+	expiresAt := time.Now().Add(time.Duration(request.Expiry) * time.Second)
+	token, err := sc.signingKeys.Sign(id, request.Port, request.PathPrefix, expiresAt)
+	if err != nil {
+		log.Error(err, "failed to sign url", "sandboxID", id)
+		return web.ApiResponse[*models.SignedURL]{}, &web.ApiError{
+			Code:    http.StatusInternalServerError,
+			Message: fmt.Sprintf("Failed to sign url: %v", err),
+		}
+	}
+	path := request.PathPrefix
+	if path == "" {
+		path = "/"
+	}
+	signedURL := fmt.Sprintf("https://%s%s?%s=%s", managerutils.GetSandboxAddress(id, sc.domain, int32(request.Port)),
+		path, adapters.SignedURLParam, url.QueryEscape(token))
+	log.Info("url signed", "sandboxID", id, "port", request.Port, "pathPrefix", request.PathPrefix, "expiresAt", expiresAt)
+	return web.ApiResponse[*models.SignedURL]{
+		Code: http.StatusCreated,
+		Body: &models.SignedURL{
+			URL:       signedURL,
+			Token:     token,
+			ExpiresAt: expiresAt,
+		},
+	}, nil
+}
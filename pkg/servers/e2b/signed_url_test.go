@@ -0,0 +1,127 @@
+package e2b
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openkruise/agents/pkg/servers/e2b/adapters"
+	"github.com/openkruise/agents/pkg/servers/e2b/keys"
+	"github.com/openkruise/agents/pkg/servers/e2b/models"
+	"github.com/openkruise/agents/pkg/servers/web"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSignedURL(t *testing.T) {
+	templateName := "test-template"
+	controller, client, teardown := Setup(t)
+	defer teardown()
+	cleanup := CreateSandboxPool(t, client.SandboxClient, templateName, 1)
+	defer cleanup()
+	user := &models.CreatedTeamAPIKey{
+		ID:   keys.AdminKeyID,
+		Key:  InitKey,
+		Name: "admin",
+	}
+	createResp, apiErr := controller.CreateSandbox(NewRequest(t, nil, models.NewSandboxRequest{
+		TemplateID: templateName,
+		Secure:     true,
+	}, nil, user))
+	assert.Nil(t, apiErr)
+	sandboxID := createResp.Body.SandboxID
+
+	tests := []struct {
+		name        string
+		sandboxID   string
+		request     models.NewSignedURLRequest
+		expectError *web.ApiError
+		expectURL   string
+		expectPath  string
+	}{
+		{
+			name:      "default expiry",
+			sandboxID: sandboxID,
+			request:   models.NewSignedURLRequest{Port: 3000},
+			expectURL: "https://3000-" + sandboxID + ".example.com/",
+		},
+		{
+			name:       "with path prefix",
+			sandboxID:  sandboxID,
+			request:    models.NewSignedURLRequest{Port: 3000, PathPrefix: "/app", Expiry: 60},
+			expectURL:  "https://3000-" + sandboxID + ".example.com/app",
+			expectPath: "/app/index.html",
+		},
+		{
+			name:      "invalid port",
+			sandboxID: sandboxID,
+			request:   models.NewSignedURLRequest{Port: 70000},
+			expectError: &web.ApiError{
+				Code:    400,
+				Message: "invalid port: 70000",
+			},
+		},
+		{
+			name:      "invalid path prefix",
+			sandboxID: sandboxID,
+			request:   models.NewSignedURLRequest{Port: 3000, PathPrefix: "app"},
+			expectError: &web.ApiError{
+				Code:    400,
+				Message: "pathPrefix should start with /",
+			},
+		},
+		{
+			name:      "expiry too long",
+			sandboxID: sandboxID,
+			request:   models.NewSignedURLRequest{Port: 3000, Expiry: models.MaxSignedURLExpiry + 1},
+			expectError: &web.ApiError{
+				Code:    400,
+				Message: "expiry should between 1 and 604800",
+			},
+		},
+		{
+			name:      "sandbox not found",
+			sandboxID: "not-exist",
+			request:   models.NewSignedURLRequest{Port: 3000},
+			expectError: &web.ApiError{
+				Code:    404,
+				Message: "Sandbox not-exist not found",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			resp, apiErr := controller.CreateSignedURL(NewRequest(t, nil, tt.request, map[string]string{
+				"sandboxID": tt.sandboxID,
+			}, user))
+			if tt.expectError != nil {
+				assert.Equal(t, tt.expectError, apiErr)
+				return
+			}
+			assert.Nil(t, apiErr)
+			signed := resp.Body
+			assert.True(t, strings.HasPrefix(signed.URL, tt.expectURL+"?"+adapters.SignedURLParam+"="), signed.URL)
+			parsed, err := url.Parse(signed.URL)
+			assert.NoError(t, err)
+			assert.Equal(t, signed.Token, parsed.Query().Get(adapters.SignedURLParam))
+			expiry := tt.request.Expiry
+			if expiry == 0 {
+				expiry = models.DefaultSignedURLExpiry
+			}
+			assert.WithinDuration(t, start.Add(time.Duration(expiry)*time.Second), signed.ExpiresAt, time.Second)
+
+			// the url is accepted by the proxy in place of the access token
+			path := tt.expectPath
+			if path == "" {
+				path = "/"
+			}
+			adapter := adapters.NewE2BAdapter(TestServerPort)
+			adapter.Verifier = controller.signingKeys
+			ok, _ := adapter.Authorize(sandboxID, 3000, path+"?"+parsed.RawQuery, nil)
+			assert.True(t, ok)
+			ok, _ = adapter.Authorize(sandboxID, 3001, path+"?"+parsed.RawQuery, nil)
+			assert.False(t, ok)
+		})
+	}
+}
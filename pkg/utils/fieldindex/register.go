@@ -21,6 +21,7 @@ import (
 	"sync"
 
 	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/utils/templateref"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -28,6 +29,7 @@ import (
 
 const (
 	IndexNameForOwnerRefUID = "ownerRefUID"
+	IndexNameForTemplateRef = "spec.templateRef"
 )
 
 var (
@@ -42,6 +44,23 @@ var OwnerIndexFunc = func(obj client.Object) []string {
 	return owners
 }
 
+// TemplateRefIndexFunc indexes Sandboxes and SandboxSets by the template they reference, see templateref.IndexKey.
+var TemplateRefIndexFunc = func(obj client.Object) []string {
+	var tmpl *agentsv1alpha1.EmbeddedSandboxTemplate
+	switch o := obj.(type) {
+	case *agentsv1alpha1.Sandbox:
+		tmpl = &o.Spec.EmbeddedSandboxTemplate
+	case *agentsv1alpha1.SandboxSet:
+		tmpl = &o.Spec.EmbeddedSandboxTemplate
+	default:
+		return nil
+	}
+	if tmpl.TemplateRef == nil {
+		return nil
+	}
+	return []string{templateref.IndexKey(templateref.GetKind(tmpl.TemplateRef), tmpl.TemplateRef.Name)}
+}
+
 func RegisterFieldIndexes(c cache.Cache) error {
 	var err error
 	registerOnce.Do(func() {
@@ -49,6 +68,14 @@ func RegisterFieldIndexes(c cache.Cache) error {
 		if err = c.IndexField(context.TODO(), &agentsv1alpha1.Sandbox{}, IndexNameForOwnerRefUID, OwnerIndexFunc); err != nil {
 			return
 		}
+		// sandbox templateRef
+		if err = c.IndexField(context.TODO(), &agentsv1alpha1.Sandbox{}, IndexNameForTemplateRef, TemplateRefIndexFunc); err != nil {
+			return
+		}
+		// sandboxset templateRef
+		if err = c.IndexField(context.TODO(), &agentsv1alpha1.SandboxSet{}, IndexNameForTemplateRef, TemplateRefIndexFunc); err != nil {
+			return
+		}
 	})
 	return err
 }
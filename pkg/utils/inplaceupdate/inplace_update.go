@@ -19,11 +19,13 @@ package inplaceupdate
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	agentsapiv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
 	"github.com/openkruise/agents/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
@@ -31,6 +33,10 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// ErrPodResizeNotSupported is returned by Update when the resources are changed but the resize subresource of pods is
+// not served by the cluster, which requires Kubernetes 1.33 or later.
+var ErrPodResizeNotSupported = errors.New("the resize subresource of pods is not supported by the cluster, which requires Kubernetes 1.33+")
+
 const (
 	// PodAnnotationInPlaceUpdateStateKey records the state of inplace-update.
 	// The value of annotation is InPlaceUpdateState.
@@ -51,6 +57,16 @@ type InPlaceUpdateState struct {
 
 	// UpdateImages indicates there are images that should be in-place update.
 	UpdateImages bool `json:"updateImages,omitempty"`
+
+	// UpdateResources indicates there are container resources that should be resized in-place.
+	UpdateResources bool `json:"updateResources,omitempty"`
+
+	// PreviousRevision is the revision of the last in-place update, which may be still in progress when this one
+	// starts. The containers not completed by the last update are carried over to LastContainerStatuses.
+	PreviousRevision string `json:"previousRevision,omitempty"`
+
+	// UpdateCount is the number of in-place updates happened to the pod, including this one.
+	UpdateCount int32 `json:"updateCount,omitempty"`
 }
 
 // InPlaceUpdateContainerStatus records the statuses of the container that are mainly used
@@ -100,6 +116,12 @@ func DefaultGeneratePatchBodyFunc(opts InPlaceUpdateOptions) string {
 		Revision:              revision,
 		UpdateTimestamp:       metav1.Now(),
 		LastContainerStatuses: map[string]InPlaceUpdateContainerStatus{},
+		UpdateCount:           1,
+	}
+	lastState, _ := GetPodInPlaceUpdateState(pod)
+	if lastState != nil {
+		state.PreviousRevision = lastState.Revision
+		state.UpdateCount = lastState.UpdateCount + 1
 	}
 	// container.name -> container
 	originContainers := map[string]corev1.Container{}
@@ -107,19 +129,24 @@ func DefaultGeneratePatchBodyFunc(opts InPlaceUpdateOptions) string {
 		obj := box.Spec.Template.Spec.Containers[i]
 		originContainers[obj.Name] = obj
 	}
-	// container.name -> imageId
-	originStatus := map[string]string{}
+	// container.name -> status
+	originStatus := map[string]corev1.ContainerStatus{}
 	for _, status := range pod.Status.ContainerStatuses {
-		originStatus[status.Name] = status.ImageID
+		originStatus[status.Name] = status
 	}
 
 	patchSpec := corev1.PodSpec{}
+	patched := map[string]bool{}
 	for i := range pod.Spec.Containers {
 		container := pod.Spec.Containers[i]
 		origin, ok := originContainers[container.Name]
 		if !ok {
 			continue
 		}
+		if !isResourcesEqual(origin.Resources, container.Resources) {
+			// resources are resized through the pod resize subresource, see generateResizePatchBody
+			state.UpdateResources = true
+		}
 		if origin.Image == container.Image {
 			continue
 		}
@@ -128,15 +155,29 @@ func DefaultGeneratePatchBodyFunc(opts InPlaceUpdateOptions) string {
 			Image: origin.Image,
 		}
 		patchSpec.Containers = append(patchSpec.Containers, patchContainer)
+		patched[container.Name] = true
 		state.UpdateImages = true
-		imageId := originStatus[container.Name]
-		state.LastContainerStatuses[container.Name] = InPlaceUpdateContainerStatus{
-			ImageID: imageId,
+		// the container is still running the origin image if the last update is reverted before it restarts
+		if status := originStatus[container.Name]; status.Image != origin.Image {
+			state.LastContainerStatuses[container.Name] = InPlaceUpdateContainerStatus{
+				ImageID: status.ImageID,
+			}
 		}
 	}
-	if len(patchSpec.Containers) == 0 {
+	if len(patchSpec.Containers) == 0 && !state.UpdateResources && pod.Labels[agentsapiv1alpha1.PodLabelTemplateHash] == revision {
 		return ""
 	}
+	if lastState != nil {
+		// the containers not updated this time may be still restarting for the last update
+		for name, status := range lastState.LastContainerStatuses {
+			if patched[name] {
+				continue
+			}
+			if cur, ok := originStatus[name]; ok && cur.ImageID == status.ImageID {
+				state.LastContainerStatuses[name] = status
+			}
+		}
+	}
 
 	annotations := map[string]string{
 		PodAnnotationInPlaceUpdateStateKey: utils.DumpJson(state),
@@ -144,9 +185,55 @@ func DefaultGeneratePatchBodyFunc(opts InPlaceUpdateOptions) string {
 	labels := map[string]string{
 		agentsapiv1alpha1.PodLabelTemplateHash: revision,
 	}
+	if len(patchSpec.Containers) == 0 {
+		return fmt.Sprintf(`{"metadata":{"annotations":%s,"labels":%s}}`, utils.DumpJson(annotations), utils.DumpJson(labels))
+	}
 	return fmt.Sprintf(`{"metadata":{"annotations":%s,"labels":%s},"spec":%s}`, utils.DumpJson(annotations), utils.DumpJson(labels), utils.DumpJson(patchSpec))
 }
 
+// generateResizePatchBody generates the patch of the pod resize subresource for the containers whose resources
+// differ from the sandbox template, which returns empty string if there is nothing to resize.
+func generateResizePatchBody(opts InPlaceUpdateOptions) string {
+	box, pod := opts.Box, opts.Pod
+	originContainers := map[string]corev1.Container{}
+	for i := range box.Spec.Template.Spec.Containers {
+		obj := box.Spec.Template.Spec.Containers[i]
+		originContainers[obj.Name] = obj
+	}
+	patchSpec := corev1.PodSpec{}
+	for i := range pod.Spec.Containers {
+		container := pod.Spec.Containers[i]
+		origin, ok := originContainers[container.Name]
+		if !ok || isResourcesEqual(origin.Resources, container.Resources) {
+			continue
+		}
+		patchSpec.Containers = append(patchSpec.Containers, corev1.Container{
+			Name:      container.Name,
+			Resources: origin.Resources,
+		})
+	}
+	if len(patchSpec.Containers) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`{"spec":%s}`, utils.DumpJson(patchSpec))
+}
+
+// isResourcesEqual returns whether the resources declared by desired are the same in cur. The resources only in cur
+// are ignored, which are defaulted by the apiserver or LimitRanges, e.g. the requests default to the limits, so that
+// an image-only update never resizes the pod.
+func isResourcesEqual(desired, cur corev1.ResourceRequirements) bool {
+	return isResourceListCovered(desired.Requests, cur.Requests) && isResourceListCovered(desired.Limits, cur.Limits)
+}
+
+func isResourceListCovered(desired, cur corev1.ResourceList) bool {
+	for name, quantity := range desired {
+		if q, ok := cur[name]; !ok || q.Cmp(quantity) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *InPlaceUpdateControl) Update(ctx context.Context, opts InPlaceUpdateOptions) (bool, error) {
 	box, pod, revision := opts.Box, opts.Pod, opts.Revision
 	logger := logf.FromContext(ctx).WithValues("sandbox", klog.KObj(box))
@@ -156,6 +243,19 @@ func (c *InPlaceUpdateControl) Update(ctx context.Context, opts InPlaceUpdateOpt
 		return false, nil
 	}
 
+	// resize first, otherwise the resize is lost once the revision label is patched but the resize failed
+	if resizeBody := generateResizePatchBody(opts); resizeBody != "" {
+		clone := pod.DeepCopy()
+		if err := c.SubResource("resize").Patch(ctx, clone, client.RawPatch(types.StrategicMergePatchType, []byte(resizeBody))); err != nil {
+			if isSubResourceNotServed(err) {
+				err = fmt.Errorf("%w: %v", ErrPodResizeNotSupported, err)
+			}
+			logger.Error(err, "inplace resize pod failed")
+			return false, err
+		}
+		logger.Info("inplace resize pod success", "revision", revision, "resizeBody", resizeBody)
+	}
+
 	clone := pod.DeepCopy()
 	if err := c.Patch(ctx, clone, client.RawPatch(types.StrategicMergePatchType, []byte(patchBody))); err != nil {
 		logger.Error(err, "inplace update pod failed")
@@ -165,6 +265,20 @@ func (c *InPlaceUpdateControl) Update(ctx context.Context, opts InPlaceUpdateOpt
 	return true, nil
 }
 
+// isSubResourceNotServed returns whether the error means the subresource is not served, rather than the pod is not
+// found, which carries the name of the pod in the details.
+func isSubResourceNotServed(err error) bool {
+	if apierrors.IsMethodNotSupported(err) {
+		return true
+	}
+	var status apierrors.APIStatus
+	if !apierrors.IsNotFound(err) || !errors.As(err, &status) {
+		return false
+	}
+	details := status.Status().Details
+	return details == nil || details.Name == ""
+}
+
 func IsInplaceUpdateCompleted(ctx context.Context, pod *corev1.Pod) bool {
 	logger := logf.FromContext(ctx).WithValues("pod", klog.KObj(pod))
 
@@ -183,5 +297,56 @@ func IsInplaceUpdateCompleted(ctx context.Context, pod *corev1.Pod) bool {
 			return false
 		}
 	}
+	return isPodResizeCompleted(ctx, pod)
+}
+
+// isPodResizeCompleted checks whether the resources allocated to the containers by kubelet match the pod spec.
+// The containers without allocated resources reported, e.g. the InPlacePodVerticalScaling feature is disabled,
+// are regarded as completed.
+func isPodResizeCompleted(ctx context.Context, pod *corev1.Pod) bool {
+	logger := logf.FromContext(ctx).WithValues("pod", klog.KObj(pod))
+	// container.Name -> allocated resources
+	allocated := map[string]corev1.ResourceList{}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.AllocatedResources != nil {
+			allocated[status.Name] = status.AllocatedResources
+		} else if status.Resources != nil {
+			allocated[status.Name] = status.Resources.Requests
+		}
+	}
+	for _, container := range pod.Spec.Containers {
+		resources, ok := allocated[container.Name]
+		if !ok {
+			continue
+		}
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			desired, cur := container.Resources.Requests[name], resources[name]
+			if desired.Cmp(cur) != 0 {
+				logger.Info("pod container inplace resize is incompleted", "container", container.Name, "resource", name,
+					"desired", desired.String(), "allocated", cur.String(), "resizePending", getPodResizePendingReason(pod))
+				return false
+			}
+		}
+	}
 	return true
 }
+
+// IsPodResizeInfeasible returns whether kubelet refuses the resize of the pod, e.g. the node can never satisfy the
+// requests, with the message of kubelet. Deferred resizes are still retried by kubelet, which are not regarded so.
+func IsPodResizeInfeasible(pod *corev1.Pod) (bool, string) {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodResizePending && cond.Status == corev1.ConditionTrue && cond.Reason == corev1.PodReasonInfeasible {
+			return true, cond.Message
+		}
+	}
+	return false, ""
+}
+
+func getPodResizePendingReason(pod *corev1.Pod) string {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodResizePending && cond.Status == corev1.ConditionTrue {
+			return cond.Reason
+		}
+	}
+	return ""
+}
@@ -16,14 +16,22 @@ package inplaceupdate
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
 	"testing"
 	"time"
 
 	agentsapiv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 )
 
 func TestGetPodInPlaceUpdateState(t *testing.T) {
@@ -158,7 +166,7 @@ func TestInPlaceUpdateControl_Update(t *testing.T) {
 			opts: InPlaceUpdateOptions{
 				Box: &agentsapiv1alpha1.Sandbox{
 					Spec: agentsapiv1alpha1.SandboxSpec{
-						SandboxTemplate: agentsapiv1alpha1.SandboxTemplate{
+						EmbeddedSandboxTemplate: agentsapiv1alpha1.EmbeddedSandboxTemplate{
 							Template: &corev1.PodTemplateSpec{
 								Spec: corev1.PodSpec{
 									Containers: []corev1.Container{
@@ -203,7 +211,7 @@ func TestInPlaceUpdateControl_Update(t *testing.T) {
 			opts: InPlaceUpdateOptions{
 				Box: &agentsapiv1alpha1.Sandbox{
 					Spec: agentsapiv1alpha1.SandboxSpec{
-						SandboxTemplate: agentsapiv1alpha1.SandboxTemplate{
+						EmbeddedSandboxTemplate: agentsapiv1alpha1.EmbeddedSandboxTemplate{
 							Template: &corev1.PodTemplateSpec{
 								Spec: corev1.PodSpec{
 									Containers: []corev1.Container{}, // no containers
@@ -306,6 +314,44 @@ func TestInPlaceUpdateControl_Update(t *testing.T) {
 }
 
 func TestIsInplaceUpdateCompleted(t *testing.T) {
+	newResizePod := func(allocated, actual corev1.ResourceList) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-pod",
+				Namespace: "default",
+				Annotations: map[string]string{
+					PodAnnotationInPlaceUpdateStateKey: `{"revision":"abc123","updateTimestamp":"2023-01-01T00:00:00Z","lastContainerStatuses":{},"updateResources":true}`,
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "container1",
+						Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("2"),
+							corev1.ResourceMemory: resource.MustParse("4Gi"),
+						}},
+					},
+				},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:               "container1",
+						ImageID:            "image123",
+						AllocatedResources: allocated,
+						Resources: func() *corev1.ResourceRequirements {
+							if actual == nil {
+								return nil
+							}
+							return &corev1.ResourceRequirements{Requests: actual}
+						}(),
+					},
+				},
+			},
+		}
+	}
+
 	tests := []struct {
 		name              string
 		pod               *corev1.Pod
@@ -315,8 +361,8 @@ func TestIsInplaceUpdateCompleted(t *testing.T) {
 			name: "no state annotation",
 			pod: &corev1.Pod{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:      "test-pod",
-					Namespace: "default",
+					Name:        "test-pod",
+					Namespace:   "default",
 					Annotations: map[string]string{
 						// No inplace update state annotation
 					},
@@ -421,6 +467,35 @@ func TestIsInplaceUpdateCompleted(t *testing.T) {
 			},
 			expectedCompleted: false,
 		},
+		{
+			name: "incomplete resize - allocated resources differ",
+			pod: newResizePod(corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			}, nil),
+			expectedCompleted: false,
+		},
+		{
+			name: "complete resize - allocated resources match",
+			pod: newResizePod(corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2000m"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			}, nil),
+			expectedCompleted: true,
+		},
+		{
+			name: "incomplete resize - actual resources differ without allocated resources",
+			pod: newResizePod(nil, corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("2Gi"),
+			}),
+			expectedCompleted: false,
+		},
+		{
+			name:              "complete resize - no resources reported",
+			pod:               newResizePod(nil, nil),
+			expectedCompleted: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -432,3 +507,406 @@ func TestIsInplaceUpdateCompleted(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultGeneratePatchBodyFunc(t *testing.T) {
+	newBox := func(images ...string) *agentsapiv1alpha1.Sandbox {
+		box := &agentsapiv1alpha1.Sandbox{
+			Spec: agentsapiv1alpha1.SandboxSpec{
+				EmbeddedSandboxTemplate: agentsapiv1alpha1.EmbeddedSandboxTemplate{
+					Template: &corev1.PodTemplateSpec{},
+				},
+			},
+		}
+		for i, image := range images {
+			box.Spec.Template.Spec.Containers = append(box.Spec.Template.Spec.Containers,
+				corev1.Container{Name: []string{"main", "sidecar"}[i], Image: image})
+		}
+		return box
+	}
+	newPod := func(lastState *InPlaceUpdateState, images, imageIDs []string) *corev1.Pod {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+		if lastState != nil {
+			stateBytes, _ := json.Marshal(lastState)
+			pod.Annotations[PodAnnotationInPlaceUpdateStateKey] = string(stateBytes)
+		}
+		for i := range images {
+			name := []string{"main", "sidecar"}[i]
+			pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name, Image: images[i]})
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses,
+				corev1.ContainerStatus{Name: name, Image: images[i], ImageID: imageIDs[i]})
+		}
+		return pod
+	}
+
+	tests := []struct {
+		name          string
+		opts          InPlaceUpdateOptions
+		expectPatched bool
+		expectState   *InPlaceUpdateState
+	}{
+		{
+			name: "first update",
+			opts: InPlaceUpdateOptions{
+				Box:      newBox("main:v2", "sidecar:v1"),
+				Pod:      newPod(nil, []string{"main:v1", "sidecar:v1"}, []string{"main-v1", "sidecar-v1"}),
+				Revision: "rev-2",
+			},
+			expectPatched: true,
+			expectState: &InPlaceUpdateState{
+				Revision:              "rev-2",
+				LastContainerStatuses: map[string]InPlaceUpdateContainerStatus{"main": {ImageID: "main-v1"}},
+				UpdateImages:          true,
+				UpdateCount:           1,
+			},
+		},
+		{
+			name: "second update after the first completed",
+			opts: InPlaceUpdateOptions{
+				Box: newBox("main:v3", "sidecar:v1"),
+				Pod: newPod(&InPlaceUpdateState{
+					Revision:              "rev-2",
+					LastContainerStatuses: map[string]InPlaceUpdateContainerStatus{"main": {ImageID: "main-v1"}},
+					UpdateCount:           1,
+				}, []string{"main:v2", "sidecar:v1"}, []string{"main-v2", "sidecar-v1"}),
+				Revision: "rev-3",
+			},
+			expectPatched: true,
+			expectState: &InPlaceUpdateState{
+				Revision:              "rev-3",
+				LastContainerStatuses: map[string]InPlaceUpdateContainerStatus{"main": {ImageID: "main-v2"}},
+				UpdateImages:          true,
+				PreviousRevision:      "rev-2",
+				UpdateCount:           2,
+			},
+		},
+		{
+			name: "second update while the first is in flight",
+			opts: InPlaceUpdateOptions{
+				Box: func() *agentsapiv1alpha1.Sandbox {
+					// main stays at v2 of the first update, sidecar is updated this time
+					return newBox("main:v2", "sidecar:v2")
+				}(),
+				Pod: func() *corev1.Pod {
+					pod := newPod(&InPlaceUpdateState{
+						Revision:              "rev-2",
+						LastContainerStatuses: map[string]InPlaceUpdateContainerStatus{"main": {ImageID: "main-v1"}},
+						UpdateCount:           1,
+					}, []string{"main:v2", "sidecar:v1"}, []string{"main-v1", "sidecar-v1"})
+					// main container has not been restarted yet
+					pod.Status.ContainerStatuses[0].Image = "main:v1"
+					return pod
+				}(),
+				Revision: "rev-3",
+			},
+			expectPatched: true,
+			expectState: &InPlaceUpdateState{
+				Revision: "rev-3",
+				LastContainerStatuses: map[string]InPlaceUpdateContainerStatus{
+					"main":    {ImageID: "main-v1"},
+					"sidecar": {ImageID: "sidecar-v1"},
+				},
+				UpdateImages:     true,
+				PreviousRevision: "rev-2",
+				UpdateCount:      2,
+			},
+		},
+		{
+			name: "in-flight update reverted before the container restarts",
+			opts: InPlaceUpdateOptions{
+				Box: newBox("main:v1", "sidecar:v1"),
+				Pod: func() *corev1.Pod {
+					pod := newPod(&InPlaceUpdateState{
+						Revision:              "rev-2",
+						LastContainerStatuses: map[string]InPlaceUpdateContainerStatus{"main": {ImageID: "main-v1"}},
+						UpdateCount:           1,
+					}, []string{"main:v2", "sidecar:v1"}, []string{"main-v1", "sidecar-v1"})
+					pod.Status.ContainerStatuses[0].Image = "main:v1"
+					return pod
+				}(),
+				Revision: "rev-1",
+			},
+			expectPatched: true,
+			expectState: &InPlaceUpdateState{
+				Revision:              "rev-1",
+				LastContainerStatuses: map[string]InPlaceUpdateContainerStatus{},
+				UpdateImages:          true,
+				PreviousRevision:      "rev-2",
+				UpdateCount:           2,
+			},
+		},
+		{
+			name: "no changes",
+			opts: InPlaceUpdateOptions{
+				Box: newBox("main:v1", "sidecar:v1"),
+				Pod: func() *corev1.Pod {
+					pod := newPod(nil, []string{"main:v1", "sidecar:v1"}, []string{"main-v1", "sidecar-v1"})
+					pod.Labels = map[string]string{agentsapiv1alpha1.PodLabelTemplateHash: "rev-1"}
+					return pod
+				}(),
+				Revision: "rev-1",
+			},
+			expectPatched: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := DefaultGeneratePatchBodyFunc(tt.opts)
+			if !tt.expectPatched {
+				if body != "" {
+					t.Errorf("Expected empty patch body, got %s", body)
+				}
+				return
+			}
+			patch := struct {
+				Metadata metav1.ObjectMeta `json:"metadata"`
+			}{}
+			if err := json.Unmarshal([]byte(body), &patch); err != nil {
+				t.Fatalf("Failed to unmarshal patch body: %v", err)
+			}
+			if patch.Metadata.Labels[agentsapiv1alpha1.PodLabelTemplateHash] != tt.opts.Revision {
+				t.Errorf("Expected revision label %s, got %s", tt.opts.Revision, patch.Metadata.Labels[agentsapiv1alpha1.PodLabelTemplateHash])
+			}
+			state := &InPlaceUpdateState{}
+			if err := json.Unmarshal([]byte(patch.Metadata.Annotations[PodAnnotationInPlaceUpdateStateKey]), state); err != nil {
+				t.Fatalf("Failed to unmarshal state annotation: %v", err)
+			}
+			state.UpdateTimestamp = metav1.Time{}
+			if !reflect.DeepEqual(state, tt.expectState) {
+				t.Errorf("Expected state %+v, got %+v", tt.expectState, state)
+			}
+		})
+	}
+}
+
+func TestInPlaceUpdateControl_Resize(t *testing.T) {
+	scheme, err := agentsapiv1alpha1.SchemeBuilder.Build()
+	if err != nil {
+		t.Fatalf("Failed to build scheme: %v", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add corev1 to scheme: %v", err)
+	}
+	small := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}
+	large := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("8Gi")},
+	}
+	box := &agentsapiv1alpha1.Sandbox{
+		Spec: agentsapiv1alpha1.SandboxSpec{
+			EmbeddedSandboxTemplate: agentsapiv1alpha1.EmbeddedSandboxTemplate{
+				Template: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "main", Image: "nginx:1.25", Resources: large}},
+					},
+				},
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+			Labels:    map[string]string{agentsapiv1alpha1.PodLabelTemplateHash: "rev-1"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "main", Image: "nginx:1.25", Resources: small}},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "main", Image: "nginx:1.25", ImageID: "nginx-id", AllocatedResources: small.Requests},
+			},
+		},
+	}
+	control := NewInPlaceUpdateControl(fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build(), DefaultGeneratePatchBodyFunc)
+	changed, err := control.Update(context.TODO(), InPlaceUpdateOptions{Box: box, Pod: pod, Revision: "rev-2"})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if !changed {
+		t.Fatalf("Expected pod to be changed")
+	}
+
+	updated := &corev1.Pod{}
+	if err := control.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, updated); err != nil {
+		t.Fatalf("Failed to get updated pod: %v", err)
+	}
+	if updated.Labels[agentsapiv1alpha1.PodLabelTemplateHash] != "rev-2" {
+		t.Errorf("Expected revision label rev-2, got %s", updated.Labels[agentsapiv1alpha1.PodLabelTemplateHash])
+	}
+	if updated.Spec.Containers[0].Image != "nginx:1.25" {
+		t.Errorf("Expected image not changed, got %s", updated.Spec.Containers[0].Image)
+	}
+	if !isResourcesEqual(updated.Spec.Containers[0].Resources, large) {
+		t.Errorf("Expected resources %v, got %v", large, updated.Spec.Containers[0].Resources)
+	}
+	state, err := GetPodInPlaceUpdateState(updated)
+	if err != nil || state == nil {
+		t.Fatalf("GetPodInPlaceUpdateState() state = %v, error = %v", state, err)
+	}
+	if !state.UpdateResources || state.UpdateImages {
+		t.Errorf("Expected only resources to be updated, got %+v", state)
+	}
+	if IsInplaceUpdateCompleted(context.TODO(), updated) {
+		t.Errorf("Expected in-place update not completed before the resources are allocated")
+	}
+	updated.Status.ContainerStatuses[0].AllocatedResources = large.Requests
+	if !IsInplaceUpdateCompleted(context.TODO(), updated) {
+		t.Errorf("Expected in-place update completed after the resources are allocated")
+	}
+}
+
+func TestInPlaceUpdateControl_ImageOnlySkipsResize(t *testing.T) {
+	scheme, err := agentsapiv1alpha1.SchemeBuilder.Build()
+	if err != nil {
+		t.Fatalf("Failed to build scheme: %v", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add corev1 to scheme: %v", err)
+	}
+	limits := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+	box := &agentsapiv1alpha1.Sandbox{
+		Spec: agentsapiv1alpha1.SandboxSpec{
+			EmbeddedSandboxTemplate: agentsapiv1alpha1.EmbeddedSandboxTemplate{
+				Template: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "main", Image: "nginx:1.26", Resources: corev1.ResourceRequirements{Limits: limits}}},
+					},
+				},
+			},
+		},
+	}
+	// the requests are defaulted to the limits by the apiserver
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+			Labels:    map[string]string{agentsapiv1alpha1.PodLabelTemplateHash: "rev-1"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "main", Image: "nginx:1.25", Resources: corev1.ResourceRequirements{Limits: limits, Requests: limits}}},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).WithInterceptorFuncs(interceptor.Funcs{
+		SubResourcePatch: func(_ context.Context, _ client.Client, subResourceName string, _ client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+			return fmt.Errorf("subresource %s is not supported", subResourceName)
+		},
+	}).Build()
+	control := NewInPlaceUpdateControl(c, DefaultGeneratePatchBodyFunc)
+	changed, err := control.Update(context.TODO(), InPlaceUpdateOptions{Box: box, Pod: pod, Revision: "rev-2"})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if !changed {
+		t.Fatalf("Expected pod to be changed")
+	}
+	updated := &corev1.Pod{}
+	if err := control.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, updated); err != nil {
+		t.Fatalf("Failed to get updated pod: %v", err)
+	}
+	if updated.Spec.Containers[0].Image != "nginx:1.26" {
+		t.Errorf("Expected image nginx:1.26, got %s", updated.Spec.Containers[0].Image)
+	}
+	if state, _ := GetPodInPlaceUpdateState(updated); state == nil || state.UpdateResources {
+		t.Errorf("Expected only images to be updated, got %+v", state)
+	}
+}
+
+func TestInPlaceUpdateControl_ResizeNotSupported(t *testing.T) {
+	scheme, err := agentsapiv1alpha1.SchemeBuilder.Build()
+	if err != nil {
+		t.Fatalf("Failed to build scheme: %v", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add corev1 to scheme: %v", err)
+	}
+	small := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+	large := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}
+	box := &agentsapiv1alpha1.Sandbox{
+		Spec: agentsapiv1alpha1.SandboxSpec{
+			EmbeddedSandboxTemplate: agentsapiv1alpha1.EmbeddedSandboxTemplate{
+				Template: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "main", Image: "nginx:1.26", Resources: corev1.ResourceRequirements{Requests: large}}},
+					},
+				},
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pod",
+			Namespace: "default",
+			Labels:    map[string]string{agentsapiv1alpha1.PodLabelTemplateHash: "rev-1"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "main", Image: "nginx:1.25", Resources: corev1.ResourceRequirements{Requests: small}}},
+		},
+	}
+	tests := []struct {
+		name               string
+		err                error
+		expectNotSupported bool
+	}{
+		{
+			name: "subresource not found",
+			err: &apierrors.StatusError{ErrStatus: metav1.Status{
+				Status: metav1.StatusFailure, Code: http.StatusNotFound, Reason: metav1.StatusReasonNotFound,
+				Message: "the server could not find the requested resource",
+			}},
+			expectNotSupported: true,
+		},
+		{
+			name:               "method not supported",
+			err:                apierrors.NewMethodNotSupported(corev1.Resource("pods/resize"), "patch"),
+			expectNotSupported: true,
+		},
+		{
+			name: "pod not found",
+			err:  apierrors.NewNotFound(corev1.Resource("pods"), "test-pod"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod.DeepCopy()).WithInterceptorFuncs(interceptor.Funcs{
+				SubResourcePatch: func(context.Context, client.Client, string, client.Object, client.Patch, ...client.SubResourcePatchOption) error {
+					return tt.err
+				},
+			}).Build()
+			control := NewInPlaceUpdateControl(c, DefaultGeneratePatchBodyFunc)
+			changed, err := control.Update(context.TODO(), InPlaceUpdateOptions{Box: box, Pod: pod, Revision: "rev-2"})
+			if changed || err == nil {
+				t.Fatalf("Expected update failed, got changed %v, error %v", changed, err)
+			}
+			if got := errors.Is(err, ErrPodResizeNotSupported); got != tt.expectNotSupported {
+				t.Errorf("Expected resize not supported %v, got %v", tt.expectNotSupported, err)
+			}
+			// the image is not updated either, or the resize would be lost
+			updated := &corev1.Pod{}
+			if err := control.Get(context.TODO(), types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, updated); err != nil {
+				t.Fatalf("Failed to get updated pod: %v", err)
+			}
+			if updated.Spec.Containers[0].Image != "nginx:1.25" || updated.Labels[agentsapiv1alpha1.PodLabelTemplateHash] != "rev-1" {
+				t.Errorf("Expected pod not updated, got image %s, labels %v", updated.Spec.Containers[0].Image, updated.Labels)
+			}
+		})
+	}
+}
+
+func TestIsPodResizeInfeasible(t *testing.T) {
+	pod := &corev1.Pod{}
+	if infeasible, _ := IsPodResizeInfeasible(pod); infeasible {
+		t.Errorf("Expected resize not infeasible without the condition")
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodResizePending, Status: corev1.ConditionTrue, Reason: corev1.PodReasonDeferred}}
+	if infeasible, _ := IsPodResizeInfeasible(pod); infeasible {
+		t.Errorf("Expected deferred resize not infeasible")
+	}
+	pod.Status.Conditions[0].Reason, pod.Status.Conditions[0].Message = corev1.PodReasonInfeasible, "too large"
+	if infeasible, message := IsPodResizeInfeasible(pod); !infeasible || message != "too large" {
+		t.Errorf("Expected infeasible resize, got %v %q", infeasible, message)
+	}
+}
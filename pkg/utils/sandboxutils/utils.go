@@ -1,6 +1,7 @@
 package sandboxutils
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -15,7 +16,9 @@ func GetSandboxState(sbx *agentsv1alpha1.Sandbox) (state string, reason string)
 	if sbx.DeletionTimestamp != nil {
 		return agentsv1alpha1.SandboxStateDead, "ResourceDeleted"
 	}
-	if sbx.Spec.ShutdownTime != nil && time.Since(sbx.Spec.ShutdownTime.Time) > 0 {
+	// the sandbox is paused rather than dead with the Pause shutdown policy
+	if sbx.Spec.ShutdownTime != nil && time.Since(sbx.Spec.ShutdownTime.Time) > 0 &&
+		sbx.Spec.ShutdownPolicy != agentsv1alpha1.SandboxShutdownPause {
 		return agentsv1alpha1.SandboxStateDead, "ShutdownTimeReached"
 	}
 	if sbx.Status.Phase == agentsv1alpha1.SandboxPending {
@@ -33,7 +36,9 @@ func GetSandboxState(sbx *agentsv1alpha1.Sandbox) (state string, reason string)
 
 	sandboxReady := IsSandboxReady(sbx)
 	if IsControlledBySandboxCR(sbx) {
-		if sandboxReady {
+		if sandboxReady && isSandboxInplaceUpdating(sbx) {
+			return agentsv1alpha1.SandboxStateCreating, "ResourceControlledBySbsAndInplaceUpdating"
+		} else if sandboxReady {
 			return agentsv1alpha1.SandboxStateAvailable, "ResourceControlledBySbsAndReady"
 		} else {
 			return agentsv1alpha1.SandboxStateCreating, "ResourceControlledBySbsButNotReady"
@@ -43,8 +48,12 @@ func GetSandboxState(sbx *agentsv1alpha1.Sandbox) (state string, reason string)
 			if sbx.Spec.Paused {
 				return agentsv1alpha1.SandboxStatePaused, "RunningResourceClaimedAndPaused"
 			} else {
-				if sandboxReady {
+				if sandboxReady && IsSandboxReiniting(sbx) {
+					return agentsv1alpha1.SandboxStateCreating, "RunningResourceClaimedAndReiniting"
+				} else if sandboxReady {
 					return agentsv1alpha1.SandboxStateRunning, "RunningResourceClaimedAndReady"
+				} else if isSandboxRestarting(sbx) {
+					return agentsv1alpha1.SandboxStateCreating, "RunningResourceClaimedAndRestarting"
 				} else {
 					return agentsv1alpha1.SandboxStateDead, "RunningResourceClaimedButNotReady"
 				}
@@ -77,3 +86,51 @@ func IsSandboxReady(sbx *agentsv1alpha1.Sandbox) bool {
 	readyCond := utils.GetSandboxCondition(&sbx.Status, string(agentsv1alpha1.SandboxConditionReady))
 	return readyCond != nil && readyCond.Status == metav1.ConditionTrue
 }
+
+// IsSandboxReiniting returns whether the claim-time init of the sandbox has not been replayed in its recreated pod yet,
+// see AnnotationInitedPod.
+func IsSandboxReiniting(sbx *agentsv1alpha1.Sandbox) bool {
+	inited, ok := sbx.Annotations[agentsv1alpha1.AnnotationInitedPod]
+	return ok && inited != string(sbx.Status.PodInfo.PodUID)
+}
+
+// isSandboxRestarting returns whether the lost pod of the sandbox is being recreated by the restart policy.
+func isSandboxRestarting(sbx *agentsv1alpha1.Sandbox) bool {
+	readyCond := utils.GetSandboxCondition(&sbx.Status, string(agentsv1alpha1.SandboxConditionReady))
+	return readyCond != nil && readyCond.Reason == agentsv1alpha1.SandboxReadyReasonRestarting
+}
+
+// isSandboxInplaceUpdating returns whether the sandbox is being updated in place, or its spec has not been observed
+// by the sandbox controller yet, so that the Ready condition may be stale.
+func isSandboxInplaceUpdating(sbx *agentsv1alpha1.Sandbox) bool {
+	if sbx.Status.ObservedGeneration < sbx.Generation {
+		return true
+	}
+	cond := utils.GetSandboxCondition(&sbx.Status, string(agentsv1alpha1.SandboxConditionInplaceUpdate))
+	return cond != nil && cond.Status == metav1.ConditionFalse &&
+		cond.Reason == agentsv1alpha1.SandboxInplaceUpdateReasonInplaceUpdating
+}
+
+// GetExposedPorts returns the ports declared by AnnotationExposedPorts, nil if not declared.
+func GetExposedPorts(annotations map[string]string) ([]agentsv1alpha1.SandboxPort, error) {
+	raw, ok := annotations[agentsv1alpha1.AnnotationExposedPorts]
+	if !ok {
+		return nil, nil
+	}
+	var ports []agentsv1alpha1.SandboxPort
+	if err := json.Unmarshal([]byte(raw), &ports); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %w", agentsv1alpha1.AnnotationExposedPorts, err)
+	}
+	return ports, nil
+}
+
+// GetPortByName returns the exposed port with the name, or defaultPort if not declared.
+func GetPortByName(annotations map[string]string, name string, defaultPort int32) int32 {
+	ports, _ := GetExposedPorts(annotations)
+	for _, port := range ports {
+		if port.Name == name {
+			return port.Port
+		}
+	}
+	return defaultPort
+}
@@ -51,6 +51,21 @@ func TestGetSandboxState(t *testing.T) {
 			expectedState:  agentsv1alpha1.SandboxStateDead,
 			expectedReason: "ShutdownTimeReached",
 		},
+		{
+			name: "Sandbox with expired ShutdownTime and pause policy",
+			sandbox: &agentsv1alpha1.Sandbox{
+				Spec: agentsv1alpha1.SandboxSpec{
+					ShutdownTime:   &pastTime,
+					ShutdownPolicy: agentsv1alpha1.SandboxShutdownPause,
+					Paused:         true,
+				},
+				Status: agentsv1alpha1.SandboxStatus{
+					Phase: agentsv1alpha1.SandboxPaused,
+				},
+			},
+			expectedState:  agentsv1alpha1.SandboxStatePaused,
+			expectedReason: "NotRunningResourceClaimed",
+		},
 		{
 			name: "Sandbox with future ShutdownTime",
 			sandbox: &agentsv1alpha1.Sandbox{
@@ -142,6 +157,69 @@ func TestGetSandboxState(t *testing.T) {
 			expectedState:  agentsv1alpha1.SandboxStateAvailable,
 			expectedReason: "ResourceControlledBySbsAndReady",
 		},
+		{
+			name: "Sandbox controlled by SandboxSet and Ready but generation not observed",
+			sandbox: &agentsv1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{
+					Generation: 2,
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "agents.kruise.io/v1alpha1",
+							Kind:       "SandboxSet",
+							Controller: &[]bool{true}[0],
+						},
+					},
+				},
+				Status: agentsv1alpha1.SandboxStatus{
+					ObservedGeneration: 1,
+					Phase:              agentsv1alpha1.SandboxRunning,
+					Conditions: []metav1.Condition{
+						{
+							Type:   string(agentsv1alpha1.SandboxConditionReady),
+							Status: metav1.ConditionTrue,
+						},
+					},
+					PodInfo: agentsv1alpha1.PodInfo{
+						PodIP: "1.2.3.4",
+					},
+				},
+			},
+			expectedState:  agentsv1alpha1.SandboxStateCreating,
+			expectedReason: "ResourceControlledBySbsAndInplaceUpdating",
+		},
+		{
+			name: "Sandbox controlled by SandboxSet and Ready but in-place updating",
+			sandbox: &agentsv1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{
+					OwnerReferences: []metav1.OwnerReference{
+						{
+							APIVersion: "agents.kruise.io/v1alpha1",
+							Kind:       "SandboxSet",
+							Controller: &[]bool{true}[0],
+						},
+					},
+				},
+				Status: agentsv1alpha1.SandboxStatus{
+					Phase: agentsv1alpha1.SandboxRunning,
+					Conditions: []metav1.Condition{
+						{
+							Type:   string(agentsv1alpha1.SandboxConditionReady),
+							Status: metav1.ConditionTrue,
+						},
+						{
+							Type:   string(agentsv1alpha1.SandboxConditionInplaceUpdate),
+							Status: metav1.ConditionFalse,
+							Reason: agentsv1alpha1.SandboxInplaceUpdateReasonInplaceUpdating,
+						},
+					},
+					PodInfo: agentsv1alpha1.PodInfo{
+						PodIP: "1.2.3.4",
+					},
+				},
+			},
+			expectedState:  agentsv1alpha1.SandboxStateCreating,
+			expectedReason: "ResourceControlledBySbsAndInplaceUpdating",
+		},
 		{
 			name: "Sandbox controlled by SandboxSet but not Ready",
 			sandbox: &agentsv1alpha1.Sandbox{
@@ -251,6 +329,69 @@ func TestGetSandboxState(t *testing.T) {
 			expectedState:  agentsv1alpha1.SandboxStateDead,
 			expectedReason: "RunningResourceClaimedButNotReady",
 		},
+		{
+			name: "Running Sandbox claimed and restarting",
+			sandbox: &agentsv1alpha1.Sandbox{
+				Status: agentsv1alpha1.SandboxStatus{
+					Phase: agentsv1alpha1.SandboxRunning,
+					Conditions: []metav1.Condition{
+						{
+							Type:   string(agentsv1alpha1.SandboxConditionReady),
+							Status: metav1.ConditionFalse,
+							Reason: agentsv1alpha1.SandboxReadyReasonRestarting,
+						},
+					},
+				},
+			},
+			expectedState:  agentsv1alpha1.SandboxStateCreating,
+			expectedReason: "RunningResourceClaimedAndRestarting",
+		},
+		{
+			name: "Running Sandbox claimed but not reinited in the recreated pod",
+			sandbox: &agentsv1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{agentsv1alpha1.AnnotationInitedPod: "old-pod"},
+				},
+				Status: agentsv1alpha1.SandboxStatus{
+					Phase: agentsv1alpha1.SandboxRunning,
+					Conditions: []metav1.Condition{
+						{
+							Type:   string(agentsv1alpha1.SandboxConditionReady),
+							Status: metav1.ConditionTrue,
+						},
+					},
+					PodInfo: agentsv1alpha1.PodInfo{
+						PodIP:  "1.2.3.4",
+						PodUID: "new-pod",
+					},
+				},
+			},
+			expectedState:  agentsv1alpha1.SandboxStateCreating,
+			expectedReason: "RunningResourceClaimedAndReiniting",
+		},
+		{
+			name: "Running Sandbox claimed and reinited",
+			sandbox: &agentsv1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{agentsv1alpha1.AnnotationInitedPod: "new-pod"},
+				},
+				Status: agentsv1alpha1.SandboxStatus{
+					Phase: agentsv1alpha1.SandboxRunning,
+					Conditions: []metav1.Condition{
+						{
+							Type:   string(agentsv1alpha1.SandboxConditionReady),
+							Status: metav1.ConditionTrue,
+						},
+					},
+					PodInfo: agentsv1alpha1.PodInfo{
+						PodIP:  "1.2.3.4",
+						PodUID: "new-pod",
+					},
+				},
+			},
+			expectedState:  agentsv1alpha1.SandboxStateRunning,
+			expectedReason: "RunningResourceClaimedAndReady",
+		},
 		{
 			name: "Not Running Sandbox claimed",
 			sandbox: &agentsv1alpha1.Sandbox{
@@ -395,3 +536,50 @@ func TestGetSandboxID(t *testing.T) {
 		})
 	}
 }
+
+func TestGetPortByName(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expectPort  int32
+		expectErr   bool
+	}{
+		{
+			name:       "not declared",
+			expectPort: agentsv1alpha1.DefaultEnvdPort,
+		},
+		{
+			name: "declared",
+			annotations: map[string]string{
+				agentsv1alpha1.AnnotationExposedPorts: `[{"name":"http","port":8080},{"name":"envd","port":50000}]`,
+			},
+			expectPort: 50000,
+		},
+		{
+			name: "declared without envd",
+			annotations: map[string]string{
+				agentsv1alpha1.AnnotationExposedPorts: `[{"name":"http","port":8080}]`,
+			},
+			expectPort: agentsv1alpha1.DefaultEnvdPort,
+		},
+		{
+			name: "invalid",
+			annotations: map[string]string{
+				agentsv1alpha1.AnnotationExposedPorts: `invalid`,
+			},
+			expectPort: agentsv1alpha1.DefaultEnvdPort,
+			expectErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GetExposedPorts(tt.annotations)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("GetExposedPorts() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if got := GetPortByName(tt.annotations, agentsv1alpha1.SandboxPortNameEnvd, agentsv1alpha1.DefaultEnvdPort); got != tt.expectPort {
+				t.Errorf("GetPortByName() = %d, expect %d", got, tt.expectPort)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2025 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templateref
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+)
+
+// SetE2BDefaults records the E2B defaults of a SandboxTemplate into the annotations of a sandbox created by sbs, the exposed ports
+// and the idle policy declared by the annotations of the SandboxSet take precedence over the ones of the SandboxTemplate.
+func SetE2BDefaults(annotations map[string]string, sbs *agentsv1alpha1.SandboxSet, e2b *agentsv1alpha1.SandboxTemplateE2B) error {
+	if e2b == nil {
+		e2b = &agentsv1alpha1.SandboxTemplateE2B{}
+	}
+	if e2b.InitEnvd {
+		annotations[agentsv1alpha1.AnnotationShouldInitEnvd] = agentsv1alpha1.True
+	}
+	if e2b.TimeoutSeconds != nil {
+		annotations[agentsv1alpha1.AnnotationDefaultTimeoutSeconds] = strconv.Itoa(int(*e2b.TimeoutSeconds))
+	}
+	ports := e2b.ExposedPorts
+	if raw, ok := sbs.Annotations[agentsv1alpha1.AnnotationExposedPorts]; ok {
+		ports = nil
+		if err := json.Unmarshal([]byte(raw), &ports); err != nil {
+			return fmt.Errorf("invalid annotation %s: %w", agentsv1alpha1.AnnotationExposedPorts, err)
+		}
+	}
+	if len(ports) > 0 {
+		by, err := json.Marshal(withEnvdPort(ports))
+		if err != nil {
+			return err
+		}
+		annotations[agentsv1alpha1.AnnotationExposedPorts] = string(by)
+	}
+	if seconds, ok := sbs.Annotations[agentsv1alpha1.AnnotationIdleTimeoutSeconds]; ok {
+		annotations[agentsv1alpha1.AnnotationIdleTimeoutSeconds] = seconds
+		if sbs.Annotations[agentsv1alpha1.AnnotationExtendTimeoutOnActivity] == agentsv1alpha1.True {
+			annotations[agentsv1alpha1.AnnotationExtendTimeoutOnActivity] = agentsv1alpha1.True
+		}
+	} else if e2b.IdleTimeoutSeconds != nil {
+		annotations[agentsv1alpha1.AnnotationIdleTimeoutSeconds] = strconv.Itoa(int(*e2b.IdleTimeoutSeconds))
+		if e2b.ExtendTimeoutOnActivity {
+			annotations[agentsv1alpha1.AnnotationExtendTimeoutOnActivity] = agentsv1alpha1.True
+		}
+	}
+	return nil
+}
+
+// withEnvdPort declares the default envd port if missing, which is always required by the E2B SDK.
+func withEnvdPort(ports []agentsv1alpha1.SandboxPort) []agentsv1alpha1.SandboxPort {
+	for _, port := range ports {
+		if port.Name == agentsv1alpha1.SandboxPortNameEnvd {
+			return ports
+		}
+	}
+	return append(slices.Clone(ports), agentsv1alpha1.SandboxPort{
+		Name: agentsv1alpha1.SandboxPortNameEnvd,
+		Port: agentsv1alpha1.DefaultEnvdPort,
+	})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2025 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templateref
+
+import (
+	"testing"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+)
+
+func TestSetE2BDefaults(t *testing.T) {
+	tests := []struct {
+		name           string
+		sbsAnnotations map[string]string
+		e2b            *agentsv1alpha1.SandboxTemplateE2B
+		expectErr      bool
+		expectPorts    string
+		expectIdle     map[string]string
+	}{
+		{
+			name: "no ports declared",
+			e2b:  &agentsv1alpha1.SandboxTemplateE2B{InitEnvd: true},
+		},
+		{
+			name: "ports of template with envd declared",
+			e2b: &agentsv1alpha1.SandboxTemplateE2B{ExposedPorts: []agentsv1alpha1.SandboxPort{
+				{Name: "envd", Port: 50000},
+				{Name: "cdp", Port: 9222, Protocol: agentsv1alpha1.SandboxPortProtocolWebSocket, Auth: agentsv1alpha1.SandboxPortAuthNone},
+			}},
+			expectPorts: `[{"name":"envd","port":50000},{"name":"cdp","port":9222,"protocol":"WebSocket","auth":"None"}]`,
+		},
+		{
+			name:           "ports of sandboxset take precedence",
+			sbsAnnotations: map[string]string{agentsv1alpha1.AnnotationExposedPorts: `[{"port":3000,"protocol":"HTTP"}]`},
+			e2b:            &agentsv1alpha1.SandboxTemplateE2B{ExposedPorts: []agentsv1alpha1.SandboxPort{{Port: 8080}}},
+			expectPorts:    `[{"port":3000,"protocol":"HTTP"},{"name":"envd","port":49983}]`,
+		},
+		{
+			name:           "ports of sandboxset without template",
+			sbsAnnotations: map[string]string{agentsv1alpha1.AnnotationExposedPorts: `[{"port":3000}]`},
+			expectPorts:    `[{"port":3000},{"name":"envd","port":49983}]`,
+		},
+		{
+			name: "idle policy of template",
+			e2b:  &agentsv1alpha1.SandboxTemplateE2B{IdleTimeoutSeconds: ptr.To[int32](900), ExtendTimeoutOnActivity: true},
+			expectIdle: map[string]string{
+				agentsv1alpha1.AnnotationIdleTimeoutSeconds:      "900",
+				agentsv1alpha1.AnnotationExtendTimeoutOnActivity: agentsv1alpha1.True,
+			},
+		},
+		{
+			name:           "idle policy of sandboxset takes precedence",
+			sbsAnnotations: map[string]string{agentsv1alpha1.AnnotationIdleTimeoutSeconds: "600"},
+			e2b:            &agentsv1alpha1.SandboxTemplateE2B{IdleTimeoutSeconds: ptr.To[int32](900), ExtendTimeoutOnActivity: true},
+			expectIdle:     map[string]string{agentsv1alpha1.AnnotationIdleTimeoutSeconds: "600"},
+		},
+		{
+			name:           "invalid ports of sandboxset",
+			sbsAnnotations: map[string]string{agentsv1alpha1.AnnotationExposedPorts: `3000`},
+			expectErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sbs := &agentsv1alpha1.SandboxSet{}
+			sbs.Annotations = tt.sbsAnnotations
+			annotations := map[string]string{}
+			err := SetE2BDefaults(annotations, sbs, tt.e2b)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.expectPorts == "" {
+				assert.NotContains(t, annotations, agentsv1alpha1.AnnotationExposedPorts)
+			} else {
+				assert.JSONEq(t, tt.expectPorts, annotations[agentsv1alpha1.AnnotationExposedPorts])
+			}
+			for _, key := range []string{agentsv1alpha1.AnnotationIdleTimeoutSeconds, agentsv1alpha1.AnnotationExtendTimeoutOnActivity} {
+				if v, ok := tt.expectIdle[key]; ok {
+					assert.Equal(t, v, annotations[key])
+				} else {
+					assert.NotContains(t, annotations, key)
+				}
+			}
+		})
+	}
+}
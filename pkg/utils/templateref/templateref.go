@@ -0,0 +1,196 @@
+/*
+Copyright 2025 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templateref
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultKind is the kind of templateRef when it is not specified.
+	DefaultKind = "PodTemplate"
+	// DefaultAPIVersion is the apiVersion of templateRef when it is not specified.
+	DefaultAPIVersion = "v1"
+)
+
+var (
+	// ErrUnsupportedKind is returned when templateRef points to a kind that cannot be resolved into a pod template.
+	ErrUnsupportedKind = errors.New("unsupported template kind")
+	// ErrRevisionNotReady is returned when the current revision of the referenced SandboxTemplate is not recorded yet.
+	ErrRevisionNotReady = errors.New("template revision not ready")
+)
+
+var (
+	podTemplateKind     = corev1.SchemeGroupVersion.WithKind(DefaultKind)
+	sandboxTemplateKind = agentsv1alpha1.SandboxTemplateControllerKind
+)
+
+// GetKind returns the kind of ref, default to PodTemplate.
+func GetKind(ref *agentsv1alpha1.SandboxTemplateRef) string {
+	if ref.Kind == nil || *ref.Kind == "" {
+		return DefaultKind
+	}
+	return *ref.Kind
+}
+
+// GetAPIVersion returns the apiVersion of ref, default to v1.
+func GetAPIVersion(ref *agentsv1alpha1.SandboxTemplateRef) string {
+	if ref.APIVersion == nil || *ref.APIVersion == "" {
+		return DefaultAPIVersion
+	}
+	return *ref.APIVersion
+}
+
+// GroupVersionKind returns the defaulted GroupVersionKind of ref.
+func GroupVersionKind(ref *agentsv1alpha1.SandboxTemplateRef) schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(GetAPIVersion(ref), GetKind(ref))
+}
+
+// SupportedKinds returns the GroupVersionKinds that templateRef can reference.
+func SupportedKinds() []string {
+	return []string{podTemplateKind.String(), sandboxTemplateKind.String()}
+}
+
+// IsSupported reports whether ref references a kind that can be resolved into a pod template.
+func IsSupported(ref *agentsv1alpha1.SandboxTemplateRef) bool {
+	gvk := GroupVersionKind(ref)
+	return gvk == podTemplateKind || gvk == sandboxTemplateKind
+}
+
+// IndexKey returns the key of ref used by the templateRef field index, e.g. PodTemplate/my-template.
+func IndexKey(kind, name string) string {
+	return kind + "/" + name
+}
+
+// IsUnresolvable reports whether err means the templateRef can never be resolved until the referenced object
+// or the ref itself changes, so that it is not worth retrying.
+func IsUnresolvable(err error) bool {
+	return apierrors.IsNotFound(err) || errors.Is(err, ErrUnsupportedKind) || errors.Is(err, ErrRevisionNotReady)
+}
+
+// Reason returns the reason of the TemplateResolved condition for the error returned by Resolve.
+func Reason(err error) string {
+	switch {
+	case err == nil:
+		return agentsv1alpha1.SandboxTemplateReasonResolved
+	case apierrors.IsNotFound(err):
+		return agentsv1alpha1.SandboxTemplateReasonNotFound
+	case errors.Is(err, ErrRevisionNotReady):
+		return agentsv1alpha1.SandboxTemplateReasonRevisionNotReady
+	default:
+		return agentsv1alpha1.SandboxTemplateReasonUnsupportedKind
+	}
+}
+
+// Resolve fetches the object referenced by ref in namespace and returns the template spec it carries.
+// Only spec.template is set if ref references a PodTemplate.
+func Resolve(ctx context.Context, c client.Reader, namespace string, ref *agentsv1alpha1.SandboxTemplateRef) (*agentsv1alpha1.SandboxTemplateSpec, error) {
+	gvk := GroupVersionKind(ref)
+	switch gvk {
+	case podTemplateKind:
+		podTemplate := &corev1.PodTemplate{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, podTemplate); err != nil {
+			return nil, err
+		}
+		return &agentsv1alpha1.SandboxTemplateSpec{Template: podTemplate.Template.DeepCopy()}, nil
+	case sandboxTemplateKind:
+		return resolveSandboxTemplate(ctx, c, namespace, ref)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKind, gvk.String())
+	}
+}
+
+// resolveSandboxTemplate returns the spec recorded in the pinned revision, or in the current revision if no revision
+// is pinned. The live spec of SandboxTemplate is never used, so that all consumers see the same immutable content.
+func resolveSandboxTemplate(ctx context.Context, c client.Reader, namespace string, ref *agentsv1alpha1.SandboxTemplateRef) (*agentsv1alpha1.SandboxTemplateSpec, error) {
+	revisionName := ref.Revision
+	if revisionName == "" {
+		sbt := &agentsv1alpha1.SandboxTemplate{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, sbt); err != nil {
+			return nil, err
+		}
+		if sbt.Status.CurrentRevision == "" || sbt.Status.ObservedGeneration != sbt.Generation {
+			return nil, fmt.Errorf("%w: current revision of SandboxTemplate %s is not recorded", ErrRevisionNotReady, ref.Name)
+		}
+		revisionName = sbt.Status.CurrentRevision
+	}
+	revision := &apps.ControllerRevision{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: revisionName}, revision); err != nil {
+		return nil, err
+	}
+	if revision.Labels[agentsv1alpha1.LabelSandboxTemplate] != ref.Name {
+		return nil, apierrors.NewNotFound(apps.Resource("controllerrevisions"), revisionName)
+	}
+	return DecodeRevision(revision)
+}
+
+// EncodeRevision returns the data of the revision recording spec, revisionHistoryLimit is not recorded.
+func EncodeRevision(spec *agentsv1alpha1.SandboxTemplateSpec) (runtime.RawExtension, error) {
+	clone := spec.DeepCopy()
+	clone.RevisionHistoryLimit = nil
+	by, err := json.Marshal(clone)
+	if err != nil {
+		return runtime.RawExtension{}, err
+	}
+	return runtime.RawExtension{Raw: by}, nil
+}
+
+// DecodeRevision returns the spec recorded in a SandboxTemplate revision.
+func DecodeRevision(revision *apps.ControllerRevision) (*agentsv1alpha1.SandboxTemplateSpec, error) {
+	spec := &agentsv1alpha1.SandboxTemplateSpec{}
+	if err := json.Unmarshal(revision.Data.Raw, spec); err != nil {
+		return nil, fmt.Errorf("failed to decode revision %s: %w", revision.Name, err)
+	}
+	if spec.Template == nil {
+		return nil, fmt.Errorf("revision %s has no template", revision.Name)
+	}
+	return spec, nil
+}
+
+// ResolveTemplate returns the template spec described by tmpl, and nil if neither Template nor TemplateRef is set.
+// Template takes precedence over TemplateRef, and the VolumeClaimTemplates of tmpl take precedence over the
+// referenced ones.
+func ResolveTemplate(ctx context.Context, c client.Reader, namespace string, tmpl *agentsv1alpha1.EmbeddedSandboxTemplate) (*agentsv1alpha1.SandboxTemplateSpec, error) {
+	if tmpl.Template != nil {
+		return &agentsv1alpha1.SandboxTemplateSpec{
+			Template:             tmpl.Template,
+			VolumeClaimTemplates: tmpl.VolumeClaimTemplates,
+		}, nil
+	}
+	if tmpl.TemplateRef == nil {
+		return nil, nil
+	}
+	spec, err := Resolve(ctx, c, namespace, tmpl.TemplateRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.VolumeClaimTemplates) > 0 {
+		spec.VolumeClaimTemplates = tmpl.VolumeClaimTemplates
+	}
+	return spec, nil
+}
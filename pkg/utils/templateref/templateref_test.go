@@ -0,0 +1,218 @@
+/*
+Copyright 2025 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templateref
+
+import (
+	"context"
+	"testing"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestResolveTemplate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	podTemplate := &corev1.PodTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "default"},
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "from-ref"}}},
+		},
+	}
+	inline := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "inline"}}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(podTemplate).Build()
+
+	tests := []struct {
+		name            string
+		namespace       string
+		tmpl            agentsv1alpha1.EmbeddedSandboxTemplate
+		expectImage     string
+		expectNil       bool
+		expectErr       bool
+		expectUnresolve bool
+	}{
+		{
+			name:      "neither template nor templateRef",
+			namespace: "default",
+			expectNil: true,
+		},
+		{
+			name:        "inline template takes precedence",
+			namespace:   "default",
+			tmpl:        agentsv1alpha1.EmbeddedSandboxTemplate{Template: inline, TemplateRef: &agentsv1alpha1.SandboxTemplateRef{Name: "shared"}},
+			expectImage: "inline",
+		},
+		{
+			name:        "default kind is PodTemplate",
+			namespace:   "default",
+			tmpl:        agentsv1alpha1.EmbeddedSandboxTemplate{TemplateRef: &agentsv1alpha1.SandboxTemplateRef{Name: "shared"}},
+			expectImage: "from-ref",
+		},
+		{
+			name:      "explicit PodTemplate kind",
+			namespace: "default",
+			tmpl: agentsv1alpha1.EmbeddedSandboxTemplate{TemplateRef: &agentsv1alpha1.SandboxTemplateRef{
+				Name: "shared", Kind: ptr.To("PodTemplate"), APIVersion: ptr.To("v1"),
+			}},
+			expectImage: "from-ref",
+		},
+		{
+			name:            "template in another namespace",
+			namespace:       "other",
+			tmpl:            agentsv1alpha1.EmbeddedSandboxTemplate{TemplateRef: &agentsv1alpha1.SandboxTemplateRef{Name: "shared"}},
+			expectNil:       true,
+			expectErr:       true,
+			expectUnresolve: true,
+		},
+		{
+			name:      "unsupported kind",
+			namespace: "default",
+			tmpl: agentsv1alpha1.EmbeddedSandboxTemplate{TemplateRef: &agentsv1alpha1.SandboxTemplateRef{
+				Name: "shared", Kind: ptr.To("Deployment"), APIVersion: ptr.To("apps/v1"),
+			}},
+			expectNil:       true,
+			expectErr:       true,
+			expectUnresolve: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveTemplate(context.Background(), c, tt.namespace, &tt.tmpl)
+			assert.Equal(t, tt.expectErr, err != nil)
+			assert.Equal(t, tt.expectUnresolve, IsUnresolvable(err))
+			if tt.expectNil {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equal(t, tt.expectImage, got.Template.Spec.Containers[0].Image)
+		})
+	}
+}
+
+func TestIsSupported(t *testing.T) {
+	assert.True(t, IsSupported(&agentsv1alpha1.SandboxTemplateRef{Name: "a"}))
+	assert.True(t, IsSupported(&agentsv1alpha1.SandboxTemplateRef{Name: "a", Kind: ptr.To("PodTemplate")}))
+	assert.False(t, IsSupported(&agentsv1alpha1.SandboxTemplateRef{Name: "a", Kind: ptr.To("PodTemplate"), APIVersion: ptr.To("apps/v1")}))
+	assert.False(t, IsSupported(&agentsv1alpha1.SandboxTemplateRef{Name: "a", Kind: ptr.To("Pod")}))
+	assert.True(t, IsSupported(&agentsv1alpha1.SandboxTemplateRef{
+		Name: "a", Kind: ptr.To("SandboxTemplate"), APIVersion: ptr.To(agentsv1alpha1.GroupVersion.String()),
+	}))
+}
+
+func newSandboxTemplateRevision(t *testing.T, template, name, image string) *apps.ControllerRevision {
+	data, err := EncodeRevision(&agentsv1alpha1.SandboxTemplateSpec{
+		Template: &corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: image}}},
+		},
+		PersistentContents:   []string{agentsv1alpha1.PersistentContentFilesystem},
+		RevisionHistoryLimit: ptr.To[int32](3),
+	})
+	assert.NoError(t, err)
+	return &apps.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{agentsv1alpha1.LabelSandboxTemplate: template},
+		},
+		Data: data,
+	}
+}
+
+func TestResolveSandboxTemplate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = agentsv1alpha1.AddToScheme(scheme)
+	ready := &agentsv1alpha1.SandboxTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "ready", Namespace: "default", Generation: 2},
+		Status:     agentsv1alpha1.SandboxTemplateStatus{ObservedGeneration: 2, CurrentRevision: "ready-v2"},
+	}
+	notReady := &agentsv1alpha1.SandboxTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "not-ready", Namespace: "default", Generation: 2},
+		Status:     agentsv1alpha1.SandboxTemplateStatus{ObservedGeneration: 1, CurrentRevision: "not-ready-v1"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ready, notReady,
+		newSandboxTemplateRevision(t, "ready", "ready-v1", "v1"),
+		newSandboxTemplateRevision(t, "ready", "ready-v2", "v2"),
+		newSandboxTemplateRevision(t, "not-ready", "not-ready-v1", "v1"),
+	).Build()
+
+	ref := func(name, revision string) *agentsv1alpha1.SandboxTemplateRef {
+		return &agentsv1alpha1.SandboxTemplateRef{
+			Name:       name,
+			Kind:       ptr.To(agentsv1alpha1.SandboxTemplateControllerKind.Kind),
+			APIVersion: ptr.To(agentsv1alpha1.GroupVersion.String()),
+			Revision:   revision,
+		}
+	}
+	tests := []struct {
+		name         string
+		ref          *agentsv1alpha1.SandboxTemplateRef
+		expectImage  string
+		expectReason string
+	}{
+		{
+			name:         "follow current revision",
+			ref:          ref("ready", ""),
+			expectImage:  "v2",
+			expectReason: agentsv1alpha1.SandboxTemplateReasonResolved,
+		},
+		{
+			name:         "pinned revision",
+			ref:          ref("ready", "ready-v1"),
+			expectImage:  "v1",
+			expectReason: agentsv1alpha1.SandboxTemplateReasonResolved,
+		},
+		{
+			name:         "pinned revision of another template",
+			ref:          ref("ready", "not-ready-v1"),
+			expectReason: agentsv1alpha1.SandboxTemplateReasonNotFound,
+		},
+		{
+			name:         "current revision not recorded",
+			ref:          ref("not-ready", ""),
+			expectReason: agentsv1alpha1.SandboxTemplateReasonRevisionNotReady,
+		},
+		{
+			name:         "template not found",
+			ref:          ref("missing", ""),
+			expectReason: agentsv1alpha1.SandboxTemplateReasonNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(context.Background(), c, "default", tt.ref)
+			assert.Equal(t, tt.expectReason, Reason(err))
+			if tt.expectImage == "" {
+				assert.True(t, IsUnresolvable(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectImage, got.Template.Spec.Containers[0].Image)
+			assert.Equal(t, []string{agentsv1alpha1.PersistentContentFilesystem}, got.PersistentContents)
+			assert.Nil(t, got.RevisionHistoryLimit)
+		})
+	}
+}
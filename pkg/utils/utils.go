@@ -22,7 +22,8 @@ import (
 func SetSandboxCondition(status *agentsv1alpha1.SandboxStatus, condition metav1.Condition) {
 	currentCond := GetSandboxCondition(status, condition.Type)
 	if currentCond != nil && currentCond.Status == condition.Status && currentCond.Reason == condition.Reason &&
-		currentCond.Message == condition.Message && currentCond.LastTransitionTime == condition.LastTransitionTime {
+		currentCond.Message == condition.Message && currentCond.LastTransitionTime == condition.LastTransitionTime &&
+		currentCond.ObservedGeneration == condition.ObservedGeneration {
 		return
 	} else if currentCond == nil {
 		status.Conditions = append(status.Conditions, condition)
@@ -32,6 +33,7 @@ func SetSandboxCondition(status *agentsv1alpha1.SandboxStatus, condition metav1.
 	currentCond.LastTransitionTime = condition.LastTransitionTime
 	currentCond.Reason = condition.Reason
 	currentCond.Message = condition.Message
+	currentCond.ObservedGeneration = condition.ObservedGeneration
 }
 
 func GetSandboxCondition(status *agentsv1alpha1.SandboxStatus, condType string) *metav1.Condition {
@@ -0,0 +1,60 @@
+package mutating
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/discovery"
+	"github.com/openkruise/agents/pkg/features"
+	"github.com/openkruise/agents/pkg/utils/defaults"
+	utilfeature "github.com/openkruise/agents/pkg/utils/feature"
+)
+
+var (
+	controllerKind = agentsv1alpha1.SchemeGroupVersion.WithKind("Sandbox")
+)
+
+type SandboxDefaulter struct {
+	Client  client.Client
+	Decoder admission.Decoder
+}
+
+// +kubebuilder:webhook:path=/default-sandbox,mutating=true,failurePolicy=fail,sideEffects=None,admissionReviewVersions=v1;v1beta1,groups=agents.kruise.io,resources=sandboxes,verbs=create;update,versions=v1alpha1,name=md-sbx.kb.io
+
+func (h *SandboxDefaulter) Path() string {
+	return "/default-sandbox"
+}
+
+func (h *SandboxDefaulter) Enabled() bool {
+	if !utilfeature.DefaultFeatureGate.Enabled(features.SandboxGate) || !discovery.DiscoverGVK(controllerKind) {
+		return false
+	}
+	return true
+}
+
+func (h *SandboxDefaulter) Handle(_ context.Context, req admission.Request) admission.Response {
+	obj := &agentsv1alpha1.Sandbox{}
+	err := h.Decoder.Decode(req, obj)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	clone := obj.DeepCopy()
+	defaults.SetDefaultPodTemplate(obj.Spec.Template)
+	defaults.SetDefaultVolumeClaimTemplates(obj.Spec.VolumeClaimTemplates)
+
+	if !reflect.DeepEqual(obj, clone) {
+		marshal, err := json.Marshal(obj)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		return admission.PatchResponseFromRaw(req.Object.Raw, marshal)
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,98 @@
+package mutating
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"github.com/openkruise/agents/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestSandboxDefaulter_Handle(t *testing.T) {
+	err := v1alpha1.AddToScheme(scheme.Scheme)
+	require.NoError(t, err)
+
+	template := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "test-container", Image: "nginx:latest"}},
+		},
+	}
+	tests := []struct {
+		name        string
+		sandbox     *v1alpha1.Sandbox
+		expectPatch bool
+	}{
+		{
+			name: "template is defaulted",
+			sandbox: &v1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-sbx", Namespace: "default"},
+				Spec: v1alpha1.SandboxSpec{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						Template: template.DeepCopy(),
+						VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+							{ObjectMeta: metav1.ObjectMeta{Name: "data"}},
+						},
+					},
+				},
+			},
+			expectPatch: true,
+		},
+		{
+			name: "templateRef is not defaulted",
+			sandbox: &v1alpha1.Sandbox{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-sbx", Namespace: "default"},
+				Spec: v1alpha1.SandboxSpec{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "tmpl", Kind: ptr.To("SandboxTemplate")},
+					},
+				},
+			},
+			expectPatch: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+
+			var objs []runtime.Object
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objs...).Build()
+			defaulter := &SandboxDefaulter{
+				Client:  fakeClient,
+				Decoder: admission.NewDecoder(scheme.Scheme),
+			}
+
+			sbxRaw, err := json.Marshal(tt.sandbox)
+			require.NoError(t, err)
+			req := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: sbxRaw},
+				},
+			}
+			response := defaulter.Handle(context.TODO(), req)
+			g.Expect(response.Allowed).To(gomega.BeTrue())
+			if !tt.expectPatch {
+				g.Expect(response.Patches).To(gomega.BeEmpty())
+				return
+			}
+			g.Expect(response.Patches).NotTo(gomega.BeEmpty())
+			var paths []string
+			for _, patch := range response.Patches {
+				paths = append(paths, patch.Path)
+			}
+			g.Expect(paths).To(gomega.ContainElement("/spec/template/spec/automountServiceAccountToken"))
+			g.Expect(paths).To(gomega.ContainElement("/spec/volumeClaimTemplates/0/spec/accessModes"))
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package validating
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/controller/sandbox/core"
+	"github.com/openkruise/agents/pkg/discovery"
+	"github.com/openkruise/agents/pkg/features"
+	"github.com/openkruise/agents/pkg/utils/defaults"
+	utilfeature "github.com/openkruise/agents/pkg/utils/feature"
+	webhookutils "github.com/openkruise/agents/pkg/webhook/utils"
+)
+
+var controllerKind = agentsv1alpha1.SchemeGroupVersion.WithKind("Sandbox")
+
+type SandboxValidatingHandler struct {
+	Client  client.Client
+	Decoder admission.Decoder
+}
+
+// +kubebuilder:webhook:path=/validate-sandbox,mutating=false,failurePolicy=fail,sideEffects=None,admissionReviewVersions=v1;v1beta1,groups=agents.kruise.io,resources=sandboxes,verbs=create;update,versions=v1alpha1,name=v-sbx.kb.io
+
+func (h *SandboxValidatingHandler) Path() string {
+	return "/validate-sandbox"
+}
+
+func (h *SandboxValidatingHandler) Enabled() bool {
+	if !utilfeature.DefaultFeatureGate.Enabled(features.SandboxGate) || !discovery.DiscoverGVK(controllerKind) {
+		return false
+	}
+	return true
+}
+
+func (h *SandboxValidatingHandler) Handle(_ context.Context, req admission.Request) admission.Response {
+	obj := &agentsv1alpha1.Sandbox{}
+	err := h.Decoder.Decode(req, obj)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	var oldObj *agentsv1alpha1.Sandbox
+	if req.Operation == admissionv1.Update {
+		oldObj = &agentsv1alpha1.Sandbox{}
+		if err = h.Decoder.DecodeRaw(req.OldObject, oldObj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// the terminating sandbox is only updated to remove finalizers
+		if !obj.DeletionTimestamp.IsZero() {
+			return admission.Allowed("")
+		}
+	}
+
+	var errList field.ErrorList
+	errList = append(errList, validation.ValidateObjectMeta(&obj.ObjectMeta, true, validation.NameIsDNSSubdomain, field.NewPath("metadata"))...)
+	errList = append(errList, validateSandboxSpec(obj.Spec, field.NewPath("spec"))...)
+	if oldObj != nil {
+		errList = append(errList, validateSandboxUpdate(obj, oldObj, field.NewPath("spec"))...)
+	}
+	if !webhookutils.IsTrusted(req.UserInfo) {
+		errList = append(errList, validateInternalKeys(obj, oldObj, field.NewPath("metadata"))...)
+	}
+	if len(errList) > 0 {
+		return admission.Errored(http.StatusUnprocessableEntity, errList.ToAggregate())
+	}
+	return admission.Allowed("")
+}
+
+func validateSandboxSpec(spec agentsv1alpha1.SandboxSpec, fldPath *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	switch {
+	case spec.Template != nil && spec.TemplateRef != nil:
+		errList = append(errList, field.Forbidden(fldPath.Child("templateRef"), "template and templateRef are mutually exclusive"))
+	case spec.Template == nil && spec.TemplateRef == nil:
+		errList = append(errList, field.Required(fldPath.Child("template"), "either template or templateRef must be set"))
+	case spec.TemplateRef != nil:
+		errList = append(errList, webhookutils.ValidateTemplateRef(spec.TemplateRef, fldPath.Child("templateRef"))...)
+	default:
+		errList = append(errList, webhookutils.ValidatePodTemplateSpec(spec.Template, fldPath.Child("template"))...)
+	}
+
+	// the contents without a persistence backend, such as memory by default, would keep the pod of a paused sandbox
+	supportedPersistentContents := core.SupportedPersistentContents()
+	seen := sets.New[string]()
+	for i, content := range spec.PersistentContents {
+		contentFld := fldPath.Child("persistentContents").Index(i)
+		if !supportedPersistentContents.Has(content) {
+			errList = append(errList, field.NotSupported(contentFld, content, sets.List(supportedPersistentContents)))
+		} else if seen.Has(content) {
+			errList = append(errList, field.Duplicate(contentFld, content))
+		}
+		seen.Insert(content)
+	}
+	if spec.PausedRetentionSeconds != nil && *spec.PausedRetentionSeconds <= 0 {
+		errList = append(errList, field.Invalid(fldPath.Child("pausedRetentionSeconds"), *spec.PausedRetentionSeconds, "must be positive"))
+	}
+	return errList
+}
+
+// validateSandboxUpdate forbids changing the fields which cannot be updated in place, the same as what
+// SandboxHashWithoutImageAndResources covers.
+func validateSandboxUpdate(obj, oldObj *agentsv1alpha1.Sandbox, fldPath *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	if (obj.Spec.Template == nil) != (oldObj.Spec.Template == nil) {
+		errList = append(errList, field.Forbidden(fldPath.Child("template"), "cannot switch between template and templateRef"))
+	} else if obj.Spec.Template != nil && hashWithoutImageAndResources(obj) != hashWithoutImageAndResources(oldObj) {
+		errList = append(errList, field.Forbidden(fldPath.Child("template"), "only images and resources of containers can be updated"))
+	}
+	if !apiequality.Semantic.DeepEqual(obj.Spec.TemplateRef, oldObj.Spec.TemplateRef) {
+		errList = append(errList, field.Forbidden(fldPath.Child("templateRef"), "templateRef is immutable"))
+	}
+	if !apiequality.Semantic.DeepEqual(defaultedVolumeClaimTemplates(obj), defaultedVolumeClaimTemplates(oldObj)) {
+		errList = append(errList, field.Forbidden(fldPath.Child("volumeClaimTemplates"), "volumeClaimTemplates is immutable"))
+	}
+	// the sandbox is being deleted once its shutdown time is reached, unless it is paused by the shutdown policy
+	if oldObj.Spec.ShutdownPolicy != agentsv1alpha1.SandboxShutdownPause && oldObj.Spec.ShutdownTime != nil &&
+		oldObj.Spec.ShutdownTime.Time.Before(time.Now()) && !apiequality.Semantic.DeepEqual(obj.Spec.ShutdownTime, oldObj.Spec.ShutdownTime) {
+		errList = append(errList, field.Forbidden(fldPath.Child("shutdownTime"), "shutdownTime cannot be changed after it is reached"))
+	}
+	return errList
+}
+
+// hashWithoutImageAndResources hashes the defaulted template, in case the old sandbox was created without defaults
+func hashWithoutImageAndResources(sbx *agentsv1alpha1.Sandbox) string {
+	clone := sbx.DeepCopy()
+	defaults.SetDefaultPodTemplate(clone.Spec.Template)
+	_, hash := core.HashSandbox(clone)
+	return hash
+}
+
+func defaultedVolumeClaimTemplates(sbx *agentsv1alpha1.Sandbox) []corev1.PersistentVolumeClaim {
+	clone := sbx.DeepCopy()
+	defaults.SetDefaultVolumeClaimTemplates(clone.Spec.VolumeClaimTemplates)
+	return clone.Spec.VolumeClaimTemplates
+}
+
+// validateInternalKeys forbids setting or changing the labels and annotations managed by the controllers and the
+// sandbox-manager, such as the owner and the lock of a claimed sandbox.
+func validateInternalKeys(obj, oldObj *agentsv1alpha1.Sandbox, fldPath *field.Path) field.ErrorList {
+	var oldLabels, oldAnnotations map[string]string
+	if oldObj != nil {
+		oldLabels, oldAnnotations = oldObj.Labels, oldObj.Annotations
+	}
+	var errList field.ErrorList
+	errList = append(errList, validateInternalKeysOf(obj.Labels, oldLabels, fldPath.Child("labels"))...)
+	errList = append(errList, validateInternalKeysOf(obj.Annotations, oldAnnotations, fldPath.Child("annotations"))...)
+	return errList
+}
+
+func validateInternalKeysOf(m, oldM map[string]string, fldPath *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	keys := sets.KeySet(m).Union(sets.KeySet(oldM))
+	for _, k := range sets.List(keys) {
+		if !isInternalKey(k) {
+			continue
+		}
+		v, ok := m[k]
+		oldV, oldOk := oldM[k]
+		if ok != oldOk || v != oldV {
+			errList = append(errList, field.Forbidden(fldPath.Key(k), "internal key can only be modified by the sandbox-controller or the sandbox-manager"))
+		}
+	}
+	return errList
+}
+
+func isInternalKey(k string) bool {
+	return strings.HasPrefix(k, agentsv1alpha1.InternalPrefix) || strings.HasPrefix(k, agentsv1alpha1.E2BPrefix)
+}
@@ -0,0 +1,275 @@
+package validating
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"github.com/openkruise/agents/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	controllerUser = "system:serviceaccount:sandbox-system:sandbox-controller-manager"
+	normalUser     = "alice"
+)
+
+func newSandbox() *v1alpha1.Sandbox {
+	return &v1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sbx", Namespace: "default"},
+		Spec: v1alpha1.SandboxSpec{
+			EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+				Template: &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						RestartPolicy:                 corev1.RestartPolicyAlways,
+						DNSPolicy:                     corev1.DNSClusterFirst,
+						TerminationGracePeriodSeconds: new(int64),
+						Containers: []corev1.Container{
+							{
+								Name:                     "test",
+								Image:                    "nginx:latest",
+								ImagePullPolicy:          corev1.PullAlways,
+								TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestSandboxValidatingHandler_Handle(t *testing.T) {
+	err := v1alpha1.AddToScheme(scheme.Scheme)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		user         string
+		oldSandbox   *v1alpha1.Sandbox
+		modify       func(sbx *v1alpha1.Sandbox)
+		expectAllow  bool
+		errorMessage string
+	}{
+		{
+			name:        "valid sandbox",
+			user:        normalUser,
+			modify:      func(sbx *v1alpha1.Sandbox) {},
+			expectAllow: true,
+		},
+		{
+			name: "template and templateRef are both set",
+			user: normalUser,
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Spec.TemplateRef = &v1alpha1.SandboxTemplateRef{Name: "tmpl", Kind: ptr.To("SandboxTemplate")}
+			},
+			errorMessage: "template and templateRef are mutually exclusive",
+		},
+		{
+			name: "neither template nor templateRef is set",
+			user: normalUser,
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Spec.Template = nil
+			},
+			errorMessage: "either template or templateRef must be set",
+		},
+		{
+			name: "unsupported persistent content",
+			user: normalUser,
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Spec.PersistentContents = []string{v1alpha1.PersistentContentIp, "disk"}
+			},
+			errorMessage: `spec.persistentContents[1]: Unsupported value: "disk"`,
+		},
+		{
+			name: "duplicated persistent content",
+			user: normalUser,
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Spec.PersistentContents = []string{v1alpha1.PersistentContentIp, v1alpha1.PersistentContentIp}
+			},
+			errorMessage: "spec.persistentContents[1]: Duplicate value",
+		},
+		{
+			name: "persistent content without backend",
+			user: normalUser,
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Spec.PersistentContents = []string{v1alpha1.PersistentContentMemory}
+			},
+			errorMessage: `spec.persistentContents[0]: Unsupported value: "memory"`,
+		},
+		{
+			name: "user creates sandbox with lock",
+			user: normalUser,
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Annotations = map[string]string{v1alpha1.AnnotationLock: "lock"}
+			},
+			errorMessage: "metadata.annotations[agents.kruise.io/lock]: Forbidden",
+		},
+		{
+			name: "controller creates sandbox with internal keys",
+			user: controllerUser,
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Labels = map[string]string{v1alpha1.LabelSandboxPool: "pool"}
+				sbx.Annotations = map[string]string{v1alpha1.AnnotationOwner: "owner"}
+			},
+			expectAllow: true,
+		},
+		{
+			name:       "user changes owner",
+			user:       normalUser,
+			oldSandbox: withAnnotations(newSandbox(), v1alpha1.AnnotationOwner, "owner"),
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Annotations[v1alpha1.AnnotationOwner] = "hacker"
+			},
+			errorMessage: "metadata.annotations[agents.kruise.io/owner]: Forbidden",
+		},
+		{
+			name:       "user removes lock",
+			user:       normalUser,
+			oldSandbox: withAnnotations(newSandbox(), v1alpha1.AnnotationLock, "lock"),
+			modify: func(sbx *v1alpha1.Sandbox) {
+				delete(sbx.Annotations, v1alpha1.AnnotationLock)
+			},
+			errorMessage: "metadata.annotations[agents.kruise.io/lock]: Forbidden",
+		},
+		{
+			name:       "user updates other annotations",
+			user:       normalUser,
+			oldSandbox: withAnnotations(newSandbox(), v1alpha1.AnnotationOwner, "owner"),
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Annotations["foo"] = "bar"
+			},
+			expectAllow: true,
+		},
+		{
+			name:       "sandbox-manager changes owner",
+			user:       "system:serviceaccount:sandbox-system:sandbox-manager",
+			oldSandbox: withAnnotations(newSandbox(), v1alpha1.AnnotationOwner, "owner"),
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Annotations[v1alpha1.AnnotationOwner] = "another"
+			},
+			expectAllow: true,
+		},
+		{
+			name:       "update image and resources in place",
+			user:       normalUser,
+			oldSandbox: newSandbox(),
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Spec.Template.Spec.Containers[0].Image = "nginx:new"
+				sbx.Spec.Template.Spec.Containers[0].Resources.Limits = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+			},
+			expectAllow: true,
+		},
+		{
+			name:       "update fields not in-place updatable",
+			user:       controllerUser,
+			oldSandbox: newSandbox(),
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "FOO", Value: "bar"}}
+			},
+			errorMessage: "only images and resources of containers can be updated",
+		},
+		{
+			name:       "update volumeClaimTemplates",
+			user:       normalUser,
+			oldSandbox: newSandbox(),
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}}
+			},
+			errorMessage: "volumeClaimTemplates is immutable",
+		},
+		{
+			name: "extend reached shutdown time",
+			user: normalUser,
+			oldSandbox: func() *v1alpha1.Sandbox {
+				sbx := newSandbox()
+				sbx.Spec.ShutdownTime = ptr.To(metav1.NewTime(time.Now().Add(-time.Minute)))
+				return sbx
+			}(),
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Spec.ShutdownTime = ptr.To(metav1.NewTime(time.Now().Add(time.Hour)))
+			},
+			errorMessage: "shutdownTime cannot be changed after it is reached",
+		},
+		{
+			name: "resume sandbox paused by shutdown policy",
+			user: normalUser,
+			oldSandbox: func() *v1alpha1.Sandbox {
+				sbx := newSandbox()
+				sbx.Spec.Paused = true
+				sbx.Spec.ShutdownPolicy = v1alpha1.SandboxShutdownPause
+				sbx.Spec.ShutdownTime = ptr.To(metav1.NewTime(time.Now().Add(-time.Minute)))
+				return sbx
+			}(),
+			modify: func(sbx *v1alpha1.Sandbox) {
+				sbx.Spec.Paused = false
+				sbx.Spec.ShutdownTime = ptr.To(metav1.NewTime(time.Now().Add(time.Hour)))
+			},
+			expectAllow: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+
+			var objs []runtime.Object
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objs...).Build()
+			handler := &SandboxValidatingHandler{
+				Client:  fakeClient,
+				Decoder: admission.NewDecoder(scheme.Scheme),
+			}
+
+			req := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					UserInfo:  authenticationv1.UserInfo{Username: tt.user},
+				},
+			}
+			sbx := newSandbox()
+			if tt.oldSandbox != nil {
+				oldRaw, err := json.Marshal(tt.oldSandbox)
+				require.NoError(t, err)
+				req.Operation = admissionv1.Update
+				req.OldObject = runtime.RawExtension{Raw: oldRaw}
+				sbx = tt.oldSandbox.DeepCopy()
+			}
+			tt.modify(sbx)
+			sbxRaw, err := json.Marshal(sbx)
+			require.NoError(t, err)
+			req.Object = runtime.RawExtension{Raw: sbxRaw}
+
+			response := handler.Handle(context.TODO(), req)
+			if tt.expectAllow {
+				t.Log(response.String())
+				g.Expect(response.Allowed).To(gomega.BeTrue())
+			} else {
+				g.Expect(response.Allowed).To(gomega.BeFalse())
+				g.Expect(response.Result).NotTo(gomega.BeNil())
+				g.Expect(response.Result.Message).To(gomega.ContainSubstring(tt.errorMessage))
+			}
+		})
+	}
+}
+
+func withAnnotations(sbx *v1alpha1.Sandbox, kv ...string) *v1alpha1.Sandbox {
+	if sbx.Annotations == nil {
+		sbx.Annotations = map[string]string{}
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		sbx.Annotations[kv[i]] = kv[i+1]
+	}
+	return sbx
+}
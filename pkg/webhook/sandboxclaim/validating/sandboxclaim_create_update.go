@@ -0,0 +1,133 @@
+package validating
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/discovery"
+	"github.com/openkruise/agents/pkg/features"
+	utilfeature "github.com/openkruise/agents/pkg/utils/feature"
+	webhookutils "github.com/openkruise/agents/pkg/webhook/utils"
+)
+
+type SandboxClaimValidatingHandler struct {
+	Client  client.Client
+	Decoder admission.Decoder
+}
+
+// +kubebuilder:webhook:path=/validate-sandboxclaim,mutating=false,failurePolicy=fail,sideEffects=None,admissionReviewVersions=v1;v1beta1,groups=agents.kruise.io,resources=sandboxclaims,verbs=create;update,versions=v1alpha1,name=v-sbc.kb.io
+
+func (h *SandboxClaimValidatingHandler) Path() string {
+	return "/validate-sandboxclaim"
+}
+
+func (h *SandboxClaimValidatingHandler) Enabled() bool {
+	if !utilfeature.DefaultFeatureGate.Enabled(features.SandboxClaimGate) || !discovery.DiscoverGVK(agentsv1alpha1.SandboxClaimControllerKind) {
+		return false
+	}
+	return true
+}
+
+func (h *SandboxClaimValidatingHandler) Handle(_ context.Context, req admission.Request) admission.Response {
+	obj := &agentsv1alpha1.SandboxClaim{}
+	err := h.Decoder.Decode(req, obj)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	var oldObj *agentsv1alpha1.SandboxClaim
+	if req.Operation == admissionv1.Update {
+		oldObj = &agentsv1alpha1.SandboxClaim{}
+		if err = h.Decoder.DecodeRaw(req.OldObject, oldObj); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		// the terminating claim is only updated to remove finalizers
+		if !obj.DeletionTimestamp.IsZero() {
+			return admission.Allowed("")
+		}
+	}
+
+	var errList field.ErrorList
+	errList = append(errList, validation.ValidateObjectMeta(&obj.ObjectMeta, true, validation.NameIsDNSSubdomain, field.NewPath("metadata"))...)
+	errList = append(errList, validateSandboxClaimSpec(obj.Spec, field.NewPath("spec"))...)
+	if oldObj != nil {
+		errList = append(errList, validateSandboxClaimUpdate(obj, oldObj, field.NewPath("spec"))...)
+	}
+	if !webhookutils.IsTrusted(req.UserInfo) {
+		errList = append(errList, validateOwner(obj, oldObj, req.UserInfo.Username, field.NewPath("spec", "owner"))...)
+	}
+	if len(errList) > 0 {
+		return admission.Errored(http.StatusUnprocessableEntity, errList.ToAggregate())
+	}
+	return admission.Allowed("")
+}
+
+func validateSandboxClaimSpec(spec agentsv1alpha1.SandboxClaimSpec, fldPath *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	if spec.SandboxSetName == "" {
+		errList = append(errList, field.Required(fldPath.Child("sandboxSetName"), "sandboxSetName is required"))
+	} else {
+		for _, msg := range validation.NameIsDNSSubdomain(spec.SandboxSetName, false) {
+			errList = append(errList, field.Invalid(fldPath.Child("sandboxSetName"), spec.SandboxSetName, msg))
+		}
+	}
+	if spec.TimeoutSeconds != nil && *spec.TimeoutSeconds < 1 {
+		errList = append(errList, field.Invalid(fldPath.Child("timeoutSeconds"), *spec.TimeoutSeconds, "must be positive"))
+	}
+	switch spec.ReclaimPolicy {
+	case "", agentsv1alpha1.SandboxClaimReclaimDelete, agentsv1alpha1.SandboxClaimReclaimRetain:
+	default:
+		errList = append(errList, field.NotSupported(fldPath.Child("reclaimPolicy"), spec.ReclaimPolicy, []agentsv1alpha1.SandboxClaimReclaimPolicy{
+			agentsv1alpha1.SandboxClaimReclaimDelete, agentsv1alpha1.SandboxClaimReclaimRetain}))
+	}
+	errList = append(errList, metav1validation.ValidateLabels(spec.Labels, fldPath.Child("labels"))...)
+	errList = append(errList, validation.ValidateAnnotations(spec.Annotations, fldPath.Child("annotations"))...)
+	errList = append(errList, validateInnerKeys(spec.Labels, fldPath.Child("labels"))...)
+	errList = append(errList, validateInnerKeys(spec.Annotations, fldPath.Child("annotations"))...)
+	return errList
+}
+
+// validateInnerKeys forbids the claim from overriding the keys managed by the controllers, the same as the metadata
+// of the E2B API.
+func validateInnerKeys(m map[string]string, fldPath *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	for k := range m {
+		if strings.HasPrefix(k, agentsv1alpha1.InternalPrefix) || strings.HasPrefix(k, agentsv1alpha1.E2BPrefix) {
+			errList = append(errList, field.Forbidden(fldPath.Key(k), "keys managed by the controllers are forbidden"))
+		}
+	}
+	return errList
+}
+
+// validateOwner forbids the claim from impersonating the other users, the owner set or changed by the untrusted
+// requester must be the requester itself.
+func validateOwner(obj, oldObj *agentsv1alpha1.SandboxClaim, username string, fldPath *field.Path) field.ErrorList {
+	owner := obj.Spec.Owner
+	if owner == "" || owner == username || (oldObj != nil && oldObj.Spec.Owner == owner) {
+		return nil
+	}
+	return field.ErrorList{field.Forbidden(fldPath, "owner must be empty or the requesting user, unless claimed by the trusted service accounts")}
+}
+
+// validateSandboxClaimUpdate forbids changing the spec of a bound claim, which is applied to the sandbox only at
+// binding, except the reclaim policy.
+func validateSandboxClaimUpdate(obj, oldObj *agentsv1alpha1.SandboxClaim, fldPath *field.Path) field.ErrorList {
+	if oldObj.Status.SandboxName == "" {
+		return nil
+	}
+	spec, oldSpec := obj.Spec.DeepCopy(), oldObj.Spec.DeepCopy()
+	spec.ReclaimPolicy, oldSpec.ReclaimPolicy = "", ""
+	if apiequality.Semantic.DeepEqual(spec, oldSpec) {
+		return nil
+	}
+	return field.ErrorList{field.Forbidden(fldPath, "spec of a bound claim is immutable except reclaimPolicy")}
+}
@@ -0,0 +1,229 @@
+package validating
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"github.com/openkruise/agents/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newSandboxClaim() *v1alpha1.SandboxClaim {
+	return &v1alpha1.SandboxClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-claim", Namespace: "default"},
+		Spec: v1alpha1.SandboxClaimSpec{
+			SandboxSetName: "pool",
+			TimeoutSeconds: ptr.To[int32](300),
+			Labels:         map[string]string{"foo": "bar"},
+		},
+	}
+}
+
+func newBoundSandboxClaim() *v1alpha1.SandboxClaim {
+	claim := newSandboxClaim()
+	claim.Status.Phase = v1alpha1.SandboxClaimBound
+	claim.Status.SandboxName = "sbx"
+	return claim
+}
+
+func TestSandboxClaimValidatingHandler_Handle(t *testing.T) {
+	err := v1alpha1.AddToScheme(scheme.Scheme)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		username     string
+		oldClaim     *v1alpha1.SandboxClaim
+		modify       func(claim *v1alpha1.SandboxClaim)
+		expectAllow  bool
+		errorMessage string
+	}{
+		{
+			name:        "valid claim",
+			modify:      func(claim *v1alpha1.SandboxClaim) {},
+			expectAllow: true,
+		},
+		{
+			name: "sandboxSetName is required",
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.SandboxSetName = ""
+			},
+			errorMessage: "sandboxSetName is required",
+		},
+		{
+			name: "invalid sandboxSetName",
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.SandboxSetName = "Pool_1"
+			},
+			errorMessage: "spec.sandboxSetName",
+		},
+		{
+			name: "non-positive timeout",
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.TimeoutSeconds = ptr.To[int32](0)
+			},
+			errorMessage: "must be positive",
+		},
+		{
+			name: "unsupported reclaimPolicy",
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.ReclaimPolicy = "Recycle"
+			},
+			errorMessage: "spec.reclaimPolicy",
+		},
+		{
+			name: "invalid label",
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.Labels = map[string]string{"foo": "bar baz"}
+			},
+			errorMessage: "spec.labels",
+		},
+		{
+			name: "internal annotation",
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.Annotations = map[string]string{v1alpha1.AnnotationLock: "hack"}
+			},
+			errorMessage: "keys managed by the controllers are forbidden",
+		},
+		{
+			name: "e2b label",
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.Labels = map[string]string{v1alpha1.AnnotationEnvdAccessToken: "hack"}
+			},
+			errorMessage: "keys managed by the controllers are forbidden",
+		},
+		{
+			name:     "owner is the requesting user",
+			username: "alice",
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.Owner = "alice"
+			},
+			expectAllow: true,
+		},
+		{
+			name:     "owner is another user",
+			username: "alice",
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.Owner = "bob"
+			},
+			errorMessage: "spec.owner",
+		},
+		{
+			name:     "owner set by the trusted service account",
+			username: "system:serviceaccount:sandbox-system:sandbox-manager",
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.Owner = "bob"
+			},
+			expectAllow: true,
+		},
+		{
+			name:     "update pending claim with the owner unchanged",
+			username: "alice",
+			oldClaim: func() *v1alpha1.SandboxClaim {
+				claim := newSandboxClaim()
+				claim.Spec.Owner = "bob"
+				return claim
+			}(),
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.Image = "new-image"
+			},
+			expectAllow: true,
+		},
+		{
+			name:     "update owner of pending claim to another user",
+			username: "alice",
+			oldClaim: newSandboxClaim(),
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.Owner = "bob"
+			},
+			errorMessage: "spec.owner",
+		},
+		{
+			name:     "update pending claim",
+			oldClaim: newSandboxClaim(),
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.SandboxSetName = "another"
+				claim.Spec.Image = "new-image"
+			},
+			expectAllow: true,
+		},
+		{
+			name:     "update reclaimPolicy of bound claim",
+			oldClaim: newBoundSandboxClaim(),
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.ReclaimPolicy = v1alpha1.SandboxClaimReclaimRetain
+			},
+			expectAllow: true,
+		},
+		{
+			name:     "update image of bound claim",
+			oldClaim: newBoundSandboxClaim(),
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.Image = "new-image"
+			},
+			errorMessage: "spec of a bound claim is immutable except reclaimPolicy",
+		},
+		{
+			name:     "update sandboxSetName of bound claim",
+			oldClaim: newBoundSandboxClaim(),
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.Spec.SandboxSetName = "another"
+			},
+			errorMessage: "spec of a bound claim is immutable except reclaimPolicy",
+		},
+		{
+			name:     "update terminating claim",
+			oldClaim: newBoundSandboxClaim(),
+			modify: func(claim *v1alpha1.SandboxClaim) {
+				claim.DeletionTimestamp = ptr.To(metav1.Now())
+				claim.Spec.Image = "new-image"
+			},
+			expectAllow: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+			handler := &SandboxClaimValidatingHandler{
+				Client:  fakeClient,
+				Decoder: admission.NewDecoder(scheme.Scheme),
+			}
+			req := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Create},
+			}
+			req.UserInfo.Username = tt.username
+			claim := newSandboxClaim()
+			if tt.oldClaim != nil {
+				oldRaw, err := json.Marshal(tt.oldClaim)
+				require.NoError(t, err)
+				req.Operation = admissionv1.Update
+				req.OldObject = runtime.RawExtension{Raw: oldRaw}
+				claim = tt.oldClaim.DeepCopy()
+			}
+			tt.modify(claim)
+			claimRaw, err := json.Marshal(claim)
+			require.NoError(t, err)
+			req.Object = runtime.RawExtension{Raw: claimRaw}
+			response := handler.Handle(context.TODO(), req)
+			if tt.expectAllow {
+				t.Log(response.String())
+				g.Expect(response.Allowed).To(gomega.BeTrue())
+			} else {
+				g.Expect(response.Allowed).To(gomega.BeFalse())
+				g.Expect(response.Result).NotTo(gomega.BeNil())
+				g.Expect(response.Result.Message).To(gomega.ContainSubstring(tt.errorMessage))
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package sandboxclaim
+
+import (
+	"github.com/openkruise/agents/pkg/webhook/sandboxclaim/validating"
+	"github.com/openkruise/agents/pkg/webhook/types"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func GetHandlerGetters() []types.HandlerGetter {
+	return []types.HandlerGetter{
+		func(mgr manager.Manager) types.Handler {
+			return &validating.SandboxClaimValidatingHandler{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+			}
+		},
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"reflect"
 
 	"github.com/openkruise/agents/pkg/utils/defaults"
-	v1 "k8s.io/api/core/v1"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
@@ -48,10 +47,11 @@ func (h *SandboxSetDefaulter) Handle(_ context.Context, req admission.Request) a
 	}
 
 	clone := obj.DeepCopy()
-	setDefaultPodTemplate(obj.Spec.Template)
+	defaults.SetDefaultPodTemplate(obj.Spec.Template)
 
 	// Apply defaulting logic to volume claim templates
-	setDefaultVolumeClaimTemplates(obj.Spec.VolumeClaimTemplates)
+	defaults.SetDefaultVolumeClaimTemplates(obj.Spec.VolumeClaimTemplates)
+	setDefaultAutoScaling(obj.Spec.AutoScaling)
 
 	if !reflect.DeepEqual(obj, clone) {
 		marshal, err := json.Marshal(obj)
@@ -63,29 +63,15 @@ func (h *SandboxSetDefaulter) Handle(_ context.Context, req admission.Request) a
 	return admission.Allowed("")
 }
 
-func setDefaultPodTemplate(template *v1.PodTemplateSpec) {
-	if template == nil {
+// setDefaultAutoScaling applies the default water marks to the auto scaling
+func setDefaultAutoScaling(as *agentsv1alpha1.SandboxSetAutoScaling) {
+	if as == nil {
 		return
 	}
-	if ptr.Deref(template.Spec.AutomountServiceAccountToken, true) {
-		template.Spec.AutomountServiceAccountToken = ptr.To(false)
+	if as.HighWaterMark == nil {
+		as.HighWaterMark = ptr.To(agentsv1alpha1.DefaultSandboxSetHighWaterMark)
 	}
-	defaults.SetDefaultPodSpec(&template.Spec)
-}
-
-// setDefaultVolumeClaimTemplates applies default values to the volume claim templates
-func setDefaultVolumeClaimTemplates(templates []v1.PersistentVolumeClaim) {
-	for i := range templates {
-		vct := &templates[i]
-		// Set default access modes if not specified
-		if len(vct.Spec.AccessModes) == 0 {
-			vct.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
-		}
-
-		// Set default volume mode if not specified
-		if vct.Spec.VolumeMode == nil {
-			volumeMode := v1.PersistentVolumeFilesystem
-			vct.Spec.VolumeMode = &volumeMode
-		}
+	if as.LowWaterMark == nil {
+		as.LowWaterMark = ptr.To(agentsv1alpha1.DefaultSandboxSetLowWaterMark)
 	}
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/onsi/gomega"
 	"github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/utils/defaults"
 	"github.com/stretchr/testify/require"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -38,7 +39,7 @@ func TestSandboxSetDefaulter_Handle(t *testing.T) {
 				},
 				Spec: v1alpha1.SandboxSetSpec{
 					Replicas: 3,
-					SandboxTemplate: v1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -64,7 +65,7 @@ func TestSandboxSetDefaulter_Handle(t *testing.T) {
 				},
 				Spec: v1alpha1.SandboxSetSpec{
 					Replicas: 3,
-					SandboxTemplate: v1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								AutomountServiceAccountToken: ptr.To(true),
@@ -91,7 +92,7 @@ func TestSandboxSetDefaulter_Handle(t *testing.T) {
 				},
 				Spec: v1alpha1.SandboxSetSpec{
 					Replicas: 3,
-					SandboxTemplate: v1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{},
 						},
@@ -162,7 +163,7 @@ func TestSandboxSetDefaulter_HandleUpdate(t *testing.T) {
 				},
 				Spec: v1alpha1.SandboxSetSpec{
 					Replicas: 5, // Changed replicas
-					SandboxTemplate: v1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							Spec: corev1.PodSpec{
 								Containers: []corev1.Container{
@@ -321,7 +322,7 @@ func TestSetDefaultPodTemplate(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			original := deepCopyPodTemplateSpec(tt.template)
-			setDefaultPodTemplate(tt.template)
+			defaults.SetDefaultPodTemplate(tt.template)
 
 			// Check if automount service account token is properly defaulted
 			if tt.template != nil && tt.expected != nil {
@@ -506,7 +507,7 @@ func TestSetDefaultVolumeClaimTemplates(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			setDefaultVolumeClaimTemplates(tt.templates)
+			defaults.SetDefaultVolumeClaimTemplates(tt.templates)
 
 			// If expected is nil, actual should also be nil
 			if tt.expected == nil {
@@ -571,3 +572,17 @@ func deepCopyPodTemplateSpec(template *corev1.PodTemplateSpec) *corev1.PodTempla
 	result.Spec = *template.Spec.DeepCopy()
 	return result
 }
+
+func TestSetDefaultAutoScaling(t *testing.T) {
+	setDefaultAutoScaling(nil)
+
+	as := &v1alpha1.SandboxSetAutoScaling{MinReplicas: 1, MaxReplicas: 10}
+	setDefaultAutoScaling(as)
+	require.Equal(t, v1alpha1.DefaultSandboxSetHighWaterMark, *as.HighWaterMark)
+	require.Equal(t, v1alpha1.DefaultSandboxSetLowWaterMark, *as.LowWaterMark)
+
+	as = &v1alpha1.SandboxSetAutoScaling{HighWaterMark: ptr.To[int32](90), LowWaterMark: ptr.To[int32](10)}
+	setDefaultAutoScaling(as)
+	require.Equal(t, int32(90), *as.HighWaterMark)
+	require.Equal(t, int32(10), *as.LowWaterMark)
+}
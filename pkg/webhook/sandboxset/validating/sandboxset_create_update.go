@@ -2,22 +2,27 @@ package validating
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
 	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
 	webhookutils "github.com/openkruise/agents/pkg/webhook/utils"
 	"k8s.io/apimachinery/pkg/api/validation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation/field"
-	"k8s.io/kubernetes/pkg/apis/core"
-	corev1 "k8s.io/kubernetes/pkg/apis/core/v1"
-	corevalidation "k8s.io/kubernetes/pkg/apis/core/validation"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
+// minIdleTimeoutSeconds is the same minimum as the idle timeout of the SandboxTemplate
+const minIdleTimeoutSeconds = 60
+
 type SandboxSetValidatingHandler struct {
 	Client  client.Client
 	Decoder admission.Decoder
@@ -51,11 +56,62 @@ func (h *SandboxSetValidatingHandler) Handle(_ context.Context, req admission.Re
 func validateSandboxSetMetadata(metadata metav1.ObjectMeta, fldPath *field.Path) field.ErrorList {
 	var errList field.ErrorList
 	errList = append(errList, validation.ValidateObjectMeta(&metadata, true, validation.NameIsDNSSubdomain, fldPath)...)
-	errList = append(errList, validateLabelsAndAnnotations(metadata, fldPath)...)
+	errList = append(errList, validateLabelsAndAnnotations(metadata, fldPath, agentsv1alpha1.AnnotationExposedPorts,
+		agentsv1alpha1.AnnotationIdleTimeoutSeconds, agentsv1alpha1.AnnotationExtendTimeoutOnActivity)...)
+	annoFld := fldPath.Child("annotations")
+	if raw, ok := metadata.Annotations[agentsv1alpha1.AnnotationExposedPorts]; ok {
+		errList = append(errList, validateExposedPorts(raw, annoFld.Key(agentsv1alpha1.AnnotationExposedPorts))...)
+	}
+	if raw, ok := metadata.Annotations[agentsv1alpha1.AnnotationIdleTimeoutSeconds]; ok {
+		if seconds, err := strconv.Atoi(raw); err != nil || seconds < minIdleTimeoutSeconds {
+			errList = append(errList, field.Invalid(annoFld.Key(agentsv1alpha1.AnnotationIdleTimeoutSeconds), raw,
+				fmt.Sprintf("must be an integer no less than %d", minIdleTimeoutSeconds)))
+		}
+	}
+	if raw, ok := metadata.Annotations[agentsv1alpha1.AnnotationExtendTimeoutOnActivity]; ok {
+		if raw != agentsv1alpha1.True && raw != "false" {
+			errList = append(errList, field.Invalid(annoFld.Key(agentsv1alpha1.AnnotationExtendTimeoutOnActivity), raw,
+				"must be true or false"))
+		}
+	}
+	return errList
+}
+
+// validateExposedPorts validates the exposed ports declared by the annotation, which overrides the SandboxTemplate.
+func validateExposedPorts(raw string, fldPath *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	var ports []agentsv1alpha1.SandboxPort
+	if err := json.Unmarshal([]byte(raw), &ports); err != nil {
+		return append(errList, field.Invalid(fldPath, raw, err.Error()))
+	}
+	seen := make(map[int32]struct{}, len(ports))
+	for i, port := range ports {
+		idxPath := fldPath.Index(i)
+		if port.Port < 1 || port.Port > 65535 {
+			errList = append(errList, field.Invalid(idxPath.Child("port"), port.Port, "must be between 1 and 65535"))
+		}
+		if _, ok := seen[port.Port]; ok {
+			errList = append(errList, field.Duplicate(idxPath.Child("port"), port.Port))
+		}
+		seen[port.Port] = struct{}{}
+		switch port.Protocol {
+		case "", agentsv1alpha1.SandboxPortProtocolHTTP, agentsv1alpha1.SandboxPortProtocolWebSocket, agentsv1alpha1.SandboxPortProtocolGRPC:
+		default:
+			errList = append(errList, field.NotSupported(idxPath.Child("protocol"), port.Protocol, []agentsv1alpha1.SandboxPortProtocol{
+				agentsv1alpha1.SandboxPortProtocolHTTP, agentsv1alpha1.SandboxPortProtocolWebSocket, agentsv1alpha1.SandboxPortProtocolGRPC}))
+		}
+		switch port.Auth {
+		case "", agentsv1alpha1.SandboxPortAuthAccessToken, agentsv1alpha1.SandboxPortAuthNone:
+		default:
+			errList = append(errList, field.NotSupported(idxPath.Child("auth"), port.Auth, []agentsv1alpha1.SandboxPortAuth{
+				agentsv1alpha1.SandboxPortAuthAccessToken, agentsv1alpha1.SandboxPortAuthNone}))
+		}
+	}
 	return errList
 }
 
-func validateLabelsAndAnnotations(metadata metav1.ObjectMeta, fldPath *field.Path) field.ErrorList {
+// validateLabelsAndAnnotations forbids the internal E2B keys, except the allowed annotations.
+func validateLabelsAndAnnotations(metadata metav1.ObjectMeta, fldPath *field.Path, allowedAnnotations ...string) field.ErrorList {
 	var errList field.ErrorList
 	labelFld := fldPath.Child("labels")
 	for k := range metadata.Labels {
@@ -65,7 +121,7 @@ func validateLabelsAndAnnotations(metadata metav1.ObjectMeta, fldPath *field.Pat
 	}
 	annoFld := fldPath.Child("annotations")
 	for k := range metadata.Annotations {
-		if strings.HasPrefix(k, agentsv1alpha1.E2BPrefix) {
+		if strings.HasPrefix(k, agentsv1alpha1.E2BPrefix) && !slices.Contains(allowedAnnotations, k) {
 			errList = append(errList, field.Invalid(annoFld.Key(k), k, "annotation cannot start with "+agentsv1alpha1.E2BPrefix))
 		}
 	}
@@ -77,18 +133,54 @@ func validateSandboxSetSpec(spec agentsv1alpha1.SandboxSetSpec, fldPath *field.P
 	if spec.Replicas < 0 {
 		errList = append(errList, field.Invalid(fldPath.Child("replicas"), spec.Replicas, "replicas cannot be negative"))
 	}
-	errList = append(errList, validateLabelsAndAnnotations(spec.Template.ObjectMeta, fldPath.Child("template"))...)
-	errList = append(errList, validateSandboxSetPodTemplateSpec(spec, fldPath)...)
+	if spec.AutoScaling != nil {
+		errList = append(errList, validateAutoScaling(spec.AutoScaling, fldPath.Child("autoScaling"))...)
+	}
+	errList = append(errList, validateIntOrPercent(spec.UpdateStrategy.Partition, fldPath.Child("updateStrategy", "partition"))...)
+	errList = append(errList, validateIntOrPercent(spec.UpdateStrategy.MaxUnavailable, fldPath.Child("updateStrategy", "maxUnavailable"))...)
+	switch {
+	case spec.Template != nil && spec.TemplateRef != nil:
+		errList = append(errList, field.Forbidden(fldPath.Child("templateRef"), "template and templateRef are mutually exclusive"))
+	case spec.Template == nil && spec.TemplateRef == nil:
+		errList = append(errList, field.Required(fldPath.Child("template"), "either template or templateRef must be set"))
+	case spec.TemplateRef != nil:
+		errList = append(errList, webhookutils.ValidateTemplateRef(spec.TemplateRef, fldPath.Child("templateRef"))...)
+	default:
+		errList = append(errList, validateLabelsAndAnnotations(spec.Template.ObjectMeta, fldPath.Child("template"))...)
+		errList = append(errList, webhookutils.ValidatePodTemplateSpec(spec.Template, fldPath.Child("template"))...)
+	}
+	return errList
+}
+
+func validateAutoScaling(as *agentsv1alpha1.SandboxSetAutoScaling, fldPath *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	if as.MinReplicas < 0 {
+		errList = append(errList, field.Invalid(fldPath.Child("minReplicas"), as.MinReplicas, "minReplicas cannot be negative"))
+	}
+	if as.MaxReplicas < as.MinReplicas {
+		errList = append(errList, field.Invalid(fldPath.Child("maxReplicas"), as.MaxReplicas, "maxReplicas cannot be less than minReplicas"))
+	}
+	highWaterMark := ptr.Deref(as.HighWaterMark, agentsv1alpha1.DefaultSandboxSetHighWaterMark)
+	lowWaterMark := ptr.Deref(as.LowWaterMark, agentsv1alpha1.DefaultSandboxSetLowWaterMark)
+	if highWaterMark < 1 || highWaterMark > 100 {
+		errList = append(errList, field.Invalid(fldPath.Child("highWaterMark"), highWaterMark, "highWaterMark must be between 1 and 100"))
+	}
+	if lowWaterMark < 0 || lowWaterMark >= highWaterMark {
+		errList = append(errList, field.Invalid(fldPath.Child("lowWaterMark"), lowWaterMark, "lowWaterMark must be between 0 and highWaterMark"))
+	}
 	return errList
 }
 
-func validateSandboxSetPodTemplateSpec(spec agentsv1alpha1.SandboxSetSpec, fldPath *field.Path) field.ErrorList {
-	errList := field.ErrorList{}
-	coreTemplate := &core.PodTemplateSpec{}
-	if err := corev1.Convert_v1_PodTemplateSpec_To_core_PodTemplateSpec(spec.Template.DeepCopy(), coreTemplate, nil); err != nil {
-		errList = append(errList, field.Invalid(fldPath.Child("template"), spec.Template, fmt.Sprintf("Convert_v1_PodTemplateSpec_To_core_PodTemplateSpec failed: %v", err)))
-		return errList
-	}
-	errList = append(errList, corevalidation.ValidatePodTemplateSpec(coreTemplate, fldPath.Child("template"), webhookutils.DefaultPodValidationOptions)...)
+func validateIntOrPercent(v *intstr.IntOrString, fldPath *field.Path) field.ErrorList {
+	if v == nil {
+		return nil
+	}
+	var errList field.ErrorList
+	scaled, err := intstr.GetScaledValueFromIntOrPercent(v, 100, true)
+	if err != nil {
+		errList = append(errList, field.Invalid(fldPath, v.String(), err.Error()))
+	} else if scaled < 0 {
+		errList = append(errList, field.Invalid(fldPath, v.String(), "must not be negative"))
+	}
 	return errList
 }
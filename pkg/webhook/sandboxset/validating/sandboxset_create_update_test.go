@@ -12,7 +12,9 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -38,7 +40,7 @@ func TestSandboxSetValidatingHandler_Handle(t *testing.T) {
 				},
 				Spec: v1alpha1.SandboxSetSpec{
 					Replicas: 3,
-					SandboxTemplate: v1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							ObjectMeta: metav1.ObjectMeta{
 								Labels: map[string]string{
@@ -74,7 +76,7 @@ func TestSandboxSetValidatingHandler_Handle(t *testing.T) {
 				},
 				Spec: v1alpha1.SandboxSetSpec{
 					Replicas: 3,
-					SandboxTemplate: v1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							ObjectMeta: metav1.ObjectMeta{
 								Labels: map[string]string{
@@ -106,7 +108,7 @@ func TestSandboxSetValidatingHandler_Handle(t *testing.T) {
 				},
 				Spec: v1alpha1.SandboxSetSpec{
 					Replicas: -1, // Negative replicas are invalid
-					SandboxTemplate: v1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							ObjectMeta: metav1.ObjectMeta{
 								Labels: map[string]string{
@@ -133,7 +135,7 @@ func TestSandboxSetValidatingHandler_Handle(t *testing.T) {
 				},
 				Spec: v1alpha1.SandboxSetSpec{
 					Replicas: 3,
-					SandboxTemplate: v1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							ObjectMeta: metav1.ObjectMeta{
 								Labels: map[string]string{
@@ -160,7 +162,7 @@ func TestSandboxSetValidatingHandler_Handle(t *testing.T) {
 				},
 				Spec: v1alpha1.SandboxSetSpec{
 					Replicas: 3,
-					SandboxTemplate: v1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							ObjectMeta: metav1.ObjectMeta{
 								Labels: map[string]string{
@@ -184,7 +186,7 @@ func TestSandboxSetValidatingHandler_Handle(t *testing.T) {
 				},
 				Spec: v1alpha1.SandboxSetSpec{
 					Replicas: 3,
-					SandboxTemplate: v1alpha1.SandboxTemplate{
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
 						Template: &corev1.PodTemplateSpec{
 							ObjectMeta: metav1.ObjectMeta{
 								Labels: map[string]string{
@@ -199,6 +201,277 @@ func TestSandboxSetValidatingHandler_Handle(t *testing.T) {
 			expectError:  true,
 			errorMessage: "label cannot start with " + v1alpha1.E2BPrefix,
 		},
+		{
+			name: "Valid SandboxSet with templateRef",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template"},
+					},
+				},
+			},
+			expectAllow: true,
+		},
+		{
+			name: "Neither template nor templateRef",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+				},
+			},
+			expectAllow:  false,
+			expectError:  true,
+			errorMessage: "either template or templateRef must be set",
+		},
+		{
+			name: "Both template and templateRef",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template"},
+						Template: &corev1.PodTemplateSpec{
+							Spec: corev1.PodSpec{
+								Containers: []corev1.Container{{Name: "test", Image: "nginx"}},
+							},
+						},
+					},
+				},
+			},
+			expectAllow:  false,
+			expectError:  true,
+			errorMessage: "template and templateRef are mutually exclusive",
+		},
+		{
+			name: "Unsupported templateRef kind",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template", Kind: ptr.To("Deployment")},
+					},
+				},
+			},
+			expectAllow:  false,
+			expectError:  true,
+			errorMessage: "Unsupported value",
+		},
+		{
+			name: "Valid SandboxSet pinning a SandboxTemplate revision",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{
+							Name:       "shared-template",
+							Kind:       ptr.To("SandboxTemplate"),
+							APIVersion: ptr.To(v1alpha1.GroupVersion.String()),
+							Revision:   "shared-template-5d8f7c9b4",
+						},
+					},
+				},
+			},
+			expectAllow: true,
+			expectError: false,
+		},
+		{
+			name: "Revision of PodTemplate",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template", Revision: "shared-template-5d8f7c9b4"},
+					},
+				},
+			},
+			expectAllow:  false,
+			expectError:  true,
+			errorMessage: "revision is only supported for SandboxTemplate",
+		},
+		{
+			name: "Valid exposed ports",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+					Annotations: map[string]string{
+						v1alpha1.AnnotationExposedPorts: `[{"name":"envd","port":49983},{"port":9222,"protocol":"WebSocket","auth":"None"}]`,
+					},
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template"},
+					},
+				},
+			},
+			expectAllow: true,
+		},
+		{
+			name: "Invalid exposed ports",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+					Annotations: map[string]string{
+						v1alpha1.AnnotationExposedPorts: `[{"port":70000,"auth":"Unknown"}]`,
+					},
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template"},
+					},
+				},
+			},
+			expectAllow:  false,
+			expectError:  true,
+			errorMessage: "must be between 1 and 65535",
+		},
+		{
+			name: "Valid idle policy",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+					Annotations: map[string]string{
+						v1alpha1.AnnotationIdleTimeoutSeconds:      "300",
+						v1alpha1.AnnotationExtendTimeoutOnActivity: "true",
+					},
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template"},
+					},
+				},
+			},
+			expectAllow: true,
+		},
+		{
+			name: "Invalid idle policy",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+					Annotations: map[string]string{
+						v1alpha1.AnnotationIdleTimeoutSeconds:      "30",
+						v1alpha1.AnnotationExtendTimeoutOnActivity: "yes",
+					},
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template"},
+					},
+				},
+			},
+			expectAllow:  false,
+			expectError:  true,
+			errorMessage: "must be an integer no less than 60",
+		},
+		{
+			name: "Valid SandboxSet with autoScaling",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					AutoScaling: &v1alpha1.SandboxSetAutoScaling{MinReplicas: 1, MaxReplicas: 10},
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template"},
+					},
+				},
+			},
+			expectAllow: true,
+		},
+		{
+			name: "AutoScaling with maxReplicas less than minReplicas",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					AutoScaling: &v1alpha1.SandboxSetAutoScaling{MinReplicas: 5, MaxReplicas: 2},
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template"},
+					},
+				},
+			},
+			expectAllow:  false,
+			expectError:  true,
+			errorMessage: "maxReplicas cannot be less than minReplicas",
+		},
+		{
+			name: "AutoScaling with lowWaterMark above highWaterMark",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					AutoScaling: &v1alpha1.SandboxSetAutoScaling{
+						MaxReplicas:   10,
+						HighWaterMark: ptr.To[int32](50),
+						LowWaterMark:  ptr.To[int32](60),
+					},
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template"},
+					},
+				},
+			},
+			expectAllow:  false,
+			expectError:  true,
+			errorMessage: "lowWaterMark must be between 0 and highWaterMark",
+		},
+		{
+			name: "Invalid maxUnavailable of updateStrategy",
+			sandboxSet: &v1alpha1.SandboxSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sbs",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.SandboxSetSpec{
+					Replicas: 1,
+					UpdateStrategy: v1alpha1.SandboxSetUpdateStrategy{
+						MaxUnavailable: ptr.To(intstr.FromString("abc")),
+					},
+					EmbeddedSandboxTemplate: v1alpha1.EmbeddedSandboxTemplate{
+						TemplateRef: &v1alpha1.SandboxTemplateRef{Name: "shared-template"},
+					},
+				},
+			},
+			expectAllow:  false,
+			expectError:  true,
+			errorMessage: "spec.updateStrategy.maxUnavailable",
+		},
 	}
 
 	for _, tt := range tests {
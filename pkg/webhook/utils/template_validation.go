@@ -0,0 +1,57 @@
+/*
+Copyright 2025 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kubernetes/pkg/apis/core"
+	corev1 "k8s.io/kubernetes/pkg/apis/core/v1"
+	corevalidation "k8s.io/kubernetes/pkg/apis/core/validation"
+
+	agentsv1alpha1 "github.com/openkruise/agents/api/v1alpha1"
+	"github.com/openkruise/agents/pkg/utils/templateref"
+)
+
+// ValidateTemplateRef validates the templateRef of Sandbox and SandboxSet
+func ValidateTemplateRef(ref *agentsv1alpha1.SandboxTemplateRef, fldPath *field.Path) field.ErrorList {
+	var errList field.ErrorList
+	if ref.Name == "" {
+		errList = append(errList, field.Required(fldPath.Child("name"), "name of templateRef is required"))
+	}
+	if !templateref.IsSupported(ref) {
+		errList = append(errList, field.NotSupported(fldPath.Child("kind"), templateref.GroupVersionKind(ref).String(), templateref.SupportedKinds()))
+	}
+	if ref.Revision != "" && templateref.GroupVersionKind(ref) != agentsv1alpha1.SandboxTemplateControllerKind {
+		errList = append(errList, field.Forbidden(fldPath.Child("revision"), "revision is only supported for SandboxTemplate"))
+	}
+	return errList
+}
+
+// ValidatePodTemplateSpec validates the pod template of Sandbox and SandboxSet like the api-server does for pods
+func ValidatePodTemplateSpec(template *v1.PodTemplateSpec, fldPath *field.Path) field.ErrorList {
+	errList := field.ErrorList{}
+	coreTemplate := &core.PodTemplateSpec{}
+	if err := corev1.Convert_v1_PodTemplateSpec_To_core_PodTemplateSpec(template.DeepCopy(), coreTemplate, nil); err != nil {
+		errList = append(errList, field.Invalid(fldPath, template, fmt.Sprintf("Convert_v1_PodTemplateSpec_To_core_PodTemplateSpec failed: %v", err)))
+		return errList
+	}
+	errList = append(errList, corevalidation.ValidatePodTemplateSpec(coreTemplate, fldPath, DefaultPodValidationOptions)...)
+	return errList
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2025 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"flag"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func init() {
+	flag.StringVar(&trustedServiceAccounts, "sandbox-trusted-service-accounts", trustedServiceAccounts,
+		"Comma separated <namespace>:<name> of the service accounts allowed to modify the internal labels and annotations of sandboxes, "+
+			"and to claim sandboxes on behalf of the other users.")
+}
+
+// trustedServiceAccounts are the service accounts of the sandbox-controller and the sandbox-manager by default
+var trustedServiceAccounts = "sandbox-system:sandbox-controller-manager,sandbox-system:sandbox-manager"
+
+// IsTrusted returns whether the request is sent by one of the trusted service accounts.
+func IsTrusted(userInfo authenticationv1.UserInfo) bool {
+	for _, sa := range strings.Split(trustedServiceAccounts, ",") {
+		if sa = strings.TrimSpace(sa); sa != "" && userInfo.Username == "system:serviceaccount:"+sa {
+			return true
+		}
+	}
+	return false
+}